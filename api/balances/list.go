@@ -0,0 +1,36 @@
+package balances
+
+import (
+	"net/http"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+type Balance struct {
+	Account  string  `json:"account"`
+	Currency string  `json:"currency"`
+	Amount   float64 `json:"amount"`
+}
+
+func (r *Router) List(c *gin.Context) {
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	balances, err := r.bc.Balances(m, c.Query("prefix"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	res := []Balance{}
+	for _, balance := range balances {
+		res = append(res, Balance{
+			Account:  balance.Account,
+			Currency: balance.Currency,
+			Amount:   balance.Amount,
+		})
+	}
+	c.JSON(http.StatusOK, res)
+}
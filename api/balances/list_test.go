@@ -0,0 +1,49 @@
+package balances_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/balances"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5535)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	botTest.HandleErr(t, err)
+	botTest.HandleErr(t, mockBc.Repo.DeleteOpeningBalances(msg))
+	r := gin.Default()
+	balances.NewRouter(mockBc).Hook(r.Group(""))
+
+	botTest.HandleErr(t, mockBc.Repo.SetOpeningBalance(msg, &crud.OpeningBalance{Account: "Assets:Cash", Amount: 120, Currency: "EUR"}))
+	botTest.HandleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-03-01 * \"Shop\" \"Groceries\"\n  Assets:Cash -12.50 EUR\n  Expenses:Food\n"))
+	botTest.HandleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-03-02 * \"\" \"Withdrawal\"\n  Assets:Bank -50 EUR\n  Assets:Cash 50 EUR\n"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?prefix=Assets:Cash", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var res []balances.Balance
+	botTest.HandleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, []balances.Balance{{Account: "Assets:Cash", Currency: "EUR", Amount: 157.5}}, res)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	botTest.HandleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, 3, len(res))
+	if len(res) == 3 {
+		assert.Equal(t, balances.Balance{Account: "Assets:Bank", Currency: "EUR", Amount: -50}, res[0])
+		assert.Equal(t, balances.Balance{Account: "Expenses:Food", Currency: "EUR", Amount: 12.5}, res[2])
+	}
+}
@@ -15,7 +15,7 @@ func (r *Router) ReadConfig(c *gin.Context) {
 	tgChatId := c.GetInt64("tgChatId")
 	settings := map[string]interface{}{}
 	// String settings
-	for _, setting := range []string{helpers.USERSET_CUR, helpers.USERSET_TAG} {
+	for _, setting := range []string{helpers.USERSET_CUR, helpers.USERSET_TAG, helpers.USERSET_PENDINGDAYS} {
 		exists, val, err := r.bc.Repo.GetUserSetting(setting, tgChatId)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -31,7 +31,7 @@ func (r *Router) ReadConfig(c *gin.Context) {
 		}
 	}
 	// Boolean settings
-	for _, setting := range []string{helpers.USERSET_ENABLEAPI, helpers.USERSET_OMITCMDSLASH, helpers.USERSET_ADM} {
+	for _, setting := range []string{helpers.USERSET_ENABLEAPI, helpers.USERSET_OMITCMDSLASH, helpers.USERSET_AUTOPRICE, helpers.USERSET_ADM} {
 		exists, val, err := r.bc.Repo.GetUserSetting(setting, tgChatId)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
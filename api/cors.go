@@ -17,6 +17,7 @@ func configureCors(router *gin.Engine) {
 			return strings.HasPrefix(origin, "http://localhost")
 		}
 		corsConfig.AllowHeaders = []string{"authorization", "content-type"}
+		corsConfig.ExposeHeaders = []string{"X-Total-Count"}
 		corsConfig.AllowCredentials = true
 		router.Use(cors.New(corsConfig))
 	}
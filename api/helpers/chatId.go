@@ -6,12 +6,16 @@ import (
 	"strings"
 
 	"github.com/LucaBernstein/beancount-bot-tg/v2/bot"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
 	"github.com/gin-gonic/gin"
 	"gopkg.in/telebot.v3"
 )
 
 const K_CHAT_ID = "tgChatId"
 
+// K_TOKEN_ID identifies the token used for a request, see crud.ApiTokenId
+const K_TOKEN_ID = "tokenId"
+
 func AttachChatId(bc *bot.BotController) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := strings.TrimSpace(strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", ""))
@@ -38,6 +42,7 @@ func AttachChatId(bc *bot.BotController) gin.HandlerFunc {
 			return
 		}
 		c.Set(K_CHAT_ID, chatId)
+		c.Set(K_TOKEN_ID, crud.ApiTokenId(authHeader))
 		c.Next()
 	}
 }
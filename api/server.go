@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/admin"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/balances"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/config"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/health"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/suggestions"
@@ -32,6 +33,9 @@ func StartWebServer(bc *bot.BotController) {
 	transactionGroup := apiGroup.Group("/transactions")
 	transactions.NewRouter(bc).Hook(transactionGroup)
 
+	balancesGroup := apiGroup.Group("/balances")
+	balances.NewRouter(bc).Hook(balancesGroup)
+
 	suggestionsGroup := apiGroup.Group("/suggestions")
 	suggestions.NewRouter(bc).Hook(suggestionsGroup)
 
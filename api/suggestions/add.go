@@ -0,0 +1,87 @@
+package suggestions
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+type SuggestionPost struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	ToTop bool   `json:"toTop"`
+}
+
+type SuggestionsBulkPost struct {
+	Suggestions map[string][]string `json:"suggestions"`
+	ToTop       bool                `json:"toTop"`
+}
+
+func validateSuggestionType(suggestionType string) error {
+	if !h.ArrayContains(h.AllowedSuggestionTypes(), h.TypeCacheKey(suggestionType)) {
+		return fmt.Errorf("suggestion type '%s' is not supported. Allowed types: %v", suggestionType, h.AllowedSuggestionTypes())
+	}
+	return nil
+}
+
+func (r *Router) ListAdd(c *gin.Context) {
+	var suggestion SuggestionPost
+	err := c.ShouldBindJSON(&suggestion)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	r.importSuggestions(c, map[string][]string{suggestion.Type: {suggestion.Value}}, suggestion.ToTop)
+}
+
+func (r *Router) ListAddBulk(c *gin.Context) {
+	var suggestions SuggestionsBulkPost
+	err := c.ShouldBindJSON(&suggestions)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	r.importSuggestions(c, suggestions.Suggestions, suggestions.ToTop)
+}
+
+func (r *Router) importSuggestions(c *gin.Context, suggestions map[string][]string, toTop bool) {
+	if len(suggestions) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "no suggestions provided",
+		})
+		return
+	}
+	for suggestionType := range suggestions {
+		if err := validateSuggestionType(suggestionType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	added := 0
+	for suggestionType, values := range suggestions {
+		count, err := r.bc.Repo.ImportCacheHints(m, suggestionType, values, toTop)
+		added += count
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+				"added": added,
+			})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"added": added,
+	})
+}
@@ -0,0 +1,47 @@
+package suggestions_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/suggestions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAddBulk(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 8226)
+	_, err := mockBc.Repo.DeleteCacheEntries(msg, "description:", "")
+	assert.Nil(t, err)
+
+	r := gin.Default()
+	suggestions.NewRouter(mockBc).Hook(r.Group(""))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/list", strings.NewReader(`{"type": "description", "value": "Groceries"}`))
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"added":1}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/list/bulk", strings.NewReader(`{"suggestions": {"description": ["Groceries", "Rent", "Rent", " "]}}`))
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"added":1}`, w.Body.String())
+
+	values, err := mockBc.Repo.GetCacheHints(msg, "description:")
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []string{"Groceries", "Rent"}, values)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/list/bulk", strings.NewReader(`{"suggestions": {"unknown": ["value"]}}`))
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "not supported")
+}
@@ -20,5 +20,7 @@ func (r *Router) Hook(g *gin.RouterGroup) {
 	g.Use(helpers.AttachChatId(r.bc))
 
 	g.GET("/list", r.List)
+	g.POST("/list", r.ListAdd)
+	g.POST("/list/bulk", r.ListAddBulk)
 	g.DELETE("/list/:type/*name", r.ListDelete)
 }
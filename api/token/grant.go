@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	"github.com/gin-gonic/gin"
 )
@@ -36,5 +37,7 @@ func (r *Router) Grant(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"token": token,
+		// tokenId identifies the changes made with the token in the transaction history
+		"tokenId": crud.ApiTokenId(token),
 	})
 }
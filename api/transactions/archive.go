@@ -0,0 +1,99 @@
+package transactions
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+type ArchivePost struct {
+	Ids   []int  `json:"ids"`
+	Label string `json:"label"`
+}
+
+type ArchiveBatch struct {
+	Id        int    `json:"id"`
+	Label     string `json:"label"`
+	CreatedAt string `json:"createdAt"`
+	Count     int    `json:"count"`
+}
+
+func (r *Router) Archive(c *gin.Context) {
+	var archive ArchivePost
+	err := c.ShouldBindJSON(&archive)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if len(archive.Ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "no transactions to archive provided",
+		})
+		return
+	}
+	if archive.Label == "" {
+		archive.Label = "api"
+	}
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	batchId, count, err := r.repo(c).ArchiveTransactionBatch(m, archive.Label, archive.Ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"batchId":  batchId,
+		"affected": count,
+	})
+}
+
+func (r *Router) ArchiveBatches(c *gin.Context) {
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	batches, err := r.bc.Repo.GetArchiveBatches(m)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	res := []ArchiveBatch{}
+	for _, batch := range batches {
+		res = append(res, ArchiveBatch{
+			Id:        batch.Id,
+			Label:     batch.Label,
+			CreatedAt: batch.Created,
+			Count:     batch.Count,
+		})
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (r *Router) Unarchive(c *gin.Context) {
+	batchId, err := strconv.Atoi(c.Param("batchId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No batch to unarchive provided",
+		})
+		return
+	}
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	count, err := r.repo(c).UnarchiveBatch(m, batchId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"affected": count,
+	})
+}
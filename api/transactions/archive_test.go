@@ -0,0 +1,81 @@
+package transactions_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/transactions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveBatch(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5531)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	handleErr(t, mockBc.Repo.DeleteArchiveBatches(msg))
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	for i := 1; i <= 3; i++ {
+		handleErr(t, mockBc.Repo.RecordTransaction(msg, fmt.Sprintf("tx %d", i)))
+	}
+	tx, err := mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+
+	w := httptest.NewRecorder()
+	body, _ := json.Marshal(transactions.ArchivePost{Ids: []int{tx[0].Id, tx[2].Id}, Label: "first and last"})
+	req, _ := http.NewRequest("POST", "/archive", bytes.NewBuffer(body))
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var archived struct {
+		BatchId  int `json:"batchId"`
+		Affected int `json:"affected"`
+	}
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &archived))
+	assert.Equal(t, 2, archived.Affected)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/archive", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var batches []transactions.ArchiveBatch
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &batches))
+	assert.Equal(t, 1, len(batches))
+	if len(batches) == 1 {
+		assert.Equal(t, archived.BatchId, batches[0].Id)
+		assert.Equal(t, "first and last", batches[0].Label)
+		assert.Equal(t, 2, batches[0].Count)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/list?batch=%d&format=text", archived.BatchId), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "tx 1\ntx 3\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/archive/%d", archived.BatchId), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"affected":2}`, w.Body.String())
+
+	open, err := mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+	assert.Equal(t, 3, len(open))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/archive/%d", archived.BatchId), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Code)
+}
@@ -0,0 +1,63 @@
+package transactions
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+type TransactionEvent struct {
+	Id            int    `json:"id"`
+	TransactionId int    `json:"transactionId"`
+	Event         string `json:"event"`
+	Before        string `json:"before"`
+	After         string `json:"after"`
+	Source        string `json:"source"`
+	UserId        int64  `json:"userId"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+// History returns the changes to all transactions or the one given by the query parameter id, the most recent first.
+// limit restricts the number of events returned.
+func (r *Router) History(c *gin.Context) {
+	params := map[string]int{"id": 0, "limit": 0}
+	for key := range params {
+		if c.Query(key) == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(c.Query(key))
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "query parameter '" + key + "' must be a non-negative number",
+			})
+			return
+		}
+		params[key] = parsed
+	}
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	events, err := r.bc.Repo.GetTransactionEvents(m, params["id"], params["limit"])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	res := []TransactionEvent{}
+	for _, e := range events {
+		res = append(res, TransactionEvent{
+			Id:            e.Id,
+			TransactionId: e.TransactionId,
+			Event:         e.Event,
+			Before:        e.Before,
+			After:         e.After,
+			Source:        e.Source,
+			UserId:        e.UserId,
+			CreatedAt:     e.Created,
+		})
+	}
+	c.JSON(http.StatusOK, res)
+}
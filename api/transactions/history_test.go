@@ -0,0 +1,88 @@
+package transactions_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/transactions"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5533)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	handleErr(t, mockBc.Repo.DeleteTransactionEvents(msg))
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "tx 1"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "tx 2"))
+	tx, err := mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/list/%d", tx[0].Id), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/history?id=%d", tx[0].Id), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var events []transactions.TransactionEvent
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &events))
+	assert.Equal(t, 2, len(events))
+	if len(events) == 2 {
+		assert.Equal(t, crud.EVENT_DELETE, events[0].Event)
+		assert.Equal(t, crud.ApiEventSource(crud.ApiTokenId(token)), events[0].Source)
+		assert.Equal(t, int64(0), events[0].UserId)
+		assert.Equal(t, "tx 1", events[0].Before)
+		assert.Equal(t, "", events[0].After)
+		assert.Equal(t, crud.EVENT_CREATE, events[1].Event)
+		assert.Equal(t, crud.EVENT_SOURCE_BOT, events[1].Source)
+		assert.Equal(t, msg.Sender.ID, events[1].UserId)
+		assert.Equal(t, "tx 1", events[1].After)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/history?limit=2", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &events))
+	assert.Equal(t, 2, len(events))
+
+	// Archiving records the unchanged value
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/archive", strings.NewReader(fmt.Sprintf(`{"ids": [%d]}`, tx[1].Id)))
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/history?id=%d", tx[1].Id), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &events))
+	if assert.Equal(t, 2, len(events)) {
+		assert.Equal(t, crud.EVENT_ARCHIVE, events[0].Event)
+		assert.Equal(t, "tx 2", events[0].Before)
+		assert.Equal(t, "tx 2", events[0].After)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/history?id=abc", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
@@ -0,0 +1,159 @@
+package transactions
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+type ImportedRow struct {
+	Line     int     `json:"line"`
+	Date     string  `json:"date"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Payee    string  `json:"payee"`
+	Memo     string  `json:"memo"`
+	Id       string  `json:"id"`
+	// Account and DuplicateOf are only set for possible duplicates
+	Account     string `json:"account,omitempty"`
+	DuplicateOf []int  `json:"duplicateOf,omitempty"`
+}
+
+func importedRow(row *bot.ImportRow) *ImportedRow {
+	return &ImportedRow{
+		Line:     row.Line,
+		Date:     row.Date,
+		Amount:   row.Amount,
+		Currency: row.Currency,
+		Payee:    row.Payee,
+		Memo:     row.Memo,
+		Id:       row.Id,
+	}
+}
+
+// statementContent reads the statement uploaded as multipart form field 'file' or else the raw request body
+func statementContent(c *gin.Context) (string, error) {
+	var reader io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		reader = f
+	}
+	content, err := io.ReadAll(io.LimitReader(reader, h.MAX_DOCUMENT_SIZE+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > h.MAX_DOCUMENT_SIZE {
+		return "", fmt.Errorf("the file is too large (at most %d bytes are supported)", h.MAX_DOCUMENT_SIZE)
+	}
+	if len(content) == 0 {
+		return "", fmt.Errorf("no statement file provided")
+	}
+	return string(content), nil
+}
+
+// Import records the statement using the import profile given by the query parameter profile, which can be omitted
+// if there is only one. Rows not matching any import rule are booked on the account given by the query parameter
+// fallback. Without it, they are returned as uncategorised and not recorded. If rows are similar to recorded transactions,
+// nothing is recorded and they are returned as possible duplicates. Setting the query parameter force records them anyway.
+func (r *Router) Import(c *gin.Context) {
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	names := []string{}
+	if c.Query("profile") != "" {
+		names = append(names, c.Query("profile"))
+	}
+	force := false
+	if c.Query("force") != "" {
+		var err error
+		force, err = strconv.ParseBool(c.Query("force"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "query parameter 'force' must be 'true' or 'false'",
+			})
+			return
+		}
+	}
+	fallback := c.Query("fallback")
+	if fallback != "" && !bot.BeancountAccountLike(fallback) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("'%s' is no valid account", fallback),
+		})
+		return
+	}
+	profile, err := r.bc.FindImportProfile(m, names...)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	statementProfile, err := bot.NewStatementProfile(profile.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("the import profile '%s' is invalid: %s", profile.Name, err.Error()),
+		})
+		return
+	}
+	content, err := statementContent(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	statement, err := statementProfile.Parse(content, r.bc.Repo.UserGetCurrency(m))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	duplicates := bot.IMPORT_DUPLICATES_REJECT
+	if force {
+		duplicates = bot.IMPORT_DUPLICATES_RECORD
+	}
+	result, err := bot.RecordStatement(r.repo(c), m, statement, fallback, duplicates)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	uncategorised := []*ImportedRow{}
+	for _, row := range result.Uncategorised {
+		uncategorised = append(uncategorised, importedRow(row))
+	}
+	possibleDuplicates := []*ImportedRow{}
+	for _, duplicate := range result.PossibleDuplicates {
+		row := importedRow(duplicate.Row)
+		row.Account = duplicate.Account
+		for _, tx := range duplicate.Existing {
+			row.DuplicateOf = append(row.DuplicateOf, tx.Id)
+		}
+		possibleDuplicates = append(possibleDuplicates, row)
+	}
+	warnings := statement.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"rows":               result.Rows,
+		"recorded":           result.Recorded,
+		"duplicates":         result.Duplicates,
+		"balance":            result.Balance,
+		"uncategorised":      uncategorised,
+		"possibleDuplicates": possibleDuplicates,
+		"warnings":           warnings,
+	})
+}
@@ -0,0 +1,145 @@
+package transactions_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/transactions"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const importOfx = `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>EUR
+<BANKACCTFROM><ACCTID>123456</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240102<TRNAMT>-12.50<FITID>T1<NAME>REWE Markt</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240104<TRNAMT>-9.00<FITID>T2<NAME>Cinema</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>100.00<DTASOF>20240131</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>
+`
+
+func TestImport(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5537)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	handleErr(t, mockBc.Repo.DeleteImportSettings(msg))
+	defer mockBc.Repo.DeleteImportSettings(msg)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	importRequest := func(query string, body *bytes.Buffer, contentType string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/import"+query, body)
+		req.Header.Add("Authorization", "Bearer "+token)
+		if contentType != "" {
+			req.Header.Add("Content-Type", contentType)
+		}
+		r.ServeHTTP(w, req)
+		var res map[string]interface{}
+		handleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return w.Code, res
+	}
+
+	code, res := importRequest("", bytes.NewBufferString(importOfx), "")
+	assert.Equal(t, 400, code)
+	assert.Contains(t, res["error"], "you have no import profile yet")
+
+	handleErr(t, mockBc.Repo.SetImportProfile(msg, &crud.ImportProfile{Name: "giro", Config: "format=ofx account=Assets:Bank"}))
+	handleErr(t, mockBc.Repo.AddImportRule(msg, &crud.ImportRule{Pattern: "REWE", Account: "Expenses:Groceries"}))
+
+	code, res = importRequest("?fallback=unknown", bytes.NewBufferString(importOfx), "")
+	assert.Equal(t, 400, code)
+	assert.Contains(t, res["error"], "'unknown' is no valid account")
+
+	code, res = importRequest("?profile=giro", bytes.NewBufferString("Date;Amount\n"), "")
+	assert.Equal(t, 400, code)
+	assert.Contains(t, res["error"], "the file is no OFX statement")
+
+	code, res = importRequest("?profile=giro", bytes.NewBufferString(importOfx), "")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, float64(2), res["rows"])
+	assert.Equal(t, float64(1), res["recorded"])
+	assert.Equal(t, "2024-02-01 balance Assets:Bank 100.00 EUR\n", res["balance"])
+	uncategorised := res["uncategorised"].([]interface{})
+	assert.Len(t, uncategorised, 1)
+	assert.Equal(t, "T2", uncategorised[0].(map[string]interface{})["id"])
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", "statement.qfx")
+	handleErr(t, err)
+	_, err = part.Write([]byte(importOfx))
+	handleErr(t, err)
+	handleErr(t, writer.Close())
+	code, res = importRequest("?fallback=Expenses:Unknown", body, writer.FormDataContentType())
+	assert.Equal(t, 200, code)
+	assert.Equal(t, float64(1), res["duplicates"])
+	assert.Equal(t, float64(1), res["recorded"])
+	assert.Equal(t, "", res["balance"])
+	assert.Len(t, res["uncategorised"], 0)
+
+	tx, err := mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+	assert.Len(t, tx, 3)
+	assert.True(t, strings.Contains(tx[2].Tx, "Expenses:Unknown"))
+}
+
+func TestImportPossibleDuplicates(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5538)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	handleErr(t, mockBc.Repo.DeleteImportSettings(msg))
+	defer mockBc.Repo.DeleteImportSettings(msg)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	handleErr(t, mockBc.Repo.SetImportProfile(msg, &crud.ImportProfile{Name: "giro", Config: "format=ofx account=Assets:Bank balance=false"}))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2024-01-03 * \"Rewe\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Groceries\n"))
+	importRequest := func(query string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/import"+query, bytes.NewBufferString(importOfx))
+		req.Header.Add("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		var res map[string]interface{}
+		handleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return w.Code, res
+	}
+
+	code, res := importRequest("?fallback=Expenses:Misc")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, float64(0), res["recorded"], "nothing should be recorded while there are possible duplicates")
+	duplicates := res["possibleDuplicates"].([]interface{})
+	assert.Len(t, duplicates, 1)
+	duplicate := duplicates[0].(map[string]interface{})
+	assert.Equal(t, "T1", duplicate["id"])
+	assert.Equal(t, "Expenses:Misc", duplicate["account"])
+	assert.Len(t, duplicate["duplicateOf"], 1)
+
+	tx, err := mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+	assert.Len(t, tx, 1)
+
+	code, res = importRequest("?fallback=Expenses:Misc&force=true")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, float64(2), res["recorded"], "the possible duplicate should be recorded when forced")
+	assert.Equal(t, float64(0), res["duplicates"])
+	assert.Len(t, res["possibleDuplicates"], 0)
+	tx, err = mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+	assert.Len(t, tx, 3, "each row should be recorded once")
+
+	code, _ = importRequest("?force=maybe")
+	assert.Equal(t, 400, code)
+}
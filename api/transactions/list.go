@@ -1,10 +1,13 @@
 package transactions
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
 	"github.com/gin-gonic/gin"
 	"gopkg.in/telebot.v3"
 )
@@ -17,11 +20,7 @@ type Transaction struct {
 }
 
 func (r *Router) List(c *gin.Context) {
-	queryArchived := c.Query("archived")
-	if queryArchived == "" {
-		queryArchived = "false"
-	}
-	isArchived, err := strconv.ParseBool(queryArchived)
+	filter, err := listFilter(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
@@ -30,13 +29,23 @@ func (r *Router) List(c *gin.Context) {
 	}
 	chatId := c.GetInt64(helpers.K_CHAT_ID)
 	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
-	tx, err := r.bc.Repo.GetTransactions(m, isArchived)
+	tx, err := r.bc.Repo.FindTransactions(m, filter)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
+	if filter.Limit > 0 {
+		count, err := r.bc.Repo.CountTransactions(m, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.Header("X-Total-Count", strconv.Itoa(count))
+	}
 	format := c.Query("format")
 
 	transactions := []Transaction{}
@@ -45,14 +54,18 @@ func (r *Router) List(c *gin.Context) {
 			Id:         t.Id,
 			CreatedAt:  t.Date,
 			Booking:    t.Tx,
-			IsArchived: isArchived,
+			IsArchived: t.Archived,
 		})
 	}
 
 	if format == "text" {
 		var textResponse string
-		for _, t := range transactions {
-			textResponse += t.Booking + "\n"
+		entries := []string{}
+		for _, t := range tx {
+			entries = append(entries, t.Tx)
+		}
+		for _, entry := range bot.GroupListEntries(tx, entries, c.Query("group")) {
+			textResponse += entry + "\n"
 		}
 		c.String(http.StatusOK, textResponse)
 		return
@@ -64,7 +77,7 @@ func (r *Router) List(c *gin.Context) {
 func (r *Router) ListDeleteAll(c *gin.Context) {
 	chatId := c.GetInt64(helpers.K_CHAT_ID)
 	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
-	count, err := r.bc.Repo.DeleteTransactions(m)
+	count, err := r.repo(c).DeleteTransactions(m)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -104,7 +117,7 @@ func (r *Router) ListDeleteSingle(c *gin.Context) {
 	}
 	chatId := c.GetInt64(helpers.K_CHAT_ID)
 	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
-	count, err := r.bc.Repo.DeleteTransaction(m, isArchived, elId)
+	count, err := r.repo(c).DeleteTransaction(m, isArchived, elId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -115,3 +128,82 @@ func (r *Router) ListDeleteSingle(c *gin.Context) {
 		"affected": count,
 	})
 }
+
+// listFilter reads the filters from query parameters: archived (true, false or all; defaults to false),
+// q, account and tag (all repeatable), from and to (dates) and amount (repeatable, e.g. '>50', compared with the largest
+// absolute posting amount).
+// batch restricts the results to an archive batch. sort=booking orders by booking date instead of the time
+// of recording. group (day or month) separates the entries of text responses by booking date and implies sort=booking.
+// limit and offset paginate the results. The total count is returned in the X-Total-Count header then.
+func listFilter(c *gin.Context) (crud.TransactionFilter, error) {
+	limit, offset := 0, 0
+	for key, value := range map[string]*int{"limit": &limit, "offset": &offset} {
+		if c.Query(key) == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(c.Query(key))
+		if err != nil || parsed < 0 {
+			return crud.TransactionFilter{}, fmt.Errorf("query parameter '%s' must be a non-negative number", key)
+		}
+		*value = parsed
+	}
+	if offset > 0 && limit == 0 {
+		return crud.TransactionFilter{}, fmt.Errorf("query parameter 'offset' requires 'limit'")
+	}
+	queryArchived := c.Query("archived")
+	if queryArchived == "" {
+		queryArchived = "false"
+	}
+	var isArchived *bool
+	if queryArchived != "all" {
+		archived, err := strconv.ParseBool(queryArchived)
+		if err != nil {
+			return crud.TransactionFilter{}, err
+		}
+		isArchived = &archived
+	}
+	params := c.QueryArray("q")
+	for _, account := range c.QueryArray("account") {
+		params = append(params, "account:"+account)
+	}
+	for _, tag := range c.QueryArray("tag") {
+		params = append(params, "tag:"+tag)
+	}
+	for _, key := range []string{"from", "to"} {
+		if value := c.Query(key); value != "" {
+			params = append(params, key+":"+value)
+		}
+	}
+	for _, amount := range c.QueryArray("amount") {
+		params = append(params, "amount"+amount)
+	}
+	batchId := 0
+	if c.Query("batch") != "" {
+		parsed, err := strconv.Atoi(c.Query("batch"))
+		if err != nil || parsed < 1 {
+			return crud.TransactionFilter{}, fmt.Errorf("query parameter 'batch' must be a batch number")
+		}
+		batchId = parsed
+		if queryArchived == "false" && c.Query("archived") == "" {
+			isArchived = nil
+		}
+	}
+	sort := c.Query("sort")
+	if sort != "" && sort != "booking" && sort != "created" {
+		return crud.TransactionFilter{}, fmt.Errorf("query parameter 'sort' must be one of 'booking' or 'created'")
+	}
+	group := c.Query("group")
+	if group != "" && group != bot.LIST_GROUP_DAY && group != bot.LIST_GROUP_MONTH {
+		return crud.TransactionFilter{}, fmt.Errorf("query parameter 'group' must be one of '%s' or '%s'", bot.LIST_GROUP_DAY, bot.LIST_GROUP_MONTH)
+	}
+	if group != "" && sort == "created" {
+		return crud.TransactionFilter{}, fmt.Errorf("query parameter 'group' requires sorting by booking date")
+	}
+	filter, err := bot.ParseTransactionFilter(params)
+	filter.SortByBookingDate = sort == "booking" || group != ""
+	filter.Archived = isArchived
+	filter.BatchId = batchId
+	filter.Limit = limit
+	filter.Offset = offset
+	return filter, err
+}
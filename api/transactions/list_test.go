@@ -1,11 +1,13 @@
 package transactions_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/transactions"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/bot"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
@@ -48,7 +50,7 @@ func mockBcApiUser(t *testing.T) (r *gin.Engine, w *httptest.ResponseRecorder, t
 func TestList(t *testing.T) {
 	r, w, token, repo, msg := mockBcApiUser(t)
 
-	handleErr(t, repo.RecordTransaction(msg.Chat.ID, "my tx"))
+	handleErr(t, repo.RecordTransaction(msg, "my tx"))
 
 	req, _ := http.NewRequest("GET", "/list", nil)
 	req.Header.Add("Authorization", "Bearer "+token)
@@ -73,7 +75,7 @@ func TestListTextFormat(t *testing.T) {
 func TestListDeleteSingle(t *testing.T) {
 	r, w, token, repo, msg := mockBcApiUser(t)
 
-	handleErr(t, repo.RecordTransaction(msg.Chat.ID, "my tx"))
+	handleErr(t, repo.RecordTransaction(msg, "my tx"))
 	tx, err := repo.GetTransactions(msg, false)
 	handleErr(t, err)
 	id := tx[0].Id
@@ -89,7 +91,7 @@ func TestListDeleteSingle(t *testing.T) {
 func TestListDeleteAll(t *testing.T) {
 	r, w, token, repo, msg := mockBcApiUser(t)
 
-	handleErr(t, repo.RecordTransaction(msg.Chat.ID, "my tx"))
+	handleErr(t, repo.RecordTransaction(msg, "my tx"))
 
 	req, _ := http.NewRequest("DELETE", "/list", nil)
 	req.Header.Add("Authorization", "Bearer "+token)
@@ -104,3 +106,129 @@ func TestListDeleteAll(t *testing.T) {
 
 	assert.Equal(t, 0, len(tx))
 }
+
+func TestListFiltered(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5528)
+	_, err := mockBc.Repo.DeleteTransactions(msg)
+	handleErr(t, err)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-01-10 * \"Groceries\" #trip\n  Assets:Wallet  -60.00 EUR\n  Expenses:Food\n"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-02-10 * \"Rent 100%\"\n  Assets:Bank  -500.00 EUR\n  Expenses:Rent\n"))
+	handleErr(t, mockBc.Repo.ArchiveTransactions(msg))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-03-10 * \"Snacks\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food\n"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "; just a comment\n"))
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"Snacks", "just a comment"}},
+		{"?archived=all&account=Expenses:Food", []string{"Groceries", "Snacks"}},
+		{"?archived=all&q=groceries", []string{"Groceries"}},
+		{"?archived=all&q=100%25", []string{"Rent"}},
+		{"?archived=all&q=10%25", []string{}},
+		{"?archived=true&tag=trip", []string{"Groceries"}},
+		{"?archived=all&from=2022-02-01&to=2022-03-10", []string{"Rent", "Snacks"}},
+		{"?archived=all&amount=>50&amount=<=500", []string{"Groceries", "Rent"}},
+		{"?archived=all&amount=>100&account=Expenses:Food", []string{}},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/list"+tc.query, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 200, w.Code, tc.query)
+
+		var res []transactions.Transaction
+		handleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+		assert.Equal(t, len(tc.expected), len(res), tc.query)
+		for i, expected := range tc.expected {
+			if i < len(res) {
+				assert.Contains(t, res[i].Booking, expected, tc.query)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/list?amount=50", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestListPaginated(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5529)
+	_, err := mockBc.Repo.DeleteTransactions(msg)
+	handleErr(t, err)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	for i := 1; i <= 5; i++ {
+		handleErr(t, mockBc.Repo.RecordTransaction(msg, fmt.Sprintf("tx %d", i)))
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/list?limit=2&offset=2", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "5", w.Header().Get("X-Total-Count"))
+	var res []transactions.Transaction
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, 2, len(res))
+	if len(res) == 2 {
+		assert.Equal(t, "tx 3", res[0].Booking)
+		assert.Equal(t, "tx 4", res[1].Booking)
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/list?offset=2", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestListSortedByBookingDate(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5534)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-02-10 * \"Second\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-01-10 * \"First\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2022-02-01 * \"Between\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/list?sort=booking", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var res []transactions.Transaction
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, 3, len(res))
+	if len(res) == 3 {
+		assert.Contains(t, res[0].Booking, "First")
+		assert.Contains(t, res[1].Booking, "Between")
+		assert.Contains(t, res[2].Booking, "Second")
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/list?group=month&format=text", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "; ---- 2022-01 ----\n2022-01-10 * \"First\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food\n"+
+		"; ---- 2022-02 ----\n2022-02-01 * \"Between\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food\n"+
+		"2022-02-10 * \"Second\"\n  Assets:Wallet  -5.00 EUR\n  Expenses:Food\n", w.Body.String())
+
+	for _, query := range []string{"?sort=amount", "?group=year", "?group=day&sort=created"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/list"+query, nil)
+		req.Header.Add("Authorization", "Bearer "+token)
+		r.ServeHTTP(w, req)
+		assert.Equal(t, 400, w.Code, query)
+	}
+}
@@ -0,0 +1,52 @@
+package transactions
+
+import (
+	"net/http"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+// Query runs the query given by the query parameter q over all open and archived transactions
+// and returns the result as CSV including a header row.
+func (r *Router) Query(c *gin.Context) {
+	if c.Query("q") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "query parameter 'q' is required",
+		})
+		return
+	}
+	query, err := h.ParseQuery(c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	entries, err := r.bc.QueryEntries(m)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	res, err := query.Run(entries)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	csv, err := res.CSV()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", []byte(csv))
+}
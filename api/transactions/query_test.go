@@ -0,0 +1,45 @@
+package transactions_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/transactions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5536)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2024-01-02 * \"Shop\" \"Groceries\"\n  Assets:Cash -12.50 EUR\n  Expenses:Food\n"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "2024-01-03 * \"\" \"Lunch, with colleagues\"\n  Assets:Cash -7.50 EUR\n  Expenses:Food:Lunch\n"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/query?q="+url.QueryEscape(`SELECT narration, account, number WHERE account ~ "^Expenses" ORDER BY number DESC`), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "narration,account,number\nGroceries,Expenses:Food,12.5\n\"Lunch, with colleagues\",Expenses:Food:Lunch,7.5\n", w.Body.String())
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/query?q="+url.QueryEscape("SELECT amount"), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown column")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/query", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 400, w.Code)
+}
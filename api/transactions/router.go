@@ -3,6 +3,7 @@ package transactions
 import (
 	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/bot"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
 	"github.com/gin-gonic/gin"
 )
 
@@ -22,4 +23,23 @@ func (r *Router) Hook(g *gin.RouterGroup) {
 	g.GET("/list", r.List)
 	g.DELETE("/list", r.ListDeleteAll)
 	g.DELETE("/list/:id", r.ListDeleteSingle)
+
+	g.POST("/archive", r.Archive)
+	g.GET("/archive", r.ArchiveBatches)
+	g.DELETE("/archive/:batchId", r.Unarchive)
+
+	g.GET("/trash", r.Trash)
+	g.POST("/trash/:id/restore", r.TrashRestore)
+	g.DELETE("/trash", r.TrashEmpty)
+
+	g.GET("/history", r.History)
+
+	g.GET("/query", r.Query)
+
+	g.POST("/import", r.Import)
+}
+
+// repo records the changes made using the API with the API token of the request as their source
+func (r *Router) repo(c *gin.Context) *crud.Repo {
+	return r.bc.Repo.As(crud.ApiEventSource(c.GetString(helpers.K_TOKEN_ID)))
 }
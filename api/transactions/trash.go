@@ -0,0 +1,77 @@
+package transactions
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers"
+	"github.com/gin-gonic/gin"
+	"gopkg.in/telebot.v3"
+)
+
+type DeletedTransaction struct {
+	Transaction
+	DeletedAt string `json:"deletedAt"`
+}
+
+func (r *Router) Trash(c *gin.Context) {
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	tx, err := r.bc.Repo.GetTrash(m)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	transactions := []DeletedTransaction{}
+	for _, t := range tx {
+		transactions = append(transactions, DeletedTransaction{
+			Transaction: Transaction{
+				Id:         t.Id,
+				CreatedAt:  t.Date,
+				Booking:    t.Tx,
+				IsArchived: t.Archived,
+			},
+			DeletedAt: t.Deleted,
+		})
+	}
+	c.JSON(http.StatusOK, transactions)
+}
+
+func (r *Router) TrashRestore(c *gin.Context) {
+	elId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No element to restore provided",
+		})
+		return
+	}
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	count, err := r.repo(c).RestoreTransaction(m, elId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"affected": count,
+	})
+}
+
+func (r *Router) TrashEmpty(c *gin.Context) {
+	chatId := c.GetInt64(helpers.K_CHAT_ID)
+	m := &telebot.Message{Chat: &telebot.Chat{ID: chatId}}
+	count, err := r.repo(c).EmptyTrash(m)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"affected": count,
+	})
+}
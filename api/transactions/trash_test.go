@@ -0,0 +1,76 @@
+package transactions_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/helpers/apiTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/api/transactions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrash(t *testing.T) {
+	token, mockBc, msg := apiTest.MockBcApiUser(t, 5532)
+	_, err := mockBc.Repo.PurgeTransactions(msg)
+	handleErr(t, err)
+	r := gin.Default()
+	transactions.NewRouter(mockBc).Hook(r.Group(""))
+
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "tx 1"))
+	handleErr(t, mockBc.Repo.RecordTransaction(msg, "tx 2"))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("DELETE", "/list", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"affected":2}`, w.Body.String())
+
+	open, err := mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+	assert.Equal(t, 0, len(open))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/trash", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	var trash []transactions.DeletedTransaction
+	handleErr(t, json.Unmarshal(w.Body.Bytes(), &trash))
+	assert.Equal(t, 2, len(trash))
+	if len(trash) != 2 {
+		return
+	}
+	assert.NotEqual(t, "", trash[0].DeletedAt)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/trash/%d/restore", trash[0].Id), nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"affected":1}`, w.Body.String())
+
+	open, err = mockBc.Repo.GetTransactions(msg, false)
+	handleErr(t, err)
+	assert.Equal(t, 1, len(open))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("DELETE", "/trash", nil)
+	req.Header.Add("Authorization", "Bearer "+token)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+	assert.Equal(t, `{"affected":1}`, w.Body.String())
+
+	// Purging only affects transactions in the trash for long enough
+	_, err = mockBc.Repo.DeleteTransaction(msg, false, open[0].Id)
+	handleErr(t, err)
+	_, err = mockBc.Repo.PurgeTrash(1)
+	handleErr(t, err)
+	restored, err := mockBc.Repo.RestoreTransaction(msg, open[0].Id)
+	handleErr(t, err)
+	assert.Equal(t, int64(1), restored)
+}
@@ -0,0 +1,230 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func (bc *BotController) commandArchive(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_ARCHIVE, true)
+	sc.
+		Add("list", bc.archiveHandleList).
+		Add("get", bc.archiveHandleGet)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		params := h.SplitQuotedCommand(strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_ARCHIVE)))
+		if len(params) == 0 {
+			bc.archiveHelp(m, nil)
+			return nil
+		}
+		bc.Logf(TRACE, m, "Archiving selection: %v (%s)", params, err.Error())
+		bc.archiveSelection(m, params...)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled archive subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) archiveHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s <selection> [label:<label>]
+/%s list
+/%s get <batch>
+/%s <batch>
+
+Selection uses the numbers as seen in /%s and is one of:
+- single numbers or ranges, e.g. '1 3 5-7'
+- everything up to a number, e.g. 'upto:12'
+- a range of booking dates, e.g. 'from:2022-01-01 to:2022-01-31'
+
+Each archive run creates a batch. It can be downloaded again as file with 'get' or moved back to your open transactions with /%s.
+/%s archives all open transactions at once.`, CMD_ARCHIVE, CMD_ARCHIVE, CMD_ARCHIVE, CMD_ARCHIVE, CMD_UNARCHIVE, CMD_LIST, CMD_UNARCHIVE, CMD_ARCHIVE_ALL), clearKeyboard())
+}
+
+// parseArchiveSelection resolves a selection to the IDs of open transactions.
+// It returns the label to use, defaulting to the selection itself.
+func parseArchiveSelection(open []*crud.TransactionResult, params ...string) (ids []int, label string, dateRange crud.TransactionFilter, err error) {
+	selection := []string{}
+	numbers := map[int]bool{}
+	for _, param := range params {
+		if strings.HasPrefix(param, "label:") {
+			label = strings.TrimSpace(strings.TrimPrefix(param, "label:"))
+			continue
+		}
+		selection = append(selection, param)
+		if strings.HasPrefix(param, "from:") || strings.HasPrefix(param, "to:") {
+			parsed, parseErr := ParseTransactionFilter([]string{param})
+			if parseErr != nil {
+				return nil, "", dateRange, parseErr
+			}
+			if parsed.From != "" {
+				dateRange.From = parsed.From
+			} else {
+				dateRange.To = parsed.To
+			}
+			continue
+		}
+		first, last := 1, 0
+		if strings.HasPrefix(param, "upto:") {
+			last, err = strconv.Atoi(strings.TrimPrefix(param, "upto:"))
+		} else if start, end, isRange := strings.Cut(param, "-"); isRange {
+			first, err = strconv.Atoi(start)
+			if err == nil {
+				last, err = strconv.Atoi(end)
+			}
+		} else {
+			first, err = strconv.Atoi(param)
+			last = first
+		}
+		if err != nil || first < 1 || last < first {
+			return nil, "", dateRange, fmt.Errorf("'%s' is no valid selection", param)
+		}
+		if last > len(open) {
+			return nil, "", dateRange, fmt.Errorf("'%s' exceeds the number of open transactions (%d)", param, len(open))
+		}
+		for i := first; i <= last; i++ {
+			numbers[i] = true
+		}
+	}
+	if len(selection) == 0 {
+		return nil, "", dateRange, fmt.Errorf("no transactions to archive have been selected")
+	}
+	if len(numbers) > 0 && (dateRange.From != "" || dateRange.To != "") {
+		return nil, "", dateRange, fmt.Errorf("numbers and date ranges cannot be combined")
+	}
+	if label == "" {
+		label = strings.Join(selection, " ")
+	}
+	for i, t := range open {
+		if numbers[i+1] {
+			ids = append(ids, t.Id)
+		}
+	}
+	return ids, label, dateRange, nil
+}
+
+func (bc *BotController) archiveSelection(m *tb.Message, params ...string) {
+	isArchived := false
+	open, err := bc.Repo.FindTransactions(m, crud.TransactionFilter{Archived: &isArchived})
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	ids, label, dateRange, err := parseArchiveSelection(open, params...)
+	if err != nil {
+		bc.archiveHelp(m, err)
+		return
+	}
+	if dateRange.From != "" || dateRange.To != "" {
+		dateRange.Archived = &isArchived
+		inRange, err := bc.Repo.FindTransactions(m, dateRange)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+			return
+		}
+		ids = []int{}
+		for _, t := range inRange {
+			ids = append(ids, t.Id)
+		}
+		if len(ids) == 0 {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "No open transactions have been booked within this date range.", clearKeyboard())
+			return
+		}
+	}
+	batchId, count, err := bc.Repo.ArchiveTransactionBatch(m, label, ids)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong archiving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if count == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "None of the selected transactions is open anymore. Nothing has been archived.", clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Archived %d transaction(s) as batch %d ('%s'). You can undo this using '/%s %d'.",
+		count, batchId, label, CMD_UNARCHIVE, batchId), clearKeyboard())
+}
+
+func (bc *BotController) archiveHandleList(m *tb.Message, params ...string) {
+	if len(params) > 0 {
+		bc.archiveHelp(m, fmt.Errorf("no parameters expected"))
+		return
+	}
+	batches, err := bc.Repo.GetArchiveBatches(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your archive batches: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(batches) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You have not archived any transactions yet. Check '/%s' on how to do so.", CMD_ARCHIVE), clearKeyboard())
+		return
+	}
+	lines := []string{}
+	for _, batch := range batches {
+		lines = append(lines, fmt.Sprintf("%d) '%s' - %d transaction(s), archived %s", batch.Id, batch.Label, batch.Count, formatBatchDate(batch.Created)))
+	}
+	messages := bc.MergeMessagesHonorSendLimit(append([]string{"Your archive batches (newest first):\n"}, lines...), "\n")
+	for _, message := range messages {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), message, clearKeyboard())
+	}
+}
+
+func (bc *BotController) archiveHandleGet(m *tb.Message, params ...string) {
+	batchId, err := parseBatchId(params...)
+	if err != nil {
+		bc.archiveHelp(m, err)
+		return
+	}
+	tx, err := bc.Repo.FindTransactions(m, crud.TransactionFilter{BatchId: batchId})
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(tx) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("The archive batch %d does not exist or contains no transactions. Check '/%s list'.", batchId, CMD_ARCHIVE), clearKeyboard())
+		return
+	}
+	bc.sendListDocument(m, bc.formatListEntries(m, tx, false, 0), fmt.Sprintf("Archive batch %d: %d transaction(s)", batchId, len(tx)))
+}
+
+func (bc *BotController) commandUnarchive(c tb.Context) error {
+	m := c.Message()
+	params := h.SplitQuotedCommand(strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_UNARCHIVE)))
+	batchId, err := parseBatchId(params...)
+	if err != nil {
+		bc.archiveHelp(m, err)
+		return nil
+	}
+	count, err := bc.Repo.UnarchiveBatch(m, batchId)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong unarchiving your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Moved %d transaction(s) of batch %d back to your open transactions in /%s.", count, batchId, CMD_LIST), clearKeyboard())
+	return nil
+}
+
+func parseBatchId(params ...string) (int, error) {
+	if len(params) != 1 {
+		return 0, fmt.Errorf("please specify exactly one batch number as seen in '/%s list'", CMD_ARCHIVE)
+	}
+	batchId, err := strconv.Atoi(params[0])
+	if err != nil || batchId < 1 {
+		return 0, fmt.Errorf("'%s' is no valid batch number", params[0])
+	}
+	return batchId, nil
+}
+
+func formatBatchDate(created string) string {
+	// Timestamps are formatted like 2022-03-30T14:24:50Z
+	return strings.TrimSuffix(strings.Replace(created, "T", " ", 1), "Z")
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+func TestParseArchiveSelection(t *testing.T) {
+	open := []*crud.TransactionResult{}
+	for id := 11; id <= 18; id++ {
+		open = append(open, &crud.TransactionResult{Id: id})
+	}
+
+	ids, label, dateRange, err := parseArchiveSelection(open, "1", "3-4", "label:January rent")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, label, "January rent", "")
+	helpers.TestExpect(t, dateRange.From+dateRange.To, "", "")
+	helpers.TestExpect(t, fmt.Sprint(ids), "[11 13 14]", "")
+
+	ids, label, _, err = parseArchiveSelection(open, "upto:3", "2")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, label, "upto:3 2", "selection should be the default label")
+	helpers.TestExpect(t, fmt.Sprint(ids), "[11 12 13]", "")
+
+	ids, _, dateRange, err = parseArchiveSelection(open, "from:2022-01-01", "to:2022-01-31")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, len(ids), 0, "")
+	helpers.TestExpect(t, dateRange.From, "2022-01-01", "")
+	helpers.TestExpect(t, dateRange.To, "2022-01-31", "")
+
+	for _, invalid := range [][]string{{"0"}, {"9"}, {"4-2"}, {"upto:x"}, {"label:only"}, {"1", "from:2022-01-01"}, {"from:2022-13-01"}} {
+		_, _, _, err = parseArchiveSelection(open, invalid...)
+		helpers.TestExpect(t, err != nil, true, invalid[0])
+	}
+}
@@ -0,0 +1,225 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Balance is the sum of the opening balance and all recorded postings of an account in a currency
+type Balance struct {
+	Account  string
+	Currency string
+	Amount   float64
+}
+
+func balanceIncludes(account, prefix string) bool {
+	return prefix == "" || reportIncludes(account, prefix)
+}
+
+// ComputeBalances sums up the balances per account and currency, optionally restricted to accounts with the given prefix
+func ComputeBalances(opening []*crud.OpeningBalance, entries []*h.BeancountEntry, prefix string) []*Balance {
+	sums := map[string]map[string]float64{}
+	add := func(account, currency string, amount float64) {
+		if !balanceIncludes(account, prefix) || currency == "" {
+			return
+		}
+		if sums[account] == nil {
+			sums[account] = map[string]float64{}
+		}
+		sums[account][currency] += amount
+	}
+	for _, balance := range opening {
+		add(balance.Account, balance.Currency, balance.Amount)
+	}
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			add(p.Account, p.Currency, p.Amount)
+		}
+	}
+	balances := []*Balance{}
+	for _, account := range sortedKeys(sums) {
+		for _, currency := range sortedCurrencies(sums[account]) {
+			// Avoid displaying floating point residue like -0.00
+			amount := math.Round(sums[account][currency]*1e8) / 1e8
+			if amount == 0 {
+				amount = 0
+			}
+			balances = append(balances, &Balance{Account: account, Currency: currency, Amount: amount})
+		}
+	}
+	return balances
+}
+
+// Balances computes the current balances from the opening balances and all open and archived transactions
+func (bc *BotController) Balances(m *tb.Message, prefix string) ([]*Balance, error) {
+	opening, err := bc.Repo.GetOpeningBalances(m)
+	if err != nil {
+		return nil, err
+	}
+	filter := crud.TransactionFilter{}
+	if prefix != "" {
+		filter.Accounts = []string{prefix}
+	}
+	tx, err := bc.Repo.FindTransactions(m, filter)
+	if err != nil {
+		return nil, err
+	}
+	entries := []*h.BeancountEntry{}
+	for _, t := range tx {
+		parsed, err := h.ParseBeancount(t.Tx)
+		if err != nil {
+			bc.Logf(WARN, m, "Skipping transaction %d in balances: %s", t.Id, err.Error())
+			continue
+		}
+		entries = append(entries, parsed...)
+	}
+	return ComputeBalances(opening, entries, prefix), nil
+}
+
+// FormatBalances renders balances as table. With a prefix, the totals per currency are added.
+func FormatBalances(balances []*Balance, prefix string) string {
+	rows := [][]string{}
+	totals := map[string]float64{}
+	for _, balance := range balances {
+		rows = append(rows, []string{balance.Account, fmt.Sprintf("%.2f", balance.Amount), balance.Currency})
+		totals[balance.Currency] += balance.Amount
+	}
+	if prefix != "" {
+		for _, currency := range sortedCurrencies(totals) {
+			rows = append(rows, []string{"Total " + prefix, fmt.Sprintf("%.2f", totals[currency]), currency})
+		}
+	}
+	return formatReportTable(rows)
+}
+
+func (bc *BotController) commandBalances(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_BALANCES, true)
+	sc.
+		Add("set", bc.balancesHandleSet).
+		Add("rm", bc.balancesHandleRemove).
+		Add("opening", bc.balancesHandleOpening)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		params := h.SplitQuotedCommand(m.Text)[1:]
+		if len(params) > 1 || (len(params) == 1 && !BeancountAccountLike(params[0])) {
+			bc.balancesHelp(m, fmt.Errorf("unknown subcommand or invalid account prefix"))
+			return nil
+		}
+		prefix := ""
+		if len(params) == 1 {
+			prefix = params[0]
+		}
+		bc.balancesHandleList(m, prefix)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled balances subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) balancesHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s [account prefix] - Show the current balance per account and currency, e.g. '/%s Assets'
+/%s set <account> <amount> [currency] - Set the opening balance of an account. Defaults to your currency.
+/%s opening - List your opening balances
+/%s rm <account> [currency] - Remove an opening balance
+
+Balances are computed from your opening balances and all of your open and archived transactions.`, CMD_BALANCES, CMD_BALANCES, CMD_BALANCES, CMD_BALANCES, CMD_BALANCES, CMD_BALANCES), clearKeyboard())
+}
+
+func (bc *BotController) balancesHandleList(m *tb.Message, prefix string) {
+	balances, err := bc.Balances(m, prefix)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong computing your balances: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(balances) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("There are no balances to show yet. Record transactions or set opening balances using '/%s set <account> <amount>'.", CMD_BALANCES), clearKeyboard())
+		return
+	}
+	bc.sendPreformatted(m, FormatBalances(balances, prefix), "balances.txt", "Your balances are too long for a message. Here they are as file.")
+}
+
+func (bc *BotController) balancesHandleSet(m *tb.Message, params ...string) {
+	if len(params) < 2 || len(params) > 3 {
+		bc.balancesHelp(m, fmt.Errorf("please specify an account and an amount"))
+		return
+	}
+	balance := &crud.OpeningBalance{Account: params[0]}
+	if !BeancountAccountLike(balance.Account) {
+		bc.balancesHelp(m, fmt.Errorf("'%s' is no valid account", balance.Account))
+		return
+	}
+	amount, err := handleThousandsSeparators(params[1])
+	if err == nil {
+		balance.Amount, err = strconv.ParseFloat(amount, 64)
+	}
+	if err != nil {
+		bc.balancesHelp(m, fmt.Errorf("'%s' is no valid amount", params[1]))
+		return
+	}
+	if len(params) == 3 {
+		if !beancountCurrency.MatchString(params[2]) {
+			bc.balancesHelp(m, fmt.Errorf("'%s' is no valid currency", params[2]))
+			return
+		}
+		balance.Currency = params[2]
+	} else {
+		balance.Currency = bc.Repo.UserGetCurrency(m)
+	}
+	err = bc.Repo.SetOpeningBalance(m, balance)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong setting your opening balance: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Set the opening balance of %s to %.2f %s. See your current balances using /%s.",
+		balance.Account, balance.Amount, balance.Currency, CMD_BALANCES), clearKeyboard())
+}
+
+func (bc *BotController) balancesHandleOpening(m *tb.Message, params ...string) {
+	opening, err := bc.Repo.GetOpeningBalances(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your opening balances: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(opening) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You have not set any opening balances yet. Set one using '/%s set <account> <amount>'.", CMD_BALANCES), clearKeyboard())
+		return
+	}
+	lines := []string{"Your opening balances:"}
+	for _, balance := range opening {
+		lines = append(lines, fmt.Sprintf("%s: %.2f %s", balance.Account, balance.Amount, balance.Currency))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+func (bc *BotController) balancesHandleRemove(m *tb.Message, params ...string) {
+	if len(params) < 1 || len(params) > 2 {
+		bc.balancesHelp(m, fmt.Errorf("please specify the account of the opening balance to remove"))
+		return
+	}
+	currency := ""
+	if len(params) == 2 {
+		currency = params[1]
+	}
+	count, err := bc.Repo.DeleteOpeningBalance(m, params[0], currency)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong removing your opening balance: "+err.Error(), clearKeyboard())
+		return
+	}
+	if count == 0 {
+		bc.balancesHelp(m, fmt.Errorf("there is no opening balance for '%s'. See /%s opening", params[0], CMD_BALANCES))
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Removed %d opening balance(s) of %s.", count, params[0]), clearKeyboard())
+}
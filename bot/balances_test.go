@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestComputeBalances(t *testing.T) {
+	opening := []*crud.OpeningBalance{
+		{Account: "Assets:Cash", Amount: 120, Currency: "EUR"},
+		{Account: "Liabilities:Card", Amount: -10.1, Currency: "EUR"},
+	}
+	entries := parseReportTestEntries(t,
+		"2022-03-01 * \"Shop\" \"Groceries\"\n  Assets:Cash -12.50 EUR\n  Expenses:Food 12.50 EUR\n",
+		"2022-03-02 * \"\" \"Lunch\"\n  Assets:Cash:Coins -0.70 EUR\n  Expenses:Food\n",
+		"2022-03-03 * \"\" \"Bus\"\n  Assets:Cash -5.00 USD\n  Expenses:Transport 5.00 USD\n",
+		"2022-03-04 * \"\" \"Pay off\"\n  Liabilities:Card 10.10 EUR\n  Assets:Bank -10.10 EUR\n",
+	)
+	balances := ComputeBalances(opening, entries, "")
+	formatted := []string{}
+	for _, b := range balances {
+		formatted = append(formatted, fmt.Sprintf("%s %.2f %s", b.Account, b.Amount, b.Currency))
+	}
+	helpers.TestExpectArrEq(t, formatted, []string{
+		"Assets:Bank -10.10 EUR",
+		"Assets:Cash 107.50 EUR",
+		"Assets:Cash -5.00 USD",
+		"Assets:Cash:Coins -0.70 EUR",
+		"Expenses:Food 13.20 EUR",
+		"Expenses:Transport 5.00 USD",
+		"Liabilities:Card 0.00 EUR",
+	}, "")
+
+	balances = ComputeBalances(opening, entries, "Assets:Cash")
+	helpers.TestExpect(t, len(balances), 3, "")
+	helpers.TestExpect(t, FormatBalances(balances, "Assets:Cash"), `Assets:Cash       107.50 EUR
+Assets:Cash        -5.00 USD
+Assets:Cash:Coins  -0.70 EUR
+Total Assets:Cash 106.80 EUR
+Total Assets:Cash  -5.00 USD`, "")
+}
+
+func TestCommandBalances(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "bot::openingBalance"`).WithArgs(chat.ID, "Assets:Cash", "EUR").WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectExec(`INSERT INTO "bot::openingBalance"`).WithArgs(chat.ID, "Assets:Cash", 120.5, "EUR").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT "account", "amount", "currency"`).WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"account", "amount", "currency"}).AddRow("Assets:Cash", 120.5, "EUR"))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WithArgs(chat.ID, "% Assets %", "% Assets:%").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(1, "2022-03-02 * \"Shop\" \"Groceries\"\n  Assets:Cash -12.50 EUR\n  Expenses:Food 12.50 EUR\n", "2022-03-02T10:00:00Z", true))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandBalances(&botTest.MockContext{M: &tb.Message{Text: "/balances set Assets:Cash 120,50 EUR", Chat: chat}})
+	helpers.TestExpect(t, bot.LastSentWhat, "Set the opening balance of Assets:Cash to 120.50 EUR. See your current balances using /balances.", "")
+
+	bc.commandBalances(&botTest.MockContext{M: &tb.Message{Text: "/balances Assets", Chat: chat}})
+	helpers.TestExpect(t, bot.LastSentWhat, "<pre>Assets:Cash  108.00 EUR\nTotal Assets 108.00 EUR</pre>", "")
+
+	bc.commandBalances(&botTest.MockContext{M: &tb.Message{Text: "/balances assets", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /balances", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,25 @@
+package botTest
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+// FailOnErrorLogs fails the test if errors are logged until it ends. Errors which are only logged and not returned
+// would otherwise go unnoticed, e.g. queries missing in the mocked database.
+func FailOnErrorLogs(t *testing.T) {
+	output := &bytes.Buffer{}
+	log.SetOutput(io.MultiWriter(os.Stderr, output))
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		if strings.Contains(output.String(), "["+helpers.ERROR.String()+"]") {
+			t.Errorf("errors have been logged:\n%s", output.String())
+		}
+	})
+}
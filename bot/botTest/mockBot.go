@@ -1,6 +1,9 @@
 package botTest
 
 import (
+	"fmt"
+	"io"
+	"strings"
 	"time"
 
 	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
@@ -9,19 +12,36 @@ import (
 
 type MockBot struct {
 	LastSentWhat    interface{}
+	LastSentOptions []interface{}
 	AllLastSentWhat []interface{}
+	LastEditedWhat  interface{}
+	// Files maps file IDs to the contents returned when downloading them
+	Files map[string]string
 }
 
 func (b *MockBot) Start()                                                                       {}
 func (b *MockBot) Handle(endpoint interface{}, handler tb.HandlerFunc, mw ...tb.MiddlewareFunc) {}
 func (b *MockBot) Send(to tb.Recipient, what interface{}, options ...interface{}) (*tb.Message, error) {
 	b.LastSentWhat = what
+	b.LastSentOptions = options
 	b.AllLastSentWhat = append(b.AllLastSentWhat, what)
 	return nil, nil
 }
+func (b *MockBot) Edit(msg tb.Editable, what interface{}, options ...interface{}) (*tb.Message, error) {
+	b.LastEditedWhat = what
+	b.LastSentOptions = options
+	return nil, nil
+}
 func (b *MockBot) Respond(c *tb.Callback, resp ...*tb.CallbackResponse) error {
 	return nil
 }
+func (b *MockBot) File(file *tb.File) (io.ReadCloser, error) {
+	content, exists := b.Files[file.FileID]
+	if !exists {
+		return nil, fmt.Errorf("file '%s' does not exist", file.FileID)
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
 func (b *MockBot) Me() *tb.User {
 	return &tb.User{Username: "Test bot"}
 }
@@ -34,14 +54,18 @@ func (b *MockBot) Reset() {
 
 type MockContext struct {
 	M *tb.Message
+	C *tb.Callback
 }
 
 func (c *MockContext) Bot() *tb.Bot      { return nil }
 func (c *MockContext) Update() tb.Update { return tb.Update{} }
 func (c *MockContext) Message() *tb.Message {
+	if c.M == nil && c.C != nil {
+		return c.C.Message
+	}
 	return c.M
 }
-func (c *MockContext) Callback() *tb.Callback                                  { return nil }
+func (c *MockContext) Callback() *tb.Callback                                  { return c.C }
 func (c *MockContext) Query() *tb.Query                                        { return nil }
 func (c *MockContext) InlineResult() *tb.InlineResult                          { return nil }
 func (c *MockContext) ShippingQuery() *tb.ShippingQuery                        { return nil }
@@ -0,0 +1,301 @@
+package bot
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	// BUDGET_ALERT_WARN is the percentage of a budget spent from which on users are warned
+	BUDGET_ALERT_WARN     = 80
+	BUDGET_ALERT_EXCEEDED = 100
+)
+
+var beancountCurrency = regexp.MustCompile(`^[A-Z][A-Z0-9'._-]*$`)
+
+// budgetPeriods maps the budget periods to the report periods they are evaluated in
+var budgetPeriods = map[string]string{
+	crud.BUDGET_PERIOD_WEEKLY:  REPORT_PERIOD_WEEK,
+	crud.BUDGET_PERIOD_MONTHLY: REPORT_PERIOD_MONTH,
+	crud.BUDGET_PERIOD_YEARLY:  REPORT_PERIOD_YEAR,
+}
+
+// BudgetState is the spending of a budget in its current period
+type BudgetState struct {
+	Budget *crud.Budget
+	Period ReportPeriod
+	Spent  float64
+}
+
+func (s *BudgetState) Percent() float64 {
+	return s.Spent / s.Budget.Amount * 100
+}
+
+// Level is the highest alert level reached, or 0 if none has been reached
+func (s *BudgetState) Level() int {
+	percent := s.Percent()
+	if percent >= BUDGET_ALERT_EXCEEDED {
+		return BUDGET_ALERT_EXCEEDED
+	}
+	if percent >= BUDGET_ALERT_WARN {
+		return BUDGET_ALERT_WARN
+	}
+	return 0
+}
+
+func (s *BudgetState) String() string {
+	return fmt.Sprintf("%s: %.2f of %.2f %s (%.0f%%) spent in %s", s.Budget.Account, s.Spent, s.Budget.Amount, s.Budget.Currency, s.Percent(), s.Period)
+}
+
+// BudgetSpent sums the postings to the account (including sub-accounts) in the currency
+func BudgetSpent(entries []*h.BeancountEntry, account, currency string) float64 {
+	spent := 0.0
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			if p.Currency == currency && reportIncludes(p.Account, account) {
+				spent += p.Amount * reportSign(p.Account)
+			}
+		}
+	}
+	return spent
+}
+
+// FormatBudgetAlert describes which alert level a budget has reached
+func FormatBudgetAlert(state *BudgetState) string {
+	if state.Level() >= BUDGET_ALERT_EXCEEDED {
+		return fmt.Sprintf("Budget alert: You have exceeded your %s budget for %s", state.Budget.Period, state)
+	}
+	return fmt.Sprintf("Budget alert: You have used %.0f%% of your %s budget for %s", state.Percent(), state.Budget.Period, state)
+}
+
+func (bc *BotController) commandBudget(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_BUDGET, true)
+	sc.
+		Add("set", bc.budgetHandleSet).
+		Add("list", bc.budgetHandleList).
+		Add("status", bc.budgetHandleStatus).
+		Add("rm", bc.budgetHandleRemove)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		if strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_BUDGET)) != "" {
+			bc.budgetHelp(m, fmt.Errorf("unknown subcommand"))
+			return nil
+		}
+		bc.budgetHandleStatus(m)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled budget subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) budgetHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s set <account> <amount> [currency] [weekly|monthly|yearly] - Set a budget for an account and its sub-accounts. Defaults to your currency and monthly.
+/%s list - List your budgets
+/%s status - Show how much of your budgets you have spent in the current period
+/%s rm <account> [currency] - Remove a budget
+
+You are warned once when %d%% of a budget are spent and once when it is exceeded in a period, after recording a transaction. If you enabled notifications in /%s, budgets crossing these thresholds otherwise (e.g. through transactions recorded via the API) are reported at your notification hour.
+
+Example: /%s set Expenses:Food 400 EUR monthly`, CMD_BUDGET, CMD_BUDGET, CMD_BUDGET, CMD_BUDGET, CMD_BUDGET, BUDGET_ALERT_WARN, CMD_CONFIG, CMD_BUDGET), clearKeyboard())
+}
+
+func (bc *BotController) budgetHandleSet(m *tb.Message, params ...string) {
+	if len(params) < 2 || len(params) > 4 {
+		bc.budgetHelp(m, fmt.Errorf("please specify at least an account and an amount"))
+		return
+	}
+	budget := &crud.Budget{Account: params[0], Period: crud.BUDGET_PERIOD_MONTHLY}
+	if !BeancountAccountLike(budget.Account) {
+		bc.budgetHelp(m, fmt.Errorf("'%s' is no valid account", budget.Account))
+		return
+	}
+	amount, err := handleThousandsSeparators(params[1])
+	if err == nil {
+		budget.Amount, err = strconv.ParseFloat(amount, 64)
+	}
+	if err != nil || budget.Amount <= 0 {
+		bc.budgetHelp(m, fmt.Errorf("'%s' is no valid positive amount", params[1]))
+		return
+	}
+	for _, param := range params[2:] {
+		if _, isPeriod := budgetPeriods[param]; isPeriod {
+			budget.Period = param
+		} else if beancountCurrency.MatchString(param) && budget.Currency == "" {
+			budget.Currency = param
+		} else {
+			bc.budgetHelp(m, fmt.Errorf("'%s' is neither a currency nor a period", param))
+			return
+		}
+	}
+	if budget.Currency == "" {
+		budget.Currency = bc.Repo.UserGetCurrency(m)
+	}
+	err = bc.Repo.SetBudget(m, budget)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong setting your budget: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Set a %s budget of %.2f %s for %s. Check it using /%s status.",
+		budget.Period, budget.Amount, budget.Currency, budget.Account, CMD_BUDGET), clearKeyboard())
+}
+
+func (bc *BotController) budgetHandleList(m *tb.Message, params ...string) {
+	budgets, err := bc.Repo.GetBudgets(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your budgets: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(budgets) == 0 {
+		bc.budgetSendEmptyHint(m)
+		return
+	}
+	lines := []string{"Your budgets:"}
+	for _, budget := range budgets {
+		lines = append(lines, fmt.Sprintf("%s: %.2f %s %s", budget.Account, budget.Amount, budget.Currency, budget.Period))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+func (bc *BotController) budgetHandleStatus(m *tb.Message, params ...string) {
+	budgets, err := bc.Repo.GetBudgets(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your budgets: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(budgets) == 0 {
+		bc.budgetSendEmptyHint(m)
+		return
+	}
+	states, err := bc.budgetStates(m, budgets)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	lines := []string{"Your budgets in the current period:"}
+	for _, state := range states {
+		line := state.String()
+		if state.Level() >= BUDGET_ALERT_EXCEEDED {
+			line += " - exceeded"
+		}
+		lines = append(lines, line)
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+func (bc *BotController) budgetHandleRemove(m *tb.Message, params ...string) {
+	if len(params) < 1 || len(params) > 2 {
+		bc.budgetHelp(m, fmt.Errorf("please specify the account of the budget to remove"))
+		return
+	}
+	currency := ""
+	if len(params) == 2 {
+		currency = params[1]
+	}
+	count, err := bc.Repo.DeleteBudget(m, params[0], currency)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong removing your budget: "+err.Error(), clearKeyboard())
+		return
+	}
+	if count == 0 {
+		bc.budgetHelp(m, fmt.Errorf("there is no budget for '%s'. See /%s list", params[0], CMD_BUDGET))
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Removed %d budget(s) for %s.", count, params[0]), clearKeyboard())
+}
+
+func (bc *BotController) budgetSendEmptyHint(m *tb.Message) {
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You have not set any budgets yet. Set one using '/%s set <account> <amount>'.", CMD_BUDGET), clearKeyboard())
+}
+
+// budgetStates evaluates the budgets against the transactions in their current periods
+func (bc *BotController) budgetStates(m *tb.Message, budgets []*crud.Budget) ([]*BudgetState, error) {
+	today := time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour)
+	entriesPerPeriod := map[string][]*h.BeancountEntry{}
+	states := []*BudgetState{}
+	for _, budget := range budgets {
+		reportPeriod, known := budgetPeriods[budget.Period]
+		if !known {
+			bc.Logf(WARN, m, "Skipping budget %d with unknown period '%s'", budget.Id, budget.Period)
+			continue
+		}
+		period, _, err := ParseReportPeriod(reportPeriod, today)
+		if err != nil {
+			return nil, err
+		}
+		entries, cached := entriesPerPeriod[reportPeriod]
+		if !cached {
+			entries, err = bc.reportEntries(m, period)
+			if err != nil {
+				return nil, err
+			}
+			entriesPerPeriod[reportPeriod] = entries
+		}
+		states = append(states, &BudgetState{
+			Budget: budget,
+			Period: period,
+			Spent:  BudgetSpent(entries, budget.Account, budget.Currency),
+		})
+	}
+	return states, nil
+}
+
+// checkBudgetAlerts warns about budgets having reached a new alert level in their current period
+func (bc *BotController) checkBudgetAlerts(m *tb.Message) {
+	budgets, err := bc.Repo.GetBudgets(m)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not get budgets: %s", err.Error())
+		return
+	}
+	if len(budgets) == 0 {
+		return
+	}
+	states, err := bc.budgetStates(m, budgets)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not evaluate budgets: %s", err.Error())
+		return
+	}
+	alerts := []string{}
+	for _, state := range states {
+		periodStart := state.Period.From.Format(h.BEANCOUNT_DATE_FORMAT)
+		alerted := 0
+		if state.Budget.AlertedPeriod == periodStart {
+			alerted = state.Budget.AlertedLevel
+		}
+		if state.Level() <= alerted {
+			continue
+		}
+		err = bc.Repo.SetBudgetAlerted(m, state.Budget.Id, state.Level(), periodStart)
+		if err != nil {
+			bc.Logf(ERROR, m, "Could not update budget alert: %s", err.Error())
+			continue
+		}
+		alerts = append(alerts, FormatBudgetAlert(state))
+	}
+	if len(alerts) > 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(alerts, "\n\n")+fmt.Sprintf("\n\nSee /%s status for all of your budgets.", CMD_BUDGET), clearKeyboard())
+	}
+}
+
+func (bc *BotController) cronBudgetAlerts() {
+	chats, err := bc.Repo.GetBudgetChatsToNotify()
+	if err != nil {
+		bc.Logf(ERROR, nil, "Error getting chats to check budgets for: %s", err.Error())
+		return
+	}
+	for _, chatId := range chats {
+		bc.checkBudgetAlerts(&tb.Message{Chat: &tb.Chat{ID: chatId}})
+	}
+}
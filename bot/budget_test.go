@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestBudgetState(t *testing.T) {
+	entries := parseReportTestEntries(t,
+		"2022-03-01 * \"Shop\" \"Groceries\"\n  Assets:Wallet -300.00 EUR\n  Expenses:Food:Groceries 300.00 EUR\n",
+		"2022-03-02 * \"\" \"Lunch\"\n  Assets:Wallet -30.00 EUR\n  Expenses:Food 30.00 EUR\n",
+		"2022-03-03 * \"\" \"Lunch\"\n  Assets:Wallet -10.00 USD\n  Expenses:Food 10.00 USD\n",
+		"2022-03-04 * \"\" \"Bus\"\n  Assets:Wallet -2.00 EUR\n  Expenses:FoodTruck 2.00 EUR\n",
+	)
+	helpers.TestExpect(t, BudgetSpent(entries, "Expenses:Food", "EUR"), 330.0, "")
+	helpers.TestExpect(t, BudgetSpent(entries, "Expenses:Food", "USD"), 10.0, "")
+
+	period, _, _ := ParseReportPeriod("2022-03-01..2022-03-31", time.Now())
+	state := &BudgetState{Budget: &crud.Budget{Account: "Expenses:Food", Amount: 400, Currency: "EUR", Period: crud.BUDGET_PERIOD_MONTHLY}, Period: period, Spent: 330}
+	helpers.TestExpect(t, state.Level(), BUDGET_ALERT_WARN, "")
+	helpers.TestExpect(t, FormatBudgetAlert(state), "Budget alert: You have used 82% of your monthly budget for Expenses:Food: 330.00 of 400.00 EUR (82%) spent in 2022-03-01..2022-03-31", "")
+	state.Spent = 400
+	helpers.TestExpect(t, state.Level(), BUDGET_ALERT_EXCEEDED, "")
+	helpers.TestExpect(t, FormatBudgetAlert(state), "Budget alert: You have exceeded your monthly budget for Expenses:Food: 400.00 of 400.00 EUR (100%) spent in 2022-03-01..2022-03-31", "")
+	state.Spent = 10
+	helpers.TestExpect(t, state.Level(), 0, "")
+}
+
+func TestCommandBudget(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "bot::budget"`).WithArgs(chat.ID, "Expenses:Food", "EUR").WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectExec(`INSERT INTO "bot::budget"`).WithArgs(chat.ID, "Expenses:Food", 400.0, "EUR", crud.BUDGET_PERIOD_WEEKLY).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	budgetColumns := []string{"id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"}
+	mock.ExpectQuery(`SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"`).WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows(budgetColumns).AddRow(1, "Expenses:Food", 400.0, "EUR", crud.BUDGET_PERIOD_WEEKLY, 0, nil))
+	mock.ExpectExec(`DELETE FROM "bot::budget"`).WithArgs(chat.ID, "Expenses:Drinks").WillReturnResult(sqlmock.NewResult(1, 0))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandBudget(&botTest.MockContext{M: &tb.Message{Text: "/budget set Expenses:Food 400 EUR weekly", Chat: chat}})
+	helpers.TestExpect(t, bot.LastSentWhat, "Set a weekly budget of 400.00 EUR for Expenses:Food. Check it using /budget status.", "")
+
+	bc.commandBudget(&botTest.MockContext{M: &tb.Message{Text: "/budget set Expenses:Food -5", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "'-5' is no valid positive amount", "")
+	bc.commandBudget(&botTest.MockContext{M: &tb.Message{Text: "/budget set Expenses:Food 5 EUR daily", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "'daily' is neither a currency nor a period", "")
+
+	bc.commandBudget(&botTest.MockContext{M: &tb.Message{Text: "/budget list", Chat: chat}})
+	helpers.TestExpect(t, bot.LastSentWhat, "Your budgets:\nExpenses:Food: 400.00 EUR weekly", "")
+
+	bc.commandBudget(&botTest.MockContext{M: &tb.Message{Text: "/budget rm Expenses:Drinks", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "there is no budget for 'Expenses:Drinks'", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCheckBudgetAlerts(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	period, _, _ := ParseReportPeriod(REPORT_PERIOD_MONTH, time.Now().UTC())
+	periodStart := period.From.Format(helpers.BEANCOUNT_DATE_FORMAT)
+	today := time.Now().UTC().Format(helpers.BEANCOUNT_DATE_FORMAT)
+	budgetColumns := []string{"id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"}
+	expectBudgets := func(alertedLevel int, alertedPeriod string) {
+		mock.ExpectQuery(`SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"`).WithArgs(chat.ID).
+			WillReturnRows(sqlmock.NewRows(budgetColumns).AddRow(7, "Expenses:Food", 100.0, "EUR", crud.BUDGET_PERIOD_MONTHLY, alertedLevel, alertedPeriod))
+		mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_TZOFF).
+			WillReturnRows(sqlmock.NewRows([]string{"value"}))
+		mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+				AddRow(1, today+" * \"Shop\" \"Groceries\"\n  Assets:Wallet -85.00 EUR\n  Expenses:Food 85.00 EUR\n", today+"T10:00:00Z", false))
+	}
+	// Alerted in a previous period
+	expectBudgets(BUDGET_ALERT_EXCEEDED, "2000-01-01")
+	mock.ExpectExec(`UPDATE "bot::budget"`).WithArgs(chat.ID, 7, BUDGET_ALERT_WARN, periodStart).WillReturnResult(sqlmock.NewResult(1, 1))
+	// Already alerted in this period
+	expectBudgets(BUDGET_ALERT_WARN, periodStart)
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	m := &tb.Message{Chat: chat}
+	bc.checkBudgetAlerts(m)
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Budget alert: You have used 85% of your monthly budget for Expenses:Food: 85.00 of 100.00 EUR (85%)", "")
+	bot.LastSentWhat = nil
+	bc.checkBudgetAlerts(m)
+	helpers.TestExpect(t, bot.LastSentWhat, nil, "should not alert twice")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,286 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"github.com/wcharczuk/go-chart/v2"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	CHART_BAR  = "bar"
+	CHART_PIE  = "pie"
+	CHART_TIME = "time"
+
+	// CHART_MAX_CATEGORIES is the number of categories shown. Smaller ones are summed up as 'Other'.
+	CHART_MAX_CATEGORIES = 10
+	// CHART_MAX_DAILY_DAYS is the longest period charted per day over time. Longer ones are charted per month.
+	CHART_MAX_DAILY_DAYS = 62
+
+	CHART_DEFAULT_ACCOUNT = "Expenses"
+	CHART_HEIGHT          = 600
+	CHART_MIN_WIDTH       = 800
+)
+
+const CHART_USAGE = `Usage help for /chart:
+/chart [week|month|year|<from>..<to>] [account prefix] [bar|pie|time]
+
+Renders your spending of open and archived transactions as image. Defaults to the current month and all 'Expenses' accounts.
+'bar' and 'pie' show the spending per category (the sub-accounts of the account prefix), 'time' shows it per day or month.
+
+Example: /chart year Expenses:Food time`
+
+// ChartData holds labelled values in the order they are charted
+type ChartData struct {
+	Labels []string
+	Values []float64
+}
+
+func (d ChartData) Total() float64 {
+	total := 0.0
+	for _, v := range d.Values {
+		total += v
+	}
+	return total
+}
+
+// chartCategory returns the sub-account of the prefix an account is summed up in
+func chartCategory(account, prefix string) string {
+	prefix = strings.TrimSuffix(prefix, ":")
+	if account == prefix {
+		segments := strings.Split(prefix, ":")
+		return segments[len(segments)-1]
+	}
+	return strings.SplitN(strings.TrimPrefix(account, prefix+":"), ":", 2)[0]
+}
+
+// ChartCurrency selects the currency to chart: The preferred one if it has been used, otherwise the most used one
+func ChartCurrency(entries []*helpers.BeancountEntry, prefix, preferred string) string {
+	totals := map[string]float64{}
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			if reportIncludes(p.Account, prefix) {
+				totals[p.Currency] += p.Amount * reportSign(p.Account)
+			}
+		}
+	}
+	if _, used := totals[preferred]; used || len(totals) == 0 {
+		return preferred
+	}
+	currency := ""
+	for c, total := range totals {
+		if currency == "" || total > totals[currency] || (total == totals[currency] && c < currency) {
+			currency = c
+		}
+	}
+	return currency
+}
+
+// ChartCategories sums the postings per sub-account of the prefix, largest first
+func ChartCategories(entries []*helpers.BeancountEntry, prefix, currency string) ChartData {
+	sums := map[string]float64{}
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			if p.Currency == currency && reportIncludes(p.Account, prefix) {
+				sums[chartCategory(p.Account, prefix)] += p.Amount * reportSign(p.Account)
+			}
+		}
+	}
+	categories := []string{}
+	for category, sum := range sums {
+		// Categories with refunds exceeding the spending can not be charted
+		if sum > 0 {
+			categories = append(categories, category)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if sums[categories[i]] != sums[categories[j]] {
+			return sums[categories[i]] > sums[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+	data := ChartData{}
+	other := 0.0
+	for i, category := range categories {
+		if i >= CHART_MAX_CATEGORIES-1 && len(categories) > CHART_MAX_CATEGORIES {
+			other += sums[category]
+			continue
+		}
+		data.Labels = append(data.Labels, category)
+		data.Values = append(data.Values, sums[category])
+	}
+	if other > 0 {
+		data.Labels = append(data.Labels, "Other")
+		data.Values = append(data.Values, other)
+	}
+	return data
+}
+
+// ChartOverTime sums the postings per day of the period, or per month for longer periods
+func ChartOverTime(entries []*helpers.BeancountEntry, prefix, currency string, period ReportPeriod) ChartData {
+	daily := period.To.Sub(period.From).Hours()/24 < CHART_MAX_DAILY_DAYS
+	bucketFormat := helpers.BEANCOUNT_DATE_FORMAT
+	labelFormat := "02"
+	if period.To.Sub(period.From).Hours()/24 < 7 {
+		labelFormat = "Mon 02"
+	}
+	if !daily {
+		bucketFormat = "2006-01"
+		labelFormat = "2006-01"
+	}
+	data := ChartData{}
+	buckets := map[string]int{}
+	for day := period.From; !day.After(period.To); day = day.AddDate(0, 0, 1) {
+		bucket := day.Format(bucketFormat)
+		if _, exists := buckets[bucket]; exists {
+			continue
+		}
+		buckets[bucket] = len(data.Values)
+		data.Labels = append(data.Labels, day.Format(labelFormat))
+		data.Values = append(data.Values, 0)
+	}
+	for _, entry := range entries {
+		date, err := time.Parse(helpers.BEANCOUNT_DATE_FORMAT, entry.Date)
+		if err != nil {
+			continue
+		}
+		i, inPeriod := buckets[date.Format(bucketFormat)]
+		if !inPeriod {
+			continue
+		}
+		for _, p := range entry.Postings {
+			if p.Currency == currency && reportIncludes(p.Account, prefix) {
+				data.Values[i] += p.Amount * reportSign(p.Account)
+			}
+		}
+	}
+	return data
+}
+
+// RenderChart renders the data as PNG image
+func RenderChart(kind, title string, data ChartData) ([]byte, error) {
+	values := []chart.Value{}
+	for i, label := range data.Labels {
+		values = append(values, chart.Value{Label: label, Value: data.Values[i]})
+	}
+	width := CHART_MIN_WIDTH
+	if kind != CHART_PIE && len(values)*50 > width {
+		width = len(values) * 50
+	}
+	var renderable interface {
+		Render(rp chart.RendererProvider, w io.Writer) error
+	}
+	switch kind {
+	case CHART_PIE:
+		for i := range values {
+			values[i].Label = fmt.Sprintf("%s (%.2f)", values[i].Label, values[i].Value)
+		}
+		renderable = chart.PieChart{
+			Title:  title,
+			Width:  width,
+			Height: CHART_HEIGHT,
+			Values: values,
+		}
+	case CHART_BAR, CHART_TIME:
+		// Days with refunds exceeding the spending are shown without spending. The range is set explicitly,
+		// as it can not be derived from bars all having the same value.
+		max := 0.0
+		for i := range values {
+			values[i].Value = math.Max(values[i].Value, 0)
+			max = math.Max(values[i].Value, max)
+		}
+		if max == 0 {
+			max = 1
+		}
+		barWidth := (width - 100) / len(values) * 2 / 3
+		renderable = chart.BarChart{
+			Title:      title,
+			Width:      width,
+			Height:     CHART_HEIGHT,
+			BarWidth:   barWidth,
+			Background: chart.Style{Padding: chart.Box{Top: 50}},
+			YAxis:      chart.YAxis{Range: &chart.ContinuousRange{Min: 0, Max: max}},
+			Bars:       values,
+		}
+	default:
+		return nil, fmt.Errorf("unknown chart type '%s'", kind)
+	}
+	buffer := &bytes.Buffer{}
+	err := renderable.Render(chart.PNG, buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (bc *BotController) commandChart(c tb.Context) error {
+	m := c.Message()
+	params := helpers.SplitQuotedCommand(m.Text)
+	if len(params) > 0 {
+		params = params[1:]
+	}
+	period, prefix, kind := "", "", ""
+	for _, param := range params {
+		target := &period
+		if param == CHART_BAR || param == CHART_PIE || param == CHART_TIME {
+			target = &kind
+		} else if BeancountAccountLike(param) {
+			target = &prefix
+		}
+		if *target != "" {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), CHART_USAGE, clearKeyboard())
+			return nil
+		}
+		*target = param
+	}
+	if period == "" {
+		period = REPORT_PERIOD_MONTH
+	}
+	if prefix == "" {
+		prefix = CHART_DEFAULT_ACCOUNT
+	}
+	if kind == "" {
+		kind = CHART_BAR
+	}
+	today := time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour)
+	current, _, err := ParseReportPeriod(period, today)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error reading your chart period: %s\n\n%s", err.Error(), CHART_USAGE), clearKeyboard())
+		return nil
+	}
+	entries, err := bc.reportEntries(m, current)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	currency := ChartCurrency(entries, prefix, bc.Repo.UserGetCurrency(m))
+	var data ChartData
+	if kind == CHART_TIME {
+		data = ChartOverTime(entries, prefix, currency, current)
+	} else {
+		data = ChartCategories(entries, prefix, currency)
+	}
+	if data.Total() <= 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("There is no spending to chart for %s in %s.", prefix, current), clearKeyboard())
+		return nil
+	}
+	title := fmt.Sprintf("%s in %s (%s)", prefix, current, currency)
+	image, err := RenderChart(kind, title, data)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not render chart: %s", err.Error())
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong rendering your chart: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), &tb.Photo{
+		File:    tb.FromReader(bytes.NewReader(image)),
+		Caption: fmt.Sprintf("%s: %.2f %s in total", title, data.Total(), currency),
+	}, clearKeyboard())
+	return nil
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func chartTestEntries(t *testing.T) []*helpers.BeancountEntry {
+	return parseReportTestEntries(t,
+		"2022-03-01 * \"Shop\" \"Groceries\"\n  Assets:Wallet -12.50 EUR\n  Expenses:Food:Groceries 12.50 EUR\n",
+		"2022-03-01 * \"\" \"Lunch\"\n  Assets:Wallet -8.00 EUR\n  Expenses:Food 8.00 EUR\n",
+		"2022-03-03 * \"\" \"Bus\"\n  Assets:Wallet -2.00 EUR\n  Expenses:Transport 2.00 EUR\n",
+		"2022-03-04 * \"\" \"Bus\"\n  Assets:Wallet -5.00 USD\n  Expenses:Transport 5.00 USD\n",
+		"2022-03-05 * \"\" \"Refund\"\n  Assets:Wallet 3.00 EUR\n  Expenses:Clothes -3.00 EUR\n",
+	)
+}
+
+func TestChartCategories(t *testing.T) {
+	entries := chartTestEntries(t)
+	data := ChartCategories(entries, "Expenses", "EUR")
+	helpers.TestExpectArrEq(t, data.Labels, []string{"Food", "Transport"}, "")
+	helpers.TestExpect(t, fmt.Sprintf("%v", data.Values), "[20.5 2]", "")
+
+	data = ChartCategories(entries, "Expenses:Food", "EUR")
+	helpers.TestExpectArrEq(t, data.Labels, []string{"Groceries", "Food"}, "")
+
+	many := []*helpers.BeancountEntry{}
+	for i := 1; i <= CHART_MAX_CATEGORIES+2; i++ {
+		many = append(many, parseReportTestEntries(t, fmt.Sprintf("2022-03-01 * \"\" \"\"\n  Assets:Wallet -%d EUR\n  Expenses:C%02d %d EUR\n", i, i, i))...)
+	}
+	data = ChartCategories(many, "Expenses", "EUR")
+	helpers.TestExpect(t, len(data.Labels), CHART_MAX_CATEGORIES, "")
+	helpers.TestExpect(t, data.Labels[CHART_MAX_CATEGORIES-1], "Other", "")
+	helpers.TestExpect(t, data.Values[CHART_MAX_CATEGORIES-1], 6.0, "three smallest categories should be summed up")
+	helpers.TestExpect(t, data.Total(), 78.0, "")
+}
+
+func TestChartOverTime(t *testing.T) {
+	entries := chartTestEntries(t)
+	week, _, _ := ParseReportPeriod("2022-02-28..2022-03-06", time.Now())
+	data := ChartOverTime(entries, "Expenses", "EUR", week)
+	helpers.TestExpectArrEq(t, data.Labels, []string{"Mon 28", "Tue 01", "Wed 02", "Thu 03", "Fri 04", "Sat 05", "Sun 06"}, "")
+	helpers.TestExpect(t, fmt.Sprintf("%v", data.Values), "[0 20.5 0 2 0 -3 0]", "")
+
+	year, _, _ := ParseReportPeriod(REPORT_PERIOD_YEAR, time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC))
+	data = ChartOverTime(entries, "Expenses", "EUR", year)
+	helpers.TestExpect(t, len(data.Labels), 12, "")
+	helpers.TestExpect(t, data.Labels[2], "2022-03", "")
+	helpers.TestExpect(t, data.Values[2], 19.5, "")
+}
+
+func TestChartCurrency(t *testing.T) {
+	entries := chartTestEntries(t)
+	helpers.TestExpect(t, ChartCurrency(entries, "Expenses", "USD"), "USD", "")
+	helpers.TestExpect(t, ChartCurrency(entries, "Expenses", "CHF"), "EUR", "most used currency should be used as fallback")
+	helpers.TestExpect(t, ChartCurrency(nil, "Expenses", "CHF"), "CHF", "")
+}
+
+func TestRenderChart(t *testing.T) {
+	data := ChartData{Labels: []string{"Food", "Transport"}, Values: []float64{20.5, 2}}
+	for _, kind := range []string{CHART_BAR, CHART_PIE, CHART_TIME} {
+		image, err := RenderChart(kind, "Expenses", data)
+		if err != nil {
+			t.Errorf("Rendering %s chart should not fail: %s", kind, err.Error())
+		}
+		helpers.TestExpect(t, bytes.HasPrefix(image, pngSignature), true, kind+" chart should be PNG")
+	}
+	for _, data := range []ChartData{
+		{Labels: []string{"Food"}, Values: []float64{20.5}},
+		{Labels: []string{"01", "02"}, Values: []float64{0, 0}},
+		{Labels: []string{"01", "02", "03"}, Values: []float64{12, -5, 12}},
+	} {
+		image, err := RenderChart(CHART_BAR, "Expenses", data)
+		if err != nil {
+			t.Errorf("Rendering bar chart of %v should not fail: %s", data.Values, err.Error())
+		}
+		helpers.TestExpect(t, bytes.HasPrefix(image, pngSignature), true, "")
+	}
+	image, err := RenderChart(CHART_PIE, "Expenses", ChartData{Labels: []string{"Food"}, Values: []float64{20.5}})
+	helpers.TestExpect(t, err, nil, "single category pie chart should render")
+	helpers.TestExpect(t, bytes.HasPrefix(image, pngSignature), true, "")
+
+	_, err = RenderChart("line", "Expenses", data)
+	if err == nil {
+		t.Errorf("Unknown chart type should fail")
+	}
+}
+
+func TestCommandChart(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_TZOFF).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(1, "2022-03-02 * \"Shop\" \"Groceries\"\n  Assets:Wallet -12.50 EUR\n  Expenses:Food 12.50 EUR\n", "2022-03-02T10:00:00Z", true))
+	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_CUR).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("EUR"))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandChart(&botTest.MockContext{M: &tb.Message{Text: "/chart pie 2022-03-01..2022-03-31", Chat: chat}})
+	photo, isPhoto := bot.LastSentWhat.(*tb.Photo)
+	if !isPhoto {
+		t.Fatalf("Expected chart to be sent as photo: %v", bot.LastSentWhat)
+	}
+	helpers.TestExpect(t, photo.Caption, "Expenses in 2022-03-01..2022-03-31 (EUR): 12.50 EUR in total", "")
+
+	bc.commandChart(&botTest.MockContext{M: &tb.Message{Text: "/chart pie bar", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /chart", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -22,7 +22,8 @@ func (bc *BotController) configHandler(m *tb.Message) {
 		Add("tz_offset", bc.configHandleTimezoneOffset).
 		Add("delete_account", bc.configHandleAccountDelete).
 		Add("omit_slash", bc.configHandleOmitLeadingSlash).
-		Add("enable_api", bc.configHandleEnableApi)
+		Add("enable_api", bc.configHandleEnableApi).
+		Add("auto_price", bc.configHandleAutoPrice)
 	_, err := sc.Handle(m)
 	if err != nil {
 		bc.configHelp(m, nil)
@@ -42,9 +43,10 @@ func (bc *BotController) configHelp(m *tb.Message, err error) {
 
 Tags will be added to each new transaction with a '#':
 
-/{{.CONFIG_COMMAND}} tag - Get currently set tag
-/{{.CONFIG_COMMAND}} tag off - Turn off tag
-/{{.CONFIG_COMMAND}} tag <name> - Set tag to apply to new transactions, e.g. when on vacation
+/{{.CONFIG_COMMAND}} tag - Get currently active tags
+/{{.CONFIG_COMMAND}} tag off - Turn off all tags
+/{{.CONFIG_COMMAND}} tag rm <name> - Turn off a single tag
+/{{.CONFIG_COMMAND}} tag <name> [until date] - Add a tag to apply to new transactions, e.g. when on vacation. It expires after the given date.
 
 Create a schedule to be notified of open transactions (i.e. not archived or deleted):
 
@@ -67,6 +69,11 @@ Feature toggle: Activate API / UI usage
 /{{.CONFIG_COMMAND}} enable_api - Get current setting value
 /{{.CONFIG_COMMAND}} enable_api on|off
 
+Feature toggle: Add the latest exchange rate (see /{{.RATES_COMMAND}}) as price to amounts in other currencies
+
+/{{.CONFIG_COMMAND}} auto_price - Get current setting value
+/{{.CONFIG_COMMAND}} auto_price on|off
+
 Additional information about this bot
 
 /{{.CONFIG_COMMAND}} about - Display the version this bot is running on
@@ -76,6 +83,7 @@ Reset your data stored by the bot. WARNING: This action is permanent!
 /{{.CONFIG_COMMAND}} delete_account yes - Permanently delete all account-related data
 `, map[string]interface{}{
 		"CONFIG_COMMAND": CMD_CONFIG,
+		"RATES_COMMAND":  CMD_RATES,
 		"TZ":             tz,
 	})
 	if err != nil {
@@ -106,33 +114,85 @@ func (bc *BotController) configHandleCurrency(m *tb.Message, params ...string) {
 }
 
 func (bc *BotController) configHandleTag(m *tb.Message, params ...string) {
+	today := time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour).Format(helpers.BEANCOUNT_DATE_FORMAT)
 	if len(params) == 0 {
-		// GET tag
-		tag := bc.Repo.UserGetTag(m)
-		if tag != "" {
-			bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("All new transactions automatically get the tag #%s added (vacation mode enabled)", tag))
-		} else {
+		// GET tags
+		tags, err := bc.Repo.GetTags(m, today)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "An error ocurred retrieving your tags: "+err.Error())
+			return
+		}
+		if len(tags) == 0 {
 			bc.Bot.SendSilent(bc.Logf, Recipient(m), "No tags are currently added to new transactions (vacation mode disabled).")
+			return
 		}
+		lines := []string{"All new transactions automatically get these tags added (vacation mode enabled):"}
+		for _, tag := range tags {
+			line := "#" + tag.Name
+			if tag.Expires != "" {
+				line += " (until " + tag.Expires + ")"
+			}
+			lines = append(lines, line)
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"))
 		return
-	} else if len(params) > 1 { // Only 0 or 1 allowed
+	} else if len(params) > 2 { // Only 0 to 2 allowed
 		bc.configHelp(m, fmt.Errorf("invalid amount of parameters specified"))
 		return
 	}
-	if params[0] == "off" {
-		// DELETE tag
-		bc.Repo.UserSetTag(m, "")
+	if params[0] == "off" && len(params) == 1 {
+		// DELETE all tags
+		err := bc.Repo.DeleteTags(m)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "An error ocurred removing the tags: "+err.Error())
+			return
+		}
 		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Disabled automatically set tags on new transactions")
 		return
 	}
-	// SET tag
-	tag := strings.TrimPrefix(params[0], "#")
-	err := bc.Repo.UserSetTag(m, tag)
+	if params[0] == "rm" && len(params) == 2 {
+		// DELETE single tag
+		tag := strings.TrimPrefix(params[1], "#")
+		count, err := bc.Repo.DeleteTag(m, tag)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "An error ocurred removing the tag: "+err.Error())
+			return
+		}
+		if count == 0 {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("The tag #%s is not added to new transactions.", tag))
+			return
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("New transactions no longer get the tag #%s added", tag))
+		return
+	}
+	// ADD tag
+	tag := &crud.Tag{Name: strings.TrimPrefix(params[0], "#")}
+	if !beancountTag.MatchString(tag.Name) {
+		bc.configHelp(m, fmt.Errorf("'%s' is no valid tag. Tags may only contain letters, numbers and '-', '_', '/' or '.'", tag.Name))
+		return
+	}
+	if len(params) == 2 {
+		expires, err := ParseDate(params[1])
+		if err != nil {
+			bc.configHelp(m, err)
+			return
+		}
+		if expires < today {
+			bc.configHelp(m, fmt.Errorf("the tag can not expire before today"))
+			return
+		}
+		tag.Expires = expires
+	}
+	err := bc.Repo.AddTag(m, tag)
 	if err != nil {
 		bc.Bot.SendSilent(bc.Logf, Recipient(m), "An error ocurred saving the tag: "+err.Error())
 		return
 	}
-	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("From now on all new transactions automatically get the tag #%s added (vacation mode enabled)", tag))
+	until := ""
+	if tag.Expires != "" {
+		until = " until " + tag.Expires
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("From now on all new transactions automatically get the tag #%s added%s (vacation mode enabled)", tag.Name, until))
 }
 
 func (bc *BotController) configHandleNotification(m *tb.Message, params ...string) {
@@ -268,6 +328,10 @@ func (bc *BotController) configHandleEnableApi(m *tb.Message, params ...string)
 	}
 }
 
+func (bc *BotController) configHandleAutoPrice(m *tb.Message, params ...string) {
+	bc.configHandleBooleanFeature(m, helpers.USERSET_AUTOPRICE, "Annotating prices", params...)
+}
+
 func (bc *BotController) configHandleBooleanFeature(m *tb.Message, key string, name string, params ...string) (state bool) {
 	var err error
 	if len(params) == 0 { // 0 params: GET
@@ -25,8 +25,16 @@ func (bc *BotController) DeleteUserData(m *tb.Message) {
 
 	errors.handle1(bc.Repo.UserSetNotificationSetting(m, -1, -1))
 
-	errors.handle2(bc.Repo.DeleteTransactions(m))
+	errors.handle2(bc.Repo.PurgeTransactions(m))
+	errors.handle1(bc.Repo.DeleteTransactionEvents(m))
+	errors.handle1(bc.Repo.DeleteArchiveBatches(m))
 	errors.handle1(bc.Repo.DeleteTemplates(m))
+	errors.handle1(bc.Repo.DeleteBudgets(m))
+	errors.handle1(bc.Repo.DeleteOpeningBalances(m))
+	errors.handle1(bc.Repo.DeleteImportSettings(m))
+	errors.handle1(bc.Repo.DeleteRates(m))
+	errors.handle1(bc.Repo.DeleteTrips(m))
+	errors.handle1(bc.Repo.DeleteTags(m))
 
 	errors.handle1(bc.Repo.DeleteAllUserSettings(m.Chat.ID))
 
@@ -28,7 +28,7 @@ func TestAccountDeletion(t *testing.T) {
 	if err != nil {
 		t.Errorf("Error encountered while issuing command: %e", err)
 	}
-	err = repo.RecordTransaction(chatId, "my awesome transaction")
+	err = repo.RecordTransaction(msg, "my awesome transaction")
 	if err != nil {
 		t.Errorf("Error encountered while issuing command: %e", err)
 	}
@@ -69,6 +69,10 @@ func TestAccountDeletion(t *testing.T) {
 	if err != nil || len(tx) > 0 {
 		t.Errorf("No more transactions should be found for user. Got: %d. Err: %e", len(hints), err)
 	}
+	events, err := repo.GetTransactionEvents(msg, 0, 0)
+	if err != nil || len(events) > 0 {
+		t.Errorf("No more transaction events should be found for user. Got: %d. Err: %e", len(events), err)
+	}
 	// Adding template for that user should fail now:
 	err = repo.AddTemplate(chatId, "awesome_template", "some template values")
 	if err == nil {
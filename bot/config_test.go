@@ -93,17 +93,27 @@ func TestConfigTag(t *testing.T) {
 	bc := NewBotController(db)
 	bot := &botTest.MockBot{}
 	bc.AddBotAndStart(bot)
+	today := time.Now().UTC().Format(helpers.BEANCOUNT_DATE_FORMAT)
+	expectTzOffset := func() {
+		mock.
+			ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+			WithArgs(chat.ID, helpers.USERSET_TZOFF).
+			WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	}
 
+	expectTzOffset()
 	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag invalid amount of parameters", Chat: chat}})
 	if !strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /config") {
 		t.Errorf("/config tag invalid amount of parameters: %s", bot.LastSentWhat)
 	}
 
-	// SET tag
+	// ADD tag
+	expectTzOffset()
 	mock.ExpectBegin()
-	mock.ExpectExec(`DELETE FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TAG).WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec(`INSERT INTO "bot::userSetting"`).
-		WithArgs(12345, helpers.USERSET_TAG, "vacation2021").
+	mock.ExpectExec(`DELETE FROM "bot::tag"`).WithArgs(12345, "vacation2021").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TAG, "vacation2021").WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectExec(`INSERT INTO "bot::tag"`).
+		WithArgs(12345, "vacation2021", nil).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag vacation2021", Chat: chat}})
@@ -114,23 +124,38 @@ func TestConfigTag(t *testing.T) {
 		t.Errorf("/config tag vacation2021 response did not contain set tag: %s", bot.LastSentWhat)
 	}
 
-	// GET tag
+	expectTzOffset()
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM "bot::tag"`).WithArgs(12345, "conference").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TAG, "conference").WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectExec(`INSERT INTO "bot::tag"`).
+		WithArgs(12345, "conference", "2999-01-31").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag #conference 2999-01-31", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "get the tag #conference added until 2999-01-31", "")
+
+	expectTzOffset()
+	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag conference 2000-01-31", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "the tag can not expire before today", "")
+	expectTzOffset()
+	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag no#tag", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "'no#tag' is no valid tag", "")
+
+	// GET tags
+	expectTzOffset()
 	mock.
-		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
-		WithArgs(chat.ID, helpers.USERSET_TAG).
-		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("vacation2021"))
+		ExpectQuery(`SELECT "tag", "expires" FROM "bot::tag"`).
+		WithArgs(chat.ID, today).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "expires"}).AddRow("conference", "2999-01-31").AddRow("vacation2021", nil))
 	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag", Chat: chat}})
-	if strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /config") {
-		t.Errorf("/config tag: %s", bot.LastSentWhat)
-	}
-	if !strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "get the tag #vacation2021 added") {
-		t.Errorf("/config tag vacation2021 response did not contain set tag: %s", bot.LastSentWhat)
-	}
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "All new transactions automatically get these tags added (vacation mode enabled):\n#conference (until 2999-01-31)\n#vacation2021", "")
 
+	expectTzOffset()
 	mock.
-		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
-		WithArgs(chat.ID, helpers.USERSET_TAG).
-		WillReturnRows(sqlmock.NewRows([]string{"tag"}).AddRow(nil))
+		ExpectQuery(`SELECT "tag", "expires" FROM "bot::tag"`).
+		WithArgs(chat.ID, today).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "expires"}))
 	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag", Chat: chat}})
 	if strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /config") {
 		t.Errorf("/config tag: %s", bot.LastSentWhat)
@@ -139,7 +164,23 @@ func TestConfigTag(t *testing.T) {
 		t.Errorf("/config tag vacation2021 response did not contain set tag: %s", bot.LastSentWhat)
 	}
 
-	// DELETE tag
+	// DELETE single tag
+	expectTzOffset()
+	mock.ExpectExec(`DELETE FROM "bot::tag"`).WithArgs(12345, "conference").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TAG, "conference").WillReturnResult(sqlmock.NewResult(1, 0))
+	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag rm conference", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "New transactions no longer get the tag #conference added", "")
+
+	// The tag setting is removed like any other tag
+	expectTzOffset()
+	mock.ExpectExec(`DELETE FROM "bot::tag"`).WithArgs(12345, "vacation").WillReturnResult(sqlmock.NewResult(1, 0))
+	mock.ExpectExec(`DELETE FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TAG, "vacation").WillReturnResult(sqlmock.NewResult(1, 1))
+	bc.commandConfig(&botTest.MockContext{M: &tb.Message{Text: "/config tag rm vacation", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "New transactions no longer get the tag #vacation added", "")
+
+	// DELETE all tags
+	expectTzOffset()
+	mock.ExpectExec(`DELETE FROM "bot::tag"`).WithArgs(12345).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectBegin()
 	mock.ExpectExec(`DELETE FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TAG).WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
@@ -38,6 +38,10 @@ type BotController struct {
 func (bc *BotController) ConfigureCronScheduler() *BotController {
 	s := gocron.NewScheduler(time.UTC)
 	s.Cron("0 * * * *").Do(bc.cronNotifications)
+	s.Cron("30 3 * * *").Do(bc.cronPruneSuggestions)
+	s.Cron("45 3 * * *").Do(bc.cronPurgeTrash)
+	s.Cron("50 3 * * *").Do(bc.cronPruneTags)
+	s.Cron("5 * * * *").Do(bc.cronEndTrips)
 	bc.CronScheduler = s
 	return bc
 }
@@ -54,6 +58,11 @@ func (bc *BotController) AddBotAndStart(b IBot) {
 	}
 
 	b.Handle(tb.OnText, bc.handleTextState)
+	b.Handle(tb.OnDocument, bc.handleDocument)
+	b.Handle("\f"+LIST_CALLBACK_UNIQUE, bc.handleListCallback)
+	b.Handle("\f"+DUPLICATE_CALLBACK_UNIQUE, bc.handleDuplicateCallback)
+	b.Handle("\f"+RECONCILE_CALLBACK_UNIQUE, bc.handleReconcileCallback)
+	b.Handle("\f"+PENDING_CALLBACK_UNIQUE, bc.handlePendingCallback)
 
 	bc.Logf(TRACE, nil, "Starting bot '%s'", b.Me().Username)
 
@@ -70,9 +79,25 @@ const (
 	CMD_HELP        = "help"
 	CMD_CANCEL      = "cancel"
 	CMD_SIMPLE      = "simple"
+	CMD_PASTE       = "paste"
 	CMD_LIST        = "list"
+	CMD_PENDING     = "pending"
+	CMD_FIND        = "find"
+	CMD_HISTORY     = "history"
+	CMD_REPORT      = "report"
+	CMD_BUDGET      = "budget"
+	CMD_RATES       = "rates"
+	CMD_TRIP        = "trip"
+	CMD_CHART       = "chart"
+	CMD_BALANCES    = "balances"
+	CMD_QUERY       = "query"
+	CMD_IMPORT      = "import"
+	CMD_RECONCILE   = "reconcile"
+	CMD_ARCHIVE     = "archive"
+	CMD_UNARCHIVE   = "unarchive"
 	CMD_ARCHIVE_ALL = "archiveAll"
 	CMD_DELETE_ALL  = "deleteAll"
+	CMD_TRASH       = "trash"
 	CMD_SUGGEST     = "suggestions"
 	CMD_CONFIG      = "config"
 
@@ -90,14 +115,30 @@ func (bc *BotController) commandMappings() []*CMD {
 		{CommandAlias: []string{CMD_HELP}, Handler: bc.commandHelp, Help: "List this command help"},
 		{CommandAlias: []string{CMD_START}, Handler: bc.commandStart, Help: "Give introduction into this bot"},
 		{CommandAlias: []string{CMD_CANCEL}, Handler: bc.commandCancel, Help: "Cancel any running commands or transactions"},
-		{CommandAlias: []string{CMD_SIMPLE}, Handler: bc.commandCreateSimpleTx, Help: "Record a simple transaction, defaults to today; Can be omitted by sending amount directy", Optional: []string{"date"}},
+		{CommandAlias: []string{CMD_SIMPLE}, Handler: bc.commandCreateSimpleTx, Help: "Record a simple transaction, defaults to today; Can be omitted by sending amount directy", Optional: []string{"date", "pending"}},
 		{CommandAlias: CMD_COMMENT, Handler: bc.commandAddComment, Help: "Add arbitrary text to transaction list"},
+		{CommandAlias: []string{CMD_PASTE}, Handler: bc.commandPaste, Help: "Record multiple beancount entries at once, sent as text or document"},
 		{CommandAlias: CMD_TEMPLATE, Handler: bc.commandTemplates, Help: "Create and use template transactions"},
-		{CommandAlias: []string{CMD_LIST}, Handler: bc.commandList, Help: "List your recorded transactions or remove entries", Optional: []string{"archived", "dated", "numbered", "rm <number>"}},
+		{CommandAlias: []string{CMD_LIST}, Handler: bc.commandList, Help: "List your recorded transactions, remove or reverse entries", Optional: []string{"archived", "dated", "sorted", "group:day|month", "all", "file [from:<date>] [to:<date>]", "numbered", "rm <number>", "reverse <number> [date] [refund]"}},
+		{CommandAlias: []string{CMD_PENDING}, Handler: bc.commandPending, Help: "List transactions not settled yet and confirm them", Optional: []string{"confirm <number>|all", "remind <days>|off"}},
+		{CommandAlias: []string{CMD_FIND}, Handler: bc.commandFind, Help: "Search open and archived transactions", Optional: []string{"text", "account:<account>", "tag:<tag>", "from:<date>", "to:<date>", "amount><number>"}},
+		{CommandAlias: []string{CMD_HISTORY}, Handler: bc.commandHistory, Help: "Show who changed which transactions", Optional: []string{"[archived] <number>"}},
+		{CommandAlias: []string{CMD_REPORT}, Handler: bc.commandReport, Help: "Total expenses and income per account", Optional: []string{"week|month|year|<from>..<to>", "account prefix"}},
+		{CommandAlias: []string{CMD_CHART}, Handler: bc.commandChart, Help: "Render your spending as chart image", Optional: []string{"week|month|year|<from>..<to>", "account prefix", "bar|pie|time"}},
+		{CommandAlias: []string{CMD_BALANCES}, Handler: bc.commandBalances, Help: "Show the current balances of your accounts", Optional: []string{"account prefix", "set <account> <amount> [currency]", "opening", "rm <account> [currency]"}},
+		{CommandAlias: []string{CMD_QUERY}, Handler: bc.commandQuery, Help: "Query your transactions using a subset of the beancount query language", Optional: []string{"SELECT ..."}},
+		{CommandAlias: []string{CMD_IMPORT}, Handler: bc.commandImport, Help: "Import a CSV statement of your bank", Optional: []string{"profile", "profile set|rm <name> ...", "profiles", "rule <account> <regex>", "rule rm <id>", "rules"}},
+		{CommandAlias: []string{CMD_RECONCILE}, Handler: bc.commandReconcile, Help: "Find your open transactions already contained in your ledger file and archive them"},
+		{CommandAlias: []string{CMD_BUDGET}, Handler: bc.commandBudget, Help: "Set budgets and check your spending against them", Optional: []string{"set <account> <amount> [currency] [weekly|monthly|yearly]", "list", "status", "rm <account> [currency]"}},
+		{CommandAlias: []string{CMD_TRIP}, Handler: bc.commandTrip, Help: "Tag transactions and use another currency while travelling", Optional: []string{"start <name> [currency] [until date]", "end", "report <name>", "list"}},
+		{CommandAlias: []string{CMD_RATES}, Handler: bc.commandRates, Help: "Maintain exchange rates to convert reports and annotate prices", Optional: []string{"set <currency> <quote currency> <rate> [date]", "rm <currency> <quote currency> [date]", "prices"}},
 		{CommandAlias: []string{CMD_SUGGEST}, Handler: bc.commandSuggestions, Help: "List, add or remove suggestions"},
 		{CommandAlias: []string{CMD_CONFIG}, Handler: bc.commandConfig, Help: "Bot configurations"},
+		{CommandAlias: []string{CMD_ARCHIVE}, Handler: bc.commandArchive, Help: "Archive selected transactions as a batch", Optional: []string{"<selection> [label:<label>]", "list", "get <batch>"}},
+		{CommandAlias: []string{CMD_UNARCHIVE}, Handler: bc.commandUnarchive, Help: "Move an archive batch back to your open transactions: /" + CMD_UNARCHIVE + " <batch>"},
 		{CommandAlias: []string{CMD_ARCHIVE_ALL}, Handler: bc.commandArchiveTransactions, Help: "Archive recorded transactions"},
-		{CommandAlias: []string{CMD_DELETE_ALL}, Handler: bc.commandDeleteTransactions, Help: "Permanently delete recorded transactions"},
+		{CommandAlias: []string{CMD_DELETE_ALL}, Handler: bc.commandDeleteTransactions, Help: "Move all recorded transactions to the trash"},
+		{CommandAlias: []string{CMD_TRASH}, Handler: bc.commandTrash, Help: "List deleted transactions or restore them", Optional: []string{"restore <number>", "empty"}},
 
 		{CommandAlias: []string{CMD_ADM_NOTIFY}, Handler: bc.commandAdminNofify, Help: "Send notification to user(s): /" + CMD_ADM_NOTIFY + " [chatId] \"<message>\""},
 		{CommandAlias: []string{CMD_ADM_CRON}, Handler: bc.commandAdminCronInfo, Help: "Check cron status"},
@@ -159,6 +200,10 @@ func (bc *BotController) commandCancel(c tb.Context) error {
 	if hasState {
 		if tx == ST_TPL {
 			msg = "Your currently running template creation has been cancelled."
+		} else if tx == ST_DOC {
+			msg = "Waiting for your file upload has been cancelled."
+		} else if tx == ST_IMP {
+			msg = "Categorising your imported statement has been cancelled. The remaining rows have not been recorded."
 		} else {
 			msg = "Your currently running transaction has been cancelled."
 		}
@@ -228,7 +273,7 @@ func (bc *BotController) commandAddComment(c tb.Context) error {
 	}
 	comment = strings.ReplaceAll(comment, "\\\"", "\"")
 
-	err := bc.Repo.RecordTransaction(c.Message().Chat.ID, comment+"\n")
+	err := bc.Repo.RecordTransaction(c.Message(), comment+"\n")
 	if err != nil {
 		bc.Logf(ERROR, c.Message(), "Something went wrong while recording the comment: "+err.Error())
 		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Something went wrong while recording your comment: "+err.Error(), clearKeyboard())
@@ -244,22 +289,68 @@ func (bc *BotController) commandList(c tb.Context) error {
 	isArchived := false
 	isDated := false
 	isNumbered := false
+	isAll := false
+	isFile := false
+	isSorted := false
+	grouping := ""
 	isDeleteCommand := false
+	isReverseCommand := false
+	isRefund := false
+	reverseDate := ""
 	elementNumber := -1
+	dateRange := crud.TransactionFilter{}
 	if len(command) > 1 {
 		for _, option := range command[1:] {
-			if option == "archived" {
+			if strings.HasPrefix(option, "from:") || strings.HasPrefix(option, "to:") {
+				parsed, err := ParseTransactionFilter([]string{option})
+				if err == nil {
+					if parsed.From != "" {
+						dateRange.From = parsed.From
+					} else {
+						dateRange.To = parsed.To
+					}
+					continue
+				}
+				bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("The option '%s' could not be recognized: %s", option, err.Error()), clearKeyboard())
+				return nil
+			} else if strings.HasPrefix(option, "group:") {
+				grouping = strings.TrimPrefix(option, "group:")
+				if grouping != LIST_GROUP_DAY && grouping != LIST_GROUP_MONTH {
+					bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("The option '%s' could not be recognized. Transactions can be grouped by '%s' or '%s'.", option, LIST_GROUP_DAY, LIST_GROUP_MONTH), clearKeyboard())
+					return nil
+				}
+				continue
+			} else if option == "archived" {
 				isArchived = true
 				continue
+			} else if option == "sorted" {
+				isSorted = true
+				continue
 			} else if option == "dated" {
 				isDated = true
 				continue
 			} else if option == "numbered" {
 				isNumbered = true
 				continue
+			} else if option == "all" {
+				isAll = true
+				continue
+			} else if option == "file" {
+				isFile = true
+				continue
 			} else if option == "rm" {
 				isDeleteCommand = true
 				continue
+			} else if option == "reverse" {
+				isReverseCommand = true
+				continue
+			} else if option == "refund" {
+				isRefund = true
+				continue
+			} else if elementNumber > 0 && reverseDate == "" {
+				// The booking date of a reversal follows the element number
+				reverseDate = option
+				continue
 			} else {
 				var err error
 				elementNumber, err = strconv.Atoi(option)
@@ -271,11 +362,58 @@ func (bc *BotController) commandList(c tb.Context) error {
 			}
 		}
 	}
-	if isDeleteCommand && (isNumbered || isDated || elementNumber <= 0) {
+	if isDeleteCommand && (isNumbered || isDated || isAll || isFile || elementNumber <= 0) {
 		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "For removing a single element from the list, determine it's number by sending the command '/list numbered' and then removing an entry by sending '/list rm <number>'.", clearKeyboard())
 		return nil
 	}
-	tx, err := bc.Repo.GetTransactions(c.Message(), isArchived)
+	if isReverseCommand && (isDeleteCommand || isNumbered || isDated || isAll || isFile || elementNumber <= 0) {
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("For reversing a transaction, e.g. for a refund, determine it's number by sending the command '/%s numbered' and then reverse it by sending '/%s reverse <number> [date] [refund]'.", CMD_LIST, CMD_LIST), clearKeyboard())
+		return nil
+	}
+	if !isReverseCommand && (isRefund || reverseDate != "") {
+		option := "refund"
+		if reverseDate != "" {
+			option = reverseDate
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("The option '%s' could not be recognized. Please try again with '/list', with options added to the end separated by space.", option), clearKeyboard())
+		return nil
+	}
+	if isReverseCommand && reverseDate != "" {
+		var err error
+		reverseDate, err = ParseDate(reverseDate)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "The date of the reversal could not be recognized: "+err.Error(), clearKeyboard())
+			return nil
+		}
+	}
+	if dateRange.From != "" && dateRange.To != "" && dateRange.From > dateRange.To {
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "The 'from' date must not be after the 'to' date.", clearKeyboard())
+		return nil
+	}
+	if (dateRange.From != "" || dateRange.To != "") && !isFile {
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Date ranges are only supported for files: '/%s file from:2022-01-01 to:2022-01-31'.", CMD_LIST), clearKeyboard())
+		return nil
+	}
+	if (isDeleteCommand || isReverseCommand) && grouping != "" {
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Grouping is not supported for single entries. Use e.g. '/%s sorted rm <number>' for numbers as seen in a sorted list.", CMD_LIST), clearKeyboard())
+		return nil
+	}
+	options := listPage{Archived: isArchived, Dated: isDated, Sorted: isSorted, Grouping: grouping}
+	if isFile {
+		bc.sendListFile(c.Message(), options, dateRange)
+		return nil
+	}
+	if !isDeleteCommand && !isReverseCommand && !isAll {
+		bc.sendListPage(c.Message(), options)
+		return nil
+	}
+	var tx []*crud.TransactionResult
+	var err error
+	if isSorted || grouping != "" {
+		tx, err = bc.Repo.FindTransactions(c.Message(), options.filter())
+	} else {
+		tx, err = bc.Repo.GetTransactions(c.Message(), isArchived)
+	}
 	if err != nil {
 		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
 		return nil
@@ -284,6 +422,14 @@ func (bc *BotController) commandList(c tb.Context) error {
 		bc.Logf(ERROR, c.Message(), "Tx unexpectedly was nil")
 		return nil
 	}
+	if isReverseCommand {
+		if elementNumber > len(tx) {
+			bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Something went wrong while trying to reverse a transaction: the number you specified was too high. Please use a correct number as seen from '/%s [archived] numbered'", CMD_LIST), clearKeyboard())
+			return nil
+		}
+		bc.reverseTransaction(c.Message(), tx[elementNumber-1], reverseDate, isRefund)
+		return nil
+	}
 	if isDeleteCommand {
 		var err error
 		if elementNumber <= len(tx) {
@@ -296,23 +442,44 @@ func (bc *BotController) commandList(c tb.Context) error {
 			bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Something went wrong while trying to delete a single transaction: "+err.Error(), clearKeyboard())
 			return nil
 		}
-		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Successfully deleted the list entry specified.", clearKeyboard())
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Moved the list entry specified to the /%s. It can be restored from there.", CMD_TRASH), clearKeyboard())
+		return nil
+	}
+	firstNumber := 0
+	if isNumbered {
+		firstNumber = 1
+	}
+	txList := GroupListEntries(tx, bc.formatListEntries(c.Message(), tx, isDated, firstNumber), grouping)
+	messageSplits := bc.MergeMessagesHonorSendLimit(txList, "\n")
+	if len(messageSplits) == 0 {
+		bc.sendEmptyListHint(c.Message(), isArchived)
 		return nil
 	}
+	if len(messageSplits) > LIST_MAX_MESSAGES {
+		bc.Logf(DEBUG, c.Message(), "List would need %d messages. Sending as file instead.", len(messageSplits))
+		bc.sendListDocument(c.Message(), txList, fmt.Sprintf("Your list of %d transactions is too long for messages. Here it is as file.", len(tx)))
+		return nil
+	}
+	for _, message := range messageSplits {
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), message, clearKeyboard())
+	}
+	return nil
+}
+
+// formatListEntries prepares transactions for listing. Entries are numbered starting at firstNumber if positive.
+func (bc *BotController) formatListEntries(m *tb.Message, tx []*crud.TransactionResult, isDated bool, firstNumber int) []string {
 	SEP := "\n"
 	txList := []string{}
-	txEntryNumber := 0
-	for _, t := range tx {
+	for i, t := range tx {
 		var dateComment string
-		txEntryNumber++
 		if isDated {
-			tzOffset := bc.Repo.UserGetTzOffset(c.Message())
+			tzOffset := bc.Repo.UserGetTzOffset(m)
 			timezoneOff := time.Duration(tzOffset) * time.Hour
 			// 2022-03-30T14:24:50.390084Z
 			dateParsed, err := time.Parse("2006-01-02T15:04:05Z", t.Date)
 			if err != nil {
-				bc.Logf(ERROR, c.Message(), "Parsing time failed: %s", err.Error())
-				bc.Logf(WARN, c.Message(), "Turning off dated option!")
+				bc.Logf(ERROR, m, "Parsing time failed: %s", err.Error())
+				bc.Logf(WARN, m, "Turning off dated option!")
 				isDated = false
 			} else {
 				date := dateParsed.Add(timezoneOff).Format(helpers.BEANCOUNT_DATE_FORMAT + " 15:04")
@@ -320,26 +487,47 @@ func (bc *BotController) commandList(c tb.Context) error {
 			}
 		}
 		numberPrefix := ""
-		if isNumbered {
-			numberPrefix = fmt.Sprintf("%d) ", txEntryNumber)
+		if firstNumber > 0 {
+			numberPrefix = fmt.Sprintf("%d) ", firstNumber+i)
 		}
-		txMessage := dateComment + numberPrefix + t.Tx
-		txList = append(txList, txMessage)
+		txList = append(txList, dateComment+numberPrefix+t.Tx)
 	}
-	messageSplits := bc.MergeMessagesHonorSendLimit(txList, "\n")
-	if len(messageSplits) == 0 {
-		archivedSuggestion := ""
-		if !isArchived {
-			archivedSuggestion = " archived"
+	return txList
+}
+
+// GroupListEntries inserts a separator comment before the entries of each day or month of booking.
+// Comments without booking date are grouped by the day they have been recorded. tx has to be sorted by booking date.
+func GroupListEntries(tx []*crud.TransactionResult, entries []string, grouping string) []string {
+	if grouping != LIST_GROUP_DAY && grouping != LIST_GROUP_MONTH {
+		return entries
+	}
+	grouped := []string{}
+	lastGroup := ""
+	for i, t := range tx {
+		date, _, ok := helpers.BookingInfo(t.Tx)
+		if !ok && len(t.Date) >= 10 {
+			date = t.Date[:10]
 		}
-		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Your transaction list is empty. Create some first. Check /%s for commands to create a transaction."+
-			"\nYou might also be looking for%s transactions using '/list%s'.", CMD_HELP, archivedSuggestion, archivedSuggestion), clearKeyboard())
-		return nil
+		group := date
+		if grouping == LIST_GROUP_MONTH && len(date) >= 7 {
+			group = date[:7]
+		}
+		if group != lastGroup {
+			grouped = append(grouped, fmt.Sprintf("; ---- %s ----", group))
+			lastGroup = group
+		}
+		grouped = append(grouped, entries[i])
 	}
-	for _, message := range messageSplits {
-		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), message, clearKeyboard())
+	return grouped
+}
+
+func (bc *BotController) sendEmptyListHint(m *tb.Message, isArchived bool) {
+	archivedSuggestion := ""
+	if !isArchived {
+		archivedSuggestion = " archived"
 	}
-	return nil
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Your transaction list is empty. Create some first. Check /%s for commands to create a transaction."+
+		"\nYou might also be looking for%s transactions using '/list%s'.", CMD_HELP, archivedSuggestion, archivedSuggestion), clearKeyboard())
 }
 
 func (bc *BotController) MergeMessagesHonorSendLimit(m []string, sep string) []string {
@@ -369,7 +557,7 @@ func (bc *BotController) commandArchiveTransactions(c tb.Context) error {
 		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Something went wrong archiving your transactions: "+err.Error())
 		return nil
 	}
-	bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Archived all transactions. Your /%s is empty again.", CMD_LIST), clearKeyboard())
+	bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Archived all transactions. Your /%s is empty again. See '/%s list' for your archive batches.", CMD_LIST, CMD_ARCHIVE), clearKeyboard())
 	return nil
 }
 
@@ -384,7 +572,7 @@ func (bc *BotController) commandDeleteTransactions(c tb.Context) error {
 		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Something went wrong deleting your transactions: "+err.Error())
 		return nil
 	}
-	bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Permanently deleted all your transactions. Your /%s is empty again.", CMD_LIST), clearKeyboard())
+	bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), fmt.Sprintf("Moved all your transactions to the /%s. Your /%s is empty again.", CMD_TRASH, CMD_LIST), clearKeyboard())
 	return nil
 }
 
@@ -446,9 +634,43 @@ func (bc *BotController) cronNotifications() {
 				"\n\nYou are getting this message because you enabled reminder notifications for open transactions in /config.", openCount, s, overdue))
 	}
 
+	bc.cronBudgetAlerts()
+	bc.cronPendingReminders()
+
 	bc.Logf(TRACE, nil, bc.cronInfo())
 }
 
+func (bc *BotController) cronPruneSuggestions() {
+	const ENV_SUGGESTIONS_PRUNE_DAYS = "SUGGESTIONS_PRUNE_DAYS"
+	setting := helpers.Env(ENV_SUGGESTIONS_PRUNE_DAYS)
+	if setting == "" {
+		return
+	}
+	days, err := strconv.Atoi(setting)
+	if err != nil || days <= 0 {
+		bc.Logf(ERROR, nil, "Invalid value for ENV var '%s' (expected positive number of days): '%s'", ENV_SUGGESTIONS_PRUNE_DAYS, setting)
+		return
+	}
+	bc.Logf(INFO, nil, "Running suggestions pruning job for suggestions unused for %d days.", days)
+	count, err := bc.Repo.PruneCache(days)
+	if err != nil {
+		bc.Logf(ERROR, nil, "Error pruning suggestions: %s", err.Error())
+		return
+	}
+	bc.Logf(INFO, nil, "Pruned %d unused suggestion(s).", count)
+}
+
+// cronPruneTags removes expired tags. Tags expire at the end of the day in the timezone of the user, so only tags
+// which expired before yesterday (UTC) are removed here and newer ones are filtered when reading them.
+func (bc *BotController) cronPruneTags() {
+	count, err := bc.Repo.PruneTags(time.Now().UTC().AddDate(0, 0, -1).Format(helpers.BEANCOUNT_DATE_FORMAT))
+	if err != nil {
+		bc.Logf(ERROR, nil, "Error pruning tags: %s", err.Error())
+		return
+	}
+	bc.Logf(INFO, nil, "Pruned %d expired tag(s).", count)
+}
+
 type ReceiverImpl struct {
 	ChatId string
 }
@@ -528,9 +750,13 @@ func (bc *BotController) matchesCommandWithoutLeadingSlash(m tb.Context) tb.Hand
 	if potentialCommandSplit == "" {
 		return nil
 	}
+	return bc.commandHandler(potentialCommandSplit)
+}
+
+func (bc *BotController) commandHandler(name string) tb.HandlerFunc {
 	for _, mapping := range bc.commandMappings() {
 		for _, command := range mapping.CommandAlias {
-			if command == potentialCommandSplit {
+			if command == name {
 				return mapping.Handler
 			}
 		}
@@ -584,11 +810,25 @@ func (bc *BotController) handleTextState(c tb.Context) error {
 		hint := tx.NextHint(bc.Repo, c.Message())
 		bc.sendNextTxHint(hint, c.Message())
 		return nil
+	} else if state == ST_DUP {
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "Please decide above whether to record your transaction anyway, or /cancel it.")
+		return nil
 	} else if state == ST_TPL {
 		if bc.processNewTemplateResponse(c.Message(), bc.State.tplStates[chatId(c.Message().Chat.ID)]) {
 			bc.State.Clear(c.Message())
 		}
 		return nil
+	} else if state == ST_DOC {
+		if bc.State.GetDocumentPurpose(c.Message()) == DOC_PASTE {
+			bc.State.Clear(c.Message())
+			bc.pasteEntries(c.Message(), c.Message().Text)
+			return nil
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(c.Message()), "I am waiting for you to send a file (as document). You can /cancel this operation.", clearKeyboard())
+		return nil
+	} else if state == ST_IMP {
+		bc.importHandleCategorisation(c.Message())
+		return nil
 	}
 	bc.Logf(ERROR, c.Message(), "Something went wrong processing text input. Ran to end, though should have been caught by a branch. "+
 		"Are there new state types not maintained yet?")
@@ -607,16 +847,30 @@ func clearKeyboard() *tb.ReplyMarkup {
 
 func (bc *BotController) finishTransaction(m *tb.Message, tx Tx) {
 	currency := bc.Repo.UserGetCurrency(m)
-	tag := bc.Repo.UserGetTag(m)
 	tzOffset := bc.Repo.UserGetTzOffset(m)
-	transaction, err := tx.FillTemplate(currency, tag, tzOffset)
+	tags := bc.Repo.UserGetTags(m, time.Now().UTC().Add(time.Duration(tzOffset)*time.Hour).Format(helpers.BEANCOUNT_DATE_FORMAT))
+	transaction, err := tx.FillTemplate(currency, tags, tzOffset)
 	if err != nil {
 		bc.Logf(ERROR, m, "Something went wrong while templating the transaction: "+err.Error())
 		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong while templating the transaction: "+err.Error(), clearKeyboard())
 		return
 	}
+	transaction = bc.annotatePrices(m, transaction, currency)
 
-	err = bc.Repo.RecordTransaction(m.Chat.ID, transaction)
+	duplicates, err := FindDuplicatesOf(bc.Repo, m, transaction)
+	if err != nil {
+		bc.Logf(ERROR, m, "Something went wrong while checking for duplicate transactions: %s", err.Error())
+	}
+	if len(duplicates) > 0 {
+		bc.warnDuplicateTransaction(m, &PendingTransaction{Tx: tx, Transaction: transaction}, duplicates)
+		return
+	}
+	bc.recordTransaction(m, tx, transaction)
+}
+
+// recordTransaction records the templated transaction and finishes the state of the chat
+func (bc *BotController) recordTransaction(m *tb.Message, tx Tx, transaction string) {
+	err := bc.Repo.RecordTransaction(m, transaction)
 	if err != nil {
 		bc.Logf(ERROR, m, "Something went wrong while recording the transaction: "+err.Error())
 		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong while recording your transaction: "+err.Error(), clearKeyboard())
@@ -630,7 +884,11 @@ func (bc *BotController) finishTransaction(m *tb.Message, tx Tx) {
 		// Don't return, instead continue flow (if recording was successful)
 	}
 
-	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Successfully recorded your transaction.\n"+
+	pendingHint := ""
+	if helpers.IsPending(transaction) {
+		pendingHint = fmt.Sprintf("It is flagged as pending. Confirm it with /%s once it is settled.\n", CMD_PENDING)
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Successfully recorded your transaction.\n"+pendingHint+
 		"You can get a list of all your transactions using /%s. "+
 		"With /%s you can delete all of them (e.g. once you copied them into your bookkeeping)."+
 		"\n\nYou can start a new transaction with /%s or type /%s to see all commands available.",
@@ -638,5 +896,7 @@ func (bc *BotController) finishTransaction(m *tb.Message, tx Tx) {
 		clearKeyboard(),
 	)
 
+	bc.checkBudgetAlerts(m)
+
 	bc.State.Clear(m)
 }
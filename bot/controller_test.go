@@ -19,6 +19,7 @@ import (
 func TestTextHandlingWithoutPriorState(t *testing.T) {
 	// create test dependencies
 	crud.TEST_MODE = true
+	botTest.FailOnErrorLogs(t)
 	chat := &tb.Chat{ID: 12345}
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -35,27 +36,42 @@ func TestTextHandlingWithoutPriorState(t *testing.T) {
 		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
 		WithArgs(chat.ID, helpers.USERSET_CUR).
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("TEST_CURRENCY"))
-	mock.
-		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
-		WithArgs(chat.ID, helpers.USERSET_TAG).
-		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("vacation2021"))
 	today := time.Now().Format(helpers.BEANCOUNT_DATE_FORMAT)
 	mock.
 		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
 		WithArgs(chat.ID, helpers.USERSET_TZOFF).
 		WillReturnRows(sqlmock.NewRows([]string{"value"}))
 	mock.
-		ExpectExec(`INSERT INTO "bot::transaction"`).
+		ExpectQuery(`SELECT "tag", "expires" FROM "bot::tag"`).
+		WithArgs(chat.ID, today).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "expires"}).AddRow("vacation2021", nil))
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_AUTOPRICE).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	// Duplicate check
+	amount := 17.34
+	mock.
+		ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WithArgs(chat.ID, dateOffset(today, -helpers.DUPLICATE_MAX_DAYS), dateOffset(today, helpers.DUPLICATE_MAX_DAYS), amount-0.005, amount+0.005).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}))
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(`INSERT INTO "bot::transaction"`).
 		WithArgs(chat.ID, today+` * "Buy something in the grocery store" #vacation2021
   Assets:Wallet                               -17.34 TEST_CURRENCY
   Expenses:Groceries
-`).
+`, today, 17.34, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 	// Cache handling on saving tx
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(chat.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 	mock.
 		ExpectExec(`INSERT INTO "bot::cache"`).
 		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
@@ -69,9 +85,14 @@ func TestTextHandlingWithoutPriorState(t *testing.T) {
 		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	// Budget alerts on saving tx
+	mock.
+		ExpectQuery(`SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod" FROM "bot::budget"`).
 		WithArgs(chat.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"}))
 
 	bc := NewBotController(db)
 	bot := &botTest.MockBot{}
@@ -103,6 +124,107 @@ func TestTextHandlingWithoutPriorState(t *testing.T) {
 	}
 }
 
+func TestAutoPriceAnnotation(t *testing.T) {
+	// create test dependencies
+	crud.TEST_MODE = true
+	botTest.FailOnErrorLogs(t)
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	// Create
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_CUR).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("EUR"))
+	// Finish
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_CUR).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("EUR"))
+	today := time.Now().Format(helpers.BEANCOUNT_DATE_FORMAT)
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_TZOFF).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.
+		ExpectQuery(`SELECT "tag", "expires" FROM "bot::tag"`).
+		WithArgs(chat.ID, today).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "expires"}))
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_AUTOPRICE).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("true"))
+	mock.
+		ExpectQuery(`SELECT "date", "base", "quote", "rate" FROM "bot::rate"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "base", "quote", "rate"}).
+			AddRow("2024-01-01", "USD", "EUR", 0.9).
+			AddRow("2024-02-01", "USD", "EUR", 0.92))
+	// Duplicate check
+	amount := 50.0
+	mock.
+		ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WithArgs(chat.ID, dateOffset(today, -helpers.DUPLICATE_MAX_DAYS), dateOffset(today, helpers.DUPLICATE_MAX_DAYS), amount-0.005, amount+0.005).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}))
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(`INSERT INTO "bot::transaction"`).
+		WithArgs(chat.ID, today+` * "Souvenir"
+  Assets:Wallet                               -50.00 USD @ 0.92 EUR
+  Expenses:Gifts
+`, today, 50.0, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// Cache handling on saving tx
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	// Budget alerts on saving tx
+	mock.
+		ExpectQuery(`SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod" FROM "bot::budget"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"}))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandCreateSimpleTx(&botTest.MockContext{M: &tb.Message{Chat: chat}})
+	tx := bc.State.txStates[12345]
+	tx.Input(&tb.Message{Text: "50 USD"})                                                        // amount
+	tx.Input(&tb.Message{Text: "Souvenir"})                                                      // description
+	tx.Input(&tb.Message{Text: "Assets:Wallet"})                                                 // from
+	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "Expenses:Gifts"}}) // to (via handleTextState)
+
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Successfully recorded your transaction.", "")
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestStartTransactionWithPlainAmountThousandsSeparated(t *testing.T) {
 	// create test dependencies
 	crud.TEST_MODE = true
@@ -140,10 +262,15 @@ func TestTransactionDeletion(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	mock.ExpectBegin()
 	mock.
-		ExpectExec(`DELETE FROM "bot::transaction" WHERE "tgChatId" = ?`).
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(`UPDATE "bot::transaction" SET "deleted" = CURRENT_TIMESTAMP WHERE "tgChatId" = \$1 AND "deleted" IS NULL`).
 		WithArgs(chat.ID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	bc := NewBotController(db)
 	bot := &botTest.MockBot{}
@@ -155,7 +282,7 @@ func TestTransactionDeletion(t *testing.T) {
 	}
 
 	bc.commandDeleteTransactions(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/deleteAll YeS"}})
-	if !strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "Permanently deleted all your transactions") {
+	if !strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "Moved all your transactions to the /trash") {
 		t.Errorf("Deletion should work with confirmation. Got: %s", bot.LastSentWhat)
 	}
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -192,7 +319,7 @@ func TestTransactionListMaxLength(t *testing.T) {
 	bc.AddBotAndStart(bot)
 
 	// < 4096 chars tx
-	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat}})
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list all"}})
 	if len(bot.AllLastSentWhat) != 1 {
 		t.Errorf("Expected exactly one message to be sent out: %v", bot.AllLastSentWhat)
 	}
@@ -200,7 +327,7 @@ func TestTransactionListMaxLength(t *testing.T) {
 	bot.Reset()
 
 	// > 4096 chars tx
-	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat}})
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list all"}})
 	if len(bot.AllLastSentWhat) != 2 {
 		t.Errorf("Expected exactly two messages to be sent out: %v", strings.Join(stringArr(bot.AllLastSentWhat), ", "))
 	}
@@ -234,7 +361,7 @@ func TestTransactionsListArchivedDated(t *testing.T) {
 		)
 	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TZOFF).WillReturnRows(mock.NewRows([]string{"value"}))
 
-	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/testListCommand(ignored) archived dated"}})
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/testListCommand(ignored) archived dated all"}})
 
 	if bot.LastSentWhat != "; recorded on 2022-03-30 14:24\ntx1\n; recorded on 2022-03-30 15:24\ntx2" {
 		t.Errorf("Expected last message to contain transactions:\n%v", bot.LastSentWhat)
@@ -249,7 +376,7 @@ func TestTransactionsListArchivedDated(t *testing.T) {
 		)
 	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(12345, helpers.USERSET_TZOFF).WillReturnRows(mock.NewRows([]string{"value"}))
 
-	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/testListCommand(ignored) archived dated"}})
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/testListCommand(ignored) archived dated all"}})
 
 	if bot.LastSentWhat != "tx1\ntx2" {
 		t.Errorf("Expected last message to contain transactions:\n%v", bot.LastSentWhat)
@@ -268,10 +395,15 @@ func TestWritingComment(t *testing.T) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(`INSERT INTO "bot::transaction"`).
+		WithArgs(chat.ID, "; This is a comment"+"\n", nil, nil, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.
-		ExpectExec(`INSERT INTO "bot::transaction"`).
-		WithArgs(chat.ID, "; This is a comment"+"\n").
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	bc := NewBotController(db)
 	bot := &botTest.MockBot{}
@@ -283,10 +415,15 @@ func TestWritingComment(t *testing.T) {
 	}
 
 	// Comment does not require quotes, as it only has a single parameter
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(`INSERT INTO "bot::transaction"`).
+		WithArgs(chat.ID, "This is another comment without \" (quotes)"+"\n", nil, nil, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
 	mock.
-		ExpectExec(`INSERT INTO "bot::transaction"`).
-		WithArgs(chat.ID, "This is another comment without \" (quotes)"+"\n").
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	bc.commandAddComment(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/c This is another comment without \\\" (quotes)"}})
 	if !strings.Contains(fmt.Sprintf("%v", bot.LastSentWhat), "added the comment") {
@@ -363,6 +500,7 @@ func TestCommandCancel(t *testing.T) {
 func TestTimezoneOffsetForAutomaticDate(t *testing.T) {
 	// create test dependencies
 	crud.TEST_MODE = true
+	botTest.FailOnErrorLogs(t)
 	chat := &tb.Chat{ID: 12345}
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -377,22 +515,63 @@ func TestTimezoneOffsetForAutomaticDate(t *testing.T) {
 		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
 		WithArgs(chat.ID, helpers.USERSET_CUR).
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("TEST_CURRENCY"))
-	mock.
-		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
-		WithArgs(chat.ID, helpers.USERSET_TAG).
-		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("vacation2021"))
 	yesterday_tzCorrection := time.Now().Add(-24 * time.Hour).Format(helpers.BEANCOUNT_DATE_FORMAT)
 	mock.
 		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
 		WithArgs(chat.ID, helpers.USERSET_TZOFF).
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("-24"))
 	mock.
-		ExpectExec(`INSERT INTO "bot::transaction"`).
+		ExpectQuery(`SELECT "tag", "expires" FROM "bot::tag"`).
+		WithArgs(chat.ID, yesterday_tzCorrection).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "expires"}).AddRow("vacation2021", nil))
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_AUTOPRICE).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	// Duplicate check
+	amount := 17.34
+	mock.
+		ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WithArgs(chat.ID, dateOffset(yesterday_tzCorrection, -helpers.DUPLICATE_MAX_DAYS), dateOffset(yesterday_tzCorrection, helpers.DUPLICATE_MAX_DAYS), amount-0.005, amount+0.005).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}))
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(`INSERT INTO "bot::transaction"`).
 		WithArgs(chat.ID, yesterday_tzCorrection+` * "Buy something in the grocery store" #vacation2021
   Assets:Wallet                               -17.34 TEST_CURRENCY
   Expenses:Groceries
-`).
+`, yesterday_tzCorrection, 17.34, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// Cache handling on saving tx
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	// Budget alerts on saving tx
+	mock.
+		ExpectQuery(`SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod" FROM "bot::budget"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"}))
 
 	bc := NewBotController(db)
 	bot := &botTest.MockBot{}
@@ -406,6 +585,11 @@ func TestTimezoneOffsetForAutomaticDate(t *testing.T) {
 	tx.Input(&tb.Message{Text: "Assets:Wallet"})                                                     // from
 	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "Expenses:Groceries"}}) // to (via handleTextState)
 
+	mock.
+		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
+		WithArgs(chat.ID, helpers.USERSET_OMITCMDSLASH).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("false"))
+
 	// After the first tx is done, send some command
 	m := &botTest.MockContext{M: &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}}
 	bc.handleTextState(m)
@@ -450,3 +634,9 @@ func TestCommandWithoutLeadingSlash(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+// dateOffset returns the date the number of days after date
+func dateOffset(date string, days int) string {
+	day, _ := time.Parse(helpers.BEANCOUNT_DATE_FORMAT, date)
+	return day.AddDate(0, 0, days).Format(helpers.BEANCOUNT_DATE_FORMAT)
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func (bc *BotController) documentHandlers() map[DocumentPurpose]func(m *tb.Message, params ...string) {
+	return map[DocumentPurpose]func(m *tb.Message, params ...string){
+		DOC_SUGGESTIONS: bc.suggestionsImportDocument,
+		DOC_IMPORT:      bc.importDocument,
+		DOC_RECONCILE:   bc.reconcileDocument,
+		DOC_PASTE:       bc.pasteDocument,
+	}
+}
+
+func (bc *BotController) handleDocument(c tb.Context) error {
+	m := c.Message()
+	caption := strings.TrimSpace(m.Caption)
+	if strings.HasPrefix(caption, "/") {
+		// Commands in captions are handled as if they had been sent with the document attached
+		command := strings.SplitN(strings.SplitN(strings.TrimPrefix(caption, "/"), " ", 2)[0], "@", 2)[0]
+		if handlerFunc := bc.commandHandler(command); handlerFunc != nil {
+			bc.Logf(TRACE, m, "Handling document with command caption: %s", caption)
+			m.Text = caption
+			return handlerFunc(c)
+		}
+	}
+
+	handler, exists := bc.documentHandlers()[bc.State.GetDocumentPurpose(m)]
+	if !exists {
+		if m.Sender != nil && crud.IsGroupChat(m) {
+			bc.Logf(DEBUG, m, "Received document without expecting one in a group chat. Ignoring.")
+			return nil
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("I did not expect a file from you. Please check /%s on which commands accept files.", CMD_HELP), clearKeyboard())
+		return nil
+	}
+	params := bc.State.GetDocumentParams(m)
+	bc.State.Clear(m)
+	handler(m, params...)
+	return nil
+}
+
+// attachedDocument returns the document sent with the message or the one the message replies to.
+func attachedDocument(m *tb.Message) *tb.Document {
+	if m.Document != nil {
+		return m.Document
+	}
+	if m.ReplyTo != nil && m.ReplyTo.Document != nil {
+		return m.ReplyTo.Document
+	}
+	return nil
+}
+
+// downloadDocument returns the content of the document, which must not be larger than maxSize bytes
+func (bc *BotController) downloadDocument(doc *tb.Document, maxSize int) (string, error) {
+	if doc.FileSize > int64(maxSize) {
+		return "", fmt.Errorf("the file is too large (%d bytes, at most %d bytes are supported)", doc.FileSize, maxSize)
+	}
+	reader, err := bc.Bot.File(&doc.File)
+	if err != nil {
+		return "", fmt.Errorf("downloading the file failed: %s", err.Error())
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(io.LimitReader(reader, int64(maxSize)+1))
+	if err != nil {
+		return "", fmt.Errorf("reading the file failed: %s", err.Error())
+	}
+	if len(content) > maxSize {
+		return "", fmt.Errorf("the file is too large (at most %d bytes are supported)", maxSize)
+	}
+	return string(content), nil
+}
+
+func documentFromString(fileName, content string) *tb.Document {
+	return &tb.Document{
+		File:     tb.FromReader(strings.NewReader(content)),
+		FileName: fileName,
+		MIME:     "text/plain",
+	}
+}
+
+// sendPreformatted sends the text as preformatted message. If it is too long for a message, it is sent as document instead.
+func (bc *BotController) sendPreformatted(m *tb.Message, text, fileName, caption string) {
+	message := "<pre>" + html.EscapeString(text) + "</pre>"
+	if len(message) <= helpers.TG_MAX_MSG_CHAR_LEN {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), message, clearKeyboard(), tb.ModeHTML)
+		return
+	}
+	doc := documentFromString(fileName, text)
+	doc.Caption = caption
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), doc, clearKeyboard())
+}
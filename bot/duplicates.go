@@ -0,0 +1,133 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	DUPLICATE_CALLBACK_UNIQUE = "dup"
+	DUPLICATE_ACTION_RECORD   = "rec"
+	DUPLICATE_ACTION_CANCEL   = "cancel"
+
+	// DUPLICATE_MAX_SHOWN is the number of possibly duplicated transactions shown in a warning
+	DUPLICATE_MAX_SHOWN = 3
+)
+
+// PendingTransaction is a finished transaction waiting to be confirmed, as it might duplicate a recorded one
+type PendingTransaction struct {
+	Tx          Tx
+	Transaction string
+}
+
+// FindDuplicateTransactions returns the open and archived transactions booked within h.DUPLICATE_MAX_DAYS days of the date,
+// with the same (largest) amount in the currency and a similar description
+func FindDuplicateTransactions(repo *crud.Repo, m *tb.Message, date string, amount float64, currency string, description string) ([]*crud.TransactionResult, error) {
+	day, err := time.Parse(h.BEANCOUNT_DATE_FORMAT, date)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := repo.FindTransactions(m, crud.TransactionFilter{
+		From: day.AddDate(0, 0, -h.DUPLICATE_MAX_DAYS).Format(h.BEANCOUNT_DATE_FORMAT),
+		To:   day.AddDate(0, 0, h.DUPLICATE_MAX_DAYS).Format(h.BEANCOUNT_DATE_FORMAT),
+		Amounts: []crud.AmountCondition{
+			{Operator: ">=", Value: amount - 0.005},
+			{Operator: "<=", Value: amount + 0.005},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	duplicates := []*crud.TransactionResult{}
+	for _, candidate := range candidates {
+		entry, err := h.ParseBeancountEntry(candidate.Tx)
+		if err != nil {
+			continue
+		}
+		if entry.HasAmount(amount, currency) && h.SimilarDescriptions(entry.Description(), description) {
+			duplicates = append(duplicates, candidate)
+		}
+	}
+	return duplicates, nil
+}
+
+// FindDuplicatesOf returns the recorded transactions the transaction might duplicate. Entries which are no transactions have none.
+func FindDuplicatesOf(repo *crud.Repo, m *tb.Message, transaction string) ([]*crud.TransactionResult, error) {
+	entry, err := h.ParseBeancountEntry(transaction)
+	if err != nil {
+		return nil, nil
+	}
+	amount, currency := entry.MaxAbsAmount(), ""
+	for _, p := range entry.Postings {
+		if math.Abs(p.Amount) == amount {
+			currency = p.Currency
+			break
+		}
+	}
+	return FindDuplicateTransactions(repo, m, entry.Date, amount, currency, entry.Description())
+}
+
+// formatDuplicates lists the transactions, shortened to DUPLICATE_MAX_SHOWN
+func formatDuplicates(duplicates []*crud.TransactionResult) string {
+	shown := []string{}
+	for i, duplicate := range duplicates {
+		if i >= DUPLICATE_MAX_SHOWN {
+			shown = append(shown, fmt.Sprintf("... and %d more", len(duplicates)-DUPLICATE_MAX_SHOWN))
+			break
+		}
+		shown = append(shown, strings.TrimSpace(duplicate.Tx))
+	}
+	return strings.Join(shown, "\n\n")
+}
+
+func (bc *BotController) warnDuplicateTransaction(m *tb.Message, pending *PendingTransaction, duplicates []*crud.TransactionResult) {
+	bc.State.StartDuplicate(m, pending)
+	markup := &tb.ReplyMarkup{}
+	markup.Inline(markup.Row(
+		markup.Data("Record anyway", DUPLICATE_CALLBACK_UNIQUE, DUPLICATE_ACTION_RECORD),
+		markup.Data("Cancel", DUPLICATE_CALLBACK_UNIQUE, DUPLICATE_ACTION_CANCEL),
+	))
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Your transaction looks like %d transaction(s) you have already recorded:\n\n%s\n\nDo you want to record it anyway?",
+		len(duplicates), formatDuplicates(duplicates)), markup)
+}
+
+func (bc *BotController) handleDuplicateCallback(c tb.Context) error {
+	m := c.Message()
+	action := c.Callback().Data
+	bc.Logf(TRACE, m, "Handling duplicate callback '%s'", action)
+	pending := bc.State.GetDuplicate(m)
+	if pending == nil {
+		return c.Respond(&tb.CallbackResponse{Text: "This transaction has already been handled."})
+	}
+	if c.Callback().Sender != nil {
+		// The warning has been sent by the bot. The transaction is recorded by the user pressing the button.
+		actor := *m
+		actor.Sender = c.Callback().Sender
+		m = &actor
+	}
+	text := ""
+	switch action {
+	case DUPLICATE_ACTION_RECORD:
+		text = "Recording the transaction despite the possible duplicate."
+	case DUPLICATE_ACTION_CANCEL:
+		text = "The transaction has been cancelled and not recorded."
+	default:
+		return c.Respond(&tb.CallbackResponse{Text: "Unknown action: " + action})
+	}
+	_, err := bc.Bot.Edit(c.Message(), text, &tb.ReplyMarkup{})
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not update duplicate warning: %s", err.Error())
+	}
+	if action == DUPLICATE_ACTION_RECORD {
+		bc.recordTransaction(m, pending.Tx, pending.Transaction)
+	} else {
+		bc.State.Clear(m)
+	}
+	return c.Respond(&tb.CallbackResponse{})
+}
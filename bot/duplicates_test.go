@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestFinishTransactionDuplicate(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -260}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	_, err := bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	defer bc.DeleteUserData(m)
+
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-02 * \"Dinner at Luigi\"\n  Assets:Cash  -42.00 EUR\n  Expenses:Food\n"))
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-10 * \"Luigi dinner\"\n  Assets:Cash  -42.00 EUR\n  Expenses:Food\n"))
+	duplicates, err := FindDuplicateTransactions(bc.Repo, m, "2024-01-04", 42, "EUR", "luigi")
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(duplicates), 1, "only transactions within two days should be found")
+	duplicates, _ = FindDuplicateTransactions(bc.Repo, m, "2024-01-03", 42.5, "EUR", "luigi")
+	helpers.TestExpect(t, len(duplicates), 0, "the amount should match")
+	duplicates, _ = FindDuplicateTransactions(bc.Repo, m, "2024-01-03", 42, "USD", "luigi")
+	helpers.TestExpect(t, len(duplicates), 0, "the currency should match")
+
+	finish := func() {
+		tx, err := ImportTx(&ImportRow{Date: "2024-01-03", Amount: -42, Currency: "EUR", Payee: "Luigi"}, "Assets:Card", "Expenses:Food")
+		botTest.HandleErr(t, err)
+		bc.finishTransaction(m, tx)
+	}
+	callback := func(action string) {
+		bc.handleDuplicateCallback(&botTest.MockContext{M: &tb.Message{Chat: chat}, C: &tb.Callback{Sender: m.Sender, Data: action}})
+	}
+	countTx := func() int {
+		tx, err := bc.Repo.GetTransactions(m, false)
+		botTest.HandleErr(t, err)
+		return len(tx)
+	}
+
+	finish()
+	helpers.TestExpect(t, bc.State.GetType(m), ST_DUP, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Your transaction looks like 1 transaction(s) you have already recorded:\n\n2024-01-02 * \"Dinner at Luigi\"", "")
+	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Text: "Expenses:Food", Chat: chat, Sender: m.Sender}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Please decide above", "")
+	callback(DUPLICATE_ACTION_CANCEL)
+	helpers.TestExpect(t, bc.State.GetType(m), ST_NONE, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastEditedWhat), "has been cancelled", "")
+	helpers.TestExpect(t, countTx(), 2, "")
+
+	finish()
+	callback(DUPLICATE_ACTION_RECORD)
+	helpers.TestExpect(t, bc.State.GetType(m), ST_NONE, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Successfully recorded your transaction", "")
+	helpers.TestExpect(t, countTx(), 3, "")
+	callback(DUPLICATE_ACTION_RECORD)
+	helpers.TestExpect(t, countTx(), 3, "a pending transaction should only be recorded once")
+}
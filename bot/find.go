@@ -0,0 +1,110 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const FIND_USAGE = `Usage help for /find:
+/find <text> [account:<account>] [tag:<tag>] [from:<date>] [to:<date>] [amount<op><number>]
+
+All criteria have to match. Open and archived transactions are searched.
+Dates are inclusive and formatted like 2022-01-31.
+Accounts match their sub-accounts as well, tags have to match as a whole.
+Amount compares the largest absolute posting amount of a transaction (regardless of sign and currency) using one of >, >=, <, <= or =.
+
+Example: /find groceries account:Expenses:Food from:2022-01-01 amount>50`
+
+func (bc *BotController) commandFind(c tb.Context) error {
+	m := c.Message()
+	params := helpers.SplitQuotedCommand(m.Text)
+	if len(params) > 0 && strings.HasPrefix(params[0], "/") {
+		params = params[1:]
+	}
+	if len(params) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), FIND_USAGE, clearKeyboard())
+		return nil
+	}
+	filter, err := ParseTransactionFilter(params)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error reading your search: %s\n\n%s", err.Error(), FIND_USAGE), clearKeyboard())
+		return nil
+	}
+	tx, err := bc.Repo.FindTransactions(m, filter)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong searching your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	if len(tx) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No transactions matched your search.", clearKeyboard())
+		return nil
+	}
+	txList := []string{fmt.Sprintf("Found %d transaction(s):\n", len(tx))}
+	for _, t := range tx {
+		entry := t.Tx
+		if t.Archived {
+			entry = "; archived\n" + entry
+		}
+		txList = append(txList, entry)
+	}
+	for _, message := range bc.MergeMessagesHonorSendLimit(txList, "\n") {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), message, clearKeyboard())
+	}
+	return nil
+}
+
+// ParseTransactionFilter reads search criteria like 'tag:trip' or 'amount>50'. Other terms are searched as text.
+func ParseTransactionFilter(params []string) (crud.TransactionFilter, error) {
+	filter := crud.TransactionFilter{}
+	for _, param := range params {
+		key, value, hasKey := strings.Cut(param, ":")
+		switch {
+		case hasKey && key == "account":
+			filter.Accounts = append(filter.Accounts, value)
+		case hasKey && key == "tag":
+			filter.Tags = append(filter.Tags, strings.TrimPrefix(value, "#"))
+		case hasKey && (key == "from" || key == "to"):
+			if _, err := time.Parse(helpers.BEANCOUNT_DATE_FORMAT, value); err != nil {
+				return filter, fmt.Errorf("'%s' is no valid date (expected format: %s)", value, helpers.BEANCOUNT_DATE_FORMAT)
+			}
+			if key == "from" {
+				filter.From = value
+			} else {
+				filter.To = value
+			}
+		case strings.HasPrefix(param, "amount"):
+			condition, err := ParseAmountCondition(strings.TrimPrefix(param, "amount"))
+			if err != nil {
+				return filter, err
+			}
+			filter.Amounts = append(filter.Amounts, condition)
+		default:
+			filter.Texts = append(filter.Texts, param)
+		}
+	}
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		return filter, fmt.Errorf("the 'from' date must not be after the 'to' date")
+	}
+	return filter, nil
+}
+
+// ParseAmountCondition reads comparisons like '>50' or '<=12.5'
+func ParseAmountCondition(s string) (crud.AmountCondition, error) {
+	for _, operator := range crud.AMOUNT_OPERATORS {
+		if !strings.HasPrefix(s, operator) {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimPrefix(s, operator), 64)
+		if err != nil {
+			return crud.AmountCondition{}, fmt.Errorf("'%s' is no valid amount", strings.TrimPrefix(s, operator))
+		}
+		return crud.AmountCondition{Operator: operator, Value: value}, nil
+	}
+	return crud.AmountCondition{}, fmt.Errorf("amount comparison '%s' must start with one of %v", s, crud.AMOUNT_OPERATORS)
+}
@@ -0,0 +1,64 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestParseTransactionFilter(t *testing.T) {
+	filter, err := ParseTransactionFilter([]string{"groceries", "account:Expenses:Food", "tag:#trip", "from:2022-01-01", "to:2022-12-31", "amount>=50", "amount<100.5"})
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpectArrEq(t, filter.Texts, []string{"groceries"}, "")
+	helpers.TestExpectArrEq(t, filter.Accounts, []string{"Expenses:Food"}, "")
+	helpers.TestExpectArrEq(t, filter.Tags, []string{"trip"}, "")
+	helpers.TestExpect(t, filter.From, "2022-01-01", "")
+	helpers.TestExpect(t, filter.To, "2022-12-31", "")
+	helpers.TestExpect(t, fmt.Sprintf("%v", filter.Amounts), "[{>= 50} {< 100.5}]", "")
+	helpers.TestExpect(t, filter.Archived == nil, true, "open and archived should be searched")
+
+	for _, invalid := range []string{"from:2022-13-01", "amount50", "amount>abc"} {
+		_, err = ParseTransactionFilter([]string{invalid})
+		helpers.TestExpect(t, err != nil, true, invalid)
+	}
+	_, err = ParseTransactionFilter([]string{"from:2022-02-01", "to:2022-01-01"})
+	helpers.TestExpect(t, err != nil, true, "from after to")
+}
+
+func TestCommandFind(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.
+		ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WithArgs(chat.ID, "%groceries%", "% #trip %").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(1, "2022-01-01 * \"Groceries\" #trip", "2022-01-01T10:00:00Z", true).
+			AddRow(2, "2022-01-02 * \"More groceries\" #trip", "2022-01-02T10:00:00Z", false))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandFind(&botTest.MockContext{M: &tb.Message{Text: "/find", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /find", "")
+
+	bc.commandFind(&botTest.MockContext{M: &tb.Message{Text: "/find amount~5", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Error reading your search", "")
+
+	bc.commandFind(&botTest.MockContext{M: &tb.Message{Text: "/find Groceries tag:trip", Chat: chat}})
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "Found 2 transaction(s):\n\n; archived\n2022-01-01 * \"Groceries\" #trip\n2022-01-02 * \"More groceries\" #trip", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// HISTORY_RECENT_EVENTS is the number of events shown by /history without a transaction number
+const HISTORY_RECENT_EVENTS = 20
+
+func (bc *BotController) commandHistory(c tb.Context) error {
+	m := c.Message()
+	params := h.SplitQuotedCommand(m.Text)
+	if len(params) > 0 {
+		params = params[1:]
+	}
+	// The transactions are numbered like in the list with the same options
+	page := listPage{}
+	for len(params) > 0 && (params[0] == "archived" || params[0] == "sorted") {
+		page.Archived = page.Archived || params[0] == "archived"
+		page.Sorted = page.Sorted || params[0] == "sorted"
+		params = params[1:]
+	}
+	if len(params) == 0 && page == (listPage{}) {
+		events, err := bc.Repo.GetTransactionEvents(m, 0, HISTORY_RECENT_EVENTS)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving the history: "+err.Error(), clearKeyboard())
+			return nil
+		}
+		bc.sendHistory(m, events, fmt.Sprintf("The %d most recent changes to your transactions (most recent first):\n", HISTORY_RECENT_EVENTS))
+		return nil
+	}
+	number := 0
+	var err error
+	if len(params) == 1 {
+		number, err = strconv.Atoi(params[0])
+	}
+	if len(params) != 1 || err != nil || number < 1 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Usage help for /%s:\n/%s - Show the most recent changes to your transactions\n/%s [archived] [sorted] <number> - Show all changes to a transaction, using its number as seen in '/%s [archived] [sorted] numbered'", CMD_HISTORY, CMD_HISTORY, CMD_HISTORY, CMD_LIST), clearKeyboard())
+		return nil
+	}
+	tx, err := bc.Repo.FindTransactions(m, page.filter())
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	if number > len(tx) {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("The number you specified was too high. Please use a correct number as seen from '/%s [archived] [sorted] numbered'.", CMD_LIST), clearKeyboard())
+		return nil
+	}
+	events, err := bc.Repo.GetTransactionEvents(m, tx[number-1].Id, 0)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving the history: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	bc.sendHistory(m, events, fmt.Sprintf("Changes to transaction %d (most recent first):\n", number))
+	return nil
+}
+
+func (bc *BotController) sendHistory(m *tb.Message, events []*crud.TransactionEvent, header string) {
+	if len(events) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No changes have been recorded yet.", clearKeyboard())
+		return
+	}
+	entries := []string{header}
+	for _, event := range events {
+		entries = append(entries, FormatTransactionEvent(event))
+	}
+	for _, message := range bc.MergeMessagesHonorSendLimit(entries, "\n") {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), message, clearKeyboard())
+	}
+}
+
+// FormatTransactionEvent describes who changed what. Values are only shown if they have been changed.
+func FormatTransactionEvent(event *crud.TransactionEvent) string {
+	actor := "via " + event.Source
+	if event.UserId != 0 {
+		actor = fmt.Sprintf("by user %d %s", event.UserId, actor)
+	}
+	s := fmt.Sprintf("%s: %s (#%d) %s", formatBatchDate(event.Created), event.Event, event.TransactionId, actor)
+	if event.Before == event.After {
+		// E.g. archiving does not change the transaction
+		if event.Before != "" {
+			s += "\nvalue:\n" + event.Before
+		}
+		return s
+	}
+	if event.Before != "" {
+		s += "\nbefore:\n" + event.Before
+	}
+	if event.After != "" {
+		s += "\nafter:\n" + event.After
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestFormatTransactionEvent(t *testing.T) {
+	helpers.TestExpect(t, FormatTransactionEvent(&crud.TransactionEvent{TransactionId: 3, Event: crud.EVENT_CREATE, After: "tx", Source: crud.EVENT_SOURCE_BOT, UserId: 42, Created: "2022-04-02T08:00:00Z"}),
+		"2022-04-02 08:00:00: create (#3) by user 42 via bot\nafter:\ntx", "")
+	helpers.TestExpect(t, FormatTransactionEvent(&crud.TransactionEvent{TransactionId: 3, Event: crud.EVENT_ARCHIVE, Before: "tx", After: "tx", Source: crud.ApiEventSource("1a2b3c4d"), Created: "2022-04-02T08:00:00Z"}),
+		"2022-04-02 08:00:00: archive (#3) via api:1a2b3c4d\nvalue:\ntx", "")
+}
+
+func TestCommandHistory(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	eventColumns := []string{"id", "transactionId", "event", "before", "after", "source", "tgUserId", "created"}
+	mock.ExpectQuery(`SELECT "id", "transactionId", "event", "before", "after", "source", "tgUserId", "created" FROM "bot::transactionEvent"`).
+		WithArgs(chat.ID, HISTORY_RECENT_EVENTS).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(2, 11, crud.EVENT_DELETE, "tx11", nil, crud.EVENT_SOURCE_BOT, 42, "2022-04-02T08:00:00Z"))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction" WHERE .* ORDER BY "created" ASC`).WithArgs(chat.ID, true).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).AddRow(12, "tx12", "2022-03-30T14:24:50Z", true))
+	mock.ExpectQuery(`SELECT "id", "transactionId", "event", "before", "after", "source", "tgUserId", "created" FROM "bot::transactionEvent"`).
+		WithArgs(chat.ID, 12).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(1, 12, crud.EVENT_CREATE, nil, "tx12", crud.EVENT_SOURCE_BOT, nil, "2022-03-30T14:24:50Z"))
+
+	// Numbered by booking date like in '/list sorted numbered'
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction" WHERE .* ORDER BY COALESCE\("bookingDate", DATE\("created"\)\) ASC`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(14, "2022-03-01 * \"Back-dated\"", "2022-03-31T14:24:50Z", false).
+			AddRow(13, "2022-03-30 * \"Earlier recorded\"", "2022-03-30T14:24:50Z", false))
+	mock.ExpectQuery(`SELECT "id", "transactionId", "event", "before", "after", "source", "tgUserId", "created" FROM "bot::transactionEvent"`).
+		WithArgs(chat.ID, 13).
+		WillReturnRows(sqlmock.NewRows(eventColumns).
+			AddRow(3, 13, crud.EVENT_ARCHIVE, "tx13", "tx13", crud.ApiEventSource("1a2b3c4d"), nil, "2022-03-31T08:00:00Z"))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandHistory(&botTest.MockContext{M: &tb.Message{Text: "/history", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "2022-04-02 08:00:00: delete (#11) by user 42 via bot\nbefore:\ntx11", "")
+
+	bc.commandHistory(&botTest.MockContext{M: &tb.Message{Text: "/history archived 1", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Changes to transaction 1 (most recent first):\n\n2022-03-30 14:24:50: create (#12) via bot\nafter:\ntx12", "")
+
+	bc.commandHistory(&botTest.MockContext{M: &tb.Message{Text: "/history sorted 2", Chat: chat}})
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "Changes to transaction 2 (most recent first):\n\n2022-03-31 08:00:00: archive (#13) via api:1a2b3c4d\nvalue:\ntx13", "")
+
+	bc.commandHistory(&botTest.MockContext{M: &tb.Message{Text: "/history archived", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /history", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
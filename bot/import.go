@@ -0,0 +1,552 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	IMPORT_SKIP = "skip"
+	// IMPORT_MAX_WARNINGS is the number of unreadable lines listed after an import
+	IMPORT_MAX_WARNINGS = 10
+)
+
+// ImportSession holds the imported rows not matching any rule, to be categorised one after another
+type ImportSession struct {
+	Account string
+	Tags    []string
+	Rows    []*ImportRow
+	// Duplicates holds the rows which might have been recorded before
+	Duplicates map[*ImportRow]*ImportDuplicate
+	// Uncategorised is the number of rows to categorise initially
+	Uncategorised int
+	Recorded      int
+	Skipped       int
+}
+
+// ImportRuleAccount returns the account of the first rule matching the payee or memo of the row, or an empty string
+func ImportRuleAccount(rules []*crud.ImportRule, row *ImportRow) string {
+	for _, rule := range rules {
+		re, err := regexp.Compile("(?i)" + rule.Pattern)
+		if err != nil {
+			continue
+		}
+		if re.MatchString(row.Payee) || re.MatchString(row.Memo) {
+			return rule.Account
+		}
+	}
+	return ""
+}
+
+// ImportTx fills the default template with the statement row of the account. counterAccount is the other side of the booking.
+func ImportTx(row *ImportRow, account, counterAccount string) (Tx, error) {
+	tx, err := CreateSimpleTx(row.Currency, TEMPLATE_SIMPLE_DEFAULT)
+	if err != nil {
+		return nil, err
+	}
+	from, to := account, counterAccount
+	if row.Amount > 0 {
+		from, to = counterAccount, account
+	}
+	description := strings.ReplaceAll(row.Description(), "\"", "'")
+	_, err = tx.SetDate(row.Date)
+	if err != nil {
+		return nil, err
+	}
+	simpleTx := tx.(*SimpleTx)
+	simpleTx.data[h.FqCacheKey(h.FIELD_DESCRIPTION)] = description
+	simpleTx.data[h.FqCacheKey(h.FIELD_AMOUNT)] = FORMATTER_PLACEHOLDER + ParseAmount(math.Abs(row.Amount)) + " " + row.Currency
+	simpleTx.data[h.FIELD_ACCOUNT+":"+h.FIELD_ACCOUNT_FROM] = from
+	simpleTx.data[h.FIELD_ACCOUNT+":"+h.FIELD_ACCOUNT_TO] = to
+	if !tx.IsDone() {
+		return nil, fmt.Errorf("the default template could not be filled with the statement row")
+	}
+	return tx, nil
+}
+
+// ImportDuplicate is a statement row which might have been recorded before, e.g. manually
+type ImportDuplicate struct {
+	Row *ImportRow
+	// Account is the account the row would have been booked on, if any
+	Account  string
+	Existing []*crud.TransactionResult
+}
+
+// ImportResult summarises the recording of a statement
+type ImportResult struct {
+	Rows int
+	// Duplicates is the number of rows which have already been imported before
+	Duplicates int
+	Recorded   int
+	// Balance is the recorded balance assertion, if any
+	Balance       string
+	Uncategorised []*ImportRow
+	// PossibleDuplicates are not recorded, as they match transactions recorded before
+	PossibleDuplicates []*ImportDuplicate
+	Tags               []string
+}
+
+// ImportDuplicates decides how RecordStatement handles rows similar to recorded transactions
+type ImportDuplicates int
+
+const (
+	// IMPORT_DUPLICATES_RETURN records the other rows and returns the possible duplicates to decide on them one by one
+	IMPORT_DUPLICATES_RETURN ImportDuplicates = iota
+	// IMPORT_DUPLICATES_REJECT records no rows at all if there are possible duplicates, so that the statement can be
+	// imported again as a whole once they have been checked. Rows without ID could not be skipped otherwise.
+	IMPORT_DUPLICATES_REJECT
+	// IMPORT_DUPLICATES_RECORD records possible duplicates like all other rows
+	IMPORT_DUPLICATES_RECORD
+)
+
+// RecordStatement records the statement rows matching an import rule. The other rows are booked on the fallback account,
+// or returned as uncategorised if none is given. Rows and balance assertions which have already been imported are skipped.
+// Rows similar to recorded transactions are handled as given by duplicates.
+func RecordStatement(repo *crud.Repo, m *tb.Message, statement *ImportStatement, fallbackAccount string, duplicates ImportDuplicates) (*ImportResult, error) {
+	rules, err := repo.GetImportRules(m)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := repo.GetImportedIds(m, statement.Account)
+	if err != nil {
+		return nil, err
+	}
+	today := time.Now().UTC().Add(time.Duration(repo.UserGetTzOffset(m)) * time.Hour).Format(h.BEANCOUNT_DATE_FORMAT)
+	result := &ImportResult{Rows: len(statement.Rows), Uncategorised: []*ImportRow{}, PossibleDuplicates: []*ImportDuplicate{}, Tags: repo.UserGetTags(m, today)}
+	rows := []*ImportRow{}
+	for _, row := range statement.Rows {
+		if row.Id != "" {
+			if imported[row.Id] {
+				result.Duplicates++
+				continue
+			}
+			imported[row.Id] = true
+		}
+		rows = append(rows, row)
+	}
+	// All rows are checked before recording any, as rows of the same statement are no duplicates of each other
+	accounts := map[*ImportRow]string{}
+	for _, row := range rows {
+		accounts[row] = ImportRuleAccount(rules, row)
+		if accounts[row] == "" {
+			accounts[row] = fallbackAccount
+		}
+		if duplicates == IMPORT_DUPLICATES_RECORD {
+			continue
+		}
+		existing, err := FindDuplicateTransactions(repo, m, row.Date, math.Abs(row.Amount), row.Currency, row.Description())
+		if err != nil {
+			return nil, err
+		}
+		if len(existing) > 0 {
+			result.PossibleDuplicates = append(result.PossibleDuplicates, &ImportDuplicate{Row: row, Account: accounts[row], Existing: existing})
+		}
+	}
+	if duplicates == IMPORT_DUPLICATES_REJECT && len(result.PossibleDuplicates) > 0 {
+		return result, nil
+	}
+	possibleDuplicate := map[*ImportRow]bool{}
+	for _, duplicate := range result.PossibleDuplicates {
+		possibleDuplicate[duplicate.Row] = true
+	}
+	for _, row := range rows {
+		account := accounts[row]
+		if possibleDuplicate[row] {
+			continue
+		}
+		if account == "" {
+			result.Uncategorised = append(result.Uncategorised, row)
+			continue
+		}
+		err = recordImportRow(repo, m, row, statement.Account, account, result.Tags)
+		if err != nil {
+			return result, fmt.Errorf("line %d: %s", row.Line, err.Error())
+		}
+		result.Recorded++
+	}
+	if statement.Balance != nil && !imported[statement.Balance.Id()] {
+		result.Balance = statement.Balance.Directive(statement.Account)
+		err = repo.RecordTransaction(m, result.Balance)
+		if err != nil {
+			return result, err
+		}
+		err = repo.AddImportedId(m, statement.Account, statement.Balance.Id())
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func recordImportRow(repo *crud.Repo, m *tb.Message, row *ImportRow, account, counterAccount string, tags []string) error {
+	tx, err := ImportTx(row, account, counterAccount)
+	if err != nil {
+		return err
+	}
+	transaction, err := tx.FillTemplate(row.Currency, tags, 0)
+	if err != nil {
+		return err
+	}
+	err = repo.RecordTransaction(m, transaction)
+	if err != nil {
+		return err
+	}
+	return markImportRow(repo, m, row, account)
+}
+
+// markImportRow remembers the row as imported, so that it is not imported again with later statements
+func markImportRow(repo *crud.Repo, m *tb.Message, row *ImportRow, account string) error {
+	if row.Id == "" {
+		return nil
+	}
+	return repo.AddImportedId(m, account, row.Id)
+}
+
+func (bc *BotController) commandImport(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_IMPORT, true)
+	sc.
+		Add("profile", bc.importHandleProfile).
+		Add("profiles", bc.importHandleProfiles).
+		Add("rule", bc.importHandleRule).
+		Add("rules", bc.importHandleRules)
+	parameters, err := sc.Handle(m)
+	if err == nil {
+		bc.Logf(TRACE, m, "Handled import subcommand: %v", parameters)
+		return nil
+	}
+	params := h.SplitQuotedCommand(m.Text)[1:]
+	if len(params) > 1 {
+		bc.importHelp(m, fmt.Errorf("unknown subcommand"))
+		return nil
+	}
+	if attachedDocument(m) != nil {
+		bc.importDocument(m, params...)
+		return nil
+	}
+	if bc.State.GetType(m) != ST_NONE {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), MSG_UNFINISHED_STATE)
+		return nil
+	}
+	profile, err := bc.FindImportProfile(m, params...)
+	if err != nil {
+		bc.importHelp(m, err)
+		return nil
+	}
+	statementProfile, err := NewStatementProfile(profile.Config)
+	if err != nil {
+		bc.importHelp(m, fmt.Errorf("the import profile '%s' is invalid: %s", profile.Name, err.Error()))
+		return nil
+	}
+	bc.State.StartDocument(m, DOC_IMPORT, profile.Name)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Please send me your %s statement as document now. It will be imported using the profile '%s'. You can /cancel this operation.", strings.ToUpper(statementProfile.Format), profile.Name), clearKeyboard())
+	return nil
+}
+
+func (bc *BotController) importHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s [profile] - Import a CSV or OFX/QFX statement of your bank sent as document. The profile can be omitted if you only have one.
+/%s profile set <name> <key=value>... - Create or replace an import profile
+/%s profile rm <name> - Remove an import profile
+/%s profiles - List your import profiles
+/%s rule <account> <regex> - Assign the account to rows whose payee or memo match the regular expression
+/%s rule rm <id> - Remove an import rule
+/%s rules - List your import rules
+
+Profile settings: %s
+The format is csv (default) or ofx. For CSV statements, the columns (date, amount, payee, memo) are referenced by number (starting at 1) or by their name in the header line.
+OFX statements only need the account. If a file contains statements of multiple accounts, acctid selects the account id to import. The ledger balance is recorded as balance assertion unless balance=false.
+Examples:
+/%s profile set bank account=Assets:Bank date=Date amount=Amount payee=Payee memo=Purpose dateformat=DD.MM.YYYY delimiter=; decimal=,
+/%s profile set card format=ofx account=Liabilities:Card
+
+Imported rows are recorded as open transactions. Rows not matching any rule are offered for categorisation one after another. Rows of OFX statements which have been imported or skipped before are not imported again.`,
+		CMD_IMPORT, CMD_IMPORT, CMD_IMPORT, CMD_IMPORT, CMD_IMPORT, CMD_IMPORT, CMD_IMPORT, CMD_IMPORT, strings.Join(IMPORT_PROFILE_KEYS, ", "), CMD_IMPORT, CMD_IMPORT), clearKeyboard())
+}
+
+// FindImportProfile returns the import profile with the given name or the only one if no name is given
+func (bc *BotController) FindImportProfile(m *tb.Message, name ...string) (*crud.ImportProfile, error) {
+	profiles, err := bc.Repo.GetImportProfiles(m)
+	if err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("you have no import profile yet. Please create one first")
+	}
+	if len(name) == 0 {
+		if len(profiles) > 1 {
+			return nil, fmt.Errorf("you have multiple import profiles. Please specify which one to use")
+		}
+		return profiles[0], nil
+	}
+	for _, profile := range profiles {
+		if profile.Name == name[0] {
+			return profile, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no import profile named '%s'. See /%s profiles", name[0], CMD_IMPORT)
+}
+
+func (bc *BotController) importHandleProfile(m *tb.Message, params ...string) {
+	if len(params) == 2 && params[0] == "rm" {
+		count, err := bc.Repo.DeleteImportProfile(m, params[1])
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong removing your import profile: "+err.Error(), clearKeyboard())
+			return
+		}
+		if count == 0 {
+			bc.importHelp(m, fmt.Errorf("there is no import profile named '%s'", params[1]))
+			return
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Removed the import profile '%s'.", params[1]), clearKeyboard())
+		return
+	}
+	if len(params) < 3 || params[0] != "set" {
+		bc.importHelp(m, fmt.Errorf("please specify the profile name and its settings"))
+		return
+	}
+	settings, err := ParseImportProfileConfig(params[2:]...)
+	if err != nil {
+		bc.importHelp(m, err)
+		return
+	}
+	profile := &crud.ImportProfile{Name: params[1], Config: FormatImportProfileConfig(settings)}
+	_, err = NewStatementProfile(profile.Config)
+	if err != nil {
+		bc.importHelp(m, err)
+		return
+	}
+	err = bc.Repo.SetImportProfile(m, profile)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong saving your import profile: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Saved the import profile '%s': %s\nSend your statement using /%s %s.", profile.Name, profile.Config, CMD_IMPORT, profile.Name), clearKeyboard())
+}
+
+func (bc *BotController) importHandleProfiles(m *tb.Message, params ...string) {
+	profiles, err := bc.Repo.GetImportProfiles(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your import profiles: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(profiles) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You have no import profiles yet. Create one using '/%s profile set <name> <key=value>...'.", CMD_IMPORT), clearKeyboard())
+		return
+	}
+	lines := []string{"Your import profiles:"}
+	for _, profile := range profiles {
+		lines = append(lines, fmt.Sprintf("%s: %s", profile.Name, profile.Config))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+func (bc *BotController) importHandleRule(m *tb.Message, params ...string) {
+	if len(params) == 2 && params[0] == "rm" {
+		id, err := strconv.Atoi(params[1])
+		if err != nil {
+			bc.importHelp(m, fmt.Errorf("'%s' is no rule id. See /%s rules", params[1], CMD_IMPORT))
+			return
+		}
+		count, err := bc.Repo.DeleteImportRule(m, id)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong removing your import rule: "+err.Error(), clearKeyboard())
+			return
+		}
+		if count == 0 {
+			bc.importHelp(m, fmt.Errorf("there is no import rule with id %d", id))
+			return
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Removed the import rule %d.", id), clearKeyboard())
+		return
+	}
+	// The pattern is taken from the raw message, as quotes and backslashes are part of regular expressions
+	pattern := h.CommandRemainder(m.Text, 3)
+	if len(params) < 2 || pattern == "" {
+		bc.importHelp(m, fmt.Errorf("please specify an account and a regular expression"))
+		return
+	}
+	rule := &crud.ImportRule{Account: params[0], Pattern: pattern}
+	if !BeancountAccountLike(rule.Account) {
+		bc.importHelp(m, fmt.Errorf("'%s' is no valid account", rule.Account))
+		return
+	}
+	if _, err := regexp.Compile("(?i)" + rule.Pattern); err != nil {
+		bc.importHelp(m, fmt.Errorf("'%s' is no valid regular expression: %s", rule.Pattern, err.Error()))
+		return
+	}
+	err := bc.Repo.AddImportRule(m, rule)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong saving your import rule: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Imported rows matching '%s' will be booked on %s.", rule.Pattern, rule.Account), clearKeyboard())
+}
+
+func (bc *BotController) importHandleRules(m *tb.Message, params ...string) {
+	rules, err := bc.Repo.GetImportRules(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your import rules: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(rules) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You have no import rules yet. Add one using '/%s rule <account> <regex>'.", CMD_IMPORT), clearKeyboard())
+		return
+	}
+	lines := []string{"Your import rules, applied in this order:"}
+	for _, rule := range rules {
+		lines = append(lines, fmt.Sprintf("%d: %s -> %s", rule.Id, rule.Pattern, rule.Account))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+func (bc *BotController) importDocument(m *tb.Message, params ...string) {
+	doc := attachedDocument(m)
+	if doc == nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No file has been found to import.", clearKeyboard())
+		return
+	}
+	profile, err := bc.FindImportProfile(m, params...)
+	if err != nil {
+		bc.importHelp(m, err)
+		return
+	}
+	statementProfile, err := NewStatementProfile(profile.Config)
+	if err != nil {
+		bc.importHelp(m, fmt.Errorf("the import profile '%s' is invalid: %s", profile.Name, err.Error()))
+		return
+	}
+	content, err := bc.downloadDocument(doc, h.MAX_DOCUMENT_SIZE)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Error encountered while importing your statement: "+err.Error(), clearKeyboard())
+		return
+	}
+	statement, err := statementProfile.Parse(content, bc.Repo.UserGetCurrency(m))
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Your statement could not be read using the profile '%s': %s", profile.Name, err.Error()), clearKeyboard())
+		return
+	}
+	result, err := RecordStatement(bc.Repo, m, statement, "", IMPORT_DUPLICATES_RETURN)
+	if err != nil {
+		recorded := 0
+		if result != nil {
+			recorded = result.Recorded
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Something went wrong recording your statement (%d transaction(s) have been recorded before): %s", recorded, err.Error()), clearKeyboard())
+		return
+	}
+
+	msg := fmt.Sprintf("Read %d row(s) of your statement using the profile '%s'. %d of them matched your import rules and have been recorded.", result.Rows, profile.Name, result.Recorded)
+	if result.Duplicates > 0 {
+		msg += fmt.Sprintf(" %d row(s) have already been imported before and have been skipped.", result.Duplicates)
+	}
+	if result.Balance != "" {
+		msg += "\nRecorded the balance assertion: " + strings.TrimSpace(result.Balance)
+	}
+	if len(statement.Warnings) > 0 {
+		msg += fmt.Sprintf("\n\n%d line(s) could not be read and have been skipped:", len(statement.Warnings))
+		for i, warning := range statement.Warnings {
+			if i >= IMPORT_MAX_WARNINGS {
+				msg += "\n..."
+				break
+			}
+			msg += "\n" + warning
+		}
+	}
+	if len(result.Uncategorised)+len(result.PossibleDuplicates) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), msg+fmt.Sprintf("\n\nSee your transactions using /%s.", CMD_LIST), clearKeyboard())
+		return
+	}
+	session := &ImportSession{Account: statement.Account, Tags: result.Tags, Rows: result.Uncategorised, Duplicates: map[*ImportRow]*ImportDuplicate{}}
+	msg += "\n"
+	if len(result.Uncategorised) > 0 {
+		msg += fmt.Sprintf("\n%d row(s) did not match any rule.", len(result.Uncategorised))
+	}
+	if len(result.PossibleDuplicates) > 0 {
+		msg += fmt.Sprintf("\n%d row(s) look like transactions you have already recorded.", len(result.PossibleDuplicates))
+		for _, duplicate := range result.PossibleDuplicates {
+			session.Rows = append(session.Rows, duplicate.Row)
+			session.Duplicates[duplicate.Row] = duplicate
+		}
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), msg+" Please categorise them now.", clearKeyboard())
+	session.Uncategorised = len(session.Rows)
+	bc.State.StartImport(m, session)
+	bc.importSendNextRow(m, session)
+}
+
+func (bc *BotController) importSendNextRow(m *tb.Message, session *ImportSession) {
+	row := session.Rows[0]
+	direction, cacheKey := "the money went to", h.FIELD_ACCOUNT+":"+h.FIELD_ACCOUNT_TO
+	if row.Amount > 0 {
+		direction, cacheKey = "the money came from", h.FIELD_ACCOUNT+":"+h.FIELD_ACCOUNT_FROM
+	}
+	options, err := bc.Repo.GetCacheHints(m, cacheKey)
+	if err != nil {
+		bc.Logf(ERROR, m, "Error occurred getting cached hints for import: %s", err.Error())
+	}
+	hint := ""
+	if duplicate, exists := session.Duplicates[row]; exists {
+		hint = fmt.Sprintf("\n\nThis row looks like %d transaction(s) you have already recorded:\n%s", len(duplicate.Existing), formatDuplicates(duplicate.Existing))
+		if duplicate.Account != "" {
+			options = append([]string{duplicate.Account}, options...)
+		}
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Uncategorised row %d of %d:\n%s%s\n\nPlease enter the account %s, '%s' to not record it or /cancel to stop categorising.",
+		session.Uncategorised-len(session.Rows)+1, session.Uncategorised, row, hint, direction, IMPORT_SKIP), ReplyKeyboard(append([]string{IMPORT_SKIP}, options...)))
+}
+
+func (bc *BotController) importHandleCategorisation(m *tb.Message) {
+	session := bc.State.GetImport(m)
+	if session == nil || len(session.Rows) == 0 {
+		bc.State.Clear(m)
+		return
+	}
+	row := session.Rows[0]
+	input := strings.TrimSpace(m.Text)
+	if strings.EqualFold(input, IMPORT_SKIP) {
+		err := markImportRow(bc.Repo, m, row, session.Account)
+		if err != nil {
+			bc.Logf(ERROR, m, "Something went wrong while marking the skipped row as imported. Error: %s", err.Error())
+		}
+		session.Skipped++
+	} else if !BeancountAccountLike(input) {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("'%s' is no valid account. Please try again.", input))
+		return
+	} else {
+		err := recordImportRow(bc.Repo, m, row, session.Account, input, session.Tags)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong recording the row: "+err.Error())
+			return
+		}
+		cacheKey := h.FIELD_ACCOUNT + ":" + h.FIELD_ACCOUNT_TO
+		if row.Amount > 0 {
+			cacheKey = h.FIELD_ACCOUNT + ":" + h.FIELD_ACCOUNT_FROM
+		}
+		err = bc.Repo.PutCacheHints(m, map[string]string{cacheKey: input})
+		if err != nil {
+			bc.Logf(ERROR, m, "Something went wrong while caching the imported account. Error: %s", err.Error())
+		}
+		session.Recorded++
+	}
+	session.Rows = session.Rows[1:]
+	if len(session.Rows) > 0 {
+		bc.importSendNextRow(m, session)
+		return
+	}
+	bc.State.Clear(m)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Finished categorising your statement: %d more transaction(s) have been recorded, %d row(s) have been skipped. See your transactions using /%s.",
+		session.Recorded, session.Skipped, CMD_LIST), clearKeyboard())
+}
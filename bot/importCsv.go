@@ -0,0 +1,136 @@
+package bot
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+// goDateLayout converts date formats like 'DD.MM.YYYY' to the layout used for parsing
+func goDateLayout(format string) string {
+	return strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(format)
+}
+
+// parseImportAmount reads amounts like '-1.234,56 €' using the profile's decimal separator
+func (p *StatementProfile) parseImportAmount(value string) (float64, error) {
+	cleaned := strings.Builder{}
+	for _, c := range value {
+		if (c >= '0' && c <= '9') || c == '-' || c == '+' || c == '.' || c == ',' {
+			cleaned.WriteRune(c)
+		}
+	}
+	amount := cleaned.String()
+	if p.DecimalSeparator == "," {
+		amount = strings.ReplaceAll(strings.ReplaceAll(amount, ".", ""), ",", ".")
+	} else {
+		amount = strings.ReplaceAll(amount, ",", "")
+	}
+	return strconv.ParseFloat(amount, 64)
+}
+
+// ParseCsv reads the statement rows. Rows which can not be read, e.g. summary lines, are skipped and reported as warnings.
+func (p *StatementProfile) ParseCsv(content, defaultCurrency string) (rows []*ImportRow, warnings []string, err error) {
+	content = strings.TrimPrefix(strings.ReplaceAll(content, "\r\n", "\n"), "\ufeff")
+	lines := strings.Split(content, "\n")
+	if p.Skip >= len(lines) {
+		return nil, nil, fmt.Errorf("the file has only %d lines, but %d should be skipped", len(lines), p.Skip)
+	}
+	reader := csv.NewReader(strings.NewReader(strings.Join(lines[p.Skip:], "\n")))
+	reader.Comma = p.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	columns := map[string]int{}
+	resolveColumns := func(header []string) error {
+		for key, column := range p.Columns {
+			if n, err := strconv.Atoi(column); err == nil {
+				columns[key] = n - 1
+				continue
+			}
+			columns[key] = -1
+			for i, name := range header {
+				if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column)) {
+					columns[key] = i
+					break
+				}
+			}
+			if columns[key] < 0 {
+				return fmt.Errorf("the column '%s' for '%s' has not been found in the header line", column, key)
+			}
+		}
+		return nil
+	}
+	if !p.Header {
+		err = resolveColumns(nil)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	currency := p.Currency
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	layout := goDateLayout(p.DateFormat)
+	headerRead := !p.Header
+	rows = []*ImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("the file could not be read: %s", err.Error())
+		}
+		line, _ := reader.FieldPos(0)
+		line += p.Skip
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+		if !headerRead {
+			err = resolveColumns(record)
+			if err != nil {
+				return nil, nil, err
+			}
+			headerRead = true
+			continue
+		}
+		cell := func(key string) string {
+			i, mapped := columns[key]
+			if !mapped || i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		date, err := time.Parse(layout, cell(IMPORT_KEY_DATE))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: '%s' is no date of the format %s", line, cell(IMPORT_KEY_DATE), p.DateFormat))
+			continue
+		}
+		amount, err := p.parseImportAmount(cell(IMPORT_KEY_AMOUNT))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("line %d: '%s' is no valid amount", line, cell(IMPORT_KEY_AMOUNT)))
+			continue
+		}
+		if p.Invert {
+			amount = -amount
+		}
+		rows = append(rows, &ImportRow{
+			Line:     line,
+			Date:     date.Format(helpers.BEANCOUNT_DATE_FORMAT),
+			Amount:   amount,
+			Currency: currency,
+			Payee:    cell(IMPORT_KEY_PAYEE),
+			Memo:     cell(IMPORT_KEY_MEMO),
+		})
+	}
+	if !headerRead {
+		return nil, nil, fmt.Errorf("the file has no header line")
+	}
+	return rows, warnings, nil
+}
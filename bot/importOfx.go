@@ -0,0 +1,229 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+// ofxTag matches the tags of both SGML (OFX 1.x) and XML (OFX 2.x) files, together with the text following them.
+// In SGML files, elements holding a value have no closing tag.
+var ofxTag = regexp.MustCompile(`<(/?)([A-Za-z0-9._]+)[^>]*>([^<]*)`)
+
+// OfxTransaction is a STMTTRN element of an OFX statement
+type OfxTransaction struct {
+	Line     int
+	FitId    string
+	Type     string
+	Date     string
+	Amount   string
+	Currency string
+	Name     string
+	Memo     string
+}
+
+// OfxStatement is a bank (STMTRS) or credit card (CCSTMTRS) statement of an OFX file
+type OfxStatement struct {
+	AccountId    string
+	Currency     string
+	Transactions []*OfxTransaction
+	// Balance and BalanceDate are the ledger balance at the end of the statement, if provided
+	Balance     string
+	BalanceDate string
+}
+
+// ofxAggregates are the elements whose children are read. An element of SGML files holding an empty value looks
+// like an aggregate, so the values are assigned to the innermost of these instead of the innermost open element.
+var ofxAggregates = []string{"STMTRS", "CCSTMTRS", "BANKACCTFROM", "CCACCTFROM", "LEDGERBAL", "STMTTRN", "PAYEE", "CURRENCY", "ORIGCURRENCY"}
+
+// ReadOfx reads the statements of an OFX or QFX file. Values are returned as found in the file.
+func ReadOfx(content string) ([]*OfxStatement, error) {
+	if !strings.Contains(strings.ToUpper(content), "<OFX>") {
+		return nil, fmt.Errorf("the file is no OFX statement")
+	}
+	statements := []*OfxStatement{}
+	var statement *OfxStatement
+	var transaction *OfxTransaction
+	open := []string{}
+	for _, match := range ofxTag.FindAllStringSubmatchIndex(content, -1) {
+		closing := match[3] > match[2]
+		name := strings.ToUpper(content[match[4]:match[5]])
+		value := strings.TrimSpace(html.UnescapeString(content[match[6]:match[7]]))
+		if closing {
+			// Elements without closing tag are closed together with their parent
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					open = open[:i]
+					break
+				}
+			}
+			switch {
+			case name == "STMTTRN" && statement != nil && transaction != nil:
+				statement.Transactions = append(statement.Transactions, transaction)
+				transaction = nil
+			case (name == "STMTRS" || name == "CCSTMTRS") && statement != nil:
+				statements = append(statements, statement)
+				statement = nil
+			}
+			continue
+		}
+		if value == "" {
+			open = append(open, name)
+			switch name {
+			case "STMTRS", "CCSTMTRS":
+				statement = &OfxStatement{}
+			case "STMTTRN":
+				transaction = &OfxTransaction{Line: strings.Count(content[:match[0]], "\n") + 1}
+			}
+			continue
+		}
+		if statement == nil {
+			continue
+		}
+		parent := ""
+		for i := len(open) - 1; i >= 0 && parent == ""; i-- {
+			if helpers.ArrayContains(ofxAggregates, open[i]) {
+				parent = open[i]
+			}
+		}
+		if transaction != nil {
+			switch {
+			case parent == "STMTTRN" && name == "FITID":
+				transaction.FitId = value
+			case parent == "STMTTRN" && name == "TRNTYPE":
+				transaction.Type = value
+			case parent == "STMTTRN" && name == "DTPOSTED":
+				transaction.Date = value
+			case parent == "STMTTRN" && name == "TRNAMT":
+				transaction.Amount = value
+			case (parent == "STMTTRN" || parent == "PAYEE") && name == "NAME" && transaction.Name == "":
+				transaction.Name = value
+			case parent == "STMTTRN" && name == "MEMO":
+				transaction.Memo = value
+			case parent == "CURRENCY" && name == "CURSYM":
+				transaction.Currency = value
+			}
+			continue
+		}
+		switch {
+		case name == "CURDEF":
+			statement.Currency = value
+		case (parent == "BANKACCTFROM" || parent == "CCACCTFROM") && name == "ACCTID":
+			statement.AccountId = value
+		case parent == "LEDGERBAL" && name == "BALAMT":
+			statement.Balance = value
+		case parent == "LEDGERBAL" && name == "DTASOF":
+			statement.BalanceDate = value
+		}
+	}
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("the file contains no statement")
+	}
+	return statements, nil
+}
+
+// parseOfxDate reads the date of OFX timestamps like '20240102120000.000[+1:CET]'
+func parseOfxDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("'%s' is no valid date", value)
+	}
+	return time.Parse("20060102", value[:8])
+}
+
+// parseOfxAmount reads OFX amounts, which some banks write with a decimal comma
+func parseOfxAmount(value string) (float64, error) {
+	if !strings.Contains(value, ".") {
+		value = strings.ReplaceAll(value, ",", ".")
+	}
+	return strconv.ParseFloat(strings.TrimPrefix(value, "+"), 64)
+}
+
+// ParseOfx reads the statement of the profile's account from an OFX file.
+// The balance assertion is dated the day after the ledger balance, as beancount checks balances at the beginning of the day.
+func (p *StatementProfile) ParseOfx(content, defaultCurrency string) (*ImportStatement, error) {
+	statements, err := ReadOfx(content)
+	if err != nil {
+		return nil, err
+	}
+	if p.AccountId != "" {
+		matching := []*OfxStatement{}
+		for _, s := range statements {
+			if s.AccountId == p.AccountId {
+				matching = append(matching, s)
+			}
+		}
+		if len(matching) == 0 {
+			return nil, fmt.Errorf("the file contains no statement of the account id '%s'", p.AccountId)
+		}
+		statements = matching
+	}
+	if len(statements) > 1 {
+		ids := []string{}
+		for _, s := range statements {
+			ids = append(ids, s.AccountId)
+		}
+		return nil, fmt.Errorf("the file contains %d statements (account ids: %s). Please set '%s' in the profile", len(statements), strings.Join(ids, ", "), IMPORT_KEY_ACCTID)
+	}
+	ofx := statements[0]
+
+	currency := p.Currency
+	if currency == "" {
+		currency = ofx.Currency
+	}
+	if currency == "" {
+		currency = defaultCurrency
+	}
+	statement := &ImportStatement{Account: p.Account, Rows: []*ImportRow{}}
+	for _, trn := range ofx.Transactions {
+		date, err := parseOfxDate(trn.Date)
+		if err != nil {
+			statement.Warnings = append(statement.Warnings, fmt.Sprintf("line %d: '%s' is no valid date", trn.Line, trn.Date))
+			continue
+		}
+		amount, err := parseOfxAmount(trn.Amount)
+		if err != nil {
+			statement.Warnings = append(statement.Warnings, fmt.Sprintf("line %d: '%s' is no valid amount", trn.Line, trn.Amount))
+			continue
+		}
+		if p.Invert {
+			amount = -amount
+		}
+		rowCurrency := currency
+		if trn.Currency != "" && p.Currency == "" {
+			rowCurrency = trn.Currency
+		}
+		statement.Rows = append(statement.Rows, &ImportRow{
+			Line:     trn.Line,
+			Date:     date.Format(helpers.BEANCOUNT_DATE_FORMAT),
+			Amount:   amount,
+			Currency: rowCurrency,
+			Payee:    trn.Name,
+			Memo:     trn.Memo,
+			Id:       trn.FitId,
+		})
+	}
+	if p.Balance && ofx.Balance != "" && ofx.BalanceDate != "" {
+		date, err := parseOfxDate(ofx.BalanceDate)
+		if err != nil {
+			return nil, fmt.Errorf("the ledger balance date '%s' is invalid", ofx.BalanceDate)
+		}
+		amount, err := parseOfxAmount(ofx.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("the ledger balance '%s' is invalid", ofx.Balance)
+		}
+		if p.Invert {
+			amount = -amount
+		}
+		statement.Balance = &ImportBalance{
+			Date:     date.AddDate(0, 0, 1).Format(helpers.BEANCOUNT_DATE_FORMAT),
+			Amount:   amount,
+			Currency: currency,
+		}
+	}
+	return statement, nil
+}
@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+const (
+	IMPORT_KEY_FORMAT     = "format"
+	IMPORT_KEY_ACCOUNT    = "account"
+	IMPORT_KEY_CURRENCY   = "currency"
+	IMPORT_KEY_ACCTID     = "acctid"
+	IMPORT_KEY_BALANCE    = "balance"
+	IMPORT_KEY_DATE       = "date"
+	IMPORT_KEY_AMOUNT     = "amount"
+	IMPORT_KEY_PAYEE      = "payee"
+	IMPORT_KEY_MEMO       = "memo"
+	IMPORT_KEY_DATEFORMAT = "dateformat"
+	IMPORT_KEY_DELIMITER  = "delimiter"
+	IMPORT_KEY_DECIMAL    = "decimal"
+	IMPORT_KEY_SKIP       = "skip"
+	IMPORT_KEY_HEADER     = "header"
+	IMPORT_KEY_INVERT     = "invert"
+
+	IMPORT_FORMAT_CSV = "csv"
+	IMPORT_FORMAT_OFX = "ofx"
+
+	IMPORT_DEFAULT_DATEFORMAT = "YYYY-MM-DD"
+)
+
+// IMPORT_PROFILE_KEYS are the settings of an import profile. Columns are referenced by number (starting at 1) or header name.
+var IMPORT_PROFILE_KEYS = []string{
+	IMPORT_KEY_FORMAT, IMPORT_KEY_ACCOUNT, IMPORT_KEY_CURRENCY, IMPORT_KEY_ACCTID, IMPORT_KEY_BALANCE,
+	IMPORT_KEY_DATE, IMPORT_KEY_AMOUNT, IMPORT_KEY_PAYEE, IMPORT_KEY_MEMO,
+	IMPORT_KEY_DATEFORMAT, IMPORT_KEY_DELIMITER, IMPORT_KEY_DECIMAL, IMPORT_KEY_SKIP, IMPORT_KEY_HEADER, IMPORT_KEY_INVERT,
+}
+
+// ImportRow is a statement row to be recorded as transaction. Negative amounts are money leaving the account.
+type ImportRow struct {
+	Line     int
+	Date     string
+	Amount   float64
+	Currency string
+	Payee    string
+	Memo     string
+	// Id identifies the row across imports, e.g. the FITID of OFX statements. Rows without id are not deduplicated.
+	Id string
+}
+
+// Description joins payee and memo, as the transaction has a single description
+func (r *ImportRow) Description() string {
+	parts := []string{}
+	for _, part := range []string{r.Payee, r.Memo} {
+		part = strings.Join(strings.Fields(part), " ")
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, " - ")
+}
+
+func (r *ImportRow) String() string {
+	return fmt.Sprintf("%s %s %s %s", r.Date, ParseAmount(r.Amount), r.Currency, r.Description())
+}
+
+// ImportBalance is the balance of the account at the beginning of Date, as asserted by beancount's balance directive
+type ImportBalance struct {
+	Date     string
+	Amount   float64
+	Currency string
+}
+
+// Id identifies the balance assertion across imports like the id of a row
+func (b *ImportBalance) Id() string {
+	return "balance:" + b.Date
+}
+
+// Directive formats the balance assertion of the account
+func (b *ImportBalance) Directive(account string) string {
+	return fmt.Sprintf("%s balance %s %s %s\n", b.Date, account, ParseAmount(b.Amount), b.Currency)
+}
+
+// ImportStatement holds the rows read from a statement of the profile's account
+type ImportStatement struct {
+	Account string
+	Rows    []*ImportRow
+	// Warnings list the rows which could not be read, e.g. summary lines
+	Warnings []string
+	// Balance is only set if the statement contains the balance of its end date
+	Balance *ImportBalance
+}
+
+// StatementProfile describes the statement export of a bank: its format and for CSV files how the columns are mapped
+type StatementProfile struct {
+	Format           string
+	Account          string
+	Currency         string
+	AccountId        string
+	Balance          bool
+	Columns          map[string]string
+	DateFormat       string
+	Delimiter        rune
+	DecimalSeparator string
+	Skip             int
+	Header           bool
+	Invert           bool
+}
+
+// ParseImportProfileConfig splits a profile config like 'account=Assets:Bank date=1 payee="Name of payee"' into its settings
+func ParseImportProfileConfig(params ...string) (map[string]string, error) {
+	settings := map[string]string{}
+	for _, param := range params {
+		kv := strings.SplitN(param, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("'%s' is no setting of the form key=value", param)
+		}
+		if !helpers.ArrayContains(IMPORT_PROFILE_KEYS, key) {
+			return nil, fmt.Errorf("unknown setting '%s' (available: %s)", key, strings.Join(IMPORT_PROFILE_KEYS, ", "))
+		}
+		settings[key] = kv[1]
+	}
+	return settings, nil
+}
+
+// FormatImportProfileConfig is the inverse of ParseImportProfileConfig, quoting values containing spaces
+func FormatImportProfileConfig(settings map[string]string) string {
+	parts := []string{}
+	for _, key := range IMPORT_PROFILE_KEYS {
+		value, exists := settings[key]
+		if !exists {
+			continue
+		}
+		value = strings.ReplaceAll(strings.ReplaceAll(value, "\\", "\\\\"), "\"", "\\\"")
+		if strings.Contains(value, " ") || value == "" {
+			value = "\"" + value + "\""
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// NewStatementProfile validates the settings of a profile and applies the defaults
+func NewStatementProfile(config string) (*StatementProfile, error) {
+	settings, err := ParseImportProfileConfig(helpers.SplitQuotedCommand(config)...)
+	if err != nil {
+		return nil, err
+	}
+	p := &StatementProfile{
+		Format:           IMPORT_FORMAT_CSV,
+		Account:          settings[IMPORT_KEY_ACCOUNT],
+		Currency:         settings[IMPORT_KEY_CURRENCY],
+		AccountId:        settings[IMPORT_KEY_ACCTID],
+		Balance:          true,
+		Columns:          map[string]string{},
+		DateFormat:       IMPORT_DEFAULT_DATEFORMAT,
+		Delimiter:        ',',
+		DecimalSeparator: ".",
+		Header:           true,
+	}
+	if format, exists := settings[IMPORT_KEY_FORMAT]; exists {
+		p.Format = strings.ToLower(format)
+		if p.Format != IMPORT_FORMAT_CSV && p.Format != IMPORT_FORMAT_OFX {
+			return nil, fmt.Errorf("the format must be '%s' or '%s'", IMPORT_FORMAT_CSV, IMPORT_FORMAT_OFX)
+		}
+	}
+	required := []string{IMPORT_KEY_ACCOUNT}
+	if p.Format == IMPORT_FORMAT_CSV {
+		required = append(required, IMPORT_KEY_DATE, IMPORT_KEY_AMOUNT)
+	}
+	for _, key := range required {
+		if settings[key] == "" {
+			return nil, fmt.Errorf("the setting '%s' is required", key)
+		}
+	}
+	if !BeancountAccountLike(p.Account) {
+		return nil, fmt.Errorf("'%s' is no valid account", p.Account)
+	}
+	if p.Currency != "" && !beancountCurrency.MatchString(p.Currency) {
+		return nil, fmt.Errorf("'%s' is no valid currency", p.Currency)
+	}
+	for _, key := range []string{IMPORT_KEY_DATE, IMPORT_KEY_AMOUNT, IMPORT_KEY_PAYEE, IMPORT_KEY_MEMO} {
+		if settings[key] == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(settings[key]); err == nil && n < 1 {
+			return nil, fmt.Errorf("column numbers start at 1")
+		}
+		p.Columns[key] = settings[key]
+	}
+	if format, exists := settings[IMPORT_KEY_DATEFORMAT]; exists {
+		p.DateFormat = format
+	}
+	if delimiter, exists := settings[IMPORT_KEY_DELIMITER]; exists {
+		if strings.ToLower(delimiter) == "tab" {
+			delimiter = "\t"
+		}
+		if len([]rune(delimiter)) != 1 {
+			return nil, fmt.Errorf("the delimiter must be a single character or 'tab'")
+		}
+		p.Delimiter = []rune(delimiter)[0]
+	}
+	if decimal, exists := settings[IMPORT_KEY_DECIMAL]; exists {
+		if decimal != "." && decimal != "," {
+			return nil, fmt.Errorf("the decimal separator must be '.' or ','")
+		}
+		p.DecimalSeparator = decimal
+	}
+	if skip, exists := settings[IMPORT_KEY_SKIP]; exists {
+		p.Skip, err = strconv.Atoi(skip)
+		if err != nil || p.Skip < 0 {
+			return nil, fmt.Errorf("the number of lines to skip must be a non-negative number")
+		}
+	}
+	for key, target := range map[string]*bool{IMPORT_KEY_HEADER: &p.Header, IMPORT_KEY_INVERT: &p.Invert, IMPORT_KEY_BALANCE: &p.Balance} {
+		if value, exists := settings[key]; exists {
+			*target, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("the setting '%s' must be 'true' or 'false'", key)
+			}
+		}
+	}
+	if p.Format == IMPORT_FORMAT_CSV && !p.Header {
+		for key, column := range p.Columns {
+			if _, err := strconv.Atoi(column); err != nil {
+				return nil, fmt.Errorf("the column of '%s' must be a number, as the file has no header", key)
+			}
+		}
+	}
+	return p, nil
+}
+
+// Parse reads the statement in the format of the profile
+func (p *StatementProfile) Parse(content, defaultCurrency string) (*ImportStatement, error) {
+	if p.Format == IMPORT_FORMAT_OFX {
+		return p.ParseOfx(content, defaultCurrency)
+	}
+	rows, warnings, err := p.ParseCsv(content, defaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportStatement{Account: p.Account, Rows: rows, Warnings: warnings}, nil
+}
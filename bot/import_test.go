@@ -0,0 +1,263 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const importTestStatement = `Account statement;;;
+Booking date;Payee;Purpose;Amount
+02.01.2024;REWE Markt;Groceries;-12,50 €
+03.01.2024;"ACME ""Corp""";Salary January;1.500,00 €
+04.01.2024;Cinema;;-9,00 €
+;;Balance;1.478,50 €
+`
+
+func TestCsvImportProfile(t *testing.T) {
+	_, err := NewStatementProfile("account=Assets:Bank date=1")
+	helpers.TestStringContains(t, err.Error(), "'amount' is required", "")
+	_, err = NewStatementProfile("account=assets date=1 amount=2")
+	helpers.TestStringContains(t, err.Error(), "no valid account", "")
+	_, err = NewStatementProfile("account=Assets:Bank date=Date amount=2 header=false")
+	helpers.TestStringContains(t, err.Error(), "must be a number", "")
+	_, err = ParseImportProfileConfig("unknown=1")
+	helpers.TestStringContains(t, err.Error(), "unknown setting 'unknown'", "")
+
+	settings, err := ParseImportProfileConfig("account=Assets:Bank", "date=Booking date", "amount=4", "payee=Payee", "memo=3", "dateformat=DD.MM.YYYY", "delimiter=;", "decimal=,", "skip=1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	config := FormatImportProfileConfig(settings)
+	helpers.TestExpect(t, config, `account=Assets:Bank date="Booking date" amount=4 payee=Payee memo=3 dateformat=DD.MM.YYYY delimiter=; decimal=, skip=1`, "")
+	p, err := NewStatementProfile(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	rows, warnings, err := p.ParseCsv(importTestStatement, "EUR")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(rows), 3, "")
+	helpers.TestExpect(t, *rows[0], ImportRow{Line: 3, Date: "2024-01-02", Amount: -12.5, Currency: "EUR", Payee: "REWE Markt", Memo: "Groceries"}, "")
+	helpers.TestExpect(t, rows[1].Amount, 1500.0, "")
+	helpers.TestExpect(t, rows[1].Description(), `ACME "Corp" - Salary January`, "")
+	helpers.TestExpect(t, rows[2].Description(), "Cinema", "")
+	helpers.TestExpectArrEq(t, warnings, []string{"line 6: '' is no date of the format DD.MM.YYYY"}, "")
+
+	p, _ = NewStatementProfile("account=Assets:Bank date=1 amount=Betrag skip=1 delimiter=;")
+	_, _, err = p.ParseCsv(importTestStatement, "EUR")
+	helpers.TestStringContains(t, err.Error(), "the column 'Betrag' for 'amount' has not been found", "")
+}
+
+func TestImportTx(t *testing.T) {
+	rules := []*crud.ImportRule{
+		{Pattern: "rewe|lidl", Account: "Expenses:Groceries"},
+		{Pattern: "^Salary", Account: "Income:Salary"},
+	}
+	spending := &ImportRow{Date: "2024-01-02", Amount: -12.5, Currency: "EUR", Payee: "REWE Markt", Memo: "Groceries"}
+	income := &ImportRow{Date: "2024-01-03", Amount: 1500, Currency: "EUR", Payee: `ACME "Corp"`, Memo: "Salary January"}
+	helpers.TestExpect(t, ImportRuleAccount(rules, spending), "Expenses:Groceries", "")
+	helpers.TestExpect(t, ImportRuleAccount(rules, income), "Income:Salary", "")
+	helpers.TestExpect(t, ImportRuleAccount(rules, &ImportRow{Payee: "Cinema"}), "", "")
+
+	tx, err := ImportTx(spending, "Assets:Bank", "Expenses:Groceries")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	filled, err := tx.FillTemplate("USD", []string{}, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, filled, `2024-01-02 * "REWE Markt - Groceries"
+  Assets:Bank                                 -12.50 EUR
+  Expenses:Groceries
+`, "")
+
+	tx, _ = ImportTx(income, "Assets:Bank", "Income:Salary")
+	filled, _ = tx.FillTemplate("USD", []string{"vacation"}, 0)
+	helpers.TestExpect(t, filled, `2024-01-03 * "ACME 'Corp' - Salary January" #vacation
+  Income:Salary                             -1500.00 EUR
+  Assets:Bank
+`, "")
+}
+
+func TestCommandImport(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -258}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{Files: map[string]string{"statement": importTestStatement}}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	botTest.HandleErr(t, bc.Repo.DeleteImportSettings(m))
+	_, err := bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	defer bc.DeleteUserData(m)
+
+	command := func(text string) string {
+		bc.commandImport(&botTest.MockContext{M: &tb.Message{Text: text, Chat: chat, Sender: m.Sender}})
+		return fmt.Sprintf("%v", bot.LastSentWhat)
+	}
+	helpers.TestStringContains(t, command("/import"), "you have no import profile yet", "")
+	helpers.TestStringContains(t, command(`/import profile set giro account=Assets:Bank date=1 amount=4 payee=Payee memo=Purpose dateformat=DD.MM.YYYY delimiter=; decimal=, skip=1`),
+		"Saved the import profile 'giro'", "")
+	helpers.TestStringContains(t, command(`/import rule Expenses:Groceries \bREWE\b|"Lidl"`), `Imported rows matching '\bREWE\b|"Lidl"' will be booked on Expenses:Groceries.`, "")
+	command(`/import rule Income:Salary ^Salary`)
+	helpers.TestStringContains(t, command("/import rules"), `\bREWE\b|"Lidl" -> Expenses:Groceries`, "")
+
+	helpers.TestStringContains(t, command("/import"), "Please send me your CSV statement", "")
+	helpers.TestExpect(t, bc.State.GetType(m), ST_DOC, "")
+	bc.handleDocument(&botTest.MockContext{M: &tb.Message{Chat: chat, Sender: m.Sender, Document: &tb.Document{File: tb.File{FileID: "statement"}}}})
+	helpers.TestExpect(t, bc.State.GetType(m), ST_IMP, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.AllLastSentWhat[len(bot.AllLastSentWhat)-2]), "Read 3 row(s) of your statement using the profile 'giro'. 2 of them matched", "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Uncategorised row 1 of 1:\n2024-01-04 -9.00 EUR Cinema", "")
+
+	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Text: "fun", Chat: chat, Sender: m.Sender}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "'fun' is no valid account", "")
+	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Text: "Expenses:Fun", Chat: chat, Sender: m.Sender}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "1 more transaction(s) have been recorded, 0 row(s) have been skipped", "")
+	helpers.TestExpect(t, bc.State.GetType(m), ST_NONE, "")
+
+	tx, err := bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(tx), 3, "")
+	if len(tx) == 3 {
+		helpers.TestStringContains(t, tx[2].Tx, "2024-01-04 * \"Cinema\"\n  Assets:Bank", "")
+		helpers.TestStringContains(t, tx[2].Tx, "  Expenses:Fun\n", "")
+	}
+	hints, err := bc.Repo.GetCacheHints(m, "account:to")
+	botTest.HandleErr(t, err)
+	helpers.TestExpectArrEq(t, hints, []string{"Expenses:Fun"}, "categorised accounts should be suggested")
+}
+
+const importTestOfxSgml = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20240201</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STMTRS>
+<CURDEF>EUR
+<BANKACCTFROM><BANKID>10010010<ACCTID>123456<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST><DTSTART>20240101<DTEND>20240131
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240102120000.000[+1:CET]<TRNAMT>-12.50<FITID>T1<NAME>REWE Markt<MEMO>Groceries &amp; more</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20240103<TRNAMT>1500,00<FITID>T2<NAME>ACME Corp<MEMO>Salary January</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>2024<TRNAMT>-1.00<FITID>T3<NAME>Broken</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>1487.50<DTASOF>20240131</LEDGERBAL>
+<AVAILBAL><BALAMT>1000.00<DTASOF>20240131</AVAILBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const importTestOfxXml = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><CCSTMTRS>
+    <CURDEF>USD</CURDEF>
+    <CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN>
+        <TRNTYPE>DEBIT</TRNTYPE>
+        <DTPOSTED>20240105</DTPOSTED>
+        <TRNAMT>-9.00</TRNAMT>
+        <FITID>C1</FITID>
+        <PAYEE><NAME>Cinema</NAME></PAYEE>
+        <MEMO></MEMO>
+      </STMTTRN>
+    </BANKTRANLIST>
+  </CCSTMTRS></CCSTMTTRNRS>
+  <CCSTMTTRNRS><CCSTMTRS>
+    <CURDEF>USD</CURDEF>
+    <CCACCTFROM><ACCTID>4222</ACCTID></CCACCTFROM>
+    <BANKTRANLIST></BANKTRANLIST>
+  </CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>
+`
+
+func TestOfxImportProfile(t *testing.T) {
+	_, err := NewStatementProfile("format=qif account=Assets:Bank")
+	helpers.TestStringContains(t, err.Error(), "the format must be 'csv' or 'ofx'", "")
+	p, err := NewStatementProfile("format=ofx account=Assets:Bank")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	_, err = p.Parse(importTestStatement, "USD")
+	helpers.TestStringContains(t, err.Error(), "the file is no OFX statement", "")
+
+	statement, err := p.Parse(importTestOfxSgml, "USD")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(statement.Rows), 2, "")
+	helpers.TestExpect(t, *statement.Rows[0], ImportRow{Line: 11, Date: "2024-01-02", Amount: -12.5, Currency: "EUR", Payee: "REWE Markt", Memo: "Groceries & more", Id: "T1"}, "")
+	helpers.TestExpect(t, statement.Rows[1].Amount, 1500.0, "")
+	helpers.TestExpectArrEq(t, statement.Warnings, []string{"line 13: '2024' is no valid date"}, "")
+	helpers.TestExpect(t, statement.Balance.Directive(statement.Account), "2024-02-01 balance Assets:Bank 1487.50 EUR\n", "the balance should be asserted at the beginning of the next day")
+
+	p, _ = NewStatementProfile("format=ofx account=Assets:Bank balance=false")
+	statement, _ = p.Parse(importTestOfxSgml, "USD")
+	helpers.TestExpect(t, statement.Balance == nil, true, "")
+
+	p, _ = NewStatementProfile("format=ofx account=Liabilities:Card")
+	_, err = p.Parse(importTestOfxXml, "EUR")
+	helpers.TestStringContains(t, err.Error(), "the file contains 2 statements (account ids: 4111, 4222)", "")
+	p, _ = NewStatementProfile("format=ofx account=Liabilities:Card acctid=4111")
+	statement, err = p.Parse(importTestOfxXml, "EUR")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(statement.Rows), 1, "")
+	helpers.TestExpect(t, *statement.Rows[0], ImportRow{Line: 8, Date: "2024-01-05", Amount: -9, Currency: "USD", Payee: "Cinema", Id: "C1"}, "")
+	helpers.TestExpect(t, statement.Balance == nil, true, "")
+}
+
+func TestCommandImportOfx(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -259}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{Files: map[string]string{"statement.ofx": importTestOfxSgml}}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	botTest.HandleErr(t, bc.Repo.DeleteImportSettings(m))
+	_, err := bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	defer bc.DeleteUserData(m)
+
+	command := func(text string) string {
+		bc.commandImport(&botTest.MockContext{M: &tb.Message{Text: text, Chat: chat, Sender: m.Sender}})
+		return fmt.Sprintf("%v", bot.LastSentWhat)
+	}
+	helpers.TestStringContains(t, command("/import profile set giro format=ofx account=Assets:Bank"), "Saved the import profile 'giro'", "")
+	command("/import rule Expenses:Groceries REWE")
+	helpers.TestStringContains(t, command("/import"), "Please send me your OFX statement", "")
+	sendStatement := func() {
+		bc.handleDocument(&botTest.MockContext{M: &tb.Message{Chat: chat, Sender: m.Sender, Document: &tb.Document{File: tb.File{FileID: "statement.ofx"}}}})
+	}
+	sendStatement()
+	msg := fmt.Sprintf("%v", bot.AllLastSentWhat[len(bot.AllLastSentWhat)-2])
+	helpers.TestStringContains(t, msg, "Read 2 row(s) of your statement using the profile 'giro'. 1 of them matched", "")
+	helpers.TestStringContains(t, msg, "Recorded the balance assertion: 2024-02-01 balance Assets:Bank 1487.50 EUR", "")
+	helpers.TestStringContains(t, msg, "line 13: '2024' is no valid date", "")
+	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Text: "skip", Chat: chat, Sender: m.Sender}})
+	helpers.TestExpect(t, bc.State.GetType(m), ST_NONE, "")
+
+	command("/import")
+	sendStatement()
+	msg = fmt.Sprintf("%v", bot.LastSentWhat)
+	helpers.TestStringContains(t, msg, "Read 2 row(s) of your statement using the profile 'giro'. 0 of them matched your import rules and have been recorded. 2 row(s) have already been imported before", "")
+	helpers.TestExpect(t, strings.Contains(msg, "balance assertion"), false, "the balance assertion should not be recorded twice")
+	helpers.TestExpect(t, bc.State.GetType(m), ST_NONE, "")
+
+	tx, err := bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(tx), 2, "")
+}
@@ -0,0 +1,276 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	LIST_PAGE_SIZE = 10
+	// LIST_PAGE_ENTRY_MAX_LEN keeps a full page within the message length limit
+	LIST_PAGE_ENTRY_MAX_LEN = (helpers.TG_MAX_MSG_CHAR_LEN - 200) / LIST_PAGE_SIZE
+
+	// LIST_MAX_MESSAGES is the number of messages a full list is sent in at most. Longer lists are sent as file.
+	LIST_MAX_MESSAGES = 5
+
+	LIST_CALLBACK_UNIQUE = "list"
+	LIST_ACTION_PAGE     = "p"
+	LIST_ACTION_DELETE   = "rm"
+	// LIST_ACTION_DELETE_ASK asks for confirmation before deleting
+	LIST_ACTION_DELETE_ASK = "rmq"
+	LIST_ACTION_ARCHIVE    = "ar"
+
+	LIST_GROUP_DAY   = "day"
+	LIST_GROUP_MONTH = "month"
+)
+
+// listPage is the state of a paginated list message. It is carried in the callback data of its buttons
+// as '<action>|<offset>|<options>|<id>', e.g. 'rm|10|ad|1234', to stay within the 64 bytes allowed.
+type listPage struct {
+	Archived bool
+	Dated    bool
+	// Sorted orders by booking date instead of the time of recording
+	Sorted bool
+	// Grouping separates the entries per day or month of their booking date. Implies Sorted.
+	Grouping string
+	Offset   int
+	// ConfirmDelete is the id of the entry to show the delete confirmation for. It is not part of the callback data.
+	ConfirmDelete int
+}
+
+func (p listPage) options() string {
+	options := ""
+	if p.Archived {
+		options += "a"
+	}
+	if p.Dated {
+		options += "d"
+	}
+	if p.Sorted {
+		options += "s"
+	}
+	switch p.Grouping {
+	case LIST_GROUP_DAY:
+		options += "D"
+	case LIST_GROUP_MONTH:
+		options += "M"
+	}
+	return options
+}
+
+func (p listPage) filter() crud.TransactionFilter {
+	return crud.TransactionFilter{Archived: &p.Archived, SortByBookingDate: p.Sorted || p.Grouping != ""}
+}
+
+func (p listPage) withOffset(offset int) listPage {
+	p.Offset = offset
+	p.ConfirmDelete = 0
+	return p
+}
+
+func (p listPage) callbackData(action string, id int) []string {
+	return []string{action, strconv.Itoa(p.Offset), p.options(), strconv.Itoa(id)}
+}
+
+func parseListCallbackData(data string) (action string, page listPage, id int, err error) {
+	splits := strings.Split(data, "|")
+	if len(splits) != 4 {
+		return "", page, 0, fmt.Errorf("unexpected callback data '%s'", data)
+	}
+	page.Offset, err = strconv.Atoi(splits[1])
+	if err != nil {
+		return
+	}
+	page.Archived = strings.Contains(splits[2], "a")
+	page.Dated = strings.Contains(splits[2], "d")
+	page.Sorted = strings.Contains(splits[2], "s")
+	if strings.Contains(splits[2], "D") {
+		page.Grouping = LIST_GROUP_DAY
+	} else if strings.Contains(splits[2], "M") {
+		page.Grouping = LIST_GROUP_MONTH
+	}
+	id, err = strconv.Atoi(splits[3])
+	return splits[0], page, id, err
+}
+
+func (bc *BotController) renderListPage(m *tb.Message, page listPage) (string, *tb.ReplyMarkup, error) {
+	filter := page.filter()
+	count, err := bc.Repo.CountTransactions(m, filter)
+	if err != nil {
+		return "", nil, err
+	}
+	if page.Offset >= count {
+		// Entries might have been removed in the meantime
+		page.Offset = (count - 1) / LIST_PAGE_SIZE * LIST_PAGE_SIZE
+	}
+	if page.Offset < 0 {
+		page.Offset = 0
+	}
+	filter.Limit = LIST_PAGE_SIZE
+	filter.Offset = page.Offset
+	tx, err := bc.Repo.FindTransactions(m, filter)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tx) == 0 {
+		return "", nil, nil
+	}
+
+	kind := "open"
+	entryAction := LIST_ACTION_ARCHIVE
+	entryActionLabel := "Archive"
+	if page.Archived {
+		kind = "archived"
+		entryActionLabel = "Unarchive"
+	}
+	markup := &tb.ReplyMarkup{}
+	rows := []tb.Row{}
+	entries := bc.formatListEntries(m, tx, page.Dated, page.Offset+1)
+	for i, t := range tx {
+		entries[i] = shortenListEntry(entries[i])
+		number := page.Offset + i + 1
+		if t.Id == page.ConfirmDelete {
+			rows = append(rows, markup.Row(
+				markup.Data(fmt.Sprintf("Really delete %d?", number), LIST_CALLBACK_UNIQUE, page.callbackData(LIST_ACTION_DELETE, t.Id)...),
+				markup.Data("Cancel", LIST_CALLBACK_UNIQUE, page.callbackData(LIST_ACTION_PAGE, 0)...),
+			))
+			continue
+		}
+		rows = append(rows, markup.Row(
+			markup.Data(fmt.Sprintf("Delete %d", number), LIST_CALLBACK_UNIQUE, page.callbackData(LIST_ACTION_DELETE_ASK, t.Id)...),
+			markup.Data(fmt.Sprintf("%s %d", entryActionLabel, number), LIST_CALLBACK_UNIQUE, page.callbackData(entryAction, t.Id)...),
+		))
+	}
+	pages := (count + LIST_PAGE_SIZE - 1) / LIST_PAGE_SIZE
+	currentPage := page.Offset/LIST_PAGE_SIZE + 1
+	navigation := tb.Row{}
+	if currentPage > 1 {
+		navigation = append(navigation, markup.Data("« Prev", LIST_CALLBACK_UNIQUE, page.withOffset(page.Offset-LIST_PAGE_SIZE).callbackData(LIST_ACTION_PAGE, 0)...))
+	}
+	if currentPage < pages {
+		navigation = append(navigation, markup.Data("Next »", LIST_CALLBACK_UNIQUE, page.withOffset(page.Offset+LIST_PAGE_SIZE).callbackData(LIST_ACTION_PAGE, 0)...))
+	}
+	if len(navigation) > 0 {
+		rows = append(rows, navigation)
+	}
+	markup.Inline(rows...)
+	entries = GroupListEntries(tx, entries, page.Grouping)
+
+	header := fmt.Sprintf("Your %s transactions %d-%d of %d (page %d/%d). Use '/%s all' to get all of them at once.\n\n",
+		kind, page.Offset+1, page.Offset+len(tx), count, currentPage, pages, CMD_LIST)
+	return header + strings.Join(entries, "\n"), markup, nil
+}
+
+// shortenListEntry cuts entries too long for a list page. Runes are kept intact, as Telegram rejects invalid UTF-8.
+func shortenListEntry(entry string) string {
+	if utf8.RuneCountInString(entry) <= LIST_PAGE_ENTRY_MAX_LEN {
+		return entry
+	}
+	return string([]rune(entry)[:LIST_PAGE_ENTRY_MAX_LEN]) + fmt.Sprintf("... (shortened, see '/%s all')", CMD_LIST)
+}
+
+func (bc *BotController) sendListPage(m *tb.Message, page listPage) {
+	text, markup, err := bc.renderListPage(m, page)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if text == "" {
+		bc.sendEmptyListHint(m, page.Archived)
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), text, markup)
+}
+
+func (bc *BotController) handleListCallback(c tb.Context) error {
+	m := c.Message()
+	action, page, id, err := parseListCallbackData(c.Callback().Data)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not read list callback: %s", err.Error())
+		return c.Respond(&tb.CallbackResponse{Text: "This list is outdated. Please request a new /" + CMD_LIST})
+	}
+	bc.Logf(TRACE, m, "Handling list callback '%s' for element %d", action, id)
+	if c.Callback().Sender != nil {
+		// The list message has been sent by the bot. Changes are made by the user pressing the button.
+		actor := *m
+		actor.Sender = c.Callback().Sender
+		m = &actor
+	}
+	response := ""
+	switch action {
+	case LIST_ACTION_PAGE:
+	case LIST_ACTION_DELETE_ASK:
+		page.ConfirmDelete = id
+		response = "Please confirm moving the transaction to the trash."
+	case LIST_ACTION_DELETE:
+		var count int64
+		count, err = bc.Repo.DeleteTransaction(m, page.Archived, id)
+		response = "Moved the transaction to the trash."
+		if err == nil && count == 0 {
+			response = "The transaction does not exist anymore."
+		}
+	case LIST_ACTION_ARCHIVE:
+		var count int64
+		count, err = bc.Repo.SetTransactionArchived(m, id, !page.Archived)
+		response = "Archived the transaction."
+		if page.Archived {
+			response = "Moved the transaction back to your open transactions."
+		}
+		if err == nil && count == 0 {
+			response = "The transaction does not exist anymore."
+		}
+	default:
+		err = fmt.Errorf("unknown action '%s'", action)
+	}
+	if err != nil {
+		bc.Logf(ERROR, m, "Error handling list callback: %s", err.Error())
+		return c.Respond(&tb.CallbackResponse{Text: "Something went wrong: " + err.Error()})
+	}
+
+	text, markup, err := bc.renderListPage(m, page)
+	if err != nil {
+		return c.Respond(&tb.CallbackResponse{Text: "Something went wrong retrieving your transactions: " + err.Error()})
+	}
+	if text == "" {
+		text = "Your transaction list is empty now."
+		markup = &tb.ReplyMarkup{}
+	}
+	_, err = bc.Bot.Edit(m, text, markup)
+	if err != nil && err != tb.ErrSameMessageContent {
+		bc.Logf(ERROR, m, "Could not update list message: %s", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{Text: response})
+}
+
+// sendListFile sends the transactions as a beancount file, optionally restricted to a range of booking dates.
+// The offset of the list options is ignored.
+func (bc *BotController) sendListFile(m *tb.Message, options listPage, dateRange crud.TransactionFilter) {
+	filter := options.filter()
+	filter.From = dateRange.From
+	filter.To = dateRange.To
+	tx, err := bc.Repo.FindTransactions(m, filter)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(tx) == 0 {
+		bc.sendEmptyListHint(m, options.Archived)
+		return
+	}
+	entries := GroupListEntries(tx, bc.formatListEntries(m, tx, options.Dated, 0), options.Grouping)
+	bc.sendListDocument(m, entries, fmt.Sprintf("%d transaction(s)", len(tx)))
+}
+
+func (bc *BotController) sendListDocument(m *tb.Message, entries []string, caption string) {
+	date := time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour).Format(helpers.BEANCOUNT_DATE_FORMAT)
+	doc := documentFromString(fmt.Sprintf("transactions-%s.beancount", date), strings.Join(entries, "\n"))
+	doc.Caption = caption
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), doc, clearKeyboard())
+}
@@ -0,0 +1,238 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func listPageRows(ids ...int) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "value", "created", "archived"})
+	for _, id := range ids {
+		rows.AddRow(id, fmt.Sprintf("tx%d", id), "2022-03-30T14:24:50Z", false)
+	}
+	return rows
+}
+
+func inlineKeyboard(t *testing.T, options []interface{}) [][]tb.InlineButton {
+	for _, option := range options {
+		if markup, ok := option.(*tb.ReplyMarkup); ok {
+			return markup.InlineKeyboard
+		}
+	}
+	t.Errorf("No inline keyboard has been sent: %v", options)
+	return nil
+}
+
+func TestListCallbackData(t *testing.T) {
+	page := listPage{Archived: true, Dated: true, Offset: 20}
+	data := strings.Join(page.callbackData(LIST_ACTION_DELETE, 123456789), "|")
+	helpers.TestExpect(t, data, "rm|20|ad|123456789", "")
+	helpers.TestExpect(t, len("\f"+LIST_CALLBACK_UNIQUE+"|"+data) <= 64, true, "callback data must not exceed 64 bytes")
+
+	action, parsed, id, err := parseListCallbackData(data)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, action, LIST_ACTION_DELETE, "")
+	helpers.TestExpect(t, parsed, page, "")
+	helpers.TestExpect(t, id, 123456789, "")
+
+	_, _, _, err = parseListCallbackData("rm|x|a|1")
+	helpers.TestExpect(t, err != nil, true, "")
+	_, _, _, err = parseListCallbackData("rm|1")
+	helpers.TestExpect(t, err != nil, true, "")
+}
+
+func TestShortenListEntry(t *testing.T) {
+	helpers.TestExpect(t, shortenListEntry("short"), "short", "")
+	shortened := shortenListEntry(strings.Repeat("€", LIST_PAGE_ENTRY_MAX_LEN+1))
+	helpers.TestExpect(t, utf8.ValidString(shortened), true, "multi-byte characters should not be cut")
+	helpers.TestExpect(t, strings.HasPrefix(shortened, strings.Repeat("€", LIST_PAGE_ENTRY_MAX_LEN)+"... (shortened"), true, shortened)
+}
+
+func TestListPaginated(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// First page
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM "bot::transaction"`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID, false, LIST_PAGE_SIZE, 0).
+		WillReturnRows(listPageRows(1, 2, 3, 4, 5, 6, 7, 8, 9, 10))
+	// Next page
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM "bot::transaction"`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(12))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID, false, LIST_PAGE_SIZE, 10).
+		WillReturnRows(listPageRows(11, 12))
+	// Archive last entry
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "bot::transaction"`).WithArgs(chat.ID, 12, true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM "bot::transaction"`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID, false, LIST_PAGE_SIZE, 10).
+		WillReturnRows(listPageRows(11))
+	// Delete remaining entry on page after confirmation: falls back to previous page
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM "bot::transaction"`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID, false, LIST_PAGE_SIZE, 10).
+		WillReturnRows(listPageRows(11))
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "bot::transaction" SET "deleted" = CURRENT_TIMESTAMP`).WithArgs(chat.ID, false, 11).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM "bot::transaction"`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID, false, LIST_PAGE_SIZE, 0).
+		WillReturnRows(listPageRows(1, 2, 3, 4, 5, 6, 7, 8, 9, 10))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list"}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "transactions 1-10 of 12 (page 1/2)", "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "\n1) tx1\n", "")
+	keyboard := inlineKeyboard(t, bot.LastSentOptions)
+	helpers.TestExpect(t, len(keyboard), LIST_PAGE_SIZE+1, "entry rows and navigation")
+	helpers.TestExpect(t, keyboard[0][0].Text, "Delete 1", "")
+	helpers.TestExpect(t, keyboard[0][1].Text, "Archive 1", "")
+	navigation := keyboard[LIST_PAGE_SIZE]
+	helpers.TestExpect(t, len(navigation), 1, "no previous page on first page")
+	helpers.TestExpect(t, navigation[0].Text, "Next »", "")
+	helpers.TestExpect(t, navigation[0].Data, "p|10||0", "")
+
+	listMessage := &tb.Message{ID: 99, Chat: chat}
+	bc.handleListCallback(&botTest.MockContext{C: &tb.Callback{Message: listMessage, Data: navigation[0].Data}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastEditedWhat), "transactions 11-12 of 12 (page 2/2)", "")
+	keyboard = inlineKeyboard(t, bot.LastSentOptions)
+	helpers.TestExpect(t, keyboard[1][1].Data, "ar|10||12", "")
+	helpers.TestExpect(t, keyboard[2][0].Text, "« Prev", "")
+
+	bc.handleListCallback(&botTest.MockContext{C: &tb.Callback{Message: listMessage, Data: keyboard[1][1].Data}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastEditedWhat), "transactions 11-11 of 11 (page 2/2)", "")
+
+	keyboard = inlineKeyboard(t, bot.LastSentOptions)
+	helpers.TestExpect(t, keyboard[0][0].Data, "rmq|10||11", "deleting should be confirmed first")
+	bc.handleListCallback(&botTest.MockContext{C: &tb.Callback{Message: listMessage, Data: keyboard[0][0].Data}})
+	keyboard = inlineKeyboard(t, bot.LastSentOptions)
+	helpers.TestExpect(t, keyboard[0][0].Text, "Really delete 11?", "")
+	helpers.TestExpect(t, keyboard[0][1].Data, "p|10||0", "")
+
+	bc.handleListCallback(&botTest.MockContext{C: &tb.Callback{Message: listMessage, Data: keyboard[0][0].Data}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastEditedWhat), "transactions 1-10 of 10 (page 1/1)", "")
+	keyboard = inlineKeyboard(t, bot.LastSentOptions)
+	helpers.TestExpect(t, len(keyboard), LIST_PAGE_SIZE, "no navigation for single page")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestListFile(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID, true, "2022-01-01", "2022-01-31").
+		WillReturnRows(listPageRows(1, 2))
+	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_TZOFF).WillReturnRows(mock.NewRows([]string{"value"}))
+	// Too long for messages
+	rows := sqlmock.NewRows([]string{"id", "value", "created"})
+	for i := 0; i < 30; i++ {
+		rows.AddRow(i, strings.Repeat("*", 1000), "")
+	}
+	mock.ExpectQuery(`SELECT "id", "value", "created" FROM "bot::transaction"`).WithArgs(chat.ID, false).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_TZOFF).WillReturnRows(mock.NewRows([]string{"value"}))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list from:2022-01-01 all"}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "only supported for files", "")
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list file from:2022-02-01 to:2022-01-31"}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "must not be after", "")
+
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list archived file from:2022-01-01 to:2022-01-31"}})
+	doc, isDoc := bot.LastSentWhat.(*tb.Document)
+	if !isDoc {
+		t.Fatalf("Expected a document to be sent: %v", bot.LastSentWhat)
+	}
+	helpers.TestExpect(t, strings.HasPrefix(doc.FileName, "transactions-") && strings.HasSuffix(doc.FileName, ".beancount"), true, doc.FileName)
+	helpers.TestExpect(t, doc.Caption, "2 transaction(s)", "")
+	content, _ := io.ReadAll(doc.FileReader)
+	helpers.TestExpect(t, string(content), "tx1\ntx2", "")
+
+	bot.Reset()
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list all"}})
+	helpers.TestExpect(t, len(bot.AllLastSentWhat), 1, "long list should be sent as single file")
+	doc, isDoc = bot.LastSentWhat.(*tb.Document)
+	helpers.TestExpect(t, isDoc && strings.Contains(doc.Caption, "too long for messages"), true, "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestGroupListEntries(t *testing.T) {
+	tx := []*crud.TransactionResult{
+		{Tx: "2022-01-30 * \"A\"\n  Assets:Wallet  -1.00 EUR\n  Expenses:Food\n", Date: "2022-02-03T10:00:00Z"},
+		{Tx: "; a comment\n", Date: "2022-01-30T10:00:00Z"},
+		{Tx: "2022-02-01 * \"B\"\n  Assets:Wallet  -1.00 EUR\n  Expenses:Food\n", Date: "2022-02-01T10:00:00Z"},
+	}
+	entries := []string{"A", "comment", "B"}
+	helpers.TestExpectArrEq(t, GroupListEntries(tx, entries, ""), entries, "")
+	helpers.TestExpectArrEq(t, GroupListEntries(tx, entries, LIST_GROUP_DAY),
+		[]string{"; ---- 2022-01-30 ----", "A", "comment", "; ---- 2022-02-01 ----", "B"}, "")
+	helpers.TestExpectArrEq(t, GroupListEntries(tx, entries, LIST_GROUP_MONTH),
+		[]string{"; ---- 2022-01 ----", "A", "comment", "; ---- 2022-02 ----", "B"}, "")
+}
+
+func TestListSortedGrouped(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction" WHERE .* ORDER BY COALESCE\("bookingDate", DATE\("created"\)\) ASC, "created" ASC, "id" ASC`).WithArgs(chat.ID, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(2, "2022-01-03 * \"Back-dated\"", "2022-01-05T10:00:00Z", false).
+			AddRow(1, "2022-01-04 * \"Earlier recorded\"", "2022-01-04T10:00:00Z", false))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list all group:day"}})
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "; ---- 2022-01-03 ----\n2022-01-03 * \"Back-dated\"\n; ---- 2022-01-04 ----\n2022-01-04 * \"Earlier recorded\"", "")
+
+	bc.commandList(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "/list group:year"}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Transactions can be grouped by 'day' or 'month'", "")
+
+	helpers.TestExpect(t, listPage{Sorted: true, Grouping: LIST_GROUP_MONTH}.options(), "sM", "")
+	_, page, _, err := parseListCallbackData("p|10|sM|0")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, page.Sorted && page.Grouping == LIST_GROUP_MONTH, true, "options should survive callbacks")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
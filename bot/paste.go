@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// PASTE_MAX_ERRORS_SHOWN is the number of entries which could not be recorded listed in the response
+const PASTE_MAX_ERRORS_SHOWN = 20
+
+// PasteError describes an entry of pasted text which has not been recorded
+type PasteError struct {
+	Entry int
+	Line  int
+	Text  string
+	Err   error
+}
+
+func (e *PasteError) String() string {
+	return fmt.Sprintf("Entry %d (line %d) '%s': %s", e.Entry, e.Line, strings.SplitN(strings.TrimSpace(e.Text), "\n", 2)[0], e.Err.Error())
+}
+
+// pasteContent returns the text following the command or else the text of the message replied to
+func pasteContent(m *tb.Message) string {
+	content := ""
+	if parts := strings.SplitN(strings.TrimSpace(m.Text), "\n", 2); strings.HasPrefix(parts[0], "/") {
+		content = strings.TrimSpace(strings.TrimPrefix(parts[0], strings.Fields(parts[0])[0]))
+		if len(parts) > 1 {
+			// Keep line numbers relative to the entries if they start on the line following the command
+			if content == "" {
+				content = parts[1]
+			} else {
+				content += "\n" + parts[1]
+			}
+		}
+	}
+	if strings.TrimSpace(content) == "" && m.ReplyTo != nil {
+		content = m.ReplyTo.Text
+	}
+	return content
+}
+
+func (bc *BotController) commandPaste(c tb.Context) error {
+	m := c.Message()
+	if attachedDocument(m) != nil && strings.TrimSpace(pasteContent(m)) == "" {
+		bc.pasteDocument(m)
+		return nil
+	}
+	if content := pasteContent(m); strings.TrimSpace(content) != "" {
+		if bc.State.GetType(m) != ST_NONE {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), MSG_UNFINISHED_STATE)
+			return nil
+		}
+		bc.pasteEntries(m, content)
+		return nil
+	}
+	if bc.State.GetType(m) != ST_NONE {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), MSG_UNFINISHED_STATE)
+		return nil
+	}
+	bc.State.StartDocument(m, DOC_PASTE)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), "Please send me your beancount entries now, either as message or as document. Each entry will be recorded as a transaction of its own. You can /cancel this operation.", clearKeyboard())
+	return nil
+}
+
+func (bc *BotController) pasteDocument(m *tb.Message, params ...string) {
+	doc := attachedDocument(m)
+	if doc == nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No file has been found to read your entries from.", clearKeyboard())
+		return
+	}
+	content, err := bc.downloadDocument(doc, h.MAX_DOCUMENT_SIZE)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Error encountered while reading your entries: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.pasteEntries(m, content)
+}
+
+// RecordPastedEntries records every valid directive of the beancount text as a transaction of its own. Blocks only consisting
+// of comments are ignored.
+func (bc *BotController) RecordPastedEntries(m *tb.Message, content string) (recorded int, failed []*PasteError) {
+	failed = []*PasteError{}
+	entry := 0
+	for _, block := range h.SplitBeancount(content) {
+		if block.IsComment() {
+			continue
+		}
+		entry++
+		err := h.ValidateBeancountDirective(block.Text)
+		if err == nil {
+			err = bc.Repo.RecordTransaction(m, strings.TrimRight(block.Text, " \t\n")+"\n")
+		}
+		if err != nil {
+			failed = append(failed, &PasteError{Entry: entry, Line: block.Line, Text: block.Text, Err: err})
+			continue
+		}
+		recorded++
+	}
+	return recorded, failed
+}
+
+func (bc *BotController) pasteEntries(m *tb.Message, content string) {
+	recorded, failed := bc.RecordPastedEntries(m, content)
+	total := recorded + len(failed)
+	if total == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No beancount entries have been found in your text.", clearKeyboard())
+		return
+	}
+	bc.Logf(DEBUG, m, "Pasted %d of %d entries", recorded, total)
+	msg := fmt.Sprintf("Recorded %d of %d entries. /%s", recorded, total, CMD_LIST)
+	if len(failed) > 0 {
+		lines := []string{}
+		for i, f := range failed {
+			if i >= PASTE_MAX_ERRORS_SHOWN {
+				lines = append(lines, fmt.Sprintf("... and %d more", len(failed)-PASTE_MAX_ERRORS_SHOWN))
+				break
+			}
+			lines = append(lines, f.String())
+		}
+		msg += fmt.Sprintf("\n\n%d entries could not be recorded:\n%s", len(failed), strings.Join(lines, "\n"))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), msg, clearKeyboard())
+}
@@ -0,0 +1,62 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const pasteTestEntries = `; From my notes
+2024-01-02 * "Groceries"
+  Assets:Cash  -12.50 EUR
+  Expenses:Food
+
+2024-01-03 * "Coffee"
+  Assets:Cash  -3.00.1 EUR
+  Expenses:Drinks
+2024-01-03 price BTC 40000 EUR
+Lunch with Anna
+`
+
+func TestCommandPaste(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -262}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{Files: map[string]string{"notes.beancount": "2024-01-05 * \"Cinema\"\n  Assets:Cash  -9 EUR\n  Expenses:Fun\n"}}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	_, err := bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	defer bc.DeleteUserData(m)
+
+	bc.commandPaste(&botTest.MockContext{M: &tb.Message{Text: "/paste\n" + pasteTestEntries, Chat: chat, Sender: m.Sender}})
+	msg := fmt.Sprintf("%v", bot.LastSentWhat)
+	helpers.TestStringContains(t, msg, "Recorded 2 of 4 entries.", "")
+	helpers.TestStringContains(t, msg, "2 entries could not be recorded:\nEntry 2 (line 6) '2024-01-03 * \"Coffee\"': line 2: could not read amount", "")
+	helpers.TestStringContains(t, msg, "Entry 4 (line 10) 'Lunch with Anna': 'Lunch with Anna' is no beancount directive", "")
+	tx, err := bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(tx), 2, "")
+	helpers.TestExpect(t, tx[0].Tx, "2024-01-02 * \"Groceries\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Food\n", "the comment only block should be ignored")
+	helpers.TestExpect(t, tx[1].Tx, "2024-01-03 price BTC 40000 EUR\n", "")
+
+	// Waiting for the entries, which are then sent as text
+	bc.commandPaste(&botTest.MockContext{M: &tb.Message{Text: "/paste", Chat: chat, Sender: m.Sender}})
+	helpers.TestExpect(t, bc.State.GetType(m), ST_DOC, "")
+	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Text: "Nothing to see here", Chat: chat, Sender: m.Sender}})
+	helpers.TestExpect(t, bc.State.GetType(m), ST_NONE, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Recorded 0 of 1 entries.", "")
+
+	bc.handleDocument(&botTest.MockContext{M: &tb.Message{Caption: "/paste", Chat: chat, Sender: m.Sender, Document: &tb.Document{File: tb.File{FileID: "notes.beancount"}}}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Recorded 1 of 1 entries.", "")
+	tx, err = bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(tx), 3, "")
+	helpers.TestExpect(t, strings.HasPrefix(tx[2].Tx, "2024-01-05 * \"Cinema\""), true, "")
+}
@@ -0,0 +1,250 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	PENDING_CALLBACK_UNIQUE = "pending"
+
+	// PENDING_REMIND_DAYS_DEFAULT is used if the user did not set after how many days to be reminded
+	PENDING_REMIND_DAYS_DEFAULT = 7
+)
+
+// IsPendingOption is true for the option recording a transaction as pending, e.g. '/simple pending'
+func IsPendingOption(option string) bool {
+	return option == "pending" || option == h.FLAG_PENDING
+}
+
+func (bc *BotController) commandPending(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_PENDING, true)
+	sc.
+		Add("confirm", bc.pendingHandleConfirm).
+		Add("remind", bc.pendingHandleRemind)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		if strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_PENDING)) != "" {
+			bc.pendingHelp(m, fmt.Errorf("unknown subcommand"))
+			return nil
+		}
+		bc.pendingHandleList(m)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled pending subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) pendingHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s - List your open transactions flagged as pending ('!'), e.g. card payments not settled yet
+/%s confirm <number>|all - Flag pending transactions as complete ('*')
+/%s remind [<days>|off] - Get reminded of transactions pending for longer than the number of days (default: %d) at your notification hour set in /%s
+
+Record a transaction as pending with '/%s [date] pending' or with a template flagged with '!'.`,
+		CMD_PENDING, CMD_PENDING, CMD_PENDING, CMD_PENDING, PENDING_REMIND_DAYS_DEFAULT, CMD_CONFIG, CMD_SIMPLE), clearKeyboard())
+}
+
+func (bc *BotController) pendingTransactions(m *tb.Message) ([]*crud.TransactionResult, error) {
+	isArchived := false
+	return bc.Repo.FindTransactions(m, crud.TransactionFilter{Archived: &isArchived, Pending: true, SortByBookingDate: true})
+}
+
+// renderPendingList lists the first LIST_PAGE_SIZE pending transactions with a button to confirm each of them.
+// The text is empty if there are no pending transactions.
+func (bc *BotController) renderPendingList(m *tb.Message) (string, *tb.ReplyMarkup, error) {
+	tx, err := bc.pendingTransactions(m)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(tx) == 0 {
+		return "", nil, nil
+	}
+	shown := tx
+	if len(shown) > LIST_PAGE_SIZE {
+		shown = shown[:LIST_PAGE_SIZE]
+	}
+	markup := &tb.ReplyMarkup{}
+	rows := []tb.Row{}
+	entries := bc.formatListEntries(m, shown, false, 1)
+	for i, t := range shown {
+		entries[i] = shortenListEntry(entries[i])
+		rows = append(rows, markup.Row(markup.Data(fmt.Sprintf("Confirm %d", i+1), PENDING_CALLBACK_UNIQUE, strconv.Itoa(t.Id))))
+	}
+	markup.Inline(rows...)
+
+	header := fmt.Sprintf("Your %d pending transaction(s). Confirm them once they are settled, or use '/%s confirm all'.\n\n", len(tx), CMD_PENDING)
+	footer := ""
+	if len(tx) > len(shown) {
+		footer = fmt.Sprintf("\n\n... and %d more. Confirm the ones above to see them.", len(tx)-len(shown))
+	}
+	return header + strings.Join(entries, "\n") + footer, markup, nil
+}
+
+func (bc *BotController) pendingHandleList(m *tb.Message) {
+	text, markup, err := bc.renderPendingList(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your pending transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if text == "" {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You have no pending transactions. Record one with '/%s pending'.", CMD_SIMPLE), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), text, markup)
+}
+
+func (bc *BotController) pendingHandleConfirm(m *tb.Message, params ...string) {
+	if len(params) != 1 {
+		bc.pendingHelp(m, fmt.Errorf("please specify exactly one number as seen in /%s, or 'all'", CMD_PENDING))
+		return
+	}
+	tx, err := bc.pendingTransactions(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your pending transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if strings.ToLower(params[0]) != "all" {
+		number, err := strconv.Atoi(params[0])
+		if err != nil || number < 1 {
+			bc.pendingHelp(m, fmt.Errorf("'%s' is no valid number", params[0]))
+			return
+		}
+		if number > len(tx) {
+			bc.pendingHelp(m, fmt.Errorf("the number you specified was too high. You have %d pending transaction(s)", len(tx)))
+			return
+		}
+		tx = tx[number-1 : number]
+	}
+	confirmed := int64(0)
+	for _, t := range tx {
+		count, err := bc.Repo.ConfirmTransaction(m, t.Id)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong confirming your transaction: "+err.Error(), clearKeyboard())
+			return
+		}
+		confirmed += count
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Confirmed %d transaction(s). See them in /%s.", confirmed, CMD_LIST), clearKeyboard())
+}
+
+func (bc *BotController) handlePendingCallback(c tb.Context) error {
+	m := c.Message()
+	id, err := strconv.Atoi(c.Callback().Data)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not read pending callback: %s", err.Error())
+		return c.Respond(&tb.CallbackResponse{Text: "This list is outdated. Please request a new /" + CMD_PENDING})
+	}
+	bc.Logf(TRACE, m, "Confirming pending transaction %d", id)
+	if c.Callback().Sender != nil {
+		// The list message has been sent by the bot. The transaction is confirmed by the user pressing the button.
+		actor := *m
+		actor.Sender = c.Callback().Sender
+		m = &actor
+	}
+	count, err := bc.Repo.ConfirmTransaction(m, id)
+	if err != nil {
+		bc.Logf(ERROR, m, "Error confirming pending transaction: %s", err.Error())
+		return c.Respond(&tb.CallbackResponse{Text: "Something went wrong: " + err.Error()})
+	}
+	response := "Confirmed the transaction."
+	if count == 0 {
+		response = "The transaction is not pending anymore."
+	}
+
+	text, markup, err := bc.renderPendingList(m)
+	if err != nil {
+		return c.Respond(&tb.CallbackResponse{Text: "Something went wrong retrieving your pending transactions: " + err.Error()})
+	}
+	if text == "" {
+		text = "All your pending transactions have been confirmed."
+		markup = &tb.ReplyMarkup{}
+	}
+	_, err = bc.Bot.Edit(c.Message(), text, markup)
+	if err != nil && err != tb.ErrSameMessageContent {
+		bc.Logf(ERROR, m, "Could not update pending list message: %s", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{Text: response})
+}
+
+func (bc *BotController) pendingHandleRemind(m *tb.Message, params ...string) {
+	if len(params) > 1 {
+		bc.pendingHelp(m, fmt.Errorf("invalid amount of parameters specified"))
+		return
+	}
+	if len(params) == 0 {
+		days := bc.pendingRemindDays(m)
+		if days == 0 {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "You are not reminded of pending transactions.", clearKeyboard())
+			return
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You are reminded of transactions pending for longer than %d day(s).%s", days, bc.pendingReminderScheduleHint(m)), clearKeyboard())
+		return
+	}
+	if strings.ToLower(params[0]) == "off" {
+		err := bc.Repo.SetUserSetting(h.USERSET_PENDINGDAYS, "0", m.Chat.ID)
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong saving your setting: "+err.Error(), clearKeyboard())
+			return
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "You will not be reminded of pending transactions anymore.", clearKeyboard())
+		return
+	}
+	days, err := strconv.Atoi(params[0])
+	if err != nil || days < 1 {
+		bc.pendingHelp(m, fmt.Errorf("'%s' is no valid number of days", params[0]))
+		return
+	}
+	err = bc.Repo.SetUserSetting(h.USERSET_PENDINGDAYS, strconv.Itoa(days), m.Chat.ID)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong saving your setting: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You will be reminded of transactions pending for longer than %d day(s).%s", days, bc.pendingReminderScheduleHint(m)), clearKeyboard())
+}
+
+// pendingRemindDays returns after how many days the user wants to be reminded of pending transactions. 0 if disabled.
+func (bc *BotController) pendingRemindDays(m *tb.Message) int {
+	exists, value, err := bc.Repo.GetUserSetting(h.USERSET_PENDINGDAYS, m.Chat.ID)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not get pending reminder setting: %s", err.Error())
+	}
+	days, err := strconv.Atoi(value)
+	if !exists || err != nil {
+		return PENDING_REMIND_DAYS_DEFAULT
+	}
+	return days
+}
+
+func (bc *BotController) pendingReminderScheduleHint(m *tb.Message) string {
+	daysDelay, _, err := bc.Repo.UserGetNotificationSetting(m)
+	if err != nil || daysDelay < 0 {
+		return fmt.Sprintf(" Reminders are sent at your notification hour. Please enable notifications in /%s first.", CMD_CONFIG)
+	}
+	return ""
+}
+
+func (bc *BotController) cronPendingReminders() {
+	chats, err := bc.Repo.GetPendingChatsToNotify(PENDING_REMIND_DAYS_DEFAULT)
+	if err != nil {
+		bc.Logf(ERROR, nil, "Error getting chats to remind of pending transactions: %s", err.Error())
+		return
+	}
+	for chatId, count := range chats {
+		bc.Logf(TRACE, nil, "Sending reminder of %d pending transaction(s) to %d", count, chatId)
+		bc.Bot.SendSilent(bc.Logf, ReceiverImpl{ChatId: strconv.FormatInt(chatId, 10)}, fmt.Sprintf(
+			"This is your reminder that %d of your transactions have been pending for a while. Check /%s to confirm them once they are settled."+
+				"\n\nYou are getting this message because you enabled reminder notifications in /%s. Change when you are reminded of pending transactions with '/%s remind'.",
+			count, CMD_PENDING, CMD_CONFIG, CMD_PENDING))
+	}
+}
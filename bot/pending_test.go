@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestCommandPending(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -265}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	defer bc.DeleteUserData(m)
+
+	command := func(text string) string {
+		bc.commandPending(&botTest.MockContext{M: &tb.Message{Text: text, Chat: chat, Sender: m.Sender}})
+		return fmt.Sprintf("%v", bot.LastSentWhat)
+	}
+	helpers.TestStringContains(t, command("/pending"), "You have no pending transactions.", "")
+
+	// Record a pending transaction using the questionnaire
+	bc.commandCreateSimpleTx(&botTest.MockContext{M: &tb.Message{Text: "/simple 2024-01-02 pending", Chat: chat, Sender: m.Sender}})
+	for _, input := range []string{"12.50", "Card payment", "Liabilities:Card", "Expenses:Food"} {
+		bc.handleTextState(&botTest.MockContext{M: &tb.Message{Text: input, Chat: chat, Sender: m.Sender}})
+	}
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "It is flagged as pending.", "")
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-01 * \"Settled\"\n  Assets:Cash  -1.00 EUR\n  Expenses:Food\n"))
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-03 ! \"Hotel\"\n  Liabilities:Card  -80.00 EUR\n  Expenses:Travel\n"))
+
+	list := command("/pending")
+	helpers.TestStringContains(t, list, "Your 2 pending transaction(s).", "")
+	helpers.TestStringContains(t, list, "1) 2024-01-02 ! \"Card payment\"", "")
+	helpers.TestStringContains(t, list, "2) 2024-01-03 ! \"Hotel\"", "")
+	keyboard := inlineKeyboard(t, bot.LastSentOptions)
+	helpers.TestExpect(t, len(keyboard), 2, "")
+	helpers.TestExpect(t, keyboard[0][0].Text, "Confirm 1", "")
+
+	// Confirm the first one with a tap
+	bc.handlePendingCallback(&botTest.MockContext{C: &tb.Callback{Message: &tb.Message{ID: 99, Chat: chat}, Sender: m.Sender, Data: keyboard[0][0].Data}})
+	edited := fmt.Sprintf("%v", bot.LastEditedWhat)
+	helpers.TestStringContains(t, edited, "Your 1 pending transaction(s).", "")
+	helpers.TestStringContains(t, edited, "1) 2024-01-03 ! \"Hotel\"", "")
+	id, _ := strconv.Atoi(keyboard[0][0].Data)
+	events, err := bc.Repo.GetTransactionEvents(m, id, 0)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, events[0].Event, "update", "")
+	helpers.TestStringContains(t, events[0].After, "2024-01-02 * \"Card payment\"", "")
+
+	helpers.TestStringContains(t, command("/pending confirm 2"), "the number you specified was too high", "")
+	helpers.TestStringContains(t, command("/pending confirm all"), "Confirmed 1 transaction(s).", "")
+	helpers.TestStringContains(t, command("/pending"), "You have no pending transactions.", "")
+
+	// Reminders
+	helpers.TestStringContains(t, command("/pending remind"), fmt.Sprintf("longer than %d day(s). Reminders are sent at your notification hour. Please enable notifications", PENDING_REMIND_DAYS_DEFAULT), "")
+	helpers.TestStringContains(t, command("/pending remind 0"), "'0' is no valid number of days", "")
+	botTest.HandleErr(t, bc.Repo.UserSetNotificationSetting(m, 0, time.Now().UTC().Hour()))
+	helpers.TestExpect(t, command("/pending remind 3"), "You will be reminded of transactions pending for longer than 3 day(s).", "")
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-04 ! \"Fuel\"\n  Liabilities:Card  -50.00 EUR\n  Expenses:Car\n"))
+	chats, err := bc.Repo.GetPendingChatsToNotify(PENDING_REMIND_DAYS_DEFAULT)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, chats[chat.ID], 0, "recently recorded transactions are not reminded of")
+
+	_, err = conn.Exec(`UPDATE "bot::transaction" SET "created" = '2024-01-04 12:00:00' WHERE "tgChatId" = $1`, chat.ID)
+	botTest.HandleErr(t, err)
+	chats, err = bc.Repo.GetPendingChatsToNotify(PENDING_REMIND_DAYS_DEFAULT)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, chats[chat.ID], 1, "")
+
+	helpers.TestStringContains(t, command("/pending remind off"), "You will not be reminded of pending transactions anymore.", "")
+	chats, err = bc.Repo.GetPendingChatsToNotify(PENDING_REMIND_DAYS_DEFAULT)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, chats[chat.ID], 0, "reminders are turned off")
+}
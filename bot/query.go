@@ -0,0 +1,91 @@
+package bot
+
+import (
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const QUERY_USAGE = `Usage help for /query:
+/query SELECT <columns> [WHERE <condition>] [GROUP BY <columns>] [ORDER BY <columns> [ASC|DESC]] [LIMIT <n>]
+
+Runs a query in a subset of the beancount query language over the postings of your open and archived transactions.
+
+Columns: date, year, month, day, flag, payee, narration, description, tags, links, account, number, currency, position
+Functions: year, month, day, root(account, n), leaf, parent, abs, units, length, lower, upper
+Aggregates: sum, count, min, max, first, last
+Operators: = != < <= > >= ~ (regular expression) !~ IN AND OR NOT + - * /
+
+Selected columns not using aggregates have to be part of GROUP BY. Sums can only be ordered if they are in a single currency.
+
+Example: /query SELECT account, sum(position) WHERE account ~ "Food" AND date >= 2024-01-01 GROUP BY account`
+
+// QueryEntries returns all open and archived transactions to run queries on
+func (bc *BotController) QueryEntries(m *tb.Message) ([]*h.BeancountEntry, error) {
+	tx, err := bc.Repo.FindTransactions(m, crud.TransactionFilter{})
+	if err != nil {
+		return nil, err
+	}
+	entries := []*h.BeancountEntry{}
+	for _, t := range tx {
+		parsed, err := h.ParseBeancount(t.Tx)
+		if err != nil {
+			bc.Logf(WARN, m, "Skipping transaction %d in query: %s", t.Id, err.Error())
+			continue
+		}
+		entries = append(entries, parsed...)
+	}
+	return entries, nil
+}
+
+// queryText strips the command from the message text. Quotes are kept as part of the query.
+func queryText(text string) string {
+	return h.CommandRemainder(text, 1)
+}
+
+func (bc *BotController) commandQuery(c tb.Context) error {
+	m := c.Message()
+	q := queryText(m.Text)
+	if q == "" {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), QUERY_USAGE, clearKeyboard())
+		return nil
+	}
+	query, err := h.ParseQuery(q)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error running your query: %s\n\nSee /%s for the supported syntax.", err.Error(), CMD_QUERY), clearKeyboard())
+		return nil
+	}
+	entries, err := bc.QueryEntries(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	res, err := query.Run(entries)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error running your query: %s\n\nSee /%s for the supported syntax.", err.Error(), CMD_QUERY), clearKeyboard())
+		return nil
+	}
+	if len(res.Rows) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Your query did not return any results.", clearKeyboard())
+		return nil
+	}
+	table := "<pre>" + html.EscapeString(res.Table()) + "</pre>"
+	if len(table) <= h.TG_MAX_MSG_CHAR_LEN {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), table, clearKeyboard(), tb.ModeHTML)
+		return nil
+	}
+	csv, err := res.CSV()
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong formatting your query result: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	doc := documentFromString(fmt.Sprintf("query-%s.csv", time.Now().Format(h.BEANCOUNT_DATE_FORMAT)), csv)
+	doc.MIME = "text/csv"
+	doc.Caption = fmt.Sprintf("Your query returned %d rows, which is too much for a message.", len(res.Rows))
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), doc, clearKeyboard())
+	return nil
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestCommandQuery(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(1, "2024-01-02 * \"Shop\" \"Groceries\"\n  Assets:Cash -12.50 EUR\n  Expenses:Food 12.50 EUR\n", "2024-01-02T10:00:00Z", false).
+			AddRow(2, "2024-01-03 * \"\" \"Lunch\"\n  Assets:Cash -7.50 EUR\n  Expenses:Food:Lunch\n", "2024-01-03T10:00:00Z", true)
+	}
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID).WillReturnRows(rows())
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).WithArgs(chat.ID).WillReturnRows(rows())
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandQuery(&botTest.MockContext{M: &tb.Message{Text: "/query", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /query", "")
+
+	bc.commandQuery(&botTest.MockContext{M: &tb.Message{Text: "/query SELECT amount", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Error running your query: syntax error at 'amount'", "")
+
+	bc.commandQuery(&botTest.MockContext{M: &tb.Message{Text: `/query SELECT root(account, 2) AS account, sum(position) WHERE account ~ "Food" GROUP BY account`, Chat: chat}})
+	helpers.TestExpect(t, bot.LastSentWhat, "<pre>account        sum(position)\n-------------  -------------\nExpenses:Food      20.00 EUR</pre>", "")
+
+	bc.commandQuery(&botTest.MockContext{M: &tb.Message{Text: "/query SELECT narration WHERE payee = 'nobody'", Chat: chat}})
+	helpers.TestExpect(t, bot.LastSentWhat, "Your query did not return any results.", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestQueryText(t *testing.T) {
+	helpers.TestExpect(t, queryText("/query@bot  SELECT *\nWHERE payee = 'a  b'"), "SELECT *\nWHERE payee = 'a  b'", "")
+	helpers.TestExpect(t, queryText(" /query "), "", "")
+}
@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// RATES_LATEST is a date after all dates rates can be set for
+const RATES_LATEST = "9999-12-31"
+
+// ExchangeRates are the rates of a user, ordered by date
+type ExchangeRates []*crud.Rate
+
+func (rates ExchangeRates) directRate(base, quote, date string) (float64, bool) {
+	var found *crud.Rate
+	for _, rate := range rates {
+		if rate.Base != base || rate.Quote != quote {
+			continue
+		}
+		if found == nil || rate.Date <= date {
+			found = rate
+		}
+	}
+	if found == nil {
+		return 0, false
+	}
+	return found.Rate, true
+}
+
+// Rate returns the rate of the base currency in the quote currency valid on the date, i.e. the latest one set on or
+// before it. If there is none, the earliest rate is used. The inverse pair is used if there are no rates for the pair.
+func (rates ExchangeRates) Rate(base, quote, date string) (float64, bool) {
+	if rate, ok := rates.directRate(base, quote, date); ok {
+		return rate, true
+	}
+	if rate, ok := rates.directRate(quote, base, date); ok && rate != 0 {
+		return 1 / rate, true
+	}
+	return 0, false
+}
+
+// Latest returns the most recent rate of the base currency in the quote currency
+func (rates ExchangeRates) Latest(base, quote string) (float64, bool) {
+	return rates.Rate(base, quote, RATES_LATEST)
+}
+
+// PriceDirectives renders the rates as beancount price directives
+func (rates ExchangeRates) PriceDirectives() string {
+	s := ""
+	for _, rate := range rates {
+		s += fmt.Sprintf("%s price %s %s %s\n", rate.Date, rate.Base, formatRate(rate.Rate), rate.Quote)
+	}
+	return s
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// ConvertEntries converts the postings in other currencies to the currency. Postings with price or cost are converted
+// by their weight, the others using the rate valid on the booking date. Postings without known rate are left unchanged.
+func ConvertEntries(entries []*h.BeancountEntry, rates ExchangeRates, currency string) {
+	for _, entry := range entries {
+		for _, p := range entry.Postings {
+			if p.Currency == "" || p.Currency == currency {
+				continue
+			}
+			amount, amountCurrency := p.Weight()
+			if amountCurrency != currency {
+				rate, ok := rates.Rate(amountCurrency, currency, entry.Date)
+				if !ok {
+					continue
+				}
+				amount *= rate
+			}
+			p.Amount, p.Currency, p.Annotation = amount, currency, ""
+		}
+	}
+}
+
+// annotatePrices adds the latest rate as price to postings in foreign currencies if the user turned this on
+func (bc *BotController) annotatePrices(m *tb.Message, transaction, currency string) string {
+	_, value, err := bc.Repo.GetUserSetting(h.USERSET_AUTOPRICE, m.Chat.ID)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not check whether to annotate prices: %s", err.Error())
+		return transaction
+	}
+	if strings.ToUpper(value) != "TRUE" {
+		return transaction
+	}
+	rates, err := bc.Repo.GetRates(m)
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not retrieve exchange rates to annotate prices: %s", err.Error())
+		return transaction
+	}
+	return h.AnnotatePostings(transaction, func(postingCurrency string) string {
+		if postingCurrency == currency {
+			return ""
+		}
+		rate, ok := ExchangeRates(rates).Latest(postingCurrency, currency)
+		if !ok {
+			return ""
+		}
+		return "@ " + formatRate(rate) + " " + currency
+	})
+}
+
+func (bc *BotController) commandRates(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_RATES, true)
+	sc.
+		Add("set", bc.ratesHandleSet).
+		Add("rm", bc.ratesHandleRemove).
+		Add("prices", bc.ratesHandlePrices)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		if strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_RATES)) != "" {
+			bc.ratesHelp(m, fmt.Errorf("unknown subcommand"))
+			return nil
+		}
+		bc.ratesHandleList(m)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled rates subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) ratesHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s - List your exchange rates
+/%s set <currency> <quote currency> <rate> [date] - Set the price of one unit of a currency, e.g. '/%s set USD EUR 0.92'. Defaults to today.
+/%s rm <currency> <quote currency> [date] - Remove the rates of a currency pair
+/%s prices - Get your rates as beancount price directives
+
+Reports are converted to your currency using the rate valid on the booking date. To add the latest rate as price to amounts in other currencies, use '/%s auto_price on'.`,
+		CMD_RATES, CMD_RATES, CMD_RATES, CMD_RATES, CMD_RATES, CMD_RATES, CMD_CONFIG), clearKeyboard())
+}
+
+func (bc *BotController) ratesHandleSet(m *tb.Message, params ...string) {
+	if len(params) < 3 || len(params) > 4 {
+		bc.ratesHelp(m, fmt.Errorf("please specify two currencies and a rate"))
+		return
+	}
+	rate := &crud.Rate{Base: params[0], Quote: params[1]}
+	for _, currency := range []string{rate.Base, rate.Quote} {
+		if !beancountCurrency.MatchString(currency) {
+			bc.ratesHelp(m, fmt.Errorf("'%s' is no valid currency", currency))
+			return
+		}
+	}
+	if rate.Base == rate.Quote {
+		bc.ratesHelp(m, fmt.Errorf("the currencies must differ"))
+		return
+	}
+	value, err := handleThousandsSeparators(params[2])
+	if err == nil {
+		rate.Rate, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil || rate.Rate <= 0 {
+		bc.ratesHelp(m, fmt.Errorf("'%s' is no valid positive rate", params[2]))
+		return
+	}
+	rate.Date = time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour).Format(h.BEANCOUNT_DATE_FORMAT)
+	if len(params) == 4 {
+		rate.Date, err = ParseDate(params[3])
+		if err != nil {
+			bc.ratesHelp(m, err)
+			return
+		}
+	}
+	err = bc.Repo.SetRate(m, rate)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong setting your exchange rate: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Set the exchange rate of 1 %s = %s %s from %s on.",
+		rate.Base, formatRate(rate.Rate), rate.Quote, rate.Date), clearKeyboard())
+}
+
+func (bc *BotController) ratesHandleList(m *tb.Message, params ...string) {
+	rates, err := bc.Repo.GetRates(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your exchange rates: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(rates) == 0 {
+		bc.ratesHelp(m, nil)
+		return
+	}
+	lines := []string{"Your exchange rates:"}
+	for _, rate := range rates {
+		lines = append(lines, fmt.Sprintf("%s: 1 %s = %s %s", rate.Date, rate.Base, formatRate(rate.Rate), rate.Quote))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+func (bc *BotController) ratesHandleRemove(m *tb.Message, params ...string) {
+	if len(params) < 2 || len(params) > 3 {
+		bc.ratesHelp(m, fmt.Errorf("please specify the currencies of the rates to remove"))
+		return
+	}
+	date := ""
+	if len(params) == 3 {
+		var err error
+		date, err = ParseDate(params[2])
+		if err != nil {
+			bc.ratesHelp(m, err)
+			return
+		}
+	}
+	count, err := bc.Repo.DeleteRate(m, params[0], params[1], date)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong removing your exchange rates: "+err.Error(), clearKeyboard())
+		return
+	}
+	if count == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("There are no exchange rates of %s in %s to remove.", params[0], params[1]), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Removed %d exchange rate(s) of %s in %s.", count, params[0], params[1]), clearKeyboard())
+}
+
+func (bc *BotController) ratesHandlePrices(m *tb.Message, params ...string) {
+	if len(params) > 0 {
+		bc.ratesHelp(m, fmt.Errorf("no parameters expected"))
+		return
+	}
+	rates, err := bc.Repo.GetRates(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your exchange rates: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(rates) == 0 {
+		bc.ratesHelp(m, fmt.Errorf("you have no exchange rates yet"))
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), ExchangeRates(rates).PriceDirectives(), clearKeyboard())
+}
@@ -0,0 +1,101 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestExchangeRates(t *testing.T) {
+	rates := ExchangeRates{
+		{Date: "2024-01-01", Base: "USD", Quote: "EUR", Rate: 0.9},
+		{Date: "2024-01-01", Base: "EUR", Quote: "CHF", Rate: 0.8},
+		{Date: "2024-02-01", Base: "USD", Quote: "EUR", Rate: 0.92},
+	}
+	rate, ok := rates.Rate("USD", "EUR", "2024-01-31")
+	helpers.TestExpect(t, ok, true, "")
+	helpers.TestExpect(t, rate, 0.9, "")
+	rate, _ = rates.Rate("USD", "EUR", "2023-12-01")
+	helpers.TestExpect(t, rate, 0.9, "the earliest rate should be used for earlier dates")
+	rate, _ = rates.Latest("USD", "EUR")
+	helpers.TestExpect(t, rate, 0.92, "")
+	rate, _ = rates.Rate("CHF", "EUR", "2024-01-02")
+	helpers.TestExpect(t, rate, 1.25, "the inverse rate should be used")
+	_, ok = rates.Rate("USD", "CHF", "2024-01-02")
+	helpers.TestExpect(t, ok, false, "")
+
+	helpers.TestExpect(t, rates.PriceDirectives(), "2024-01-01 price USD 0.9 EUR\n2024-01-01 price EUR 0.8 CHF\n2024-02-01 price USD 0.92 EUR\n", "")
+
+	entries, err := helpers.ParseBeancount(`2024-01-15 * "Dinner"
+  Assets:Dollars  -50.00 USD
+  Expenses:Food
+2024-02-15 * "Taxi"
+  Assets:Dollars  -10.00 USD @@ 9.50 EUR
+  Expenses:Transport
+2024-02-16 * "Cheese"
+  Assets:Franks  -20.00 GBP
+  Expenses:Food
+`)
+	botTest.HandleErr(t, err)
+	ConvertEntries(entries, rates, "EUR")
+	helpers.TestExpect(t, entries[0].Postings[1].Amount, 45.0, "")
+	helpers.TestExpect(t, entries[0].Postings[1].Currency, "EUR", "")
+	helpers.TestExpect(t, entries[1].Postings[0].Amount, -9.5, "the price should be used")
+	helpers.TestExpect(t, entries[1].Postings[0].Annotation, "", "")
+	helpers.TestExpect(t, entries[2].Postings[1].Currency, "GBP", "postings without rate should be left unchanged")
+}
+
+func TestCommandRates(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -263}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	_, err := bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	defer bc.DeleteUserData(m)
+	botTest.HandleErr(t, bc.Repo.UserSetCurrency(m, "EUR"))
+
+	command := func(text string) string {
+		bc.commandRates(&botTest.MockContext{M: &tb.Message{Text: text, Chat: chat, Sender: m.Sender}})
+		return fmt.Sprintf("%v", bot.LastSentWhat)
+	}
+	helpers.TestStringContains(t, command("/rates"), "Usage help for /rates", "")
+	helpers.TestStringContains(t, command("/rates set USD EUR 0,9 2024-01-01"), "Set the exchange rate of 1 USD = 0.9 EUR from 2024-01-01 on.", "")
+	command("/rates set USD EUR 0.92 2024-02-01")
+	helpers.TestStringContains(t, command("/rates set USD usd 1"), "'usd' is no valid currency", "")
+	helpers.TestStringContains(t, command("/rates set USD EUR -1"), "'-1' is no valid positive rate", "")
+	helpers.TestExpect(t, command("/rates"), "Your exchange rates:\n2024-01-01: 1 USD = 0.9 EUR\n2024-02-01: 1 USD = 0.92 EUR", "")
+	helpers.TestExpect(t, command("/rates prices"), "2024-01-01 price USD 0.9 EUR\n2024-02-01 price USD 0.92 EUR\n", "")
+
+	finish := func() string {
+		tx, err := ImportTx(&ImportRow{Date: "2024-01-15", Amount: -50, Currency: "USD", Payee: "Diner"}, "Assets:Dollars", "Expenses:Food")
+		botTest.HandleErr(t, err)
+		bc.finishTransaction(m, tx)
+		txs, err := bc.Repo.GetTransactions(m, false)
+		botTest.HandleErr(t, err)
+		return txs[len(txs)-1].Tx
+	}
+	helpers.TestExpect(t, strings.Contains(finish(), "@"), false, "prices should only be added if turned on")
+	bc.configHandleAutoPrice(m, "on")
+	_, err = bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	helpers.TestStringContains(t, finish(), "Assets:Dollars                              -50.00 USD @ 0.92 EUR\n", "the latest rate should be added as price")
+
+	bc.commandReport(&botTest.MockContext{M: &tb.Message{Text: "/report 2024-01-01..2024-01-31", Chat: chat, Sender: m.Sender}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Expenses:Food 46.00 EUR", "")
+
+	helpers.TestStringContains(t, command("/rates rm USD EUR 2024-02-01"), "Removed 1 exchange rate(s) of USD in EUR.", "")
+	helpers.TestStringContains(t, command("/rates rm USD EUR"), "Removed 1 exchange rate(s) of USD in EUR.", "")
+	helpers.TestStringContains(t, command("/rates rm USD EUR"), "There are no exchange rates of USD in EUR to remove.", "")
+	rates, err := bc.Repo.GetRates(m)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(rates), 0, "")
+}
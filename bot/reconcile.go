@@ -0,0 +1,150 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	RECONCILE_CALLBACK_UNIQUE = "reconcile"
+	RECONCILE_ACTION_ARCHIVE  = "ar"
+	RECONCILE_ARCHIVE_LABEL   = "reconciled"
+
+	// RECONCILE_MAX_LISTED is the number of matched and unmatched transactions listed each
+	RECONCILE_MAX_LISTED = 20
+)
+
+// ReconcileTransactions splits the open transactions into the ones contained in the ledger, compared by date,
+// accounts and amounts, and the others. Each ledger entry matches a single transaction at most.
+func ReconcileTransactions(open []*crud.TransactionResult, ledger []*h.BeancountEntry) (matched, unmatched []*crud.TransactionResult) {
+	available := map[string]int{}
+	for _, entry := range ledger {
+		available[entry.Fingerprint()]++
+	}
+	matched, unmatched = []*crud.TransactionResult{}, []*crud.TransactionResult{}
+	for _, tx := range open {
+		entry, err := h.ParseBeancountEntry(tx.Tx)
+		if err != nil {
+			unmatched = append(unmatched, tx)
+			continue
+		}
+		fingerprint := entry.Fingerprint()
+		if available[fingerprint] == 0 {
+			unmatched = append(unmatched, tx)
+			continue
+		}
+		available[fingerprint]--
+		matched = append(matched, tx)
+	}
+	return matched, unmatched
+}
+
+// reconcileSummary lists the first lines of the transactions, shortened to RECONCILE_MAX_LISTED
+func reconcileSummary(txs []*crud.TransactionResult) string {
+	lines := []string{}
+	for i, tx := range txs {
+		if i >= RECONCILE_MAX_LISTED {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(txs)-RECONCILE_MAX_LISTED))
+			break
+		}
+		lines = append(lines, strings.SplitN(strings.TrimSpace(tx.Tx), "\n", 2)[0])
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (bc *BotController) commandReconcile(c tb.Context) error {
+	m := c.Message()
+	if len(strings.Fields(m.Text)) > 1 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Usage help for /%s:\n/%s - Send your beancount ledger file to find the open transactions already contained in it. They can be archived afterwards.", CMD_RECONCILE, CMD_RECONCILE), clearKeyboard())
+		return nil
+	}
+	if attachedDocument(m) != nil {
+		bc.reconcileDocument(m)
+		return nil
+	}
+	if bc.State.GetType(m) != ST_NONE {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), MSG_UNFINISHED_STATE)
+		return nil
+	}
+	bc.State.StartDocument(m, DOC_RECONCILE)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), "Please send me your beancount ledger file as document now. I will check which of your open transactions it already contains. You can /cancel this operation.", clearKeyboard())
+	return nil
+}
+
+func (bc *BotController) reconcileDocument(m *tb.Message, params ...string) {
+	doc := attachedDocument(m)
+	if doc == nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No ledger file has been found to reconcile with.", clearKeyboard())
+		return
+	}
+	content, err := bc.downloadDocument(doc, h.MAX_LEDGER_SIZE)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Error encountered while reading your ledger: "+err.Error(), clearKeyboard())
+		return
+	}
+	ledger, skipped := h.ParseBeancountLenient(content)
+	open, err := bc.Repo.GetTransactions(m, false)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(open) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "You have no open transactions to reconcile.", clearKeyboard())
+		return
+	}
+	matched, unmatched := ReconcileTransactions(open, ledger)
+
+	msg := fmt.Sprintf("Read %d transaction(s) of your ledger.", len(ledger))
+	if skipped > 0 {
+		msg += fmt.Sprintf(" %d transaction(s) could not be read and have been ignored.", skipped)
+	}
+	if len(matched) > 0 {
+		msg += fmt.Sprintf("\n\n%d of your %d open transaction(s) are already in your ledger:\n%s", len(matched), len(open), reconcileSummary(matched))
+	}
+	if len(unmatched) > 0 {
+		msg += fmt.Sprintf("\n\n%d open transaction(s) have not been found in your ledger:\n%s", len(unmatched), reconcileSummary(unmatched))
+	}
+	if len(matched) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), msg, clearKeyboard())
+		return
+	}
+	ids := []int{}
+	for _, tx := range matched {
+		ids = append(ids, tx.Id)
+	}
+	bc.State.SetReconciled(m, ids)
+	markup := &tb.ReplyMarkup{}
+	markup.Inline(markup.Row(markup.Data(fmt.Sprintf("Archive %d matched", len(matched)), RECONCILE_CALLBACK_UNIQUE, RECONCILE_ACTION_ARCHIVE)))
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), msg, markup)
+}
+
+func (bc *BotController) handleReconcileCallback(c tb.Context) error {
+	m := c.Message()
+	if c.Callback().Data != RECONCILE_ACTION_ARCHIVE {
+		return c.Respond(&tb.CallbackResponse{Text: "Unknown action: " + c.Callback().Data})
+	}
+	ids := bc.State.PopReconciled(m)
+	if len(ids) == 0 {
+		return c.Respond(&tb.CallbackResponse{Text: fmt.Sprintf("This reconciliation is outdated. Please send your ledger again using /%s.", CMD_RECONCILE)})
+	}
+	if c.Callback().Sender != nil {
+		// The result has been sent by the bot. The transactions are archived by the user pressing the button.
+		actor := *m
+		actor.Sender = c.Callback().Sender
+		m = &actor
+	}
+	batchId, count, err := bc.Repo.ArchiveTransactionBatch(m, RECONCILE_ARCHIVE_LABEL, ids)
+	if err != nil {
+		bc.Logf(ERROR, m, "Error archiving reconciled transactions: %s", err.Error())
+		return c.Respond(&tb.CallbackResponse{Text: "Something went wrong: " + err.Error()})
+	}
+	_, err = bc.Bot.Edit(c.Message(), c.Message().Text+fmt.Sprintf("\n\nArchived %d transaction(s) as batch %d. You can undo this using '/%s %d'.", count, batchId, CMD_UNARCHIVE, batchId), &tb.ReplyMarkup{})
+	if err != nil {
+		bc.Logf(ERROR, m, "Could not update reconciliation message: %s", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{Text: fmt.Sprintf("Archived %d transaction(s).", count)})
+}
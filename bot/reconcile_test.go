@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const reconcileTestLedger = `option "operating_currency" "EUR"
+2024-01-01 open Assets:Cash
+
+2024-01-02 * "Groceries at the market"
+  Expenses:Food   12.50 EUR
+  Assets:Cash
+
+2024-01-03 * "Coffee"
+  Assets:Cash    -3.00 EUR
+  Expenses:Drinks
+2024-01-05 * "Broken"
+  Assets:Cash    (1 + 2) EUR
+  Expenses:Drinks
+`
+
+func TestReconcileTransactions(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -261}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{Files: map[string]string{"main.beancount": reconcileTestLedger}}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	_, err := bc.Repo.PurgeTransactions(m)
+	botTest.HandleErr(t, err)
+	defer bc.DeleteUserData(m)
+
+	bc.commandReconcile(&botTest.MockContext{M: &tb.Message{Text: "/reconcile", Chat: chat, Sender: m.Sender}})
+	helpers.TestExpect(t, bc.State.GetType(m), ST_DOC, "")
+	sendLedger := func() {
+		bc.handleDocument(&botTest.MockContext{M: &tb.Message{Chat: chat, Sender: m.Sender, Document: &tb.Document{File: tb.File{FileID: "main.beancount"}}}})
+	}
+	sendLedger()
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "You have no open transactions to reconcile.", "")
+
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-02 * \"Market\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Food\n"))
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-02 * \"Market again\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Food\n"))
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-03 * \"Coffee\"\n  Assets:Cash  -3.00 EUR\n  Expenses:Food\n"))
+	bc.commandReconcile(&botTest.MockContext{M: &tb.Message{Text: "/reconcile", Chat: chat, Sender: m.Sender}})
+	sendLedger()
+	msg := fmt.Sprintf("%v", bot.LastSentWhat)
+	helpers.TestStringContains(t, msg, "Read 2 transaction(s) of your ledger. 1 transaction(s) could not be read and have been ignored.", "")
+	helpers.TestStringContains(t, msg, "1 of your 3 open transaction(s) are already in your ledger:\n2024-01-02 * \"Market\"\n", "")
+	helpers.TestStringContains(t, msg, "2 open transaction(s) have not been found in your ledger:\n2024-01-02 * \"Market again\"\n2024-01-03 * \"Coffee\"", "")
+
+	callback := func() {
+		bc.handleReconcileCallback(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: msg}, C: &tb.Callback{Sender: m.Sender, Data: RECONCILE_ACTION_ARCHIVE}})
+	}
+	callback()
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastEditedWhat), "Archived 1 transaction(s) as batch", "")
+	open, err := bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(open), 2, "")
+	bot.LastEditedWhat = nil
+	callback()
+	helpers.TestExpect(t, bot.LastEditedWhat, nil, "the matched transactions should only be archived once")
+}
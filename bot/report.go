@@ -0,0 +1,363 @@
+package bot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	REPORT_PERIOD_WEEK  = "week"
+	REPORT_PERIOD_MONTH = "month"
+	REPORT_PERIOD_YEAR  = "year"
+	REPORT_RANGE_SEP    = ".."
+
+	// REPORT_TOP_PAYEES is the number of payees listed in a report
+	REPORT_TOP_PAYEES = 5
+)
+
+const REPORT_USAGE = `Usage help for /report:
+/report [week|month|year|<from>..<to>] [account prefix]
+
+Totals expenses and income of your open and archived transactions per account and currency. Defaults to the current month.
+Dates are formatted like 2022-01-31. An account prefix like 'Expenses:Food' restricts the report to these accounts.
+Amounts in other currencies are converted to your currency by their price or using your /rates.
+
+Example: /report 2022-01-01..2022-03-31 Expenses:Food`
+
+// ReportPeriod is a range of booking dates. Both dates are inclusive.
+type ReportPeriod struct {
+	From time.Time
+	To   time.Time
+}
+
+func (p ReportPeriod) String() string {
+	return p.From.Format(helpers.BEANCOUNT_DATE_FORMAT) + REPORT_RANGE_SEP + p.To.Format(helpers.BEANCOUNT_DATE_FORMAT)
+}
+
+// ParseReportPeriod reads a period relative to today and returns it together with the period preceding it
+func ParseReportPeriod(period string, today time.Time) (current ReportPeriod, previous ReportPeriod, err error) {
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case REPORT_PERIOD_WEEK:
+		monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+		current = ReportPeriod{From: monday, To: monday.AddDate(0, 0, 6)}
+		previous = ReportPeriod{From: monday.AddDate(0, 0, -7), To: monday.AddDate(0, 0, -1)}
+	case REPORT_PERIOD_MONTH:
+		first := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+		current = ReportPeriod{From: first, To: first.AddDate(0, 1, -1)}
+		previous = ReportPeriod{From: first.AddDate(0, -1, 0), To: first.AddDate(0, 0, -1)}
+	case REPORT_PERIOD_YEAR:
+		first := time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		current = ReportPeriod{From: first, To: first.AddDate(1, 0, -1)}
+		previous = ReportPeriod{From: first.AddDate(-1, 0, 0), To: first.AddDate(0, 0, -1)}
+	default:
+		from, to, isRange := strings.Cut(period, REPORT_RANGE_SEP)
+		if !isRange {
+			return current, previous, fmt.Errorf("'%s' is no valid period", period)
+		}
+		current.From, err = time.Parse(helpers.BEANCOUNT_DATE_FORMAT, from)
+		if err != nil {
+			return current, previous, fmt.Errorf("'%s' is no valid date (expected format: %s)", from, helpers.BEANCOUNT_DATE_FORMAT)
+		}
+		current.To, err = time.Parse(helpers.BEANCOUNT_DATE_FORMAT, to)
+		if err != nil {
+			return current, previous, fmt.Errorf("'%s' is no valid date (expected format: %s)", to, helpers.BEANCOUNT_DATE_FORMAT)
+		}
+		if current.From.After(current.To) {
+			return current, previous, fmt.Errorf("the 'from' date must not be after the 'to' date")
+		}
+		days := int(current.To.Sub(current.From).Hours()/24) + 1
+		previous = ReportPeriod{From: current.From.AddDate(0, 0, -days), To: current.From.AddDate(0, 0, -1)}
+	}
+	return current, previous, nil
+}
+
+type ReportPayee struct {
+	Name     string
+	Currency string
+	Amount   float64
+}
+
+// Report holds the totals of a period. Income is totalled with inverted sign, so that earnings are positive.
+type Report struct {
+	Transactions int
+	// Accounts holds the sum per account and currency
+	Accounts map[string]map[string]float64
+	// Totals holds the sum per account type (e.g. 'Expenses') and currency
+	Totals map[string]map[string]float64
+	Payees []ReportPayee
+}
+
+func reportAccountType(account string) string {
+	accountType, _, _ := strings.Cut(account, ":")
+	return accountType
+}
+
+// reportIncludes decides whether a posting counts into a report. Without prefix, expenses and income are reported.
+func reportIncludes(account, prefix string) bool {
+	if prefix == "" {
+		accountType := reportAccountType(account)
+		return accountType == "Expenses" || accountType == "Income"
+	}
+	return account == prefix || strings.HasPrefix(account, strings.TrimSuffix(prefix, ":")+":")
+}
+
+func reportSign(account string) float64 {
+	if reportAccountType(account) == "Income" {
+		return -1
+	}
+	return 1
+}
+
+// BuildReport totals the postings of the entries, optionally restricted to accounts with the given prefix
+func BuildReport(entries []*helpers.BeancountEntry, prefix string) *Report {
+	report := &Report{
+		Accounts: map[string]map[string]float64{},
+		Totals:   map[string]map[string]float64{},
+	}
+	add := func(sums map[string]map[string]float64, key, currency string, amount float64) {
+		if sums[key] == nil {
+			sums[key] = map[string]float64{}
+		}
+		sums[key][currency] += amount
+	}
+	payees := map[string]map[string]float64{}
+	for _, entry := range entries {
+		included := false
+		for _, p := range entry.Postings {
+			if p.Currency == "" || !reportIncludes(p.Account, prefix) {
+				continue
+			}
+			included = true
+			amount := p.Amount * reportSign(p.Account)
+			add(report.Accounts, p.Account, p.Currency, amount)
+			add(report.Totals, reportAccountType(p.Account), p.Currency, amount)
+			if reportAccountType(p.Account) == "Expenses" {
+				payee := entry.Payee
+				if payee == "" {
+					payee = entry.Narration
+				}
+				add(payees, payee, p.Currency, amount)
+			}
+		}
+		if included {
+			report.Transactions++
+		}
+	}
+	for name, amounts := range payees {
+		for currency, amount := range amounts {
+			report.Payees = append(report.Payees, ReportPayee{Name: name, Currency: currency, Amount: amount})
+		}
+	}
+	sort.Slice(report.Payees, func(i, j int) bool {
+		if report.Payees[i].Amount != report.Payees[j].Amount {
+			return report.Payees[i].Amount > report.Payees[j].Amount
+		}
+		return report.Payees[i].Name < report.Payees[j].Name
+	})
+	if len(report.Payees) > REPORT_TOP_PAYEES {
+		report.Payees = report.Payees[:REPORT_TOP_PAYEES]
+	}
+	return report
+}
+
+func sortedKeys(m map[string]map[string]float64) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedCurrencies(m map[string]float64) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatReportDiff(current, previous float64) string {
+	diff := current - previous
+	if previous == 0 {
+		return fmt.Sprintf("%+.2f", diff)
+	}
+	return fmt.Sprintf("%+.2f (%+.1f%%)", diff, diff/math.Abs(previous)*100)
+}
+
+// FormatReport renders a report as table. The totals are compared to the report of the previous period.
+func FormatReport(period ReportPeriod, report *Report, previousPeriod ReportPeriod, previous *Report) string {
+	rows := [][]string{}
+	for _, account := range sortedKeys(report.Accounts) {
+		for _, currency := range sortedCurrencies(report.Accounts[account]) {
+			rows = append(rows, []string{account, fmt.Sprintf("%.2f", report.Accounts[account][currency]), currency})
+		}
+	}
+	s := fmt.Sprintf("Report %s (%d transactions)\n\n", period, report.Transactions)
+	s += formatReportTable(rows)
+
+	rows = [][]string{}
+	totalTypes := sortedKeys(report.Totals)
+	for accountType := range previous.Totals {
+		if report.Totals[accountType] == nil {
+			totalTypes = append(totalTypes, accountType)
+		}
+	}
+	for _, accountType := range totalTypes {
+		currencies := map[string]float64{}
+		for currency := range report.Totals[accountType] {
+			currencies[currency] = 0
+		}
+		for currency := range previous.Totals[accountType] {
+			currencies[currency] = 0
+		}
+		for _, currency := range sortedCurrencies(currencies) {
+			current := report.Totals[accountType][currency]
+			rows = append(rows, []string{accountType, fmt.Sprintf("%.2f", current), currency, formatReportDiff(current, previous.Totals[accountType][currency])})
+		}
+	}
+	s += fmt.Sprintf("\n\nTotals (difference to %s)\n", previousPeriod)
+	s += formatReportTable(rows)
+
+	if len(report.Payees) > 0 {
+		rows = [][]string{}
+		for _, payee := range report.Payees {
+			rows = append(rows, []string{payee.Name, fmt.Sprintf("%.2f", payee.Amount), payee.Currency})
+		}
+		s += "\n\nTop payees\n"
+		s += formatReportTable(rows)
+	}
+	return s
+}
+
+// formatReportTable aligns the columns of the rows. Names and currencies (first and third column) are aligned left, amounts right.
+func formatReportTable(rows [][]string) string {
+	widths := []int{}
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if len(cell) > widths[i] {
+				widths[i] = len(cell)
+			}
+		}
+	}
+	lines := []string{}
+	for _, row := range rows {
+		cells := []string{}
+		for i, cell := range row {
+			if i == 0 || i == 2 {
+				cells = append(cells, fmt.Sprintf("%-*s", widths[i], cell))
+			} else {
+				cells = append(cells, fmt.Sprintf("%*s", widths[i], cell))
+			}
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, " "), " "))
+	}
+	if len(lines) == 0 {
+		return "-"
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (bc *BotController) reportEntries(m *tb.Message, period ReportPeriod) ([]*helpers.BeancountEntry, error) {
+	tx, err := bc.Repo.FindTransactions(m, crud.TransactionFilter{
+		From: period.From.Format(helpers.BEANCOUNT_DATE_FORMAT),
+		To:   period.To.Format(helpers.BEANCOUNT_DATE_FORMAT),
+	})
+	if err != nil {
+		return nil, err
+	}
+	entries := []*helpers.BeancountEntry{}
+	for _, t := range tx {
+		parsed, err := helpers.ParseBeancount(t.Tx)
+		if err != nil {
+			bc.Logf(WARN, m, "Skipping transaction %d in report: %s", t.Id, err.Error())
+			continue
+		}
+		entries = append(entries, parsed...)
+	}
+	return entries, nil
+}
+
+func (bc *BotController) commandReport(c tb.Context) error {
+	m := c.Message()
+	params := helpers.SplitQuotedCommand(m.Text)
+	if len(params) > 0 {
+		params = params[1:]
+	}
+	period := REPORT_PERIOD_MONTH
+	prefix := ""
+	if len(params) > 0 && !BeancountAccountLike(params[0]) {
+		period = params[0]
+		params = params[1:]
+	}
+	if len(params) > 0 {
+		prefix = params[0]
+		params = params[1:]
+	}
+	if len(params) > 0 || (prefix != "" && !BeancountAccountLike(prefix)) {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), REPORT_USAGE, clearKeyboard())
+		return nil
+	}
+	today := time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour)
+	current, previous, err := ParseReportPeriod(period, today)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error reading your report period: %s\n\n%s", err.Error(), REPORT_USAGE), clearKeyboard())
+		return nil
+	}
+	entries, err := bc.reportEntries(m, current)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	previousEntries, err := bc.reportEntries(m, previous)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	rates, err := bc.Repo.GetRates(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your exchange rates: "+err.Error(), clearKeyboard())
+		return nil
+	}
+	currency := bc.Repo.UserGetCurrency(m)
+	ConvertEntries(entries, rates, currency)
+	ConvertEntries(previousEntries, rates, currency)
+	report := FormatReport(current, BuildReport(entries, prefix), previous, BuildReport(previousEntries, prefix))
+	bc.sendPreformatted(m, report, fmt.Sprintf("report-%s.txt", current.From.Format(helpers.BEANCOUNT_DATE_FORMAT)), "Your report is too long for a message. Here it is as file.")
+	return nil
+}
+
+var beancountRootAccounts = []string{"Assets", "Liabilities", "Equity", "Income", "Expenses"}
+
+// BeancountAccountLike tells account names like 'Expenses:Food' or 'Expenses' apart from other parameters like
+// currencies. Accounts either start with one of the default root accounts or consist of capitalized segments
+// separated by colons.
+func BeancountAccountLike(s string) bool {
+	segments := strings.Split(s, ":")
+	for _, root := range beancountRootAccounts {
+		if segments[0] == root {
+			return true
+		}
+	}
+	if len(segments) < 2 {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" || !(segment[0] >= 'A' && segment[0] <= 'Z' || segment[0] >= '0' && segment[0] <= '9') {
+			return false
+		}
+	}
+	return true
+}
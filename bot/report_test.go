@@ -0,0 +1,172 @@
+package bot
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestParseReportPeriod(t *testing.T) {
+	today := time.Date(2022, 3, 16, 13, 0, 0, 0, time.UTC) // Wednesday
+	for _, c := range []struct{ period, current, previous string }{
+		{REPORT_PERIOD_WEEK, "2022-03-14..2022-03-20", "2022-03-07..2022-03-13"},
+		{REPORT_PERIOD_MONTH, "2022-03-01..2022-03-31", "2022-02-01..2022-02-28"},
+		{REPORT_PERIOD_YEAR, "2022-01-01..2022-12-31", "2021-01-01..2021-12-31"},
+		{"2022-01-10..2022-01-19", "2022-01-10..2022-01-19", "2021-12-31..2022-01-09"},
+	} {
+		current, previous, err := ParseReportPeriod(c.period, today)
+		if err != nil {
+			t.Errorf("Unexpected error for '%s': %s", c.period, err.Error())
+		}
+		helpers.TestExpect(t, current.String(), c.current, c.period)
+		helpers.TestExpect(t, previous.String(), c.previous, c.period)
+	}
+
+	// Sunday belongs to the week started on monday before
+	current, _, _ := ParseReportPeriod(REPORT_PERIOD_WEEK, time.Date(2022, 3, 20, 0, 0, 0, 0, time.UTC))
+	helpers.TestExpect(t, current.String(), "2022-03-14..2022-03-20", "")
+
+	for _, invalid := range []string{"day", "2022-01-10..", "2022-01-10..2022-01-01"} {
+		_, _, err := ParseReportPeriod(invalid, today)
+		if err == nil {
+			t.Errorf("Expected error for '%s'", invalid)
+		}
+	}
+}
+
+func parseReportTestEntries(t *testing.T, tx ...string) []*helpers.BeancountEntry {
+	entries := []*helpers.BeancountEntry{}
+	for _, text := range tx {
+		parsed, err := helpers.ParseBeancount(text)
+		if err != nil {
+			t.Fatalf("Could not parse test transaction: %s", err.Error())
+		}
+		entries = append(entries, parsed...)
+	}
+	return entries
+}
+
+func TestBuildReport(t *testing.T) {
+	entries := parseReportTestEntries(t,
+		"2022-03-01 * \"Shop\" \"Groceries\"\n  Assets:Wallet -12.50 EUR\n  Expenses:Food:Groceries 12.50 EUR\n",
+		"2022-03-02 * \"\" \"Lunch\"\n  Assets:Wallet -8.00 EUR\n  Expenses:Food 8.00 EUR\n",
+		"2022-03-03 * \"Shop\" \"Groceries\"\n  Assets:Wallet -4.00 USD\n  Expenses:Food:Groceries 4.00 USD\n",
+		"2022-03-25 * \"Employer\" \"Salary\"\n  Assets:Bank 3000.00 EUR\n  Income:Salary -3000.00 EUR\n",
+		"; just a comment",
+	)
+	report := BuildReport(entries, "")
+	helpers.TestExpect(t, report.Transactions, 4, "")
+	helpers.TestExpect(t, report.Accounts["Expenses:Food:Groceries"]["EUR"], 12.5, "")
+	helpers.TestExpect(t, report.Accounts["Expenses:Food:Groceries"]["USD"], 4.0, "")
+	helpers.TestExpect(t, report.Totals["Expenses"]["EUR"], 20.5, "")
+	helpers.TestExpect(t, report.Totals["Income"]["EUR"], 3000.0, "income should be positive")
+	helpers.TestExpect(t, len(report.Payees), 3, "")
+	helpers.TestExpect(t, report.Payees[0], ReportPayee{Name: "Shop", Currency: "EUR", Amount: 12.5}, "")
+	helpers.TestExpect(t, report.Payees[1], ReportPayee{Name: "Lunch", Currency: "EUR", Amount: 8}, "narration should be used without payee")
+
+	filtered := BuildReport(entries, "Expenses:Food:Groceries")
+	helpers.TestExpect(t, filtered.Transactions, 2, "")
+	helpers.TestExpect(t, len(filtered.Accounts), 1, "")
+	helpers.TestExpect(t, len(BuildReport(entries, "Expenses:Foo").Accounts), 0, "prefix should match whole account segments")
+	helpers.TestExpect(t, BuildReport(entries, "Assets:Wallet").Totals["Assets"]["EUR"], -20.5, "")
+}
+
+func TestFormatReport(t *testing.T) {
+	current, previous, _ := ParseReportPeriod("2022-03-01..2022-03-31", time.Now())
+	report := BuildReport(parseReportTestEntries(t,
+		"2022-03-01 * \"Shop\" \"Groceries\"\n  Assets:Wallet -15.00 EUR\n  Expenses:Food 15.00 EUR\n",
+	), "")
+	previousReport := BuildReport(parseReportTestEntries(t,
+		"2022-02-01 * \"Shop\" \"Groceries\"\n  Assets:Wallet -10.00 EUR\n  Expenses:Food 10.00 EUR\n",
+		"2022-02-25 * \"Employer\" \"Salary\"\n  Assets:Bank 100 EUR\n  Income:Salary -100 EUR\n",
+	), "")
+	helpers.TestExpect(t, FormatReport(current, report, previous, previousReport), `Report 2022-03-01..2022-03-31 (1 transactions)
+
+Expenses:Food 15.00 EUR
+
+Totals (difference to 2022-01-29..2022-02-28)
+Expenses 15.00 EUR    +5.00 (+50.0%)
+Income    0.00 EUR -100.00 (-100.0%)
+
+Top payees
+Shop 15.00 EUR`, "")
+}
+
+func TestCommandReport(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_TZOFF).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}).
+			AddRow(1, "2022-03-02 * \"Shop & Co\" \"Groceries\"\n  Assets:Wallet -12.50 EUR\n  Expenses:Food 12.50 EUR\n", "2022-03-02T10:00:00Z", true).
+			AddRow(2, "2022-03-03 * \"\" \"Bus\"\n  Assets:Wallet -2.00 EUR\n  Expenses:Transport 2.00 EUR\n", "2022-03-03T10:00:00Z", false))
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}))
+	mock.ExpectQuery(`SELECT "date", "base", "quote", "rate" FROM "bot::rate"`).WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"date", "base", "quote", "rate"}))
+	mock.ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).WithArgs(chat.ID, helpers.USERSET_CUR).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("EUR"))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandReport(&botTest.MockContext{M: &tb.Message{Text: "/report 2022-03-01..2022-03-31 Expenses:Food", Chat: chat}})
+	sent := fmt.Sprintf("%v", bot.LastSentWhat)
+	helpers.TestStringContains(t, sent, "<pre>Report 2022-03-01..2022-03-31 (1 transactions)", "")
+	helpers.TestStringContains(t, sent, "Shop &amp; Co 12.50 EUR", "payee should be escaped")
+	if strings.Contains(sent, "Transport") {
+		t.Errorf("Report should be restricted to the account prefix: %s", sent)
+	}
+
+	bc.commandReport(&botTest.MockContext{M: &tb.Message{Text: "/report 2022-03-01..2022-03-31 Expenses:Food additional", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Usage help for /report", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBeancountAccountLike(t *testing.T) {
+	for _, account := range []string{"Expenses", "Expenses:Food", "Assets:Bank:Checking", "Ausgaben:Essen", "Assets:2022"} {
+		helpers.TestExpect(t, BeancountAccountLike(account), true, account)
+	}
+	for _, other := range []string{"", "USD", "Food", "month", "2022-03", "Ausgaben:", "Ausgaben:essen"} {
+		helpers.TestExpect(t, BeancountAccountLike(other), false, other)
+	}
+}
+
+func TestSendPreformattedAsDocument(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	bc := NewBotController(nil)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.sendPreformatted(&tb.Message{Chat: chat}, "a < b", "report.txt", "too long")
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "<pre>a &lt; b</pre>", "")
+
+	long := strings.Repeat("Expenses:Food 12.50 EUR\n", helpers.TG_MAX_MSG_CHAR_LEN/20)
+	bc.sendPreformatted(&tb.Message{Chat: chat}, long, "report.txt", "too long")
+	doc, isDoc := bot.LastSentWhat.(*tb.Document)
+	if !isDoc {
+		t.Fatalf("Expected a document to be sent: %v", bot.LastSentWhat)
+	}
+	helpers.TestExpect(t, doc.FileName, "report.txt", "")
+	helpers.TestExpect(t, doc.Caption, "too long", "")
+	content, _ := io.ReadAll(doc.FileReader)
+	helpers.TestExpect(t, string(content), long, "the document should not be escaped")
+}
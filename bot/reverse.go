@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const REVERSE_REFUND_TAG = "refund"
+
+// reversalLink returns the link connecting a transaction with its reversal. Existing links of the transaction are
+// reused, so that the reversal joins them.
+func reversalLink(t *crud.TransactionResult) string {
+	if entry, err := h.ParseBeancountEntry(t.Tx); err == nil && len(entry.Links) > 0 {
+		return entry.Links[0]
+	}
+	return fmt.Sprintf("reversal-%d", t.Id)
+}
+
+// reverseTransaction records a new open transaction negating all postings of t, e.g. for refunds and chargebacks.
+// It is booked on date (today if empty) and linked to t.
+func (bc *BotController) reverseTransaction(m *tb.Message, t *crud.TransactionResult, date string, isRefund bool) {
+	if date == "" {
+		tzOffset := bc.Repo.UserGetTzOffset(m)
+		date = time.Now().UTC().Add(time.Duration(tzOffset) * time.Hour).Format(h.BEANCOUNT_DATE_FORMAT)
+	}
+	reversal, err := h.ReverseTransaction(t.Tx, date)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "This entry can not be reversed: "+err.Error(), clearKeyboard())
+		return
+	}
+	link := reversalLink(t)
+	fields := []string{"^" + link}
+	if isRefund {
+		fields = append(fields, "#"+REVERSE_REFUND_TAG)
+	}
+	reversal, _ = h.AddTagsAndLinks(reversal, fields...)
+
+	err = bc.Repo.RecordReversal(m, t.Id, link, reversal)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong while recording the reversal: "+err.Error(), clearKeyboard())
+		return
+	}
+	archivedHint := ""
+	if t.Archived {
+		archivedHint = fmt.Sprintf("\n\nThe original transaction has already been archived. Please add the link '^%s' to it in your ledger as well.", link)
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Recorded the reversal as new transaction in your /%s, linked to the original one with '^%s':\n\n%s%s",
+		CMD_LIST, link, reversal, archivedHint), clearKeyboard())
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestCommandListReverse(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -266}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	defer bc.DeleteUserData(m)
+
+	command := func(text string) string {
+		bc.commandList(&botTest.MockContext{M: &tb.Message{Text: text, Chat: chat, Sender: m.Sender}})
+		return fmt.Sprintf("%v", bot.LastSentWhat)
+	}
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-02 * \"Shop\" \"Shoes\"\n  Liabilities:Card  -80.00 EUR\n  Expenses:Clothes\n"))
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "; a comment\n"))
+
+	helpers.TestStringContains(t, command("/list reverse"), "'/list reverse <number> [date] [refund]'", "")
+	helpers.TestStringContains(t, command("/list 1 2024-01-20"), "The option '2024-01-20' could not be recognized.", "")
+	helpers.TestStringContains(t, command("/list reverse 3"), "the number you specified was too high", "")
+	helpers.TestStringContains(t, command("/list reverse 2"), "This entry can not be reversed", "")
+	helpers.TestStringContains(t, command("/list reverse 1 2024-01-xx"), "The date of the reversal could not be recognized", "")
+
+	reply := command("/list reverse 1 2024-01-20 refund")
+	helpers.TestStringContains(t, reply, "linked to the original one with '^reversal-", "")
+	tx, err := bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, len(tx), 3, "")
+	link := fmt.Sprintf("^reversal-%d", tx[0].Id)
+	helpers.TestExpect(t, tx[0].Tx, "2024-01-02 * \"Shop\" \"Shoes\" "+link+"\n  Liabilities:Card  -80.00 EUR\n  Expenses:Clothes\n", "")
+	helpers.TestExpect(t, tx[2].Tx, "2024-01-20 * \"Shop\" \"Shoes\" "+link+" #refund\n  Liabilities:Card   80.00 EUR\n  Expenses:Clothes\n", "")
+	events, err := bc.Repo.GetTransactionEvents(m, tx[0].Id, 0)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, events[0].Event, "update", "linking the original transaction should be recorded")
+
+	// Reversing again reuses the link
+	helpers.TestStringContains(t, command("/list reverse 1"), "linked to the original one with '"+link+"'", "")
+	tx, err = bc.Repo.GetTransactions(m, false)
+	botTest.HandleErr(t, err)
+	helpers.TestStringContains(t, tx[3].Tx, "\"Shop\" \"Shoes\" "+link+"\n  Liabilities:Card   80.00 EUR", "")
+}
@@ -9,24 +9,47 @@ import (
 type chatId int64
 type StateType string
 type TemplateName string
+type DocumentPurpose string
 
 const (
 	ST_NONE StateType = ""
 	ST_TX   StateType = "tx"
 	ST_TPL  StateType = "tpl"
+	ST_DOC  StateType = "doc"
+	ST_IMP  StateType = "imp"
+	ST_DUP  StateType = "dup"
+)
+
+const (
+	DOC_SUGGESTIONS DocumentPurpose = "suggestions"
+	DOC_IMPORT      DocumentPurpose = "import"
+	DOC_RECONCILE   DocumentPurpose = "reconcile"
+	DOC_PASTE       DocumentPurpose = "paste"
 )
 
 type StateHandler struct {
 	states    map[chatId]StateType
 	txStates  map[chatId]Tx
 	tplStates map[chatId]TemplateName
+	docStates map[chatId]DocumentPurpose
+	docParams map[chatId][]string
+	impStates map[chatId]*ImportSession
+	dupStates map[chatId]*PendingTransaction
+	// reconciled holds the transactions found in an uploaded ledger. They are kept independent of the chat's state,
+	// as they can be archived at any time later.
+	reconciled map[chatId][]int
 }
 
 func NewStateHandler() *StateHandler {
 	return &StateHandler{
-		states:    map[chatId]StateType{},
-		txStates:  map[chatId]Tx{},
-		tplStates: map[chatId]TemplateName{},
+		states:     map[chatId]StateType{},
+		txStates:   map[chatId]Tx{},
+		tplStates:  map[chatId]TemplateName{},
+		docStates:  map[chatId]DocumentPurpose{},
+		docParams:  map[chatId][]string{},
+		impStates:  map[chatId]*ImportSession{},
+		dupStates:  map[chatId]*PendingTransaction{},
+		reconciled: map[chatId][]int{},
 	}
 }
 
@@ -53,8 +76,12 @@ func (s *StateHandler) SimpleTx(m *tb.Message, suggestedCur string) (Tx, error)
 	if err != nil {
 		return nil, err
 	}
-	// Set date:
 	command := strings.Split(m.Text, " ")
+	if len(command) >= 2 && IsPendingOption(command[len(command)-1]) {
+		tx.SetPending(true)
+		command = command[:len(command)-1]
+	}
+	// Set date:
 	if len(command) >= 2 {
 		date, err := ParseDate(command[1])
 		if err != nil {
@@ -91,6 +118,63 @@ func (s *StateHandler) StartTpl(m *tb.Message, name string) {
 	s.tplStates[(chatId)(m.Chat.ID)] = TemplateName(name)
 }
 
+// StartDocument waits for a document to be handled for the purpose. The params are passed on to the document handler.
+func (s *StateHandler) StartDocument(m *tb.Message, purpose DocumentPurpose, params ...string) {
+	s.states[(chatId)(m.Chat.ID)] = ST_DOC
+	s.docStates[(chatId)(m.Chat.ID)] = purpose
+	s.docParams[(chatId)(m.Chat.ID)] = params
+}
+
+func (s *StateHandler) GetDocumentPurpose(m *tb.Message) DocumentPurpose {
+	if s.states[(chatId)(m.Chat.ID)] == ST_DOC {
+		return s.docStates[(chatId)(m.Chat.ID)]
+	}
+	return ""
+}
+
+func (s *StateHandler) GetDocumentParams(m *tb.Message) []string {
+	if s.states[(chatId)(m.Chat.ID)] == ST_DOC {
+		return s.docParams[(chatId)(m.Chat.ID)]
+	}
+	return nil
+}
+
+func (s *StateHandler) StartImport(m *tb.Message, session *ImportSession) {
+	s.states[(chatId)(m.Chat.ID)] = ST_IMP
+	s.impStates[(chatId)(m.Chat.ID)] = session
+}
+
+func (s *StateHandler) GetImport(m *tb.Message) *ImportSession {
+	if s.states[(chatId)(m.Chat.ID)] == ST_IMP {
+		return s.impStates[(chatId)(m.Chat.ID)]
+	}
+	return nil
+}
+
+// StartDuplicate waits for the user to confirm recording a transaction, which might duplicate a recorded one
+func (s *StateHandler) StartDuplicate(m *tb.Message, pending *PendingTransaction) {
+	s.states[(chatId)(m.Chat.ID)] = ST_DUP
+	s.dupStates[(chatId)(m.Chat.ID)] = pending
+}
+
+func (s *StateHandler) GetDuplicate(m *tb.Message) *PendingTransaction {
+	if s.states[(chatId)(m.Chat.ID)] == ST_DUP {
+		return s.dupStates[(chatId)(m.Chat.ID)]
+	}
+	return nil
+}
+
+func (s *StateHandler) SetReconciled(m *tb.Message, ids []int) {
+	s.reconciled[(chatId)(m.Chat.ID)] = ids
+}
+
+// PopReconciled returns the reconciled transactions only once
+func (s *StateHandler) PopReconciled(m *tb.Message) []int {
+	ids := s.reconciled[(chatId)(m.Chat.ID)]
+	delete(s.reconciled, (chatId)(m.Chat.ID))
+	return ids
+}
+
 func (s *StateHandler) CountOpen() int {
 	return len(s.states)
 }
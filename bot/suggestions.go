@@ -2,12 +2,16 @@ package bot
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	tb "gopkg.in/telebot.v3"
 )
 
+const SUGGESTION_PINNED_MARKER = "(pinned)"
+
 func isAllowedSuggestionType(s string) bool {
 	splits := strings.SplitN(s, ":", 2)
 	_, exists := TEMPLATE_TYPE_HINTS[Type(splits[0])]
@@ -19,7 +23,11 @@ func (bc *BotController) suggestionsHandler(m *tb.Message) {
 	sc.
 		Add("list", bc.suggestionsHandleList).
 		Add("add", bc.suggestionsHandleAdd).
-		Add("rm", bc.suggestionsHandleRemove)
+		Add("rm", bc.suggestionsHandleRemove).
+		Add("pin", bc.suggestionsHandlePin).
+		Add("unpin", bc.suggestionsHandleUnpin).
+		Add("export", bc.suggestionsHandleExport).
+		Add("import", bc.suggestionsHandleImport)
 	_, err := sc.Handle(m)
 	if err != nil {
 		bc.suggestionsHelp(m, nil)
@@ -43,10 +51,18 @@ func (bc *BotController) suggestionsHelp(m *tb.Message, err error) {
 /suggestions list <type>
 /suggestions add <type> <value> [<value>...]
 /suggestions rm <type> [value]
+/suggestions pin <type> <value>
+/suggestions unpin <type> <value>
+/suggestions export
+/suggestions import
 
 Parameter <type> is one of: [%s]
 
-Adding multiple suggestions at once is supported either by space separation (with quotation marks) or using newlines.`, strings.Join(suggestionTypes, ", ")))
+Adding multiple suggestions at once is supported either by space separation (with quotation marks) or using newlines.
+
+Pinned suggestions are always offered first and are never removed automatically.
+
+Export sends all your suggestions as a file grouped by type. To import such a file, send it with the caption '/suggestions import' or send the command first and the file afterwards. Suggestions already known are skipped.`, strings.Join(suggestionTypes, ", ")))
 }
 
 func (bc *BotController) suggestionsHandleList(m *tb.Message, params ...string) {
@@ -73,8 +89,20 @@ func (bc *BotController) suggestionsHandleList(m *tb.Message, params ...string)
 		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Your suggestions list for type '%s' is currently empty.", p.T))
 		return
 	}
+	pinned, err := bc.Repo.GetPinnedCacheHints(m, p.T)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error encountered while retrieving pinned suggestions for type '%s': %s", p.T, err.Error()))
+		return
+	}
+	lines := []string{}
+	for _, value := range values {
+		if h.ArrayContains(pinned, value) {
+			value += " " + SUGGESTION_PINNED_MARKER
+		}
+		lines = append(lines, value)
+	}
 	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("These suggestions are currently saved for type '%s':\n\n", p.T)+
-		strings.Join(values, "\n"))
+		strings.Join(lines, "\n"))
 }
 
 func (bc *BotController) suggestionsHandleAdd(m *tb.Message, params ...string) {
@@ -139,3 +167,156 @@ func (bc *BotController) suggestionsHandleRemove(m *tb.Message, params ...string
 	}
 	bc.Bot.SendSilent(bc.Logf, Recipient(m), "Successfully removed suggestion(s)")
 }
+
+func (bc *BotController) suggestionsHandlePin(m *tb.Message, params ...string) {
+	bc.suggestionsSetPinned(m, true, params...)
+}
+
+func (bc *BotController) suggestionsHandleUnpin(m *tb.Message, params ...string) {
+	bc.suggestionsSetPinned(m, false, params...)
+}
+
+func (bc *BotController) suggestionsSetPinned(m *tb.Message, pinned bool, params ...string) {
+	p, err := h.ExtractTypeValue(params...)
+	if err != nil {
+		bc.suggestionsHelp(m, fmt.Errorf("error encountered while (un)pinning suggestion: %s", err.Error()))
+		return
+	}
+	p.T = h.FqCacheKey(p.T)
+	if !isAllowedSuggestionType(p.T) {
+		bc.suggestionsHelp(m, fmt.Errorf("unexpected subcommand"))
+		return
+	}
+	if p.Value == "" {
+		bc.suggestionsHelp(m, fmt.Errorf("no value to (un)pin has been provided"))
+		return
+	}
+	bc.Logf(TRACE, m, "Setting pinned=%t for suggestion of type '%s' and value '%s'", pinned, p.T, p.Value)
+	err = bc.Repo.PinCacheEntry(m, p.T, p.Value, pinned)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Error encountered while (un)pinning suggestion: "+err.Error())
+		return
+	}
+	if pinned {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Successfully pinned suggestion. It will always be offered first for type '%s'.", p.T))
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), "Successfully unpinned suggestion.")
+}
+
+func (bc *BotController) suggestionsHandleExport(m *tb.Message, params ...string) {
+	if len(params) > 0 {
+		bc.suggestionsHelp(m, fmt.Errorf("no parameters expected"))
+		return
+	}
+	suggestions, err := bc.Repo.GetAllSuggestions(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Error encountered while retrieving your suggestions: "+err.Error())
+		return
+	}
+	if len(suggestions) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Your suggestions list is currently empty. There is nothing to export.")
+		return
+	}
+	date := time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour).Format(h.BEANCOUNT_DATE_FORMAT)
+	export := fmt.Sprintf("# Suggestions exported on %s. Import this file again using /%s import\n\n", date, CMD_SUGGEST) + formatSuggestionsFile(suggestions)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), documentFromString(fmt.Sprintf("suggestions-%s.txt", date), export))
+}
+
+func (bc *BotController) suggestionsHandleImport(m *tb.Message, params ...string) {
+	if len(params) > 0 {
+		bc.suggestionsHelp(m, fmt.Errorf("no parameters expected"))
+		return
+	}
+	if attachedDocument(m) != nil {
+		bc.suggestionsImportDocument(m)
+		return
+	}
+	if bc.State.GetType(m) != ST_NONE {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), MSG_UNFINISHED_STATE)
+		return
+	}
+	bc.State.StartDocument(m, DOC_SUGGESTIONS)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Please send me your suggestions file as document now. It uses the same format as '/%s export'. You can /cancel this operation.", CMD_SUGGEST))
+}
+
+func (bc *BotController) suggestionsImportDocument(m *tb.Message, params ...string) {
+	doc := attachedDocument(m)
+	if doc == nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "No file has been found to import suggestions from.")
+		return
+	}
+	content, err := bc.downloadDocument(doc, h.MAX_DOCUMENT_SIZE)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Error encountered while importing suggestions: "+err.Error())
+		return
+	}
+	suggestions, err := parseSuggestionsFile(content)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Your suggestions file could not be read: "+err.Error())
+		return
+	}
+	types := []string{}
+	for suggestionType := range suggestions {
+		if !isAllowedSuggestionType(suggestionType) {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Your suggestions file contains the unsupported type '%s'. Nothing has been imported.", suggestionType))
+			return
+		}
+		types = append(types, suggestionType)
+	}
+	sort.Strings(types)
+	total, added := 0, 0
+	for _, suggestionType := range types {
+		count, err := bc.Repo.ImportCacheHints(m, suggestionType, suggestions[suggestionType], false)
+		added += count
+		total += len(suggestions[suggestionType])
+		if err != nil {
+			bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Error encountered while importing suggestions of type '%s' (%d added so far): %s", suggestionType, added, err.Error()))
+			return
+		}
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Successfully imported your suggestions file. %d of %d suggestion(s) were new.", added, total))
+}
+
+// formatSuggestionsFile groups suggestions by their type, e.g.:
+//
+//	[account:from]
+//	Assets:Wallet
+func formatSuggestionsFile(suggestions map[string][]string) string {
+	types := []string{}
+	for suggestionType := range suggestions {
+		types = append(types, suggestionType)
+	}
+	sort.Strings(types)
+	blocks := []string{}
+	for _, suggestionType := range types {
+		if len(suggestions[suggestionType]) == 0 {
+			continue
+		}
+		blocks = append(blocks, fmt.Sprintf("[%s]\n%s\n", suggestionType, strings.Join(suggestions[suggestionType], "\n")))
+	}
+	return strings.Join(blocks, "\n")
+}
+
+func parseSuggestionsFile(content string) (map[string][]string, error) {
+	suggestions := map[string][]string{}
+	currentType := ""
+	for i, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentType = h.FqCacheKey(strings.TrimSpace(line[1 : len(line)-1]))
+			continue
+		}
+		if currentType == "" {
+			return nil, fmt.Errorf("line %d ('%s') is not preceded by a type like '[%s]'", i+1, line, h.FIELD_DESCRIPTION)
+		}
+		suggestions[currentType] = append(suggestions[currentType], line)
+	}
+	if len(suggestions) == 0 {
+		return nil, fmt.Errorf("no suggestions found")
+	}
+	return suggestions, nil
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	tb "gopkg.in/telebot.v3"
 )
 
@@ -85,45 +86,45 @@ func TestAddMultipleSuggestionsAtOnce(t *testing.T) {
 	bc.AddBotAndStart(bot)
 
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(12345).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 	mock.
 		ExpectExec(`INSERT INTO "bot::cache"`).
 		WithArgs(12345, "account:from", "First Suggestion").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(12345).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(12345).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 	mock.
 		ExpectExec(`INSERT INTO "bot::cache"`).
 		WithArgs(12345, "account:from", "Second Suggestion").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(12345).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 
 	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions add account:from\nFirst Suggestion\nSecond Suggestion", Chat: chat}})
 
 	// Add single suggestion
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(12345).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 	mock.
 		ExpectExec(`INSERT INTO "bot::cache"`).
 		WithArgs(12345, "account:to", "One lonely suggestion").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.
-		ExpectQuery(`SELECT "type", "value" FROM "bot::cache"`).
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
 		WithArgs(12345).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 
 	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions add account:to \"One lonely suggestion\"", Chat: chat}})
 
@@ -135,3 +136,114 @@ func TestAddMultipleSuggestionsAtOnce(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestSuggestionsFileRoundTrip(t *testing.T) {
+	suggestions := map[string][]string{
+		"description:":  {"Groceries", "Rent"},
+		"account:from":  {"Assets:Wallet"},
+		"account:empty": {},
+	}
+	file := formatSuggestionsFile(suggestions)
+	helpers.TestExpect(t, file, "[account:from]\nAssets:Wallet\n\n[description:]\nGroceries\nRent\n", "")
+
+	parsed, err := parseSuggestionsFile("# comment\n\n[description]\n Groceries \n\n[account:from]\nAssets:Wallet\n")
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, fmt.Sprintf("%v", parsed), "map[account:from:[Assets:Wallet] description::[Groceries]]", "")
+
+	_, err = parseSuggestionsFile("Groceries\n[description]\nRent")
+	helpers.TestExpect(t, err != nil && strings.Contains(err.Error(), "line 1"), true, "value without type should fail")
+	_, err = parseSuggestionsFile("# only a comment")
+	helpers.TestExpect(t, err != nil, true, "empty file should fail")
+}
+
+func TestSuggestionsImportDocument(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}).AddRow("description:", "Groceries", false))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, "description:", "Rent").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{Files: map[string]string{"file-1": "[description]\nGroceries\nRent\n"}}
+	bc.AddBotAndStart(bot)
+
+	// Waiting for document
+	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions import", Chat: chat}})
+	helpers.TestExpect(t, bc.State.GetType(&tb.Message{Chat: chat}), ST_DOC, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "send me your suggestions file", "")
+
+	bc.handleDocument(&botTest.MockContext{M: &tb.Message{Chat: chat, Document: &tb.Document{File: tb.File{FileID: "file-1"}}}})
+	helpers.TestExpect(t, bc.State.GetType(&tb.Message{Chat: chat}), ST_NONE, "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "1 of 2 suggestion(s) were new", "")
+
+	// Unsupported type via caption
+	bot.Files["file-2"] = "[unknown]\nvalue"
+	bc.handleDocument(&botTest.MockContext{M: &tb.Message{Caption: "/suggestions import", Chat: chat, Document: &tb.Document{File: tb.File{FileID: "file-2"}}}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "unsupported type 'unknown:'", "")
+
+	// Unexpected document
+	bc.handleDocument(&botTest.MockContext{M: &tb.Message{Chat: chat, Document: &tb.Document{File: tb.File{FileID: "file-1"}}}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "I did not expect a file", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSuggestionsPinning(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.
+		ExpectExec(`UPDATE "bot::cache" SET "pinned"`).
+		WithArgs(chat.ID, "account:from", "Assets:Wallet", true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}).
+			AddRow("account:from", "Assets:Wallet", true).
+			AddRow("account:from", "Assets:Bank", false))
+	mock.
+		ExpectExec(`UPDATE "bot::cache" SET "pinned"`).
+		WithArgs(chat.ID, "account:from", "Assets:Unknown", false).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions pin account:from", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "no value to (un)pin", "")
+
+	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions pin account:from Assets:Wallet", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Successfully pinned", "")
+
+	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions list account:from", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "Assets:Wallet (pinned)\nAssets:Bank", "")
+
+	bc.commandSuggestions(&botTest.MockContext{M: &tb.Message{Text: "/suggestions unpin account:from Assets:Unknown", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "does not exist", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
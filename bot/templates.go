@@ -97,6 +97,7 @@ func (bc *BotController) templatesHandleAdd(m *tb.Message, params ...string) {
 - ${account:from}
 - ${account:to}
 - ${account:<yourName>:<yourHint>}
+- ${tag} (your active tags), ${tag:<yourName>} (asked for, suggesting recently used tags)
 
 Example:
 
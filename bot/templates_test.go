@@ -162,6 +162,7 @@ func TestTemplateRm(t *testing.T) {
 func TestTemplateUse(t *testing.T) {
 	// test dependencies
 	crud.TEST_MODE = true
+	botTest.FailOnErrorLogs(t)
 	chat := &tb.Chat{ID: 12345}
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -192,21 +193,54 @@ func TestTemplateUse(t *testing.T) {
 		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("TEST_CURRENCY"))
 	mock.
 		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
-		WithArgs(chat.ID, helpers.USERSET_TAG).
+		WithArgs(chat.ID, helpers.USERSET_TZOFF).
 		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.
+		ExpectQuery(`SELECT "tag", "expires" FROM "bot::tag"`).
+		WithArgs(chat.ID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "expires"}))
 	mock.
 		ExpectQuery(`SELECT "value" FROM "bot::userSetting"`).
-		WithArgs(chat.ID, helpers.USERSET_TZOFF).
+		WithArgs(chat.ID, helpers.USERSET_AUTOPRICE).
 		WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	// Duplicate check
 	mock.
-		ExpectExec(regexp.QuoteMeta(`INSERT INTO "bot::transaction" ("id", "tgChatId", "value")
-		VALUES (`+dbpkg.AutoIncValue()+`,$1, $2);`)).
+		ExpectQuery(`SELECT "id", "value", "created", "archived" FROM "bot::transaction"`).
+		WithArgs(chat.ID, "2022-04-09", "2022-04-13", -0.005, 0.005).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "value", "created", "archived"}))
+	mock.ExpectBegin()
+	mock.
+		ExpectQuery(regexp.QuoteMeta(`INSERT INTO "bot::transaction" ("id", "tgChatId", "value", "bookingDate", "amount", "pending")
+		VALUES (`+dbpkg.AutoIncValue()+`,$1, $2, $3, $4, $5)
+		RETURNING "id";`)).
 		WithArgs(chat.ID, `2022-04-11 * "Test" "Buy something"
   fromFix                                     -10.51 EUR_TEST
   toFix1                                        5.255 EUR_TEST
   toFix2                                        5.255 EUR_TEST
-`).
+`, "2022-04-11", 0.0, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::transactionEvent"`).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	// Cache handling on saving tx
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned" FROM "bot::cache"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	// Budget alerts on saving tx
+	mock.
+		ExpectQuery(`SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod" FROM "bot::budget"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"}))
 	tx := bc.State.txStates[chatId(chat.ID)]
 	tx.Input(&tb.Message{Text: "10.51 EUR_TEST"})                                               // amount
 	bc.handleTextState(&botTest.MockContext{M: &tb.Message{Chat: chat, Text: "Buy something"}}) // description (via handleTextState)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -28,8 +29,68 @@ type Input struct {
 	field   TemplateField
 }
 
+var (
+	amountPriceAnnotation = regexp.MustCompile(`^(@@|@)\s*(\S+)\s+(\S+)$`)
+	amountCostAnnotation  = regexp.MustCompile(`^\{\s*(\S+)\s+(\S+)\s*\}\s*(.*)$`)
+	beancountTag          = regexp.MustCompile(`^[A-Za-z0-9_/.-]+$`)
+)
+
+// annotationAmount parses the number and currency of a price or cost annotation and renders them in beancount syntax
+func annotationAmount(value, currency string) (string, error) {
+	value, err := handleThousandsSeparators(value)
+	if err != nil {
+		return "", err
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return "", fmt.Errorf("parsing failed at value '%s': %s", value, err.Error())
+	}
+	if v < 0 {
+		return "", fmt.Errorf("prices and costs must not be negative, but got '%s'", value)
+	}
+	if !beancountCurrency.MatchString(currency) {
+		return "", fmt.Errorf("'%s' is no valid currency", currency)
+	}
+	return ParseAmount(v) + " " + currency, nil
+}
+
+// handleAnnotation parses a price ('@ 0.92 EUR' per unit, '@@ 46 EUR' in total) and/or cost ('{95.30 EUR}') annotation
+func handleAnnotation(annotation string) (string, error) {
+	parts := []string{}
+	if match := amountCostAnnotation.FindStringSubmatch(annotation); match != nil {
+		cost, err := annotationAmount(match[1], match[2])
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "{"+cost+"}")
+		annotation = strings.TrimSpace(match[3])
+		if annotation == "" {
+			return strings.Join(parts, " "), nil
+		}
+	}
+	match := amountPriceAnnotation.FindStringSubmatch(annotation)
+	if match == nil {
+		return "", fmt.Errorf("could not read price or cost '%s'. Use e.g. '@ 0.92 EUR' (per unit), '@@ 46 EUR' (total) or '{95.30 EUR}' (cost)", annotation)
+	}
+	price, err := annotationAmount(match[2], match[3])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append(parts, match[1]+" "+price), " "), nil
+}
+
 func HandleFloat(m *tb.Message) (string, error) {
 	input := strings.TrimSpace(m.Text)
+	annotation := ""
+	if idx := strings.IndexAny(input, "@{"); idx >= 0 {
+		var err error
+		annotation, err = handleAnnotation(strings.TrimSpace(input[idx:]))
+		if err != nil {
+			return "", err
+		}
+		input = strings.TrimSpace(input[:idx])
+		annotation = " " + annotation
+	}
 	split := strings.Split(input, " ")
 	var (
 		value    = split[0]
@@ -45,6 +106,9 @@ func HandleFloat(m *tb.Message) (string, error) {
 	if strings.HasSuffix(value, "+") && currency != "" {
 		return "", fmt.Errorf("for transactions being kept open with trailing '+' operator, no additionally specified currency is allowed")
 	}
+	if annotation != "" && (currency == "" || strings.HasSuffix(value, "+")) {
+		return "", fmt.Errorf("amounts with price or cost need a currency, e.g. '50 USD @ 0.92 EUR'")
+	}
 	operator := ""
 	amounts := []string{value}
 	if strings.Contains(value, "+") {
@@ -86,7 +150,7 @@ func HandleFloat(m *tb.Message) (string, error) {
 	} else {
 		finalAmount = values[0]
 	}
-	return FORMATTER_PLACEHOLDER + ParseAmount(finalAmount) + currency, nil
+	return FORMATTER_PLACEHOLDER + ParseAmount(finalAmount) + currency + annotation, nil
 }
 
 func handleThousandsSeparators(value string) (cleanValue string, err error) {
@@ -124,6 +188,14 @@ func HandleRaw(m *tb.Message) (string, error) {
 	return m.Text, nil
 }
 
+func HandleTag(m *tb.Message) (string, error) {
+	tag := strings.TrimPrefix(strings.TrimSpace(m.Text), "#")
+	if !beancountTag.MatchString(tag) {
+		return "", fmt.Errorf("'%s' is no valid tag. Tags may only contain letters, numbers and '-', '_', '/' or '.'", tag)
+	}
+	return " #" + tag, nil
+}
+
 func ParseDate(m string) (string, error) {
 	// TODO: Handle tz offset
 	today := time.Now().UTC()
@@ -156,10 +228,11 @@ type Tx interface {
 	Debug() string
 	NextHint(*crud.Repo, *tb.Message) *Hint
 	EnrichHint(r *crud.Repo, m *tb.Message, i *Input) *Hint
-	FillTemplate(currency, tag string, tzOffset int) (string, error)
+	FillTemplate(currency string, tags []string, tzOffset int) (string, error)
 	CacheData() map[string]string
 
 	SetDate(string) (Tx, error)
+	SetPending(bool) Tx
 	setTimeIfEmpty(tzOffset int) bool
 }
 
@@ -169,6 +242,8 @@ type SimpleTx struct {
 
 	nextFields []*TemplateField
 	data       map[string]string
+	// pending flags the transaction with '!' instead of the flag in the template
+	pending bool
 }
 
 type TemplateHintData struct {
@@ -199,6 +274,10 @@ var TEMPLATE_TYPE_HINTS = map[Type]HintTemplate{
 		Text:    "Please enter a *description* {{.FieldHint}} (or select one from the list)",
 		Handler: HandleRaw,
 	},
+	Type(c.FIELD_TAG): {
+		Text:    "Please enter a *tag* {{.FieldHint}} (or select one from the list)",
+		Handler: HandleTag,
+	},
 }
 
 const TEMPLATE_SIMPLE_DEFAULT = `${date} * "${description}"${tag}
@@ -218,7 +297,7 @@ func (tx *SimpleTx) CacheData() (data map[string]string) {
 	fieldOrder := []string{}
 	fields := ParseTemplateFields(tx.template, "")
 	for _, f := range fields {
-		if !c.ArrayContains(c.AllowedSuggestionTypes(), c.TypeCacheKey(f.FieldIdentifierForValue())) {
+		if !c.ArrayContains(c.AllowedSuggestionTypes(), c.TypeCacheKey(f.FieldIdentifierForValue())) || f.isActiveTags() {
 			// Don't cache non-suggestible data
 			continue
 		}
@@ -230,6 +309,9 @@ func (tx *SimpleTx) CacheData() (data map[string]string) {
 			continue
 		}
 		cleanedData[k] = strings.ReplaceAll(d, FORMATTER_PLACEHOLDER, "")
+		if c.TypeCacheKey(k) == c.FIELD_TAG {
+			cleanedData[k] = strings.TrimPrefix(cleanedData[k], " #")
+		}
 	}
 	log.Print(cleanedData)
 	return cleanedData
@@ -250,6 +332,11 @@ func (tx *SimpleTx) SetDate(d string) (Tx, error) {
 	return tx, nil
 }
 
+func (tx *SimpleTx) SetPending(pending bool) Tx {
+	tx.pending = pending
+	return tx
+}
+
 func (tx *SimpleTx) setTimeIfEmpty(tzOffset int) bool {
 	if tx.data[c.FqCacheKey(c.FIELD_DATE)] == "" {
 		// set today as fallback/default date
@@ -260,10 +347,10 @@ func (tx *SimpleTx) setTimeIfEmpty(tzOffset int) bool {
 	return false
 }
 
-func (tx *SimpleTx) setTagIfEmpty(tag string) bool {
+func (tx *SimpleTx) setTagIfEmpty(tags []string) bool {
 	if tx.data[c.FqCacheKey(c.FIELD_TAG)] == "" {
 		tagS := ""
-		if tag != "" {
+		for _, tag := range tags {
 			tagS += " #" + tag
 		}
 		tx.data[c.FqCacheKey(c.FIELD_TAG)] = tagS
@@ -324,6 +411,11 @@ func (tf *TemplateField) FieldIdentifierForValue() string {
 	return tf.FieldName + ":" + tf.FieldSpecifier
 }
 
+// isActiveTags is true for '${tag}' without name, which is filled with the active tags instead of being asked for
+func (tf *TemplateField) isActiveTags() bool {
+	return tf.FieldName == c.FIELD_TAG && tf.FieldSpecifier == ""
+}
+
 func ParseTemplateField(rawField, currencySuggestion string) *TemplateField {
 	rawField = strings.TrimSpace(rawField)
 	field := &TemplateField{
@@ -389,7 +481,7 @@ func (tx *SimpleTx) cleanNextFields() {
 		nextField := tx.nextFields[0]
 		_, isDataFilled := tx.data[nextField.FieldIdentifierForValue()]
 		_, isFieldAutoFilled := TEMPLATE_TYPE_HINTS[Type(nextField.FieldName)]
-		if isDataFilled || !isFieldAutoFilled {
+		if isDataFilled || !isFieldAutoFilled || nextField.isActiveTags() {
 			tx.nextFields = tx.nextFields[1:]
 			tx.cleanNextFields()
 			return
@@ -427,6 +519,9 @@ func (tx *SimpleTx) EnrichHint(r *crud.Repo, m *tb.Message, i *Input) *Hint {
 	if i.key == c.FIELD_ACCOUNT {
 		return tx.hintAccount(r, m, i)
 	}
+	if i.key == c.FIELD_TAG {
+		return tx.hintTag(r, m, i)
+	}
 	return i.hint
 }
 
@@ -456,6 +551,16 @@ func (tx *SimpleTx) hintDescription(r *crud.Repo, m *tb.Message, i *Input) *Hint
 	return i.hint
 }
 
+func (tx *SimpleTx) hintTag(r *crud.Repo, m *tb.Message, i *Input) *Hint {
+	tagFQSpecifier := i.field.FieldIdentifierForValue()
+	res, err := r.GetCacheHints(m, tagFQSpecifier)
+	if err != nil {
+		crud.LogDbf(r, ERROR, m, "Error occurred getting cached hint (hintTag): %s", err.Error())
+	}
+	i.hint.KeyboardOptions = res
+	return i.hint
+}
+
 func (tx *SimpleTx) IsDone() bool {
 	tx.cleanNextFields()
 	return len(tx.nextFields) == 0
@@ -497,13 +602,13 @@ func formatAllLinesWithFormatterPlaceholder(s string, dotIndentation int, curren
 	return rebuiltString
 }
 
-func (tx *SimpleTx) FillTemplate(currency, tag string, tzOffset int) (string, error) {
+func (tx *SimpleTx) FillTemplate(currency string, tags []string, tzOffset int) (string, error) {
 	if !tx.IsDone() {
 		return "", fmt.Errorf("not all data for this tx has been gathered")
 	}
 	// If still empty, set time and correct for timezone
 	tx.setTimeIfEmpty(tzOffset)
-	tx.setTagIfEmpty(tag)
+	tx.setTagIfEmpty(tags)
 
 	template := tx.template
 	fields := ParseTemplateFields(tx.template, "")
@@ -518,6 +623,9 @@ func (tx *SimpleTx) FillTemplate(currency, tag string, tzOffset int) (string, er
 		}
 	}
 	template = formatAllLinesWithFormatterPlaceholder(template, c.DOT_INDENT, currency)
+	if tx.pending {
+		template, _ = c.SetFlag(template, c.FLAG_PENDING)
+	}
 	return strings.TrimSpace(template) + "\n", nil
 }
 
@@ -544,6 +652,15 @@ func applyFieldOptionsForNumbersIfApplicable(value string, f *TemplateField) (st
 				return "", err
 			}
 			amountParsed /= float64(f.Fraction)
+			if total := strings.SplitN(currency, "@@ ", 2); len(total) > 1 {
+				// Total prices are split like the amount
+				priceSplits := strings.SplitN(total[1], " ", 2)
+				price, err := strconv.ParseFloat(priceSplits[0], 64)
+				if err != nil {
+					return "", err
+				}
+				currency = total[0] + "@@ " + ParseAmount(price/float64(f.Fraction)) + " " + priceSplits[1]
+			}
 			rightSide = ParseAmount(amountParsed) + " " + currency
 		}
 		return leftSide + FORMATTER_PLACEHOLDER + rightSide, nil
@@ -168,6 +168,42 @@ func TestHandleFloatThousandsSeparator(t *testing.T) {
 	helpers.TestExpect(t, handledFloat, bot.FORMATTER_PLACEHOLDER+"1024.00", "")
 }
 
+func TestHandleFloatPriceAndCost(t *testing.T) {
+	handledFloat, err := bot.HandleFloat(&tb.Message{Text: "50 USD @ 0.92 EUR"})
+	helpers.TestExpect(t, err, nil, "Should not throw an error for a price per unit")
+	helpers.TestExpect(t, handledFloat, bot.FORMATTER_PLACEHOLDER+"50.00 USD @ 0.92 EUR", "")
+
+	handledFloat, err = bot.HandleFloat(&tb.Message{Text: "50 USD @@ 46 EUR"})
+	helpers.TestExpect(t, err, nil, "Should not throw an error for a total price")
+	helpers.TestExpect(t, handledFloat, bot.FORMATTER_PLACEHOLDER+"50.00 USD @@ 46.00 EUR", "")
+
+	handledFloat, err = bot.HandleFloat(&tb.Message{Text: "10 VWRL {95,30 EUR}"})
+	helpers.TestExpect(t, err, nil, "Should not throw an error for a cost")
+	helpers.TestExpect(t, handledFloat, bot.FORMATTER_PLACEHOLDER+"10.00 VWRL {95.30 EUR}", "")
+
+	handledFloat, err = bot.HandleFloat(&tb.Message{Text: "-10 VWRL {95.30 EUR} @ 101.1234 EUR"})
+	helpers.TestExpect(t, err, nil, "Should not throw an error for a cost and price")
+	helpers.TestExpect(t, handledFloat, bot.FORMATTER_PLACEHOLDER+"-10.00 VWRL {95.30 EUR} @ 101.1234 EUR", "")
+
+	handledFloat, err = bot.HandleFloat(&tb.Message{Text: "20+30 USD@0.92 EUR"})
+	helpers.TestExpect(t, err, nil, "Should not throw an error for calculations with price")
+	helpers.TestExpect(t, handledFloat, bot.FORMATTER_PLACEHOLDER+"50.00 USD @ 0.92 EUR", "")
+
+	for input, expectedErr := range map[string]string{
+		"50 @ 0.92 EUR":         "amounts with price or cost need a currency",
+		"50 USD @ 0.92":         "could not read price or cost '@ 0.92'",
+		"50 USD @ -0.92 EUR":    "prices and costs must not be negative",
+		"50 USD @ 0.92 eur":     "'eur' is no valid currency",
+		"10 VWRL {95.30 EUR":    "could not read price or cost '{95.30 EUR'",
+		"10 VWRL {95.30 EUR} x": "could not read price or cost 'x'",
+	} {
+		_, err = bot.HandleFloat(&tb.Message{Text: input})
+		if err == nil || !strings.Contains(err.Error(), expectedErr) {
+			t.Errorf("Error message for '%s' should contain '%s': %v", input, expectedErr, err)
+		}
+	}
+}
+
 func TestTransactionBuilding(t *testing.T) {
 	tx, err := bot.CreateSimpleTx("", bot.TEMPLATE_SIMPLE_DEFAULT)
 	if err != nil {
@@ -182,7 +218,7 @@ func TestTransactionBuilding(t *testing.T) {
 		t.Errorf("With given input transaction data should be complete for SimpleTx")
 	}
 
-	templated, err := tx.FillTemplate("USD", "", 0)
+	templated, err := tx.FillTemplate("USD", []string{}, 0)
 	if err != nil {
 		t.Errorf("There should be no error raised during templating: %s", err.Error())
 	}
@@ -211,7 +247,7 @@ func TestTransactionBuildingWithNegativeAmount(t *testing.T) {
 		t.Errorf("With given input transaction data should be complete for SimpleTx")
 	}
 
-	templated, err := tx.FillTemplate("USD", "", 0)
+	templated, err := tx.FillTemplate("USD", []string{}, 0)
 	if err != nil {
 		t.Errorf("There should be no error raised during templating: %s", err.Error())
 	}
@@ -236,7 +272,7 @@ func TestTransactionBuildingCustomCurrencyInAmount(t *testing.T) {
 		t.Errorf("With given input transaction data should be complete for SimpleTx")
 	}
 
-	templated, err := tx.FillTemplate("EUR", "", 0)
+	templated, err := tx.FillTemplate("EUR", []string{}, 0)
 	if err != nil {
 		t.Errorf("There should be no error raised during templating: %s", err.Error())
 	}
@@ -247,6 +283,31 @@ func TestTransactionBuildingCustomCurrencyInAmount(t *testing.T) {
 `, "Templated string should be filled with variables as expected.")
 }
 
+func TestTransactionBuildingWithPrice(t *testing.T) {
+	tx, err := bot.CreateSimpleTx("", `${date} * "${description}"
+	${account:from} ${-amount}
+	${account:to:split} ${amount/2}
+	${account:to:split} ${amount/2}`)
+	if err != nil {
+		t.Errorf("Error creating simple tx: %s", err.Error())
+	}
+	tx.Input(&tb.Message{Text: "50 USD @@ 46.10 EUR"}) // amount
+	tx.Input(&tb.Message{Text: "Dinner in New York"})  // description
+	tx.Input(&tb.Message{Text: "Assets:Wallet"})       // from
+	tx.Input(&tb.Message{Text: "Expenses:Food"})       // to
+
+	templated, err := tx.FillTemplate("EUR", []string{}, 0)
+	if err != nil {
+		t.Errorf("There should be no error raised during templating: %s", err.Error())
+	}
+	today := time.Now().Format(helpers.BEANCOUNT_DATE_FORMAT)
+	helpers.TestExpect(t, templated, today+` * "Dinner in New York"
+  Assets:Wallet                               -50.00 USD @@ 46.10 EUR
+  Expenses:Food                                25.00 USD @@ 23.05 EUR
+  Expenses:Food                                25.00 USD @@ 23.05 EUR
+`, "Total prices should be split like the amount and the amounts aligned.")
+}
+
 func TestTransactionBuildingWithDate(t *testing.T) {
 	tx, err := bot.CreateSimpleTx("", bot.TEMPLATE_SIMPLE_DEFAULT)
 	tx.SetDate("2021-01-24")
@@ -262,7 +323,7 @@ func TestTransactionBuildingWithDate(t *testing.T) {
 		t.Errorf("With given input transaction data should be complete for SimpleTx")
 	}
 
-	templated, err := tx.FillTemplate("EUR", "", 0)
+	templated, err := tx.FillTemplate("EUR", []string{}, 0)
 	if err != nil {
 		t.Errorf("There should be no error raised during templating: %s", err.Error())
 	}
@@ -280,7 +341,7 @@ func TestTaggedTransaction(t *testing.T) {
 	tx.Input(&tb.Message{Text: "Buy something"})      // description
 	tx.Input(&tb.Message{Text: "Assets:Wallet"})      // from
 	tx.Input(&tb.Message{Text: "Expenses:Groceries"}) // to
-	template, err := tx.FillTemplate("EUR", "someTag", 0)
+	template, err := tx.FillTemplate("EUR", []string{"someTag"}, 0)
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err.Error())
 	}
@@ -289,6 +350,31 @@ func TestTaggedTransaction(t *testing.T) {
 	}
 }
 
+func TestTransactionBuildingWithTagField(t *testing.T) {
+	tx, _ := bot.CreateSimpleTx("", `${date} * "${description}"${tag}${tag:project}
+  ${account:from} ${-amount}
+  Expenses:Work`)
+	tx.SetDate("2021-01-24")
+	tx.Input(&tb.Message{Text: "12 EUR"})        // amount
+	tx.Input(&tb.Message{Text: "Train ticket"})  // description
+	tx.Input(&tb.Message{Text: "Assets:Wallet"}) // from
+	_, err := tx.Input(&tb.Message{Text: "no tag"})
+	if err == nil {
+		t.Errorf("Invalid tag should not be accepted")
+	}
+	tx.Input(&tb.Message{Text: "#customer-a"}) // tag:project
+	if !tx.IsDone() {
+		t.Errorf("With given input transaction data should be complete for SimpleTx")
+	}
+	helpers.TestExpect(t, tx.CacheData()["tag:project"], "customer-a", "")
+
+	template, err := tx.FillTemplate("EUR", []string{"vacation", "nyc"}, 0)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestStringContains(t, template, `2021-01-24 * "Train ticket" #vacation #nyc #customer-a`, "")
+}
+
 func TestParseAmount(t *testing.T) {
 	helpers.TestExpect(t, bot.ParseAmount(-1), "-1.00", "At least two decimal places should be present")
 	helpers.TestExpect(t, bot.ParseAmount(0), "0.00", "At least two decimal places should be present")
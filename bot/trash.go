@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// TRASH_RETENTION_DAYS_DEFAULT is used if the ENV var TRASH_RETENTION_DAYS is not set
+const TRASH_RETENTION_DAYS_DEFAULT = 30
+
+func (bc *BotController) commandTrash(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_TRASH, true)
+	sc.
+		Add("restore", bc.trashHandleRestore).
+		Add("empty", bc.trashHandleEmpty)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		if strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_TRASH)) != "" {
+			bc.trashHelp(m, fmt.Errorf("unknown subcommand"))
+			return nil
+		}
+		bc.trashHandleList(m)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled trash subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) trashHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s - List your deleted transactions
+/%s restore <number> - Move a deleted transaction back to the list it has been deleted from
+/%s empty yes - Permanently delete all transactions in the trash
+
+Deleted transactions are kept for %d days.`, CMD_TRASH, CMD_TRASH, CMD_TRASH, CMD_TRASH, trashRetentionDays()), clearKeyboard())
+}
+
+func (bc *BotController) trashHandleList(m *tb.Message) {
+	tx, err := bc.Repo.GetTrash(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your deleted transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(tx) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Your trash is empty.", clearKeyboard())
+		return
+	}
+	entries := []string{fmt.Sprintf("Your deleted transactions (most recent first). Restore them using '/%s restore <number>'. They are kept for %d days.\n", CMD_TRASH, trashRetentionDays())}
+	for i, t := range tx {
+		origin := "open"
+		if t.Archived {
+			origin = "archived"
+		}
+		entries = append(entries, fmt.Sprintf("%d) deleted %s from %s transactions\n%s", i+1, formatBatchDate(t.Deleted), origin, t.Tx))
+	}
+	for _, message := range bc.MergeMessagesHonorSendLimit(entries, "\n") {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), message, clearKeyboard())
+	}
+}
+
+func (bc *BotController) trashHandleRestore(m *tb.Message, params ...string) {
+	if len(params) != 1 {
+		bc.trashHelp(m, fmt.Errorf("please specify exactly one number as seen in /%s", CMD_TRASH))
+		return
+	}
+	number, err := strconv.Atoi(params[0])
+	if err != nil || number < 1 {
+		bc.trashHelp(m, fmt.Errorf("'%s' is no valid number", params[0]))
+		return
+	}
+	tx, err := bc.Repo.GetTrash(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your deleted transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	if number > len(tx) {
+		bc.trashHelp(m, fmt.Errorf("the number you specified was too high. Your trash contains %d transaction(s)", len(tx)))
+		return
+	}
+	restored := tx[number-1]
+	_, err = bc.Repo.RestoreTransaction(m, restored.Id)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong restoring your transaction: "+err.Error(), clearKeyboard())
+		return
+	}
+	list := "/" + CMD_LIST
+	if restored.Archived {
+		list += " archived"
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Restored the transaction. You can find it in '%s' again.", list), clearKeyboard())
+}
+
+func (bc *BotController) trashHandleEmpty(m *tb.Message, params ...string) {
+	if len(params) != 1 || strings.ToLower(params[0]) != "yes" {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Please type '/%s empty yes' to confirm the permanent deletion of the transactions in your trash", CMD_TRASH), clearKeyboard())
+		return
+	}
+	count, err := bc.Repo.EmptyTrash(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong emptying your trash: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("Permanently deleted %d transaction(s) from your trash.", count), clearKeyboard())
+}
+
+const ENV_TRASH_RETENTION_DAYS = "TRASH_RETENTION_DAYS"
+
+func trashRetentionDays() int {
+	setting := h.Env(ENV_TRASH_RETENTION_DAYS)
+	days, err := strconv.Atoi(setting)
+	if err != nil || days <= 0 {
+		if setting != "" {
+			h.LogLocalf(ERROR, nil, "Invalid value for ENV var '%s' (expected positive number of days): '%s'", ENV_TRASH_RETENTION_DAYS, setting)
+		}
+		return TRASH_RETENTION_DAYS_DEFAULT
+	}
+	return days
+}
+
+func (bc *BotController) cronPurgeTrash() {
+	days := trashRetentionDays()
+	bc.Logf(INFO, nil, "Running trash purging job for transactions deleted more than %d days ago.", days)
+	count, err := bc.Repo.As(crud.EVENT_SOURCE_JOB).PurgeTrash(days)
+	if err != nil {
+		bc.Logf(ERROR, nil, "Error purging trash: %s", err.Error())
+		return
+	}
+	bc.Logf(INFO, nil, "Purged %d deleted transaction(s).", count)
+}
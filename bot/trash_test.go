@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func trashRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "value", "created", "archived", "deleted"}).
+		AddRow(22, "tx22", "2022-03-30T14:24:50Z", true, "2022-04-02T08:00:00Z").
+		AddRow(21, "tx21", "2022-03-30T14:24:50Z", false, "2022-04-01T08:00:00Z")
+}
+
+func TestCommandTrash(t *testing.T) {
+	chat := &tb.Chat{ID: 12345}
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived", "deleted" FROM "bot::transaction"`).WithArgs(chat.ID).
+		WillReturnRows(trashRows())
+	mock.ExpectQuery(`SELECT "id", "value", "created", "archived", "deleted" FROM "bot::transaction"`).WithArgs(chat.ID).
+		WillReturnRows(trashRows())
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "bot::transaction" SET "deleted" = NULL`).WithArgs(chat.ID, 22).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`DELETE FROM "bot::transaction" WHERE "tgChatId" = \$1 AND "deleted" IS NOT NULL`).WithArgs(chat.ID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	bc := NewBotController(db)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+
+	bc.commandTrash(&botTest.MockContext{M: &tb.Message{Text: "/trash", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "1) deleted 2022-04-02 08:00:00 from archived transactions\ntx22", "")
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "2) deleted 2022-04-01 08:00:00 from open transactions\ntx21", "")
+
+	bc.commandTrash(&botTest.MockContext{M: &tb.Message{Text: "/trash restore 1", Chat: chat}})
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "Restored the transaction. You can find it in '/list archived' again.", "")
+
+	bc.commandTrash(&botTest.MockContext{M: &tb.Message{Text: "/trash restore x", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "'x' is no valid number", "")
+
+	bc.commandTrash(&botTest.MockContext{M: &tb.Message{Text: "/trash empty", Chat: chat}})
+	helpers.TestStringContains(t, fmt.Sprintf("%v", bot.LastSentWhat), "to confirm", "")
+
+	bc.commandTrash(&botTest.MockContext{M: &tb.Message{Text: "/trash empty yes", Chat: chat}})
+	helpers.TestExpect(t, fmt.Sprintf("%v", bot.LastSentWhat), "Permanently deleted 1 transaction(s) from your trash.", "")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,322 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	h "github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func tripHomeCurrency(trip *crud.Trip) string {
+	if trip.HomeCurrency == "" {
+		return h.DEFAULT_CURRENCY
+	}
+	return trip.HomeCurrency
+}
+
+func (bc *BotController) userToday(m *tb.Message) string {
+	return time.Now().UTC().Add(time.Duration(bc.Repo.UserGetTzOffset(m)) * time.Hour).Format(h.BEANCOUNT_DATE_FORMAT)
+}
+
+func (bc *BotController) commandTrip(c tb.Context) error {
+	m := c.Message()
+	sc := h.MakeSubcommandHandler("/"+CMD_TRIP, true)
+	sc.
+		Add("start", bc.tripHandleStart).
+		Add("end", bc.tripHandleEnd).
+		Add("report", bc.tripHandleReport).
+		Add("list", bc.tripHandleList)
+	parameters, err := sc.Handle(m)
+	if err != nil {
+		if strings.TrimSpace(strings.TrimPrefix(m.Text, "/"+CMD_TRIP)) != "" {
+			bc.tripHelp(m, fmt.Errorf("unknown subcommand"))
+			return nil
+		}
+		bc.tripHandleStatus(m)
+		return nil
+	}
+	bc.Logf(TRACE, m, "Handled trip subcommand: %v", parameters)
+	return nil
+}
+
+func (bc *BotController) tripHelp(m *tb.Message, err error) {
+	errorMsg := ""
+	if err != nil {
+		errorMsg += fmt.Sprintf("Error executing your command: %s\n\n", err.Error())
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), errorMsg+fmt.Sprintf(`Usage help for /%s:
+/%s - Show your current trip
+/%s start <name> [currency] [until date] - Tag all new transactions with the name and optionally use another default currency, e.g. '/%s start nyc USD 2024-03-14'. The trip ends automatically after the given date.
+/%s end - End your current trip. Its tag is removed unless it was active before, and your previous currency is restored.
+/%s report <name> - Summarise all transactions tagged with the trip name
+/%s list - List your trips`,
+		CMD_TRIP, CMD_TRIP, CMD_TRIP, CMD_TRIP, CMD_TRIP, CMD_TRIP, CMD_TRIP), clearKeyboard())
+}
+
+func (bc *BotController) tripHandleStart(m *tb.Message, params ...string) {
+	if len(params) < 1 || len(params) > 3 {
+		bc.tripHelp(m, fmt.Errorf("please specify the name of your trip"))
+		return
+	}
+	trip := &crud.Trip{Name: strings.TrimPrefix(params[0], "#"), StartDate: bc.userToday(m)}
+	if !beancountTag.MatchString(trip.Name) {
+		bc.tripHelp(m, fmt.Errorf("'%s' can not be used as tag. Please only use letters, numbers and '-', '_', '/' or '.'", trip.Name))
+		return
+	}
+	for _, param := range params[1:] {
+		if beancountCurrency.MatchString(param) && trip.Currency == "" {
+			trip.Currency = param
+			continue
+		}
+		until, err := ParseDate(param)
+		if err != nil || trip.Until != "" {
+			bc.tripHelp(m, fmt.Errorf("'%s' is neither a currency nor a date", param))
+			return
+		}
+		if until < trip.StartDate {
+			bc.tripHelp(m, fmt.Errorf("the trip can not end before today"))
+			return
+		}
+		trip.Until = until
+	}
+	active, err := bc.Repo.GetActiveTrip(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your current trip: "+err.Error(), clearKeyboard())
+		return
+	}
+	if active != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You are still on your trip '%s'. Please end it first using /%s end.", active.Name, CMD_TRIP), clearKeyboard())
+		return
+	}
+	_, trip.HomeCurrency, err = bc.Repo.GetUserSetting(h.USERSET_CUR, m.Chat.ID)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your currency: "+err.Error(), clearKeyboard())
+		return
+	}
+	// A tag added before the trip is kept when it ends
+	trip.TagAdded = !h.ArrayContains(bc.Repo.UserGetTags(m, trip.StartDate), trip.Name)
+	err = bc.Repo.StartTrip(m, trip)
+	if err == nil && trip.TagAdded {
+		err = bc.Repo.AddTag(m, &crud.Tag{Name: trip.Name, Expires: trip.Until})
+	}
+	if err == nil && trip.Currency != "" {
+		err = bc.Repo.UserSetCurrency(m, trip.Currency)
+	}
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong starting your trip: "+err.Error(), clearKeyboard())
+		return
+	}
+	msg := fmt.Sprintf("Your trip '%s' has started. New transactions are tagged #%s", trip.Name, trip.Name)
+	if trip.Currency != "" {
+		msg += fmt.Sprintf(" and use %s as default currency", trip.Currency)
+	}
+	if trip.Until != "" {
+		msg += fmt.Sprintf(" until %s (inclusive)", trip.Until)
+	}
+	msg += fmt.Sprintf(".\n\nEnd it using /%s end. Get a summary using /%s report %s.", CMD_TRIP, CMD_TRIP, trip.Name)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), msg, clearKeyboard())
+}
+
+// endTrip ends the trip, removes its tag if the trip added it and restores the currency from before, unless it has
+// been changed during the trip
+func (bc *BotController) endTrip(m *tb.Message, trip *crud.Trip) error {
+	if trip.TagAdded {
+		if _, err := bc.Repo.DeleteTag(m, trip.Name); err != nil {
+			return err
+		}
+	}
+	if trip.Currency != "" {
+		_, currency, err := bc.Repo.GetUserSetting(h.USERSET_CUR, m.Chat.ID)
+		if err != nil {
+			return err
+		}
+		if currency == trip.Currency {
+			if err = bc.Repo.SetUserSetting(h.USERSET_CUR, trip.HomeCurrency, m.Chat.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return bc.Repo.EndTrip(m, trip.Name, bc.userToday(m))
+}
+
+func (bc *BotController) tripEndedMessage(trip *crud.Trip) string {
+	return fmt.Sprintf("Your trip '%s' has ended. New transactions are no longer tagged #%s. Get a summary using /%s report %s.",
+		trip.Name, trip.Name, CMD_TRIP, trip.Name)
+}
+
+func (bc *BotController) tripHandleEnd(m *tb.Message, params ...string) {
+	if len(params) > 0 {
+		bc.tripHelp(m, fmt.Errorf("no parameters expected"))
+		return
+	}
+	trip, err := bc.Repo.GetActiveTrip(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your current trip: "+err.Error(), clearKeyboard())
+		return
+	}
+	if trip == nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("You are currently not on a trip. Start one using /%s start <name>.", CMD_TRIP), clearKeyboard())
+		return
+	}
+	err = bc.endTrip(m, trip)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong ending your trip: "+err.Error(), clearKeyboard())
+		return
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), bc.tripEndedMessage(trip), clearKeyboard())
+}
+
+func (bc *BotController) tripHandleStatus(m *tb.Message) {
+	trip, err := bc.Repo.GetActiveTrip(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your current trip: "+err.Error(), clearKeyboard())
+		return
+	}
+	if trip == nil {
+		bc.tripHelp(m, nil)
+		return
+	}
+	msg := fmt.Sprintf("You are on your trip '%s' since %s", trip.Name, trip.StartDate)
+	if trip.Until != "" {
+		msg += fmt.Sprintf(" until %s", trip.Until)
+	}
+	if trip.Currency != "" {
+		msg += fmt.Sprintf(", using %s as default currency", trip.Currency)
+	}
+	msg += fmt.Sprintf(". End it using /%s end.", CMD_TRIP)
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), msg, clearKeyboard())
+}
+
+func (bc *BotController) tripHandleList(m *tb.Message, params ...string) {
+	trips, err := bc.Repo.GetTrips(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your trips: "+err.Error(), clearKeyboard())
+		return
+	}
+	if len(trips) == 0 {
+		bc.tripHelp(m, nil)
+		return
+	}
+	lines := []string{"Your trips:"}
+	for _, trip := range trips {
+		end := trip.EndDate
+		if end == "" {
+			end = "active"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s..%s", trip.Name, trip.StartDate, end))
+	}
+	bc.Bot.SendSilent(bc.Logf, Recipient(m), strings.Join(lines, "\n"), clearKeyboard())
+}
+
+// FormatTripReport renders the totals of the transactions of a trip. Its period is the range of their booking dates.
+func FormatTripReport(name string, entries []*h.BeancountEntry, report *Report) string {
+	from, to := "", ""
+	for _, entry := range entries {
+		if from == "" || entry.Date < from {
+			from = entry.Date
+		}
+		if entry.Date > to {
+			to = entry.Date
+		}
+	}
+	rows := [][]string{}
+	for _, account := range sortedKeys(report.Accounts) {
+		for _, currency := range sortedCurrencies(report.Accounts[account]) {
+			rows = append(rows, []string{account, fmt.Sprintf("%.2f", report.Accounts[account][currency]), currency})
+		}
+	}
+	s := fmt.Sprintf("Trip %s %s%s%s (%d transactions)\n\n", name, from, REPORT_RANGE_SEP, to, report.Transactions)
+	s += formatReportTable(rows)
+
+	rows = [][]string{}
+	for _, accountType := range sortedKeys(report.Totals) {
+		for _, currency := range sortedCurrencies(report.Totals[accountType]) {
+			rows = append(rows, []string{accountType, fmt.Sprintf("%.2f", report.Totals[accountType][currency]), currency})
+		}
+	}
+	s += "\n\nTotals\n" + formatReportTable(rows)
+
+	if len(report.Payees) > 0 {
+		rows = [][]string{}
+		for _, payee := range report.Payees {
+			rows = append(rows, []string{payee.Name, fmt.Sprintf("%.2f", payee.Amount), payee.Currency})
+		}
+		s += "\n\nTop payees\n" + formatReportTable(rows)
+	}
+	return s
+}
+
+func (bc *BotController) tripHandleReport(m *tb.Message, params ...string) {
+	if len(params) != 1 {
+		bc.tripHelp(m, fmt.Errorf("please specify the name of the trip"))
+		return
+	}
+	name := strings.TrimPrefix(params[0], "#")
+	tx, err := bc.Repo.FindTransactions(m, crud.TransactionFilter{Tags: []string{name}, SortByBookingDate: true})
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your transactions: "+err.Error(), clearKeyboard())
+		return
+	}
+	entries := []*h.BeancountEntry{}
+	for _, t := range tx {
+		parsed, err := h.ParseBeancount(t.Tx)
+		if err != nil {
+			bc.Logf(WARN, m, "Skipping transaction %d in trip report: %s", t.Id, err.Error())
+			continue
+		}
+		for _, entry := range parsed {
+			// The tag filter also matches longer tags starting with the name
+			if entry.HasTag(name) {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), fmt.Sprintf("There are no transactions tagged #%s.", name), clearKeyboard())
+		return
+	}
+	// Amounts are converted to the currency used at home, which is the current one if there is no such trip
+	currency := bc.Repo.UserGetCurrency(m)
+	trips, err := bc.Repo.GetTrips(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your trips: "+err.Error(), clearKeyboard())
+		return
+	}
+	for _, trip := range trips {
+		if trip.Name == name {
+			currency = tripHomeCurrency(trip)
+		}
+	}
+	rates, err := bc.Repo.GetRates(m)
+	if err != nil {
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), "Something went wrong retrieving your exchange rates: "+err.Error(), clearKeyboard())
+		return
+	}
+	ConvertEntries(entries, rates, currency)
+	report := FormatTripReport(name, entries, BuildReport(entries, ""))
+	bc.sendPreformatted(m, report, fmt.Sprintf("trip-%s.txt", name), "Your trip report is too long for a message. Here it is as file.")
+}
+
+func (bc *BotController) cronEndTrips() {
+	trips, err := bc.Repo.GetActiveTripsWithEnd()
+	if err != nil {
+		bc.Logf(ERROR, nil, "Error getting trips to end: %s", err.Error())
+		return
+	}
+	for chatId, trip := range trips {
+		m := &tb.Message{Chat: &tb.Chat{ID: chatId}}
+		if trip.Until >= bc.userToday(m) {
+			continue
+		}
+		bc.Logf(INFO, m, "Ending trip '%s' planned until %s", trip.Name, trip.Until)
+		err = bc.endTrip(m, trip)
+		if err != nil {
+			bc.Logf(ERROR, m, "Error ending trip '%s': %s", trip.Name, err.Error())
+			continue
+		}
+		bc.Bot.SendSilent(bc.Logf, Recipient(m), bc.tripEndedMessage(trip))
+	}
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/bot/botTest"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestCommandTrip(t *testing.T) {
+	conn := db.Connection()
+	chat := &tb.Chat{ID: -264}
+	m := &tb.Message{Chat: chat, Sender: &tb.User{ID: chat.ID}}
+	bc := NewBotController(conn)
+	bot := &botTest.MockBot{}
+	bc.AddBotAndStart(bot)
+	botTest.HandleErr(t, bc.Repo.EnrichUserData(m))
+	defer bc.DeleteUserData(m)
+	botTest.HandleErr(t, bc.Repo.UserSetCurrency(m, "EUR"))
+	botTest.HandleErr(t, bc.Repo.UserSetTag(m, "home"))
+
+	command := func(text string) string {
+		bc.commandTrip(&botTest.MockContext{M: &tb.Message{Text: text, Chat: chat, Sender: m.Sender}})
+		return fmt.Sprintf("%v", bot.LastSentWhat)
+	}
+	helpers.TestStringContains(t, command("/trip"), "Usage help for /trip", "")
+	helpers.TestStringContains(t, command("/trip start #new york"), "'york' is neither a currency nor a date", "")
+	helpers.TestStringContains(t, command("/trip start nyc USD 2000-01-01"), "the trip can not end before today", "")
+	helpers.TestStringContains(t, command("/trip start nyc USD 2999-12-31"), "Your trip 'nyc' has started. New transactions are tagged #nyc and use USD as default currency until 2999-12-31 (inclusive).", "")
+	helpers.TestStringContains(t, command("/trip start other"), "You are still on your trip 'nyc'", "")
+	helpers.TestExpectArrEq(t, bc.Repo.UserGetTags(m, ""), []string{"home", "nyc"}, "")
+	helpers.TestExpect(t, bc.Repo.UserGetCurrency(m), "USD", "")
+	helpers.TestStringContains(t, command("/trip"), "You are on your trip 'nyc' since", "")
+
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-15 * \"Diner\" \"\" #nyc\n  Assets:Cash -50.00 USD\n  Expenses:Food\n"))
+	botTest.HandleErr(t, bc.Repo.RecordTransaction(m, "2024-01-16 * \"Museum\" \"\" #nyc2\n  Assets:Cash -20.00 USD\n  Expenses:Fun\n"))
+	botTest.HandleErr(t, bc.Repo.SetRate(m, &crud.Rate{Date: "2024-01-01", Base: "USD", Quote: "EUR", Rate: 0.9}))
+	report := command("/trip report nyc")
+	helpers.TestStringContains(t, report, "Trip nyc 2024-01-15..2024-01-15 (1 transactions)", "")
+	helpers.TestStringContains(t, report, "Expenses:Food 45.00 EUR", "amounts should be converted to the currency from before the trip")
+	helpers.TestStringContains(t, command("/trip report paris"), "There are no transactions tagged #paris.", "")
+
+	helpers.TestStringContains(t, command("/trip end"), "Your trip 'nyc' has ended.", "")
+	helpers.TestExpectArrEq(t, bc.Repo.UserGetTags(m, ""), []string{"home"}, "")
+	helpers.TestExpect(t, bc.Repo.UserGetTag(m), "home", "the tag setting should be kept")
+	helpers.TestExpect(t, bc.Repo.UserGetCurrency(m), "EUR", "")
+	helpers.TestStringContains(t, command("/trip end"), "You are currently not on a trip.", "")
+
+	botTest.HandleErr(t, bc.Repo.AddTag(m, &crud.Tag{Name: "paris"}))
+	command("/trip start paris")
+	botTest.HandleErr(t, bc.Repo.AddTag(m, &crud.Tag{Name: "added"}))
+	trip, err := bc.Repo.GetActiveTrip(m)
+	botTest.HandleErr(t, err)
+	trip.Until = "2000-01-01"
+	botTest.HandleErr(t, bc.Repo.StartTrip(m, trip))
+	bc.cronEndTrips()
+	trip, err = bc.Repo.GetActiveTrip(m)
+	botTest.HandleErr(t, err)
+	helpers.TestExpect(t, trip == nil, true, "the trip should have ended automatically")
+	helpers.TestExpectArrEq(t, bc.Repo.UserGetTags(m, ""), []string{"added", "home", "paris"}, "tags not added by the trip should be kept")
+
+	list := command("/trip list")
+	helpers.TestStringContains(t, list, "nyc: ", "")
+	helpers.TestStringContains(t, list, "paris: ", "")
+}
@@ -1,6 +1,8 @@
 package bot
 
 import (
+	"io"
+
 	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	tb "gopkg.in/telebot.v3"
 )
@@ -10,7 +12,9 @@ type IBot interface {
 	Start()
 	Handle(endpoint interface{}, h tb.HandlerFunc, m ...tb.MiddlewareFunc)
 	Send(to tb.Recipient, what interface{}, options ...interface{}) (*tb.Message, error)
+	Edit(msg tb.Editable, what interface{}, options ...interface{}) (*tb.Message, error)
 	Respond(c *tb.Callback, resp ...*tb.CallbackResponse) error
+	File(file *tb.File) (io.ReadCloser, error)
 	// custom by me:
 	Me() *tb.User
 	SendSilent(logFn func(level helpers.Level, m *tb.Message, format string, v ...interface{}), to tb.Recipient, what interface{}, options ...interface{}) (*tb.Message, error)
@@ -32,10 +36,18 @@ func (b *Bot) Send(to tb.Recipient, what interface{}, options ...interface{}) (*
 	return b.bot.Send(to, what, options...)
 }
 
+func (b *Bot) Edit(msg tb.Editable, what interface{}, options ...interface{}) (*tb.Message, error) {
+	return b.bot.Edit(msg, what, options...)
+}
+
 func (b *Bot) Respond(c *tb.Callback, resp ...*tb.CallbackResponse) error {
 	return b.bot.Respond(c, resp...)
 }
 
+func (b *Bot) File(file *tb.File) (io.ReadCloser, error) {
+	return b.bot.File(file)
+}
+
 func (b *Bot) Me() *tb.User {
 	return b.bot.Me
 }
@@ -1,7 +1,9 @@
 package crud
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"math/rand"
@@ -66,6 +68,12 @@ func (r *Repo) VerifyApiToken(userId int64, nonce string) (token string, err err
 	return token, nil
 }
 
+// ApiTokenId identifies a token without revealing it, e.g. in the transaction history
+func ApiTokenId(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:4])
+}
+
 func (r *Repo) RevokeApiToken(token string) (count int64, err error) {
 	res, err := r.db.Exec(`DELETE FROM "app::apiToken" WHERE "token" = $1`, token)
 	if err != nil {
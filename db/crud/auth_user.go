@@ -210,6 +210,7 @@ func (r *Repo) GetUsersToNotify() (*sql.Rows, error) {
 					u."tgChatId" = tx."tgChatId" AND
 					
 					tx.archived = FALSE AND
+					tx."deleted" IS NULL AND
 					MOD(s."notificationHour" + 24 - CASE WHEN userset."value" IS NULL THEN 0 ELSE userset."value"::DECIMAL END, 24) = $1 AND
 					tx.created + INTERVAL '1 hour' * s."delayHours" <= NOW()
 				GROUP BY u."tgChatId"
@@ -217,12 +218,13 @@ func (r *Repo) GetUsersToNotify() (*sql.Rows, error) {
 			"bot::transaction" tx2
 		WHERE
 			tx2."tgChatId" = overdue."tgChatId" AND
-			tx2.archived = FALSE
+			tx2.archived = FALSE AND
+			tx2."deleted" IS NULL
 		GROUP BY overdue."tgChatId", overdue."count"
 		`
 	} else {
 		query = `
-		WITH tx2 AS (SELECT "tgChatId", COUNT(*) AS "allTx" FROM "bot::transaction" GROUP BY "tgChatId")
+		WITH tx2 AS (SELECT "tgChatId", COUNT(*) AS "allTx" FROM "bot::transaction" WHERE "deleted" IS NULL GROUP BY "tgChatId")
 		SELECT
 			overdue."tgChatId",
 			overdue."count" overdue,
@@ -230,7 +232,7 @@ func (r *Repo) GetUsersToNotify() (*sql.Rows, error) {
 		FROM (
 			SELECT u."tgChatId", COUNT(*) AS "count"
 			FROM "auth::user" u
-				LEFT OUTER JOIN "bot::transaction" tx ON u."tgChatId" = tx."tgChatId" AND tx.archived = FALSE
+				LEFT OUTER JOIN "bot::transaction" tx ON u."tgChatId" = tx."tgChatId" AND tx.archived = FALSE AND tx."deleted" IS NULL
 				JOIN "bot::notificationSchedule" s ON u."tgChatId" = s."tgChatId"
 				LEFT OUTER JOIN "bot::userSetting" userset ON u."tgChatId" = userset."tgChatId" AND userset."setting" = 'user.tzOffset'
 			WHERE
@@ -0,0 +1,141 @@
+package crud
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+type ArchiveBatch struct {
+	Id      int
+	Label   string
+	Created string
+	Count   int
+}
+
+// ArchiveTransactionBatch archives the open transactions with the given IDs as a new batch.
+// If ids is nil, all open transactions are archived. No batch is created if nothing has been archived.
+func (r *Repo) ArchiveTransactionBatch(m *tb.Message, label string, ids []int) (batchId int, count int64, err error) {
+	LogDbf(r, helpers.TRACE, m, "Archiving transactions as batch '%s': %v", label, ids)
+	if ids != nil && len(ids) == 0 {
+		return 0, 0, fmt.Errorf("no transactions to archive have been selected")
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`
+		INSERT INTO "bot::archiveBatch" ("id", "tgChatId", "label")
+		VALUES (`+db.AutoIncValue()+`, $1, $2)
+		RETURNING "id"`, m.Chat.ID, label).Scan(&batchId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	condition := `"tgChatId" = $1 AND "archived" = FALSE AND "deleted" IS NULL`
+	params := []interface{}{m.Chat.ID}
+	if ids != nil {
+		placeholders := []string{}
+		for _, id := range ids {
+			params = append(params, id)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(params)))
+		}
+		condition += ` AND "id" IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	err = r.recordEvents(tx, m, EVENT_ARCHIVE, true, true, condition, params...)
+	if err != nil {
+		return 0, 0, err
+	}
+	params = append(params, batchId)
+	res, err := tx.Exec(fmt.Sprintf(`
+		UPDATE "bot::transaction"
+		SET "archived" = TRUE, "batchId" = $%d
+		WHERE `, len(params))+condition, params...)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err = res.RowsAffected()
+	if err != nil || count == 0 {
+		return 0, 0, err
+	}
+	return batchId, count, tx.Commit()
+}
+
+func (r *Repo) GetArchiveBatches(m *tb.Message) ([]*ArchiveBatch, error) {
+	rows, err := r.db.Query(`
+		SELECT b."id", b."label", b."created", COUNT(t."id")
+		FROM "bot::archiveBatch" b
+			LEFT JOIN "bot::transaction" t ON t."batchId" = b."id" AND t."deleted" IS NULL
+		WHERE b."tgChatId" = $1
+		GROUP BY b."id", b."label", b."created"
+		ORDER BY b."created" DESC, b."id" DESC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	batches := []*ArchiveBatch{}
+	for rows.Next() {
+		batch := &ArchiveBatch{}
+		err = rows.Scan(&batch.Id, &batch.Label, &batch.Created, &batch.Count)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// UnarchiveBatch moves the transactions of a batch back to the open ones and removes the batch.
+func (r *Repo) UnarchiveBatch(m *tb.Message, batchId int) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Unarchiving batch %d", batchId)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	condition := `"tgChatId" = $1 AND "batchId" = $2`
+	err = r.recordEvents(tx, m, EVENT_UNARCHIVE, true, true, condition, m.Chat.ID, batchId)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		UPDATE "bot::transaction"
+		SET "archived" = FALSE, "batchId" = NULL
+		WHERE `+condition, m.Chat.ID, batchId)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	res, err = tx.Exec(`
+		DELETE FROM "bot::archiveBatch"
+		WHERE "tgChatId" = $1 AND "id" = $2`, m.Chat.ID, batchId)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if deleted == 0 {
+		return 0, fmt.Errorf("archive batch %d does not exist", batchId)
+	}
+	return count, tx.Commit()
+}
+
+func (r *Repo) DeleteArchiveBatches(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting archive batches")
+	_, err := r.db.Exec(`
+		DELETE FROM "bot::archiveBatch"
+		WHERE "tgChatId" = $1`, m.Chat.ID)
+	return err
+}
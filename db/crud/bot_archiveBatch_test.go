@@ -0,0 +1,36 @@
+package crud_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"gopkg.in/telebot.v3"
+)
+
+func TestUnarchivedTransactionLeavesBatch(t *testing.T) {
+	m := &telebot.Message{Chat: &telebot.Chat{ID: -268}, Sender: &telebot.User{ID: -268}}
+	repo := crud.NewRepo(db.Connection())
+	helpers.TestExpect(t, repo.EnrichUserData(m), nil, "")
+	defer repo.DeleteArchiveBatches(m)
+	defer repo.PurgeTransactions(m)
+
+	helpers.TestExpect(t, repo.RecordTransaction(m, "; first\n"), nil, "")
+	helpers.TestExpect(t, repo.RecordTransaction(m, "; second\n"), nil, "")
+	batchId, count, err := repo.ArchiveTransactionBatch(m, "january", nil)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, count, int64(2), "")
+
+	tx, err := repo.FindTransactions(m, crud.TransactionFilter{BatchId: batchId})
+	helpers.TestExpect(t, err, nil, "")
+	_, err = repo.SetTransactionArchived(m, tx[0].Id, false)
+	helpers.TestExpect(t, err, nil, "")
+
+	tx, err = repo.FindTransactions(m, crud.TransactionFilter{BatchId: batchId})
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, len(tx), 1, "the unarchived transaction should not be part of the batch anymore")
+	batches, err := repo.GetArchiveBatches(m)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, batches[0].Count, 1, "")
+}
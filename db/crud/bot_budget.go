@@ -0,0 +1,139 @@
+package crud
+
+import (
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	BUDGET_PERIOD_WEEKLY  = "weekly"
+	BUDGET_PERIOD_MONTHLY = "monthly"
+	BUDGET_PERIOD_YEARLY  = "yearly"
+)
+
+// Budget limits the postings to an account (and its sub-accounts) in a currency per period.
+// AlertedLevel is the highest percentage the user has been alerted about in the period starting at AlertedPeriod.
+type Budget struct {
+	Id            int
+	Account       string
+	Amount        float64
+	Currency      string
+	Period        string
+	AlertedLevel  int
+	AlertedPeriod string
+}
+
+// SetBudget creates a budget or replaces the budget for the same account and currency
+func (r *Repo) SetBudget(m *tb.Message, budget *Budget) error {
+	LogDbf(r, helpers.TRACE, m, "Setting budget: %v", budget)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM "bot::budget" WHERE "tgChatId" = $1 AND "account" = $2 AND "currency" = $3`,
+		m.Chat.ID, budget.Account, budget.Currency)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO "bot::budget" ("tgChatId", "account", "amount", "currency", "period")
+		VALUES ($1, $2, $3, $4, $5)`,
+		m.Chat.ID, budget.Account, budget.Amount, budget.Currency, budget.Period)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) GetBudgets(m *tb.Message) ([]*Budget, error) {
+	rows, err := r.db.Query(`
+		SELECT "id", "account", "amount", "currency", "period", "alertedLevel", "alertedPeriod"
+		FROM "bot::budget"
+		WHERE "tgChatId" = $1
+		ORDER BY "account" ASC, "currency" ASC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	budgets := []*Budget{}
+	for rows.Next() {
+		budget := &Budget{}
+		var alertedPeriod *string
+		err = rows.Scan(&budget.Id, &budget.Account, &budget.Amount, &budget.Currency, &budget.Period, &budget.AlertedLevel, &alertedPeriod)
+		if err != nil {
+			return nil, err
+		}
+		if alertedPeriod != nil {
+			budget.AlertedPeriod = *alertedPeriod
+		}
+		budgets = append(budgets, budget)
+	}
+	return budgets, nil
+}
+
+// DeleteBudget removes the budgets of an account. If currency is empty, the budgets in all currencies are removed.
+func (r *Repo) DeleteBudget(m *tb.Message, account, currency string) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Deleting budget for '%s' (currency: '%s')", account, currency)
+	query := `DELETE FROM "bot::budget" WHERE "tgChatId" = $1 AND "account" = $2`
+	params := []interface{}{m.Chat.ID, account}
+	if currency != "" {
+		query += ` AND "currency" = $3`
+		params = append(params, currency)
+	}
+	res, err := r.db.Exec(query, params...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repo) DeleteBudgets(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting budgets")
+	_, err := r.db.Exec(`DELETE FROM "bot::budget" WHERE "tgChatId" = $1`, m.Chat.ID)
+	return err
+}
+
+// SetBudgetAlerted remembers the alert level sent for the period starting at periodStart, to not alert twice
+func (r *Repo) SetBudgetAlerted(m *tb.Message, id int, level int, periodStart string) error {
+	_, err := r.db.Exec(`
+		UPDATE "bot::budget" SET "alertedLevel" = $3, "alertedPeriod" = $4
+		WHERE "tgChatId" = $1 AND "id" = $2`, m.Chat.ID, id, level, periodStart)
+	return err
+}
+
+// GetBudgetChatsToNotify returns the chats with budgets whose notification hour is the current one
+func (r *Repo) GetBudgetChatsToNotify() ([]int64, error) {
+	var hourCondition string
+	switch db.DbType() {
+	case "POSTGRES":
+		hourCondition = `MOD(s."notificationHour" + 24 - CASE WHEN userset."value" IS NULL THEN 0 ELSE userset."value"::DECIMAL END, 24) = $1`
+	default:
+		hourCondition = `(s."notificationHour" + 24 - CASE WHEN userset."value" IS NULL THEN 0 ELSE userset."value" END)%24 = $1`
+	}
+	rows, err := r.db.Query(`
+		SELECT DISTINCT b."tgChatId"
+		FROM "bot::budget" b
+			JOIN "bot::notificationSchedule" s ON b."tgChatId" = s."tgChatId"
+			LEFT OUTER JOIN "bot::userSetting" userset ON b."tgChatId" = userset."tgChatId" AND userset."setting" = 'user.tzOffset'
+		WHERE `+hourCondition, time.Now().UTC().Hour())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := []int64{}
+	for rows.Next() {
+		var chatId int64
+		err = rows.Scan(&chatId)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, chatId)
+	}
+	return chats, nil
+}
@@ -0,0 +1,68 @@
+package crud_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"gopkg.in/telebot.v3"
+)
+
+func TestBudgetsDb(t *testing.T) {
+	m := &telebot.Message{Chat: &telebot.Chat{ID: -256}, Sender: &telebot.User{ID: -256}}
+	repo := crud.NewRepo(db.Connection())
+	repo.EnrichUserData(m)
+	defer repo.DeleteBudgets(m)
+
+	err := repo.SetBudget(m, &crud.Budget{Account: "Expenses:Food", Amount: 400, Currency: "EUR", Period: crud.BUDGET_PERIOD_MONTHLY})
+	if err != nil {
+		t.Fatalf("Setting budget should not fail: %s", err.Error())
+	}
+	_ = repo.SetBudget(m, &crud.Budget{Account: "Expenses:Food", Amount: 50, Currency: "USD", Period: crud.BUDGET_PERIOD_WEEKLY})
+	budgets, err := repo.GetBudgets(m)
+	if err != nil {
+		t.Fatalf("Getting budgets should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(budgets), 2, "")
+	helpers.TestExpect(t, *budgets[0], crud.Budget{Id: budgets[0].Id, Account: "Expenses:Food", Amount: 400, Currency: "EUR", Period: crud.BUDGET_PERIOD_MONTHLY}, "")
+
+	err = repo.SetBudgetAlerted(m, budgets[0].Id, 80, "2022-03-01")
+	if err != nil {
+		t.Errorf("Setting budget alert should not fail: %s", err.Error())
+	}
+	budgets, _ = repo.GetBudgets(m)
+	helpers.TestExpect(t, budgets[0].AlertedLevel, 80, "")
+	helpers.TestExpect(t, budgets[0].AlertedPeriod, "2022-03-01", "")
+
+	// Setting a budget again replaces it and resets its alerts
+	_ = repo.SetBudget(m, &crud.Budget{Account: "Expenses:Food", Amount: 450.5, Currency: "EUR", Period: crud.BUDGET_PERIOD_MONTHLY})
+	budgets, _ = repo.GetBudgets(m)
+	helpers.TestExpect(t, len(budgets), 2, "")
+	helpers.TestExpect(t, budgets[0].Amount, 450.5, "")
+	helpers.TestExpect(t, budgets[0].AlertedLevel, 0, "")
+
+	err = repo.UserSetNotificationSetting(m, 1, time.Now().UTC().Hour())
+	if err != nil {
+		t.Errorf("Setting notification time failed: %s", err.Error())
+	}
+	defer repo.UserSetNotificationSetting(m, -1, -1)
+	chats, err := repo.GetBudgetChatsToNotify()
+	if err != nil {
+		t.Errorf("Getting chats to notify should not fail: %s", err.Error())
+	}
+	found := false
+	for _, chatId := range chats {
+		found = found || chatId == m.Chat.ID
+	}
+	helpers.TestExpect(t, found, true, "chat with budgets should be notified in its notification hour")
+
+	count, err := repo.DeleteBudget(m, "Expenses:Food", "USD")
+	if err != nil {
+		t.Errorf("Deleting budget should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, count, int64(1), "")
+	count, _ = repo.DeleteBudget(m, "Expenses:Food", "")
+	helpers.TestExpect(t, count, int64(1), "")
+}
@@ -3,6 +3,8 @@ package crud
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
@@ -10,6 +12,25 @@ import (
 )
 
 var CACHE_LOCAL = make(map[int64]map[string][]string)
+var CACHE_PINNED = make(map[int64]map[string][]string)
+
+// cacheLock guards CACHE_LOCAL and CACHE_PINNED, which are used by handlers and cron jobs concurrently.
+// The per-chat maps are replaced as a whole and never modified after being stored.
+var cacheLock sync.RWMutex
+
+func getCache(chatId int64) (local map[string][]string, pinned map[string][]string, exists bool) {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
+	local, exists = CACHE_LOCAL[chatId]
+	return local, CACHE_PINNED[chatId], exists
+}
+
+func setCache(chatId int64, local map[string][]string, pinned map[string][]string) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	CACHE_LOCAL[chatId] = local
+	CACHE_PINNED[chatId] = pinned
+}
 
 func (r *Repo) PutCacheHints(m *tb.Message, values map[string]string) error {
 	err := r.FillCache(m)
@@ -17,8 +38,9 @@ func (r *Repo) PutCacheHints(m *tb.Message, values map[string]string) error {
 		return err
 	}
 
+	cache, _, _ := getCache(m.Chat.ID)
 	for rawKey, value := range values {
-		if helpers.ArrayContains(CACHE_LOCAL[m.Chat.ID][helpers.FqCacheKey(rawKey)], value) {
+		if helpers.ArrayContains(cache[helpers.FqCacheKey(rawKey)], value) {
 			// TODO: Update all as single statement
 			_, err = r.db.Exec(`
 				UPDATE "bot::cache"
@@ -43,37 +65,93 @@ func (r *Repo) PutCacheHints(m *tb.Message, values map[string]string) error {
 	return r.FillCache(m)
 }
 
-func (r *Repo) GetCacheHints(m *tb.Message, key string) ([]string, error) {
-	if _, exists := CACHE_LOCAL[m.Chat.ID]; !exists {
-		LogDbf(r, helpers.TRACE, m, "No cached data found for chat. Will fill cache first.")
-		err := r.FillCache(m)
+// ImportCacheHints adds values of a single suggestion type, skipping the ones already known.
+// With toTop, known values are moved to the top of the list as if they had just been used.
+func (r *Repo) ImportCacheHints(m *tb.Message, key string, values []string, toTop bool) (added int, err error) {
+	err = r.FillCache(m)
+	if err != nil {
+		return
+	}
+	key = helpers.FqCacheKey(key)
+	cache, _, _ := getCache(m.Chat.ID)
+	existing := cache[key]
+	seen := []string{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || helpers.ArrayContains(seen, value) {
+			continue
+		}
+		seen = append(seen, value)
+		if helpers.ArrayContains(existing, value) {
+			if !toTop {
+				continue
+			}
+			_, err = r.db.Exec(`
+				UPDATE "bot::cache"
+				SET "lastUsed" = `+db.Now()+`
+				WHERE "tgChatId" = $1 AND "type" = $2 AND "value" = $3`,
+				m.Chat.ID, key, value)
+		} else {
+			_, err = r.db.Exec(`
+				INSERT INTO "bot::cache" ("id", "tgChatId", "type", "value")
+				VALUES (`+db.AutoIncValue()+`, $1, $2, $3)`,
+				m.Chat.ID, key, value)
+			if err == nil {
+				added++
+			}
+		}
 		if err != nil {
-			return nil, err
+			return
 		}
 	}
-	cacheData := CACHE_LOCAL[m.Chat.ID][key]
+	return added, r.FillCache(m)
+}
+
+// filledCache returns the cache of the chat, filling it first if necessary
+func (r *Repo) filledCache(m *tb.Message) (local map[string][]string, pinned map[string][]string, err error) {
+	local, pinned, exists := getCache(m.Chat.ID)
+	if exists {
+		return local, pinned, nil
+	}
+	LogDbf(r, helpers.TRACE, m, "No cached data found for chat. Will fill cache first.")
+	err = r.FillCache(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	local, pinned, _ = getCache(m.Chat.ID)
+	return local, pinned, nil
+}
+
+func (r *Repo) GetCacheHints(m *tb.Message, key string) ([]string, error) {
+	cache, _, err := r.filledCache(m)
+	if err != nil {
+		return nil, err
+	}
+	cacheData := cache[key]
 	LogDbf(r, helpers.TRACE, m, "Got cached data for chat, key '%s': %v", key, cacheData)
 	return cacheData, nil
 }
 
 func (r *Repo) GetAllSuggestions(m *tb.Message) (map[string][]string, error) {
-	if _, exists := CACHE_LOCAL[m.Chat.ID]; !exists {
-		LogDbf(r, helpers.TRACE, m, "No cached data found for chat. Will fill cache first.")
-		err := r.FillCache(m)
-		if err != nil {
-			return nil, err
-		}
+	cache, _, err := r.filledCache(m)
+	return cache, err
+}
+
+func (r *Repo) GetPinnedCacheHints(m *tb.Message, key string) ([]string, error) {
+	_, pinned, err := r.filledCache(m)
+	if err != nil {
+		return nil, err
 	}
-	return CACHE_LOCAL[m.Chat.ID], nil
+	return pinned[key], nil
 }
 
 func (r *Repo) FillCache(m *tb.Message) error {
 	r.DeleteCache(m)
 	rows, err := r.db.Query(`
-		SELECT "type", "value"
+		SELECT "type", "value", "pinned"
 		FROM "bot::cache"
 		WHERE "tgChatId" = $1
-		ORDER BY "lastUsed" DESC`,
+		ORDER BY "pinned" DESC, "lastUsed" DESC`,
 		m.Chat.ID)
 	if err != nil {
 		return err
@@ -81,17 +159,22 @@ func (r *Repo) FillCache(m *tb.Message) error {
 	defer rows.Close()
 
 	cache := make(map[string][]string)
+	pinnedCache := make(map[string][]string)
 
 	var key string
 	var value string
+	var pinned bool
 	for rows.Next() {
-		err = rows.Scan(&key, &value)
+		err = rows.Scan(&key, &value, &pinned)
 		if err != nil {
 			return err
 		}
 		cache[key] = append(cache[key], value)
+		if pinned {
+			pinnedCache[key] = append(pinnedCache[key], value)
+		}
 	}
-	CACHE_LOCAL[m.Chat.ID] = cache
+	setCache(m.Chat.ID, cache, pinnedCache)
 	LogDbf(r, helpers.TRACE, m, "Filled cache for chat with %d keys. One example: %v", len(cache), func() string {
 		for sampleKey, sampleValue := range cache {
 			return fmt.Sprintf("%s => %v", sampleKey, sampleValue)
@@ -101,9 +184,64 @@ func (r *Repo) FillCache(m *tb.Message) error {
 	return nil
 }
 
-// TODO: Prune cache: Free from old entries after time span (async)
 func (r *Repo) DeleteCache(m *tb.Message) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
 	delete(CACHE_LOCAL, m.Chat.ID)
+	delete(CACHE_PINNED, m.Chat.ID)
+}
+
+// PinCacheEntry (un)pins a suggestion value. Pinning a value not known yet adds it.
+func (r *Repo) PinCacheEntry(m *tb.Message, t string, value string, pinned bool) error {
+	res, err := r.db.Exec(`
+		UPDATE "bot::cache"
+		SET "pinned" = $4
+		WHERE "tgChatId" = $1 AND "type" = $2 AND "value" = $3`,
+		m.Chat.ID, t, value, pinned)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		if !pinned {
+			return fmt.Errorf("suggestion '%s' does not exist for type '%s'", value, t)
+		}
+		_, err = r.db.Exec(`
+			INSERT INTO "bot::cache" ("id", "tgChatId", "type", "value", "pinned")
+			VALUES (`+db.AutoIncValue()+`, $1, $2, $3, $4)`,
+			m.Chat.ID, t, value, pinned)
+		if err != nil {
+			return err
+		}
+	}
+	return r.FillCache(m)
+}
+
+// PruneCache removes suggestions not used for the given number of days. Pinned suggestions are kept.
+func (r *Repo) PruneCache(days int) (int64, error) {
+	var threshold string
+	switch db.DbType() {
+	case "POSTGRES":
+		threshold = `NOW() - INTERVAL '1 day' * $1`
+	default:
+		threshold = `DATETIME('now', '-' || $1 || ' days')`
+	}
+	res, err := r.db.Exec(`
+		DELETE FROM "bot::cache"
+		WHERE "pinned" = FALSE AND "lastUsed" < `+threshold,
+		days)
+	if err != nil {
+		return 0, err
+	}
+	// Affected chats are not known: Reload all caches lazily
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	CACHE_LOCAL = make(map[int64]map[string][]string)
+	CACHE_PINNED = make(map[int64]map[string][]string)
+	return res.RowsAffected()
 }
 
 func (r *Repo) DeleteCacheEntries(m *tb.Message, t string, value string) (sql.Result, error) {
@@ -1,12 +1,16 @@
 package crud_test
 
 import (
+	"fmt"
 	"log"
+	"sync"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/bot"
+	dbWrapper "github.com/LucaBernstein/beancount-bot-tg/v2/db"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	tb "gopkg.in/telebot.v3"
 )
 
@@ -21,22 +25,22 @@ func TestCacheOnlySuggestible(t *testing.T) {
 	defer db.Close()
 	mock.
 		ExpectQuery(`
-			SELECT "type", "value"
+			SELECT "type", "value", "pinned"
 			FROM "bot::cache"
 			WHERE "tgChatId" = ?`).
 		WithArgs(chat.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 	mock.
 		ExpectExec(`INSERT INTO "bot::cache"`).
 		WithArgs(chat.ID, "description:", "description_value").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.
 		ExpectQuery(`
-			SELECT "type", "value"
+			SELECT "type", "value", "pinned"
 			FROM "bot::cache"
 			WHERE "tgChatId" = ?`).
 		WithArgs(chat.ID).
-		WillReturnRows(sqlmock.NewRows([]string{"type", "value"}))
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
 
 	bc := crud.NewRepo(db)
 	message := &tb.Message{Chat: chat}
@@ -56,3 +60,94 @@ func TestCacheOnlySuggestible(t *testing.T) {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
+
+func TestPinnedCacheEntriesSurvivePruning(t *testing.T) {
+	repo := crud.NewRepo(dbWrapper.Connection())
+	var tgChatId int64 = -272727
+	m := &tb.Message{Chat: &tb.Chat{ID: tgChatId}, Sender: &tb.User{ID: tgChatId}}
+	helpers.TestExpect(t, repo.EnrichUserData(m), nil, "")
+	helpers.TestExpect(t, repo.DeleteAllCacheEntries(m), nil, "")
+
+	_, err := repo.ImportCacheHints(m, "description:", []string{"recent", "old", "old pinned"}, false)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, repo.PinCacheEntry(m, "description:", "old pinned", true), nil, "")
+	helpers.TestExpect(t, repo.PinCacheEntry(m, "description:", "new pinned", true), nil, "pinning unknown value should add it")
+	helpers.TestExpect(t, repo.PinCacheEntry(m, "description:", "unknown", false) != nil, true, "unpinning unknown value should fail")
+
+	values, err := repo.GetCacheHints(m, "description:")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, len(values), 4, "")
+	pinned, err := repo.GetPinnedCacheHints(m, "description:")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpectArrEq(t, values[:2], pinned, "pinned values should come first")
+
+	_, err = dbWrapper.Connection().Exec(`UPDATE "bot::cache" SET "lastUsed" = '2000-01-01 00:00:00' WHERE "tgChatId" = $1 AND "value" LIKE 'old%'`, tgChatId)
+	helpers.TestExpect(t, err, nil, "")
+	_, err = repo.PruneCache(30)
+	helpers.TestExpect(t, err, nil, "")
+
+	values, err = repo.GetCacheHints(m, "description:")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, helpers.ArrayContains(values, "old"), false, "unused value should be pruned")
+	helpers.TestExpect(t, helpers.ArrayContains(values, "old pinned"), true, "pinned value should not be pruned")
+	helpers.TestExpect(t, helpers.ArrayContains(values, "recent"), true, "recent value should not be pruned")
+}
+
+func TestImportCacheHintsCountsOnlyInserted(t *testing.T) {
+	crud.TEST_MODE = true
+	chat := &tb.Chat{ID: 12345}
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	mock.
+		ExpectQuery(`SELECT "type", "value", "pinned"`).
+		WithArgs(chat.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"type", "value", "pinned"}))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, "description:", "first").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(`INSERT INTO "bot::cache"`).
+		WithArgs(chat.ID, "description:", "second").
+		WillReturnError(fmt.Errorf("insert failed"))
+
+	added, err := crud.NewRepo(db).ImportCacheHints(&tb.Message{Chat: chat}, "description", []string{"first", "second"}, false)
+	helpers.TestExpect(t, err != nil, true, "")
+	helpers.TestExpect(t, added, 1, "failed inserts should not be counted")
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCacheConcurrentPruning(t *testing.T) {
+	repo := crud.NewRepo(dbWrapper.Connection())
+	var tgChatId int64 = -272728
+	m := &tb.Message{Chat: &tb.Chat{ID: tgChatId}, Sender: &tb.User{ID: tgChatId}}
+	helpers.TestExpect(t, repo.EnrichUserData(m), nil, "")
+	defer repo.DeleteAllCacheEntries(m)
+	_, err := repo.ImportCacheHints(m, "description:", []string{"value"}, false)
+	helpers.TestExpect(t, err, nil, "")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := repo.GetCacheHints(m, "description:")
+			helpers.TestExpect(t, err, nil, "")
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := repo.PruneCache(30)
+			helpers.TestExpect(t, err, nil, "")
+		}()
+	}
+	wg.Wait()
+	values, err := repo.GetCacheHints(m, "description:")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpectArrEq(t, values, []string{"value"}, "")
+}
@@ -0,0 +1,154 @@
+package crud
+
+import (
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// ImportProfile describes how statements of a bank are imported. Config holds the settings as 'key=value' pairs.
+type ImportProfile struct {
+	Name   string
+	Config string
+}
+
+// ImportRule assigns the account to imported statement rows matching the regular expression pattern
+type ImportRule struct {
+	Id      int
+	Pattern string
+	Account string
+}
+
+// SetImportProfile creates an import profile or replaces the one with the same name
+func (r *Repo) SetImportProfile(m *tb.Message, profile *ImportProfile) error {
+	LogDbf(r, helpers.TRACE, m, "Setting import profile: %v", profile)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM "bot::importProfile" WHERE "tgChatId" = $1 AND "name" = $2`, m.Chat.ID, profile.Name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO "bot::importProfile" ("tgChatId", "name", "config") VALUES ($1, $2, $3)`,
+		m.Chat.ID, profile.Name, profile.Config)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) GetImportProfiles(m *tb.Message) ([]*ImportProfile, error) {
+	rows, err := r.db.Query(`
+		SELECT "name", "config"
+		FROM "bot::importProfile"
+		WHERE "tgChatId" = $1
+		ORDER BY "name" ASC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []*ImportProfile{}
+	for rows.Next() {
+		profile := &ImportProfile{}
+		err = rows.Scan(&profile.Name, &profile.Config)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func (r *Repo) DeleteImportProfile(m *tb.Message, name string) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Deleting import profile '%s'", name)
+	res, err := r.db.Exec(`DELETE FROM "bot::importProfile" WHERE "tgChatId" = $1 AND "name" = $2`, m.Chat.ID, name)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repo) AddImportRule(m *tb.Message, rule *ImportRule) error {
+	LogDbf(r, helpers.TRACE, m, "Adding import rule: %v", rule)
+	_, err := r.db.Exec(`INSERT INTO "bot::importRule" ("tgChatId", "pattern", "account") VALUES ($1, $2, $3)`,
+		m.Chat.ID, rule.Pattern, rule.Account)
+	return err
+}
+
+// GetImportRules returns the import rules in the order they have been added, which is the order they are applied in
+func (r *Repo) GetImportRules(m *tb.Message) ([]*ImportRule, error) {
+	rows, err := r.db.Query(`
+		SELECT "id", "pattern", "account"
+		FROM "bot::importRule"
+		WHERE "tgChatId" = $1
+		ORDER BY "id" ASC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []*ImportRule{}
+	for rows.Next() {
+		rule := &ImportRule{}
+		err = rows.Scan(&rule.Id, &rule.Pattern, &rule.Account)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r *Repo) DeleteImportRule(m *tb.Message, id int) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Deleting import rule %d", id)
+	res, err := r.db.Exec(`DELETE FROM "bot::importRule" WHERE "tgChatId" = $1 AND "id" = $2`, m.Chat.ID, id)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// AddImportedId remembers the id of a statement entry (e.g. an OFX FITID), which has been imported to the account
+func (r *Repo) AddImportedId(m *tb.Message, account, id string) error {
+	LogDbf(r, helpers.TRACE, m, "Adding imported id '%s' of account %s", id, account)
+	_, err := r.db.Exec(`INSERT INTO "bot::importedId" ("tgChatId", "account", "importId") VALUES ($1, $2, $3)`,
+		m.Chat.ID, account, id)
+	return err
+}
+
+// GetImportedIds returns the ids of the statement entries already imported to the account
+func (r *Repo) GetImportedIds(m *tb.Message, account string) (map[string]bool, error) {
+	rows, err := r.db.Query(`
+		SELECT "importId"
+		FROM "bot::importedId"
+		WHERE "tgChatId" = $1 AND "account" = $2`, m.Chat.ID, account)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := map[string]bool{}
+	for rows.Next() {
+		var id string
+		err = rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return ids, nil
+}
+
+// DeleteImportSettings removes all import profiles, rules and imported ids of the chat
+func (r *Repo) DeleteImportSettings(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting import profiles, rules and imported ids")
+	for _, table := range []string{"bot::importProfile", "bot::importRule", "bot::importedId"} {
+		_, err := r.db.Exec(`DELETE FROM "`+table+`" WHERE "tgChatId" = $1`, m.Chat.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
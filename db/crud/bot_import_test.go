@@ -0,0 +1,69 @@
+package crud_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"gopkg.in/telebot.v3"
+)
+
+func TestImportSettingsDb(t *testing.T) {
+	m := &telebot.Message{Chat: &telebot.Chat{ID: -257}, Sender: &telebot.User{ID: -257}}
+	repo := crud.NewRepo(db.Connection())
+	repo.EnrichUserData(m)
+	defer repo.DeleteImportSettings(m)
+
+	err := repo.SetImportProfile(m, &crud.ImportProfile{Name: "bank", Config: "account=Assets:Bank date=1 amount=2"})
+	if err != nil {
+		t.Fatalf("Setting import profile should not fail: %s", err.Error())
+	}
+	_ = repo.SetImportProfile(m, &crud.ImportProfile{Name: "bank", Config: "account=Assets:Bank date=1 amount=3"})
+	_ = repo.SetImportProfile(m, &crud.ImportProfile{Name: "card", Config: "account=Liabilities:Card date=2 amount=4"})
+	profiles, err := repo.GetImportProfiles(m)
+	if err != nil {
+		t.Fatalf("Getting import profiles should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(profiles), 2, "")
+	helpers.TestExpect(t, *profiles[0], crud.ImportProfile{Name: "bank", Config: "account=Assets:Bank date=1 amount=3"}, "profile should have been replaced")
+	count, err := repo.DeleteImportProfile(m, "card")
+	if err != nil {
+		t.Errorf("Deleting import profile should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, count, int64(1), "")
+
+	_ = repo.AddImportRule(m, &crud.ImportRule{Pattern: "REWE|Lidl", Account: "Expenses:Groceries"})
+	_ = repo.AddImportRule(m, &crud.ImportRule{Pattern: "Salary", Account: "Income:Salary"})
+	rules, err := repo.GetImportRules(m)
+	if err != nil {
+		t.Fatalf("Getting import rules should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(rules), 2, "")
+	helpers.TestExpect(t, rules[0].Pattern, "REWE|Lidl", "rules should be in the order they were added")
+	helpers.TestExpect(t, rules[1].Account, "Income:Salary", "")
+	count, _ = repo.DeleteImportRule(m, rules[0].Id)
+	helpers.TestExpect(t, count, int64(1), "")
+
+	err = repo.AddImportedId(m, "Assets:Bank", "T1")
+	if err != nil {
+		t.Fatalf("Adding imported id should not fail: %s", err.Error())
+	}
+	ids, err := repo.GetImportedIds(m, "Assets:Bank")
+	if err != nil {
+		t.Fatalf("Getting imported ids should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(ids), 1, "")
+	helpers.TestExpect(t, ids["T1"], true, "")
+	ids, _ = repo.GetImportedIds(m, "Liabilities:Card")
+	helpers.TestExpect(t, len(ids), 0, "imported ids are kept per account")
+
+	err = repo.DeleteImportSettings(m)
+	if err != nil {
+		t.Errorf("Deleting import settings should not fail: %s", err.Error())
+	}
+	profiles, _ = repo.GetImportProfiles(m)
+	rules, _ = repo.GetImportRules(m)
+	ids, _ = repo.GetImportedIds(m, "Assets:Bank")
+	helpers.TestExpect(t, len(profiles)+len(rules)+len(ids), 0, "")
+}
@@ -0,0 +1,81 @@
+package crud
+
+import (
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// OpeningBalance is the balance of an account in a currency before the first recorded transaction
+type OpeningBalance struct {
+	Account  string
+	Amount   float64
+	Currency string
+}
+
+// SetOpeningBalance creates an opening balance or replaces the one for the same account and currency
+func (r *Repo) SetOpeningBalance(m *tb.Message, balance *OpeningBalance) error {
+	LogDbf(r, helpers.TRACE, m, "Setting opening balance: %v", balance)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM "bot::openingBalance" WHERE "tgChatId" = $1 AND "account" = $2 AND "currency" = $3`,
+		m.Chat.ID, balance.Account, balance.Currency)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO "bot::openingBalance" ("tgChatId", "account", "amount", "currency")
+		VALUES ($1, $2, $3, $4)`,
+		m.Chat.ID, balance.Account, balance.Amount, balance.Currency)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repo) GetOpeningBalances(m *tb.Message) ([]*OpeningBalance, error) {
+	rows, err := r.db.Query(`
+		SELECT "account", "amount", "currency"
+		FROM "bot::openingBalance"
+		WHERE "tgChatId" = $1
+		ORDER BY "account" ASC, "currency" ASC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []*OpeningBalance{}
+	for rows.Next() {
+		balance := &OpeningBalance{}
+		err = rows.Scan(&balance.Account, &balance.Amount, &balance.Currency)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
+
+// DeleteOpeningBalance removes the opening balances of an account. If currency is empty, all currencies are removed.
+func (r *Repo) DeleteOpeningBalance(m *tb.Message, account, currency string) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Deleting opening balance for '%s' (currency: '%s')", account, currency)
+	query := `DELETE FROM "bot::openingBalance" WHERE "tgChatId" = $1 AND "account" = $2`
+	params := []interface{}{m.Chat.ID, account}
+	if currency != "" {
+		query += ` AND "currency" = $3`
+		params = append(params, currency)
+	}
+	res, err := r.db.Exec(query, params...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repo) DeleteOpeningBalances(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting opening balances")
+	_, err := r.db.Exec(`DELETE FROM "bot::openingBalance" WHERE "tgChatId" = $1`, m.Chat.ID)
+	return err
+}
@@ -0,0 +1,83 @@
+package crud
+
+import (
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Rate is the price of one unit of the base currency in the quote currency, valid from its date on
+type Rate struct {
+	Date  string
+	Base  string
+	Quote string
+	Rate  float64
+}
+
+// SetRate stores an exchange rate or replaces the one of the same date and currencies
+func (r *Repo) SetRate(m *tb.Message, rate *Rate) error {
+	LogDbf(r, helpers.TRACE, m, "Setting exchange rate: %v", rate)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM "bot::rate" WHERE "tgChatId" = $1 AND "date" = $2 AND "base" = $3 AND "quote" = $4`,
+		m.Chat.ID, rate.Date, rate.Base, rate.Quote)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO "bot::rate" ("tgChatId", "date", "base", "quote", "rate")
+		VALUES ($1, $2, $3, $4, $5)`,
+		m.Chat.ID, rate.Date, rate.Base, rate.Quote, rate.Rate)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetRates returns all exchange rates, ordered by date
+func (r *Repo) GetRates(m *tb.Message) ([]*Rate, error) {
+	rows, err := r.db.Query(`
+		SELECT "date", "base", "quote", "rate"
+		FROM "bot::rate"
+		WHERE "tgChatId" = $1
+		ORDER BY "date" ASC, "base" ASC, "quote" ASC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := []*Rate{}
+	for rows.Next() {
+		rate := &Rate{}
+		err = rows.Scan(&rate.Date, &rate.Base, &rate.Quote, &rate.Rate)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// DeleteRate removes the exchange rates of a currency pair. If date is empty, the rates of all dates are removed.
+func (r *Repo) DeleteRate(m *tb.Message, base, quote, date string) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Deleting exchange rate %s/%s (date: '%s')", base, quote, date)
+	query := `DELETE FROM "bot::rate" WHERE "tgChatId" = $1 AND "base" = $2 AND "quote" = $3`
+	params := []interface{}{m.Chat.ID, base, quote}
+	if date != "" {
+		query += ` AND "date" = $4`
+		params = append(params, date)
+	}
+	res, err := r.db.Exec(query, params...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *Repo) DeleteRates(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting exchange rates")
+	_, err := r.db.Exec(`DELETE FROM "bot::rate" WHERE "tgChatId" = $1`, m.Chat.ID)
+	return err
+}
@@ -0,0 +1,43 @@
+package crud_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"gopkg.in/telebot.v3"
+)
+
+func TestRatesDb(t *testing.T) {
+	m := &telebot.Message{Chat: &telebot.Chat{ID: -256}, Sender: &telebot.User{ID: -256}}
+	repo := crud.NewRepo(db.Connection())
+	repo.EnrichUserData(m)
+	defer repo.DeleteRates(m)
+
+	err := repo.SetRate(m, &crud.Rate{Date: "2024-02-01", Base: "USD", Quote: "EUR", Rate: 0.92})
+	if err != nil {
+		t.Fatalf("Setting rate should not fail: %s", err.Error())
+	}
+	_ = repo.SetRate(m, &crud.Rate{Date: "2024-01-01", Base: "USD", Quote: "EUR", Rate: 0.9})
+	_ = repo.SetRate(m, &crud.Rate{Date: "2024-01-01", Base: "CHF", Quote: "EUR", Rate: 1.05})
+	// Setting a rate for the same date replaces it
+	_ = repo.SetRate(m, &crud.Rate{Date: "2024-02-01", Base: "USD", Quote: "EUR", Rate: 0.93})
+	rates, err := repo.GetRates(m)
+	if err != nil {
+		t.Fatalf("Getting rates should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(rates), 3, "")
+	helpers.TestExpect(t, *rates[0], crud.Rate{Date: "2024-01-01", Base: "CHF", Quote: "EUR", Rate: 1.05}, "")
+	helpers.TestExpect(t, *rates[2], crud.Rate{Date: "2024-02-01", Base: "USD", Quote: "EUR", Rate: 0.93}, "")
+
+	count, err := repo.DeleteRate(m, "USD", "EUR", "2024-01-01")
+	if err != nil {
+		t.Errorf("Deleting rate should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, count, int64(1), "")
+	count, _ = repo.DeleteRate(m, "CHF", "EUR", "")
+	helpers.TestExpect(t, count, int64(1), "")
+	rates, _ = repo.GetRates(m)
+	helpers.TestExpect(t, len(rates), 1, "")
+}
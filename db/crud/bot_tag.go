@@ -0,0 +1,131 @@
+package crud
+
+import (
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Tag is added to new transactions while it is active. It expires after the date in Expires (inclusive), if set.
+// The tag setting (see UserSetTag) is an active tag without expiry as well.
+type Tag struct {
+	Name    string
+	Expires string
+}
+
+// AddTag activates a tag or replaces the expiry of the active one of the same name
+func (r *Repo) AddTag(m *tb.Message, tag *Tag) error {
+	LogDbf(r, helpers.TRACE, m, "Adding tag: %v", tag)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM "bot::tag" WHERE "tgChatId" = $1 AND "tag" = $2`, m.Chat.ID, tag.Name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM "bot::userSetting" WHERE "tgChatId" = $1 AND "setting" = $2 AND "value" = $3`, m.Chat.ID, helpers.USERSET_TAG, tag.Name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO "bot::tag" ("tgChatId", "tag", "expires") VALUES ($1, $2, $3)`,
+		m.Chat.ID, tag.Name, nullableString(tag.Expires))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTags returns the tags active on the date including the tag setting, ordered by name. If date is empty, expired
+// tags are included.
+func (r *Repo) GetTags(m *tb.Message, date string) ([]*Tag, error) {
+	query := `SELECT "tag", "expires" FROM "bot::tag" WHERE "tgChatId" = $1`
+	params := []interface{}{m.Chat.ID}
+	if date != "" {
+		query += ` AND ("expires" IS NULL OR "expires" >= $2)`
+		params = append(params, date)
+	}
+	query += `
+		UNION
+		SELECT "value", NULL FROM "bot::userSetting" WHERE "tgChatId" = $1 AND "setting" = '` + helpers.USERSET_TAG + `' AND "value" <> ''
+		ORDER BY "tag" ASC`
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*Tag{}
+	for rows.Next() {
+		var expires *string
+		tag := &Tag{}
+		err = rows.Scan(&tag.Name, &expires)
+		if err != nil {
+			return nil, err
+		}
+		if expires != nil {
+			tag.Expires = *expires
+		}
+		if len(tags) > 0 && tags[len(tags)-1].Name == tag.Name {
+			// Set as setting and as tag: The setting does not expire
+			if tag.Expires == "" {
+				tags[len(tags)-1].Expires = ""
+			}
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// UserGetTags returns the names of the tags active on the date
+func (r *Repo) UserGetTags(m *tb.Message, date string) []string {
+	tags, err := r.GetTags(m, date)
+	if err != nil {
+		LogDbf(r, helpers.ERROR, m, "Could not get tags: %s", err.Error())
+		return []string{}
+	}
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	return names
+}
+
+// DeleteTag deactivates the tag, also if it is the tag setting. It returns the number of tags removed.
+func (r *Repo) DeleteTag(m *tb.Message, name string) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Deleting tag '%s'", name)
+	res, err := r.db.Exec(`DELETE FROM "bot::tag" WHERE "tgChatId" = $1 AND "tag" = $2`, m.Chat.ID, name)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	res, err = r.db.Exec(`DELETE FROM "bot::userSetting" WHERE "tgChatId" = $1 AND "setting" = $2 AND "value" = $3`, m.Chat.ID, helpers.USERSET_TAG, name)
+	if err != nil {
+		return count, err
+	}
+	setting, err := res.RowsAffected()
+	return count + setting, err
+}
+
+// DeleteTags deactivates all tags including the tag setting
+func (r *Repo) DeleteTags(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Deleting all tags")
+	_, err := r.db.Exec(`DELETE FROM "bot::tag" WHERE "tgChatId" = $1`, m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	return r.UserSetTag(m, "")
+}
+
+// PruneTags removes the tags of all chats which expired before the date
+func (r *Repo) PruneTags(date string) (int64, error) {
+	res, err := r.db.Exec(`DELETE FROM "bot::tag" WHERE "expires" < $1`, date)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
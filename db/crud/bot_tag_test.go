@@ -0,0 +1,58 @@
+package crud_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"gopkg.in/telebot.v3"
+)
+
+func TestTagsDb(t *testing.T) {
+	m := &telebot.Message{Chat: &telebot.Chat{ID: -256}, Sender: &telebot.User{ID: -256}}
+	repo := crud.NewRepo(db.Connection())
+	repo.EnrichUserData(m)
+	defer repo.DeleteTags(m)
+
+	err := repo.AddTag(m, &crud.Tag{Name: "vacation"})
+	if err != nil {
+		t.Fatalf("Adding tag should not fail: %s", err.Error())
+	}
+	_ = repo.AddTag(m, &crud.Tag{Name: "nyc", Expires: "2024-01-10"})
+	_ = repo.AddTag(m, &crud.Tag{Name: "conference", Expires: "2024-01-01"})
+	// Adding an active tag again replaces its expiry
+	_ = repo.AddTag(m, &crud.Tag{Name: "conference", Expires: "2024-01-05"})
+
+	helpers.TestExpectArrEq(t, repo.UserGetTags(m, "2024-01-05"), []string{"conference", "nyc", "vacation"}, "")
+	helpers.TestExpectArrEq(t, repo.UserGetTags(m, "2024-01-06"), []string{"nyc", "vacation"}, "")
+	tags, err := repo.GetTags(m, "")
+	if err != nil {
+		t.Fatalf("Getting tags should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(tags), 3, "expired tags should be included without date")
+	helpers.TestExpect(t, *tags[1], crud.Tag{Name: "nyc", Expires: "2024-01-10"}, "")
+
+	pruned, err := repo.PruneTags("2024-01-06")
+	if err != nil {
+		t.Errorf("Pruning tags should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, pruned >= 1, true, "")
+	count, err := repo.DeleteTag(m, "nyc")
+	if err != nil {
+		t.Errorf("Deleting tag should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, count, int64(1), "")
+	helpers.TestExpectArrEq(t, repo.UserGetTags(m, ""), []string{"vacation"}, "")
+
+	// The tag setting is an active tag without expiry
+	_ = repo.AddTag(m, &crud.Tag{Name: "home", Expires: "2024-01-10"})
+	_ = repo.UserSetTag(m, "home")
+	helpers.TestExpectArrEq(t, repo.UserGetTags(m, "2024-01-11"), []string{"home", "vacation"}, "the tag setting should not expire")
+	count, err = repo.DeleteTag(m, "home")
+	if err != nil {
+		t.Errorf("Deleting tag should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, count, int64(2), "")
+	helpers.TestExpect(t, repo.UserGetTag(m), "", "deleting the tag should clear the tag setting")
+}
@@ -1,34 +1,70 @@
 package crud
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	tb "gopkg.in/telebot.v3"
 )
 
-func (r *Repo) RecordTransaction(chatId int64, tx string) error {
+func (r *Repo) RecordTransaction(m *tb.Message, tx string) error {
 	if tx == "" {
 		return fmt.Errorf("a transaction inserted into the database must not be empty")
 	}
-	_, err := r.db.Exec(`
-		INSERT INTO "bot::transaction" ("id", "tgChatId", "value")
-		VALUES (`+db.AutoIncValue()+`,$1, $2);`, chatId, tx)
-	return err
+	dbTx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	err = r.insertTransaction(dbTx, m, tx)
+	if err != nil {
+		return err
+	}
+	return dbTx.Commit()
+}
+
+// insertTransaction inserts the transaction within dbTx and records its creation
+func (r *Repo) insertTransaction(dbTx *sql.Tx, m *tb.Message, tx string) error {
+	bookingDate, amount := bookingInfo(tx)
+	var id int
+	err := dbTx.QueryRow(`
+		INSERT INTO "bot::transaction" ("id", "tgChatId", "value", "bookingDate", "amount", "pending")
+		VALUES (`+db.AutoIncValue()+`,$1, $2, $3, $4, $5)
+		RETURNING "id";`, m.Chat.ID, tx, bookingDate, amount, helpers.IsPending(tx)).Scan(&id)
+	if err != nil {
+		return err
+	}
+	return r.recordEvents(dbTx, m, EVENT_CREATE, false, true, `"tgChatId" = $1 AND "id" = $2`, m.Chat.ID, id)
+}
+
+// bookingInfo returns the values to store alongside a transaction for filtering. NULL for comments.
+func bookingInfo(tx string) (sql.NullString, sql.NullFloat64) {
+	date, amount, ok := helpers.BookingInfo(tx)
+	if !ok {
+		return sql.NullString{}, sql.NullFloat64{}
+	}
+	return sql.NullString{String: date, Valid: true}, sql.NullFloat64{Float64: amount, Valid: true}
 }
 
 type TransactionResult struct {
-	Id   int
-	Tx   string
-	Date string
+	Id       int
+	Tx       string
+	Date     string
+	Archived bool
+	// Deleted is the time the transaction has been moved to the trash
+	Deleted string
 }
 
 func (r *Repo) GetTransactions(m *tb.Message, isArchived bool) ([]*TransactionResult, error) {
 	LogDbf(r, helpers.TRACE, m, "Getting transactions")
 	rows, err := r.db.Query(`
 		SELECT "id", "value", "created" FROM "bot::transaction"
-		WHERE "tgChatId" = $1 AND "archived" = $2
+		WHERE "tgChatId" = $1 AND "archived" = $2 AND "deleted" IS NULL
 		ORDER BY "created" ASC
 	`, m.Chat.ID, isArchived)
 	if err != nil {
@@ -46,24 +82,167 @@ func (r *Repo) GetTransactions(m *tb.Message, isArchived bool) ([]*TransactionRe
 			return nil, err
 		}
 		allTransactions = append(allTransactions, &TransactionResult{
-			Id:   id,
-			Tx:   transactionString,
-			Date: created,
+			Id:       id,
+			Tx:       transactionString,
+			Date:     created,
+			Archived: isArchived,
 		})
 	}
 	return allTransactions, nil
 }
 
+type AmountCondition struct {
+	Operator string
+	Value    float64
+}
+
+// TransactionFilter restricts FindTransactions. Empty fields don't filter. Transactions in the trash are never found.
+type TransactionFilter struct {
+	Archived *bool
+	Texts    []string
+	// Accounts match postings to the account or its sub-accounts
+	Accounts []string
+	// Tags match whole tags (without '#')
+	Tags []string
+	// From and To are inclusive booking dates (YYYY-MM-DD)
+	From string
+	To   string
+	// Amounts compare the largest absolute posting amount of a transaction, regardless of its currency
+	Amounts []AmountCondition
+	BatchId int
+	// Pending only returns transactions flagged with '!'
+	Pending bool
+	// SortByBookingDate orders by the date inside the transactions instead of the time they have been recorded
+	SortByBookingDate bool
+	// Limit restricts the number of results if positive
+	Limit  int
+	Offset int
+}
+
+const ARCHIVE_ALL_LABEL = "all open transactions"
+
+var AMOUNT_OPERATORS = []string{">=", "<=", ">", "<", "="}
+
+// wordsValue is the transaction value with line breaks and tabs as spaces, wrapped in spaces,
+// so that whole words can be matched with LIKE '% word %'
+const wordsValue = `(' ' || REPLACE(REPLACE("value", '` + "\n" + `', ' '), '` + "\t" + `', ' ') || ' ')`
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (f TransactionFilter) where(chatId int64) (string, []interface{}, error) {
+	conditions := []string{`"tgChatId" = $1`, `"deleted" IS NULL`}
+	params := []interface{}{chatId}
+	add := func(condition string, values ...interface{}) {
+		for _, value := range values {
+			params = append(params, value)
+			condition = strings.Replace(condition, "$?", fmt.Sprintf("$%d", len(params)), 1)
+		}
+		conditions = append(conditions, condition)
+	}
+	if f.Archived != nil {
+		add(`"archived" = $?`, *f.Archived)
+	}
+	for _, text := range f.Texts {
+		add(`LOWER("value") LIKE $? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(text))+"%")
+	}
+	// Accounts and tags are matched as whole words, accounts also with their sub-accounts
+	for _, account := range f.Accounts {
+		add(`(`+wordsValue+` LIKE $? ESCAPE '\' OR `+wordsValue+` LIKE $? ESCAPE '\')`,
+			"% "+escapeLike(account)+" %", "% "+escapeLike(account)+":%")
+	}
+	for _, tag := range f.Tags {
+		add(wordsValue+` LIKE $? ESCAPE '\'`, "% #"+escapeLike(strings.TrimPrefix(tag, "#"))+" %")
+	}
+	if f.From != "" {
+		add(`"bookingDate" >= $?`, f.From)
+	}
+	if f.To != "" {
+		add(`"bookingDate" <= $?`, f.To)
+	}
+	if f.Pending {
+		conditions = append(conditions, `"pending" = TRUE`)
+	}
+	if f.BatchId > 0 {
+		add(`"batchId" = $?`, f.BatchId)
+	}
+	for _, amount := range f.Amounts {
+		if !helpers.ArrayContains(AMOUNT_OPERATORS, amount.Operator) {
+			return "", nil, fmt.Errorf("unsupported amount comparison '%s'", amount.Operator)
+		}
+		add(`"amount" `+amount.Operator+` $?`, amount.Value)
+	}
+	return strings.Join(conditions, " AND "), params, nil
+}
+
+// FindTransactions returns open and archived transactions matching the filter
+func (r *Repo) FindTransactions(m *tb.Message, filter TransactionFilter) ([]*TransactionResult, error) {
+	LogDbf(r, helpers.TRACE, m, "Finding transactions: %+v", filter)
+	where, params, err := filter.where(m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	order := `"created" ASC, "id" ASC`
+	if filter.SortByBookingDate {
+		// Comments have no booking date. They are sorted in by the day they have been recorded.
+		order = `COALESCE("bookingDate", DATE("created")) ASC, ` + order
+	}
+	query := `
+		SELECT "id", "value", "created", "archived" FROM "bot::transaction"
+		WHERE ` + where + `
+		ORDER BY ` + order
+	if filter.Limit > 0 {
+		params = append(params, filter.Limit, filter.Offset)
+		query += fmt.Sprintf(`
+		LIMIT $%d OFFSET $%d`, len(params)-1, len(params))
+	}
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*TransactionResult{}
+	for rows.Next() {
+		tx := &TransactionResult{}
+		err = rows.Scan(&tx.Id, &tx.Tx, &tx.Date, &tx.Archived)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// CountTransactions returns the number of transactions matching the filter, ignoring limit and offset
+func (r *Repo) CountTransactions(m *tb.Message, filter TransactionFilter) (int, error) {
+	where, params, err := filter.where(m.Chat.ID)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM "bot::transaction" WHERE `+where, params...).Scan(&count)
+	return count, err
+}
+
+// ArchiveTransactions archives all open transactions as a single batch
 func (r *Repo) ArchiveTransactions(m *tb.Message) error {
-	LogDbf(r, helpers.TRACE, m, "Archiving transactions")
-	_, err := r.db.Exec(`
-		UPDATE "bot::transaction"
-		SET "archived" = TRUE
-		WHERE "tgChatId" = $1`, m.Chat.ID)
+	_, _, err := r.ArchiveTransactionBatch(m, ARCHIVE_ALL_LABEL, nil)
 	return err
 }
 
+// DeleteTransactions moves all transactions to the trash
 func (r *Repo) DeleteTransactions(m *tb.Message) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Moving transactions to trash")
+	return r.changeTransactions(m, EVENT_DELETE, true, false, `
+		UPDATE "bot::transaction"
+		SET "deleted" = CURRENT_TIMESTAMP`,
+		`"tgChatId" = $1 AND "deleted" IS NULL`, m.Chat.ID)
+}
+
+// PurgeTransactions permanently deletes all transactions, including the ones in the trash
+func (r *Repo) PurgeTransactions(m *tb.Message) (int64, error) {
 	LogDbf(r, helpers.TRACE, m, "Permanently deleting transactions")
 	res, err := r.db.Exec(`
 		DELETE FROM "bot::transaction"
@@ -82,13 +261,231 @@ func (r *Repo) DeleteTemplates(m *tb.Message) error {
 	return err
 }
 
+// DeleteTransaction moves a single transaction to the trash
 func (r *Repo) DeleteTransaction(m *tb.Message, isArchived bool, elementId int) (int64, error) {
-	LogDbf(r, helpers.TRACE, m, "Deleting single transaction")
-	rows, err := r.db.Exec(`
-		DELETE FROM "bot::transaction"
-		WHERE "tgChatId" = $1 AND "archived" = $2 AND "id" = $3`, m.Chat.ID, isArchived, elementId)
+	LogDbf(r, helpers.TRACE, m, "Moving single transaction to trash")
+	return r.changeTransactions(m, EVENT_DELETE, true, false, `
+		UPDATE "bot::transaction"
+		SET "deleted" = CURRENT_TIMESTAMP`,
+		`"tgChatId" = $1 AND "archived" = $2 AND "id" = $3 AND "deleted" IS NULL`, m.Chat.ID, isArchived, elementId)
+}
+
+func (r *Repo) SetTransactionArchived(m *tb.Message, elementId int, archived bool) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Setting single transaction archived: %t", archived)
+	event := EVENT_ARCHIVE
+	change := `
+		UPDATE "bot::transaction"
+		SET "archived" = $3`
+	if !archived {
+		event = EVENT_UNARCHIVE
+		// Unarchived transactions don't belong to their archive batch anymore
+		change += `, "batchId" = NULL`
+	}
+	return r.changeTransactions(m, event, true, true, change,
+		`"tgChatId" = $1 AND "id" = $2 AND "deleted" IS NULL AND "archived" <> $3`, m.Chat.ID, elementId, archived)
+}
+
+// ConfirmTransaction flags a pending transaction as complete ('*')
+func (r *Repo) ConfirmTransaction(m *tb.Message, elementId int) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Confirming pending transaction")
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var value string
+	err = tx.QueryRow(`
+		SELECT "value" FROM "bot::transaction"
+		WHERE "tgChatId" = $1 AND "id" = $2 AND "pending" = TRUE AND "deleted" IS NULL`, m.Chat.ID, elementId).Scan(&value)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	confirmed, _ := helpers.SetFlag(value, helpers.FLAG_COMPLETE)
+	_, err = tx.Exec(`
+		INSERT INTO "bot::transactionEvent" ("tgChatId", "transactionId", "event", "before", "after", "source", "tgUserId")
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		m.Chat.ID, elementId, EVENT_UPDATE, value, confirmed, r.eventSource(), eventActor(m))
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(`
+		UPDATE "bot::transaction"
+		SET "value" = $3, "pending" = FALSE
+		WHERE "tgChatId" = $1 AND "id" = $2`, m.Chat.ID, elementId, confirmed)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return count, tx.Commit()
+}
+
+// RecordReversal records the reversal as new transaction and adds the link (without leading '^') to the header of the
+// open or archived original transaction, both at once
+func (r *Repo) RecordReversal(m *tb.Message, originalId int, link string, reversal string) error {
+	LogDbf(r, helpers.TRACE, m, "Recording reversal")
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var value string
+	err = tx.QueryRow(`
+		SELECT "value" FROM "bot::transaction"
+		WHERE "tgChatId" = $1 AND "id" = $2 AND "deleted" IS NULL`, m.Chat.ID, originalId).Scan(&value)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("the transaction to reverse does not exist anymore")
+	} else if err != nil {
+		return err
+	}
+	linked, _ := helpers.AddTagsAndLinks(value, "^"+link)
+	if linked != value {
+		_, err = tx.Exec(`
+			INSERT INTO "bot::transactionEvent" ("tgChatId", "transactionId", "event", "before", "after", "source", "tgUserId")
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			m.Chat.ID, originalId, EVENT_UPDATE, value, linked, r.eventSource(), eventActor(m))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE "bot::transaction" SET "value" = $3 WHERE "tgChatId" = $1 AND "id" = $2`, m.Chat.ID, originalId, linked)
+		if err != nil {
+			return err
+		}
+	}
+	err = r.insertTransaction(tx, m, reversal)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPendingChatsToNotify returns the chats whose notification hour is the current one and which have transactions
+// pending for longer than their reminder setting (in days), together with the number of these transactions.
+// Chats without the setting are reminded after defaultDays.
+func (r *Repo) GetPendingChatsToNotify(defaultDays int) (map[int64]int, error) {
+	var hourCondition, ageCondition string
+	switch db.DbType() {
+	case "POSTGRES":
+		hourCondition = `MOD(s."notificationHour" + 24 - CASE WHEN tzset."value" IS NULL THEN 0 ELSE tzset."value"::DECIMAL END, 24) = $1`
+		ageCondition = `tx."created" + INTERVAL '1 day' * COALESCE(daysset."value"::DECIMAL, $2) <= NOW()`
+	default:
+		hourCondition = `(s."notificationHour" + 24 - CASE WHEN tzset."value" IS NULL THEN 0 ELSE tzset."value" END)%24 = $1`
+		ageCondition = `datetime(tx."created", '+' || COALESCE(daysset."value", $2) || ' days') <= datetime()`
+	}
+	rows, err := r.db.Query(`
+		SELECT tx."tgChatId", COUNT(*)
+		FROM "bot::transaction" tx
+			JOIN "bot::notificationSchedule" s ON tx."tgChatId" = s."tgChatId"
+			LEFT OUTER JOIN "bot::userSetting" tzset ON tx."tgChatId" = tzset."tgChatId" AND tzset."setting" = 'user.tzOffset'
+			LEFT OUTER JOIN "bot::userSetting" daysset ON tx."tgChatId" = daysset."tgChatId" AND daysset."setting" = 'user.pendingReminderDays'
+		WHERE
+			tx."pending" = TRUE AND
+			tx."archived" = FALSE AND
+			tx."deleted" IS NULL AND
+			COALESCE(daysset."value", '') <> '0' AND
+			`+hourCondition+` AND
+			`+ageCondition+`
+		GROUP BY tx."tgChatId"`, time.Now().UTC().Hour(), defaultDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	chats := map[int64]int{}
+	for rows.Next() {
+		var chatId int64
+		var count int
+		err = rows.Scan(&chatId, &count)
+		if err != nil {
+			return nil, err
+		}
+		chats[chatId] = count
+	}
+	return chats, nil
+}
+
+// GetTrash returns the transactions in the trash, the most recently deleted first
+func (r *Repo) GetTrash(m *tb.Message) ([]*TransactionResult, error) {
+	LogDbf(r, helpers.TRACE, m, "Getting trash")
+	rows, err := r.db.Query(`
+		SELECT "id", "value", "created", "archived", "deleted" FROM "bot::transaction"
+		WHERE "tgChatId" = $1 AND "deleted" IS NOT NULL
+		ORDER BY "deleted" DESC, "id" DESC
+	`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transactions := []*TransactionResult{}
+	for rows.Next() {
+		tx := &TransactionResult{}
+		err = rows.Scan(&tx.Id, &tx.Tx, &tx.Date, &tx.Archived, &tx.Deleted)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+// RestoreTransaction moves a transaction from the trash back to the list it has been deleted from
+func (r *Repo) RestoreTransaction(m *tb.Message, elementId int) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Restoring single transaction from trash")
+	return r.changeTransactions(m, EVENT_RESTORE, false, true, `
+		UPDATE "bot::transaction"
+		SET "deleted" = NULL`,
+		`"tgChatId" = $1 AND "id" = $2 AND "deleted" IS NOT NULL`, m.Chat.ID, elementId)
+}
+
+// EmptyTrash permanently deletes the transactions in the trash
+func (r *Repo) EmptyTrash(m *tb.Message) (int64, error) {
+	LogDbf(r, helpers.TRACE, m, "Emptying trash")
+	return r.changeTransactions(m, EVENT_PURGE, true, false, `
+		DELETE FROM "bot::transaction"`,
+		`"tgChatId" = $1 AND "deleted" IS NOT NULL`, m.Chat.ID)
+}
+
+// PurgeTrash permanently deletes the transactions of all users which have been in the trash for the given number of days
+func (r *Repo) PurgeTrash(days int) (int64, error) {
+	var threshold string
+	switch db.DbType() {
+	case "POSTGRES":
+		threshold = `NOW() - INTERVAL '1 day' * $1`
+	default:
+		threshold = `DATETIME('now', '-' || $1 || ' days')`
+	}
+	return r.changeTransactions(nil, EVENT_PURGE, true, false, `
+		DELETE FROM "bot::transaction"`,
+		`"deleted" < `+threshold, days)
+}
+
+// changeTransactions applies the change to the transactions matching the condition and records an event for each of them
+func (r *Repo) changeTransactions(m *tb.Message, event string, withBefore, withAfter bool, change string, condition string, params ...interface{}) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	err = r.recordEvents(tx, m, event, withBefore, withAfter, condition, params...)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(change+`
+		WHERE `+condition, params...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := res.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
-	return rows.RowsAffected()
+	return count, tx.Commit()
 }
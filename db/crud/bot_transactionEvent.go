@@ -0,0 +1,134 @@
+package crud
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+const (
+	EVENT_CREATE    = "create"
+	EVENT_UPDATE    = "update"
+	EVENT_ARCHIVE   = "archive"
+	EVENT_UNARCHIVE = "unarchive"
+	EVENT_DELETE    = "delete"
+	EVENT_RESTORE   = "restore"
+	EVENT_PURGE     = "purge"
+
+	EVENT_SOURCE_BOT = "bot"
+	EVENT_SOURCE_API = "api"
+	EVENT_SOURCE_JOB = "job"
+)
+
+type TransactionEvent struct {
+	Id            int
+	TransactionId int
+	Event         string
+	Before        string
+	After         string
+	Source        string
+	// UserId is the acting Telegram user. 0 if unknown, e.g. for API requests.
+	UserId  int64
+	Created string
+}
+
+// As returns a repo recording the given source with the transaction events it causes
+func (r *Repo) As(source string) *Repo {
+	return &Repo{
+		db:     r.db,
+		source: source,
+	}
+}
+
+// ApiEventSource is the source of changes made using the API token with the given id (see ApiTokenId)
+func ApiEventSource(tokenId string) string {
+	return EVENT_SOURCE_API + ":" + tokenId
+}
+
+func (r *Repo) eventSource() string {
+	if r.source == "" {
+		return EVENT_SOURCE_BOT
+	}
+	return r.source
+}
+
+func eventActor(m *tb.Message) sql.NullInt64 {
+	if m == nil || m.Sender == nil || m.Sender.ID == 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: m.Sender.ID, Valid: true}
+}
+
+// recordEvents adds an event for each transaction matching the condition. The condition uses
+// the placeholders $1 to $n for its params. The current value of the transactions is stored as
+// before and/or after value. It has to be called before the matching transactions are changed.
+func (r *Repo) recordEvents(tx *sql.Tx, m *tb.Message, event string, withBefore, withAfter bool, condition string, params ...interface{}) error {
+	before, after := "NULL", "NULL"
+	if withBefore {
+		before = `"value"`
+	}
+	if withAfter {
+		after = `"value"`
+	}
+	n := len(params)
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO "bot::transactionEvent" ("tgChatId", "transactionId", "event", "before", "after", "source", "tgUserId")
+		SELECT "tgChatId", "id", CAST($%d AS TEXT), %s, %s, CAST($%d AS TEXT), CAST($%d AS NUMERIC)
+		FROM "bot::transaction"
+		WHERE %s`, n+1, before, after, n+2, n+3, condition),
+		append(params, event, r.eventSource(), eventActor(m))...)
+	return err
+}
+
+// GetTransactionEvents returns the most recent events first. If transactionId is 0, the events of all
+// transactions are returned. limit restricts the number of events if positive.
+func (r *Repo) GetTransactionEvents(m *tb.Message, transactionId int, limit int) ([]*TransactionEvent, error) {
+	LogDbf(r, helpers.TRACE, m, "Getting transaction events for %d", transactionId)
+	query := `
+		SELECT "id", "transactionId", "event", "before", "after", "source", "tgUserId", "created"
+		FROM "bot::transactionEvent"
+		WHERE "tgChatId" = $1`
+	params := []interface{}{m.Chat.ID}
+	if transactionId != 0 {
+		params = append(params, transactionId)
+		query += ` AND "transactionId" = $2`
+	}
+	query += `
+		ORDER BY "created" DESC, "id" DESC`
+	if limit > 0 {
+		params = append(params, limit)
+		query += fmt.Sprintf(`
+		LIMIT $%d`, len(params))
+	}
+	rows, err := r.db.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*TransactionEvent{}
+	for rows.Next() {
+		event := &TransactionEvent{}
+		var before, after sql.NullString
+		var userId sql.NullInt64
+		err = rows.Scan(&event.Id, &event.TransactionId, &event.Event, &before, &after, &event.Source, &userId, &event.Created)
+		if err != nil {
+			return nil, err
+		}
+		event.Before = before.String
+		event.After = after.String
+		event.UserId = userId.Int64
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (r *Repo) DeleteTransactionEvents(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting transaction events")
+	_, err := r.db.Exec(`
+		DELETE FROM "bot::transactionEvent"
+		WHERE "tgChatId" = $1`, m.Chat.ID)
+	return err
+}
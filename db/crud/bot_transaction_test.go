@@ -1,11 +1,14 @@
 package crud_test
 
 import (
+	"fmt"
 	"log"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	dbWrapper "github.com/LucaBernstein/beancount-bot-tg/v2/db"
 	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
 	tb "gopkg.in/telebot.v3"
 )
 
@@ -19,8 +22,13 @@ func TestRecordGetTransaction(t *testing.T) {
 	defer db.Close()
 	r := crud.NewRepo(db)
 
-	mock.ExpectExec(`INSERT INTO "bot::transaction"`).WithArgs(1122, "txContent").WillReturnResult(sqlmock.NewResult(1, 1))
-	err = r.RecordTransaction(1122, "txContent")
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "bot::transaction"`).WithArgs(1122, "txContent", nil, nil, false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WithArgs(1122, 5, crud.EVENT_CREATE, crud.EVENT_SOURCE_BOT, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	err = r.RecordTransaction(&tb.Message{Chat: &tb.Chat{ID: 1122}}, "txContent")
 	if err != nil {
 		t.Errorf("No error should have been returned")
 	}
@@ -60,20 +68,91 @@ func TestArchiveDeleteTransactions(t *testing.T) {
 	defer db.Close()
 	r := crud.NewRepo(db)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO "bot::archiveBatch"`).WithArgs(1122, crud.ARCHIVE_ALL_LABEL).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WithArgs(1122, crud.EVENT_ARCHIVE, crud.EVENT_SOURCE_BOT, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "bot::transaction" SET "archived" = TRUE, "batchId" = \$2 WHERE "tgChatId" = \$1 AND "archived" = FALSE`).WithArgs(1122, 7).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	r.ArchiveTransactions(&tb.Message{Chat: &tb.Chat{ID: 1122}})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WithArgs(1122, crud.EVENT_DELETE, crud.EVENT_SOURCE_API, nil).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectExec(`
 		UPDATE "bot::transaction"
-		SET "archived" = TRUE
-		WHERE "tgChatId" = ?
+		SET "deleted" = CURRENT_TIMESTAMP
+		WHERE "tgChatId" = \$1 AND "deleted" IS NULL
 	`).WithArgs(1122).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	r.ArchiveTransactions(&tb.Message{Chat: &tb.Chat{ID: 1122}})
+	mock.ExpectCommit()
+	r.As(crud.EVENT_SOURCE_API).DeleteTransactions(&tb.Message{Chat: &tb.Chat{ID: 1122}})
 
 	mock.ExpectExec(`
 		DELETE FROM "bot::transaction"
 		WHERE "tgChatId" = ?
 	`).WithArgs(1122).
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	r.DeleteTransactions(&tb.Message{Chat: &tb.Chat{ID: 1122}})
+	r.PurgeTransactions(&tb.Message{Chat: &tb.Chat{ID: 1122}})
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestFindTransactionsMatchesAccountsAndTagsExactly(t *testing.T) {
+	m := &tb.Message{Chat: &tb.Chat{ID: -267}, Sender: &tb.User{ID: -267}}
+	repo := crud.NewRepo(dbWrapper.Connection())
+	helpers.TestExpect(t, repo.EnrichUserData(m), nil, "")
+	defer repo.PurgeTransactions(m)
+
+	for _, tx := range []string{
+		"2024-01-01 * \"Lunch\" #trip\n  Assets:Cash  -10.00 EUR\n  Expenses:Food\n",
+		"2024-01-02 * \"Expenses:Food court\" #trip2023\n  Assets:Cash  -11.00 EUR\n  Expenses:FoodCourt\n",
+		"2024-01-03 * \"Groceries\" #trip\n  Assets:Cash  -12.00 EUR\n  Expenses:Food:Groceries\n",
+		"2024-01-04 * \"Dinner\" #trip\n\tAssets:Cash  -13.00 EUR\n\tExpenses:Food\n",
+	} {
+		helpers.TestExpect(t, repo.RecordTransaction(m, tx), nil, "")
+	}
+	filter := crud.TransactionFilter{Accounts: []string{"Expenses:Food"}, Tags: []string{"trip"}, Limit: 2, Offset: 1}
+	tx, err := repo.FindTransactions(m, filter)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, len(tx), 2, "")
+	helpers.TestStringContains(t, tx[0].Tx, "Groceries", "pagination should apply to the matching transactions")
+	helpers.TestStringContains(t, tx[1].Tx, "Dinner", "")
+	count, err := repo.CountTransactions(m, filter)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, count, 3, "")
+}
+
+func TestRecordReversalRollsBackLink(t *testing.T) {
+	crud.TEST_MODE = true
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	r := crud.NewRepo(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "value" FROM "bot::transaction"`).WithArgs(1122, 5).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("2024-01-02 * \"Shop\"\n  Assets:Cash -5.00 EUR\n  Expenses:Food\n"))
+	mock.ExpectExec(`INSERT INTO "bot::transactionEvent"`).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE "bot::transaction"`).
+		WithArgs(1122, 5, "2024-01-02 * \"Shop\" ^reversal-5\n  Assets:Cash -5.00 EUR\n  Expenses:Food\n").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery(`INSERT INTO "bot::transaction"`).WillReturnError(fmt.Errorf("disk full"))
+	mock.ExpectRollback()
+	err = r.RecordReversal(&tb.Message{Chat: &tb.Chat{ID: 1122}}, 5, "reversal-5", "2024-01-03 * \"Shop\" ^reversal-5\n  Assets:Cash 5.00 EUR\n  Expenses:Food\n")
+	helpers.TestExpect(t, err.Error(), "disk full", "")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT "value" FROM "bot::transaction"`).WithArgs(1122, 6).WillReturnRows(sqlmock.NewRows([]string{"value"}))
+	mock.ExpectRollback()
+	err = r.RecordReversal(&tb.Message{Chat: &tb.Chat{ID: 1122}}, 6, "reversal-6", "tx")
+	helpers.TestExpect(t, err.Error(), "the transaction to reverse does not exist anymore", "")
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
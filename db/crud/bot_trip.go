@@ -0,0 +1,126 @@
+package crud
+
+import (
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Trip tags new transactions with its name and sets the default currency while it is active. HomeCurrency holds the
+// currency setting before the trip, which is restored when it ends. TagAdded is set if the trip added its tag, which
+// is then removed when it ends. Until and EndDate are empty if not set.
+type Trip struct {
+	Name         string
+	Currency     string
+	HomeCurrency string
+	TagAdded     bool
+	StartDate    string
+	Until        string
+	EndDate      string
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// StartTrip stores a trip or replaces the (ended) one of the same name
+func (r *Repo) StartTrip(m *tb.Message, trip *Trip) error {
+	LogDbf(r, helpers.TRACE, m, "Starting trip: %v", trip)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`DELETE FROM "bot::trip" WHERE "tgChatId" = $1 AND "name" = $2`, m.Chat.ID, trip.Name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO "bot::trip" ("tgChatId", "name", "currency", "homeCurrency", "homeTag", "tagAdded", "startDate", "until", "endDate")
+		VALUES ($1, $2, $3, $4, '', $5, $6, $7, $8)`,
+		m.Chat.ID, trip.Name, trip.Currency, trip.HomeCurrency, trip.TagAdded, trip.StartDate, nullableString(trip.Until), nullableString(trip.EndDate))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const tripColumns = `"tgChatId", "name", "currency", "homeCurrency", "tagAdded", "startDate", "until", "endDate"`
+
+func (r *Repo) queryTrips(query string, params ...interface{}) (map[int64][]*Trip, error) {
+	rows, err := r.db.Query(`SELECT `+tripColumns+` FROM "bot::trip" WHERE `+query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trips := map[int64][]*Trip{}
+	for rows.Next() {
+		var (
+			chatId         int64
+			until, endDate *string
+		)
+		trip := &Trip{}
+		err = rows.Scan(&chatId, &trip.Name, &trip.Currency, &trip.HomeCurrency, &trip.TagAdded, &trip.StartDate, &until, &endDate)
+		if err != nil {
+			return nil, err
+		}
+		if until != nil {
+			trip.Until = *until
+		}
+		if endDate != nil {
+			trip.EndDate = *endDate
+		}
+		trips[chatId] = append(trips[chatId], trip)
+	}
+	return trips, nil
+}
+
+// GetTrips returns all trips of the chat, ordered by their start
+func (r *Repo) GetTrips(m *tb.Message) ([]*Trip, error) {
+	trips, err := r.queryTrips(`"tgChatId" = $1 ORDER BY "startDate" ASC, "name" ASC`, m.Chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	if trips[m.Chat.ID] == nil {
+		return []*Trip{}, nil
+	}
+	return trips[m.Chat.ID], nil
+}
+
+// GetActiveTrip returns the trip which has not ended yet or nil
+func (r *Repo) GetActiveTrip(m *tb.Message) (*Trip, error) {
+	trips, err := r.queryTrips(`"tgChatId" = $1 AND "endDate" IS NULL`, m.Chat.ID)
+	if err != nil || len(trips[m.Chat.ID]) == 0 {
+		return nil, err
+	}
+	return trips[m.Chat.ID][0], nil
+}
+
+// GetActiveTripsWithEnd returns the active trips per chat which end automatically
+func (r *Repo) GetActiveTripsWithEnd() (map[int64]*Trip, error) {
+	trips, err := r.queryTrips(`"endDate" IS NULL AND "until" IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	active := map[int64]*Trip{}
+	for chatId, chatTrips := range trips {
+		active[chatId] = chatTrips[0]
+	}
+	return active, nil
+}
+
+// EndTrip marks the trip as ended on the date
+func (r *Repo) EndTrip(m *tb.Message, name, endDate string) error {
+	LogDbf(r, helpers.TRACE, m, "Ending trip '%s' on %s", name, endDate)
+	_, err := r.db.Exec(`UPDATE "bot::trip" SET "endDate" = $3 WHERE "tgChatId" = $1 AND "name" = $2`, m.Chat.ID, name, endDate)
+	return err
+}
+
+func (r *Repo) DeleteTrips(m *tb.Message) error {
+	LogDbf(r, helpers.TRACE, m, "Permanently deleting trips")
+	_, err := r.db.Exec(`DELETE FROM "bot::trip" WHERE "tgChatId" = $1`, m.Chat.ID)
+	return err
+}
@@ -0,0 +1,48 @@
+package crud_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/crud"
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+	"gopkg.in/telebot.v3"
+)
+
+func TestTripsDb(t *testing.T) {
+	m := &telebot.Message{Chat: &telebot.Chat{ID: -256}, Sender: &telebot.User{ID: -256}}
+	repo := crud.NewRepo(db.Connection())
+	repo.EnrichUserData(m)
+	defer repo.DeleteTrips(m)
+
+	active, err := repo.GetActiveTrip(m)
+	if err != nil {
+		t.Fatalf("Getting active trip should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, active, (*crud.Trip)(nil), "")
+
+	err = repo.StartTrip(m, &crud.Trip{Name: "rome", Currency: "EUR", HomeCurrency: "CHF", StartDate: "2024-01-01", EndDate: "2024-01-10"})
+	if err != nil {
+		t.Fatalf("Starting trip should not fail: %s", err.Error())
+	}
+	_ = repo.StartTrip(m, &crud.Trip{Name: "nyc", Currency: "USD", HomeCurrency: "CHF", TagAdded: true, StartDate: "2024-03-01", Until: "2024-03-14"})
+	active, _ = repo.GetActiveTrip(m)
+	helpers.TestExpect(t, *active, crud.Trip{Name: "nyc", Currency: "USD", HomeCurrency: "CHF", TagAdded: true, StartDate: "2024-03-01", Until: "2024-03-14"}, "")
+	ending, err := repo.GetActiveTripsWithEnd()
+	if err != nil {
+		t.Fatalf("Getting trips to end should not fail: %s", err.Error())
+	}
+	helpers.TestExpect(t, ending[m.Chat.ID].Name, "nyc", "")
+
+	err = repo.EndTrip(m, "nyc", "2024-03-15")
+	if err != nil {
+		t.Errorf("Ending trip should not fail: %s", err.Error())
+	}
+	active, _ = repo.GetActiveTrip(m)
+	helpers.TestExpect(t, active, (*crud.Trip)(nil), "")
+	ending, _ = repo.GetActiveTripsWithEnd()
+	helpers.TestExpect(t, ending[m.Chat.ID], (*crud.Trip)(nil), "")
+	trips, _ := repo.GetTrips(m)
+	helpers.TestExpect(t, len(trips), 2, "")
+	helpers.TestExpect(t, trips[1].EndDate, "2024-03-15", "")
+}
@@ -6,6 +6,8 @@ import (
 
 type Repo struct {
 	db dbWrapper.DB
+	// source is recorded with transaction events. Defaults to the bot.
+	source string
 }
 
 func NewRepo(db dbWrapper.DB) *Repo {
@@ -51,6 +51,7 @@ func (r *Repo) HealthGetTransactions() (open int, archived int, err error) {
 	rows, err := r.db.Query(`
 		SELECT "archived", COUNT(*) "c"
 		FROM "bot::transaction"
+		WHERE "deleted" IS NULL
 		GROUP BY "archived"`)
 	if err != nil {
 		return
@@ -0,0 +1,17 @@
+package generic
+
+import (
+	"database/sql"
+	"log"
+)
+
+func V15AddCachePinned(db *sql.Tx) {
+	sqlStatement := `
+	ALTER TABLE "bot::cache"
+		ADD COLUMN "pinned" BOOLEAN NOT NULL DEFAULT FALSE;
+	`
+	_, err := db.Exec(sqlStatement)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,39 @@
+package generic
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+func V16BackfillBookingInfo(db *sql.Tx) {
+	rows, err := db.Query(`SELECT "id", "value" FROM "bot::transaction"`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	transactions := map[int]string{}
+	var (
+		id    int
+		value string
+	)
+	for rows.Next() {
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		transactions[id] = value
+	}
+	rows.Close()
+
+	for id, value := range transactions {
+		date, amount, ok := helpers.BookingInfo(value)
+		if !ok {
+			continue
+		}
+		_, err = db.Exec(`UPDATE "bot::transaction" SET "bookingDate" = $1, "amount" = $2 WHERE "id" = $3`, date, amount, id)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
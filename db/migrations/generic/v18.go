@@ -0,0 +1,17 @@
+package generic
+
+import (
+	"database/sql"
+	"log"
+)
+
+func V18AddTransactionDeleted(db *sql.Tx) {
+	sqlStatement := `
+	ALTER TABLE "bot::transaction"
+		ADD COLUMN "deleted" TIMESTAMP;
+	`
+	_, err := db.Exec(sqlStatement)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,17 @@
+package generic
+
+import (
+	"database/sql"
+	"log"
+)
+
+func V24AddSettingAutoPrice(db *sql.Tx) {
+	sqlStatement := `
+	INSERT INTO "bot::userSettingTypes" ("setting", "description") VALUES
+		('user.autoPrice', 'annotate foreign currency amounts with the latest exchange rate');
+	`
+	_, err := db.Exec(sqlStatement)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,30 @@
+package generic
+
+import (
+	"database/sql"
+	"log"
+)
+
+// V26TripTagAdded remembers whether a trip added its tag, so that only such a tag is removed when the trip ends. Active
+// trips which set their tag as tag setting get it added to the active tags and restore the tag setting from before.
+func V26TripTagAdded(db *sql.Tx) {
+	sqlStatement := `
+	ALTER TABLE "bot::trip" ADD COLUMN "tagAdded" BOOLEAN DEFAULT FALSE NOT NULL;
+	UPDATE "bot::trip" SET "tagAdded" = TRUE
+		WHERE "endDate" IS NULL AND EXISTS (
+			SELECT 1 FROM "bot::userSetting" s
+			WHERE s."tgChatId" = "bot::trip"."tgChatId" AND s."setting" = 'user.vacationTag' AND s."value" = "bot::trip"."name"
+		);
+	INSERT INTO "bot::tag" ("tgChatId", "tag", "expires")
+		SELECT "tgChatId", "name", "until" FROM "bot::trip" WHERE "tagAdded";
+	UPDATE "bot::userSetting" SET "value" = (
+			SELECT t."homeTag" FROM "bot::trip" t WHERE t."tgChatId" = "bot::userSetting"."tgChatId" AND t."tagAdded"
+		)
+		WHERE "setting" = 'user.vacationTag' AND "tgChatId" IN (SELECT "tgChatId" FROM "bot::trip" WHERE "tagAdded");
+	DELETE FROM "bot::userSetting" WHERE "setting" = 'user.vacationTag' AND "value" = '';
+	`
+	_, err := db.Exec(sqlStatement)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,48 @@
+package generic
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+func V27BackfillPending(db *sql.Tx) {
+	rows, err := db.Query(`SELECT "id", "value" FROM "bot::transaction"`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pending := []int{}
+	var (
+		id    int
+		value string
+	)
+	for rows.Next() {
+		err = rows.Scan(&id, &value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if helpers.IsPending(value) {
+			pending = append(pending, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range pending {
+		_, err = db.Exec(`UPDATE "bot::transaction" SET "pending" = TRUE WHERE "id" = $1`, id)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func V27AddSettingPendingReminder(db *sql.Tx) {
+	sqlStatement := `
+	INSERT INTO "bot::userSettingTypes" ("setting", "description") VALUES
+		('user.pendingReminderDays', 'remind of transactions pending for longer than this number of days (0 to disable)');
+	`
+	_, err := db.Exec(sqlStatement)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
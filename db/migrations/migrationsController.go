@@ -25,6 +25,19 @@ type MigrationProvider interface {
 	V12(*sql.Tx)
 	V13(*sql.Tx)
 	V14(*sql.Tx)
+	V15(*sql.Tx)
+	V16(*sql.Tx)
+	V17(*sql.Tx)
+	V18(*sql.Tx)
+	V19(*sql.Tx)
+	V20(*sql.Tx)
+	V21(*sql.Tx)
+	V22(*sql.Tx)
+	V23(*sql.Tx)
+	V24(*sql.Tx)
+	V25(*sql.Tx)
+	V26(*sql.Tx)
+	V27(*sql.Tx)
 }
 
 func migrate(db *sql.DB, m MigrationProvider) {
@@ -50,6 +63,19 @@ func migrate(db *sql.DB, m MigrationProvider) {
 	migrationsWrapper.Migrate(m.V12, 12)(db)
 	migrationsWrapper.Migrate(m.V13, 13)(db)
 	migrationsWrapper.Migrate(m.V14, 14)(db)
+	migrationsWrapper.Migrate(m.V15, 15)(db)
+	migrationsWrapper.Migrate(m.V16, 16)(db)
+	migrationsWrapper.Migrate(m.V17, 17)(db)
+	migrationsWrapper.Migrate(m.V18, 18)(db)
+	migrationsWrapper.Migrate(m.V19, 19)(db)
+	migrationsWrapper.Migrate(m.V20, 20)(db)
+	migrationsWrapper.Migrate(m.V21, 21)(db)
+	migrationsWrapper.Migrate(m.V22, 22)(db)
+	migrationsWrapper.Migrate(m.V23, 23)(db)
+	migrationsWrapper.Migrate(m.V24, 24)(db)
+	migrationsWrapper.Migrate(m.V25, 25)(db)
+	migrationsWrapper.Migrate(m.V26, 26)(db)
+	migrationsWrapper.Migrate(m.V27, 27)(db)
 
 	log.Printf("Migrations ran through. Schema version: %d", m.Schema(db))
 }
@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/migrations/generic"
+)
+
+func (c *Controller) V16(db *sql.Tx) {
+	v16TransactionBookingInfo(db)
+	generic.V16BackfillBookingInfo(db)
+}
+
+func v16TransactionBookingInfo(db *sql.Tx) {
+	_, err := db.Exec(`
+	ALTER TABLE "bot::transaction"
+		ADD COLUMN "bookingDate" DATE,
+		ADD COLUMN "amount" NUMERIC;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
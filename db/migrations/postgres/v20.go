@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V20(db *sql.Tx) {
+	v20Budgets(db)
+}
+
+func v20Budgets(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::budget" (
+		"id" SERIAL PRIMARY KEY,
+		"tgChatId" NUMERIC REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"account" TEXT NOT NULL,
+		"amount" NUMERIC NOT NULL,
+		"currency" TEXT NOT NULL,
+		"period" TEXT NOT NULL,
+		"alertedLevel" INTEGER NOT NULL DEFAULT 0,
+		"alertedPeriod" TEXT,
+		UNIQUE ("tgChatId", "account", "currency")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
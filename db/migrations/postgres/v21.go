@@ -0,0 +1,25 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V21(db *sql.Tx) {
+	v21OpeningBalances(db)
+}
+
+func v21OpeningBalances(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::openingBalance" (
+		"tgChatId" NUMERIC REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"account" TEXT NOT NULL,
+		"amount" NUMERIC NOT NULL,
+		"currency" TEXT NOT NULL,
+		PRIMARY KEY ("tgChatId", "account", "currency")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
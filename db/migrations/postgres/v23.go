@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V23(db *sql.Tx) {
+	v23ImportedIds(db)
+}
+
+func v23ImportedIds(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::importedId" (
+		"tgChatId" NUMERIC REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"account" TEXT NOT NULL,
+		"importId" TEXT NOT NULL,
+		PRIMARY KEY ("tgChatId", "account", "importId")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
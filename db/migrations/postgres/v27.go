@@ -0,0 +1,24 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/migrations/generic"
+)
+
+func (c *Controller) V27(db *sql.Tx) {
+	v27TransactionPending(db)
+	generic.V27BackfillPending(db)
+	generic.V27AddSettingPendingReminder(db)
+}
+
+func v27TransactionPending(db *sql.Tx) {
+	_, err := db.Exec(`
+	ALTER TABLE "bot::transaction"
+		ADD COLUMN "pending" BOOLEAN DEFAULT FALSE NOT NULL;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,11 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/migrations/generic"
+)
+
+func (c *Controller) V15(db *sql.Tx) {
+	generic.V15AddCachePinned(db)
+}
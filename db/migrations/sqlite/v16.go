@@ -0,0 +1,25 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/migrations/generic"
+)
+
+func (c *Controller) V16(db *sql.Tx) {
+	v16TransactionBookingInfo(db)
+	generic.V16BackfillBookingInfo(db)
+}
+
+func v16TransactionBookingInfo(db *sql.Tx) {
+	for _, statement := range []string{
+		`ALTER TABLE "bot::transaction" ADD COLUMN "bookingDate" DATE;`,
+		`ALTER TABLE "bot::transaction" ADD COLUMN "amount" REAL;`,
+	} {
+		_, err := db.Exec(statement)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
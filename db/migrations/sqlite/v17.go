@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V17(db *sql.Tx) {
+	v17ArchiveBatches(db)
+}
+
+func v17ArchiveBatches(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::archiveBatch" (
+		"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"created" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		"label" TEXT NOT NULL
+	);
+
+	ALTER TABLE "bot::transaction"
+		ADD COLUMN "batchId" INTEGER REFERENCES "bot::archiveBatch" ("id") ON DELETE SET NULL;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
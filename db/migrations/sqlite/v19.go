@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V19(db *sql.Tx) {
+	v19TransactionEvents(db)
+}
+
+func v19TransactionEvents(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::transactionEvent" (
+		"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"transactionId" INTEGER NOT NULL,
+		"event" TEXT NOT NULL,
+		"before" TEXT,
+		"after" TEXT,
+		"source" TEXT NOT NULL,
+		"tgUserId" INTEGER,
+		"created" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
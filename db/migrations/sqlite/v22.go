@@ -0,0 +1,39 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V22(db *sql.Tx) {
+	v22ImportProfiles(db)
+	v22ImportRules(db)
+}
+
+func v22ImportProfiles(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::importProfile" (
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"name" TEXT NOT NULL,
+		"config" TEXT NOT NULL,
+		PRIMARY KEY ("tgChatId", "name")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func v22ImportRules(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::importRule" (
+		"id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"pattern" TEXT NOT NULL,
+		"account" TEXT NOT NULL
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/migrations/generic"
+)
+
+func (c *Controller) V24(db *sql.Tx) {
+	v24Rates(db)
+	generic.V24AddSettingAutoPrice(db)
+}
+
+func v24Rates(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::rate" (
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"date" TEXT NOT NULL,
+		"base" TEXT NOT NULL,
+		"quote" TEXT NOT NULL,
+		"rate" REAL NOT NULL,
+		PRIMARY KEY ("tgChatId", "date", "base", "quote")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
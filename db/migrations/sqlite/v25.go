@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+)
+
+func (c *Controller) V25(db *sql.Tx) {
+	v25Trips(db)
+}
+
+func v25Trips(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::trip" (
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"name" TEXT NOT NULL,
+		"currency" TEXT NOT NULL,
+		"homeCurrency" TEXT NOT NULL,
+		"homeTag" TEXT NOT NULL,
+		"startDate" TEXT NOT NULL,
+		"until" TEXT,
+		"endDate" TEXT,
+		PRIMARY KEY ("tgChatId", "name")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,27 @@
+package sqlite
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/db/migrations/generic"
+)
+
+func (c *Controller) V26(db *sql.Tx) {
+	v26Tags(db)
+	generic.V26TripTagAdded(db)
+}
+
+func v26Tags(db *sql.Tx) {
+	_, err := db.Exec(`
+	CREATE TABLE "bot::tag" (
+		"tgChatId" INTEGER REFERENCES "auth::user" ("tgChatId") ON DELETE CASCADE NOT NULL,
+		"tag" TEXT NOT NULL,
+		"expires" TEXT,
+		PRIMARY KEY ("tgChatId", "tag")
+	);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
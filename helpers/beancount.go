@@ -0,0 +1,560 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BeancountEntry is a transaction directive as recorded by the bot, e.g.:
+//
+//	2022-01-01 * "Groceries" #trip ^invoice-1
+//	  Assets:Wallet   -12.34 EUR
+//	  Expenses:Food
+type BeancountEntry struct {
+	Date      string
+	Flag      string
+	Payee     string
+	Narration string
+	Tags      []string
+	Links     []string
+	Postings  []*BeancountPosting
+}
+
+type BeancountPosting struct {
+	Account  string
+	Amount   float64
+	Currency string
+	// Annotation holds cost and price annotations like '{10 USD}' or '@ 1.1 USD' unparsed
+	Annotation string
+	// Inferred is set if the amount has been elided and was calculated from the other postings
+	Inferred bool
+}
+
+const (
+	FLAG_COMPLETE = "*"
+	// FLAG_PENDING marks transactions which are not settled yet, e.g. card payments
+	FLAG_PENDING = "!"
+)
+
+var (
+	beancountEntryHeader = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\s+(\*|!|txn)(\s.*)?$`)
+	beancountAccount     = regexp.MustCompile(`^([A-Z][A-Za-z0-9-]*(?::[A-Z0-9][A-Za-z0-9-]*)+)(\s.*)?$`)
+	beancountAmount      = regexp.MustCompile(`^(-?[0-9][0-9,]*(?:\.[0-9]*)?|-?\.[0-9]+)\s+([A-Z][A-Z0-9'._-]*[A-Z0-9]|[A-Z])(\s.*)?$`)
+	beancountCost        = regexp.MustCompile(`^\{\s*(-?[0-9][0-9,]*(?:\.[0-9]*)?)\s+([A-Z][A-Z0-9'._-]*)\s*[,}]`)
+	beancountPrice       = regexp.MustCompile(`(@@|@)\s*([0-9][0-9,]*(?:\.[0-9]*)?)\s+([A-Z][A-Z0-9'._-]*)`)
+	beancountPostingSign = regexp.MustCompile(`^(\s+(?:[*!]\s+)?[A-Z][^\s;]*)(\s+)(-?)[0-9.]`)
+	beancountDirective   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}\s+([a-z]+)(\s.*)?$`)
+
+	beancountDirectiveTypes = map[string]bool{
+		"open": true, "close": true, "commodity": true, "balance": true, "pad": true, "note": true,
+		"document": true, "price": true, "event": true, "query": true, "custom": true,
+	}
+	beancountUndatedDirectiveTypes = map[string]bool{
+		"option": true, "plugin": true, "include": true, "pushtag": true, "poptag": true,
+	}
+)
+
+// ParseBeancount extracts all transaction entries from beancount text.
+// Other directives, comments and metadata are skipped.
+func ParseBeancount(text string) ([]*BeancountEntry, error) {
+	entries := []*BeancountEntry{}
+	var current *BeancountEntry
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(stripBeancountComment(line))
+		if trimmed == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			current = nil
+			if !beancountEntryHeader.MatchString(trimmed) {
+				continue
+			}
+			entry, err := parseBeancountHeader(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", i+1, err.Error())
+			}
+			entries = append(entries, entry)
+			current = entry
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "^") {
+			// Tags and links continued on the following lines
+			for _, field := range strings.Fields(trimmed) {
+				current.addTagOrLink(field)
+			}
+			continue
+		}
+		posting, err := parseBeancountPosting(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", i+1, err.Error())
+		}
+		if posting != nil {
+			current.Postings = append(current.Postings, posting)
+		}
+	}
+	for _, entry := range entries {
+		if err := entry.inferElidedAmount(); err != nil {
+			return nil, fmt.Errorf("entry of %s: %s", entry.Date, err.Error())
+		}
+	}
+	return entries, nil
+}
+
+// BeancountBlock is a top-level directive including its indented lines and the comments following it
+type BeancountBlock struct {
+	// Line is the number of the first line of the block within the text
+	Line int
+	Text string
+}
+
+// IsComment tells whether the block only consists of comments and empty lines
+func (b *BeancountBlock) IsComment() bool {
+	for _, line := range strings.Split(b.Text, "\n") {
+		if strings.TrimSpace(stripBeancountComment(line)) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitBeancount splits beancount text into its top-level blocks. Each non-indented line starts a new block.
+// Lines before the first directive, e.g. comments, form a block of their own.
+func SplitBeancount(text string) []*BeancountBlock {
+	blocks := []*BeancountBlock{}
+	lines := []string{}
+	start := 1
+	flush := func() {
+		if strings.TrimSpace(strings.Join(lines, "")) != "" {
+			blocks = append(blocks, &BeancountBlock{Line: start, Text: strings.Join(lines, "\n")})
+		}
+	}
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line != "" && line[0] != ' ' && line[0] != '\t' && strings.TrimSpace(stripBeancountComment(line)) != "" {
+			flush()
+			lines = []string{}
+			start = i + 1
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return blocks
+}
+
+// ParseBeancountLenient is like ParseBeancount, but skips the transactions which can not be read (e.g. using arithmetic
+// expressions) instead of failing. It returns the number of skipped transactions.
+func ParseBeancountLenient(text string) (entries []*BeancountEntry, skipped int) {
+	entries = []*BeancountEntry{}
+	for _, block := range SplitBeancount(text) {
+		parsed, err := ParseBeancount(block.Text)
+		if err != nil {
+			skipped++
+			continue
+		}
+		entries = append(entries, parsed...)
+	}
+	return entries, skipped
+}
+
+// ValidateBeancountDirective checks a single block as returned by SplitBeancount. Transactions must be readable, have
+// at least two postings and balance, other dated directives are only checked for a known type. Blocks only consisting
+// of comments are valid, undated directives like 'option' or 'include' are not.
+func ValidateBeancountDirective(text string) error {
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(stripBeancountComment(line))
+		if trimmed == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			return fmt.Errorf("indented line '%s' does not belong to any entry", trimmed)
+		}
+		if beancountEntryHeader.MatchString(trimmed) {
+			entry, err := ParseBeancountEntry(text)
+			if err != nil {
+				return err
+			}
+			if len(entry.Postings) == 0 {
+				return fmt.Errorf("the transaction has no postings")
+			}
+			if len(entry.Postings) == 1 {
+				return fmt.Errorf("the transaction has only one posting")
+			}
+			return entry.checkBalance()
+		}
+		if match := beancountDirective.FindStringSubmatch(trimmed); match != nil {
+			if !beancountDirectiveTypes[match[1]] {
+				return fmt.Errorf("unknown directive '%s'", match[1])
+			}
+			return nil
+		}
+		if fields := strings.Fields(trimmed); beancountUndatedDirectiveTypes[fields[0]] {
+			return fmt.Errorf("'%s' directives are not supported, only dated entries", fields[0])
+		}
+		return fmt.Errorf("'%s' is no beancount directive", trimmed)
+	}
+	return nil
+}
+
+// AnnotatePostings appends the annotation returned for the currency (e.g. a price like '@ 0.92 EUR') to the
+// transaction postings which have an amount but no annotation yet. Empty annotations leave the posting unchanged.
+func AnnotatePostings(text string, annotation func(currency string) string) string {
+	lines := strings.Split(text, "\n")
+	inTransaction := false
+	for i, line := range lines {
+		code := stripBeancountComment(line)
+		trimmed := strings.TrimSpace(code)
+		if trimmed == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			inTransaction = beancountEntryHeader.MatchString(trimmed)
+			continue
+		}
+		if !inTransaction {
+			continue
+		}
+		posting, err := parseBeancountPosting(trimmed)
+		if err != nil || posting == nil || posting.Currency == "" || posting.Annotation != "" {
+			continue
+		}
+		a := annotation(posting.Currency)
+		if a == "" {
+			continue
+		}
+		comment := line[len(code):]
+		lines[i] = strings.TrimRight(code, " \t") + " " + a
+		if comment != "" {
+			lines[i] += " " + comment
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseBeancountEntry parses a single transaction entry
+func ParseBeancountEntry(text string) (*BeancountEntry, error) {
+	entries, err := ParseBeancount(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("expected a single transaction, found %d", len(entries))
+	}
+	return entries[0], nil
+}
+
+// MaxAbsAmount returns the largest absolute amount of all postings.
+func (e *BeancountEntry) MaxAbsAmount() float64 {
+	max := 0.0
+	for _, p := range e.Postings {
+		max = math.Max(max, math.Abs(p.Amount))
+	}
+	return max
+}
+
+// HasAmount tells whether a posting of the entry has the absolute amount in the currency
+func (e *BeancountEntry) HasAmount(amount float64, currency string) bool {
+	for _, p := range e.Postings {
+		if p.Currency == currency && math.Abs(math.Abs(p.Amount)-amount) < 0.005 {
+			return true
+		}
+	}
+	return false
+}
+
+// Description joins payee and narration of the entry
+func (e *BeancountEntry) Description() string {
+	return strings.TrimSpace(e.Payee + " " + e.Narration)
+}
+
+// Fingerprint identifies the entry by its date and postings (accounts and amounts including elided ones).
+// Descriptions, tags and the order of the postings are ignored.
+func (e *BeancountEntry) Fingerprint() string {
+	postings := []string{}
+	for _, p := range e.Postings {
+		postings = append(postings, fmt.Sprintf("%s %s %s", p.Account, strconv.FormatFloat(roundAmount(p.Amount), 'f', -1, 64), p.Currency))
+	}
+	sort.Strings(postings)
+	return e.Date + "|" + strings.Join(postings, "|")
+}
+
+func (e *BeancountEntry) HasTag(tag string) bool {
+	return ArrayContains(e.Tags, strings.TrimPrefix(tag, "#"))
+}
+
+func (e *BeancountEntry) addTagOrLink(field string) bool {
+	if strings.HasPrefix(field, "#") && len(field) > 1 {
+		e.Tags = append(e.Tags, field[1:])
+		return true
+	}
+	if strings.HasPrefix(field, "^") && len(field) > 1 {
+		e.Links = append(e.Links, field[1:])
+		return true
+	}
+	return false
+}
+
+// Weight returns the amount the posting contributes to the balance of its transaction, i.e. the cost or price in case
+// of an annotation like '{95.30 EUR}', '@ 0.92 EUR' or '@@ 46 EUR', and its amount otherwise.
+func (p *BeancountPosting) Weight() (amount float64, currency string) {
+	if match := beancountCost.FindStringSubmatch(p.Annotation); match != nil {
+		if cost, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", ""), 64); err == nil {
+			return roundAmount(p.Amount * cost), match[2]
+		}
+	}
+	if match := beancountPrice.FindStringSubmatch(p.Annotation); match != nil {
+		if price, err := strconv.ParseFloat(strings.ReplaceAll(match[2], ",", ""), 64); err == nil {
+			if match[1] == "@@" {
+				return math.Copysign(price, p.Amount), match[3]
+			}
+			return roundAmount(p.Amount * price), match[3]
+		}
+	}
+	return p.Amount, p.Currency
+}
+
+func (e *BeancountEntry) inferElidedAmount() error {
+	var elided *BeancountPosting
+	sums := map[string]float64{}
+	for _, p := range e.Postings {
+		if p.Currency == "" {
+			if elided != nil {
+				return fmt.Errorf("more than one posting without amount")
+			}
+			elided = p
+			continue
+		}
+		amount, currency := p.Weight()
+		sums[currency] += amount
+	}
+	if elided == nil {
+		return nil
+	}
+	if len(sums) != 1 {
+		// Ambiguous (multiple currencies) or no other amount to infer from. Leave it empty.
+		return nil
+	}
+	for currency, sum := range sums {
+		elided.Amount = roundAmount(-sum)
+		elided.Currency = currency
+		elided.Inferred = true
+	}
+	return nil
+}
+
+// checkBalance makes sure that the weights of the postings sum up to zero per currency. A posting without amount
+// balances all remaining amounts.
+func (e *BeancountEntry) checkBalance() error {
+	sums := map[string]float64{}
+	for _, p := range e.Postings {
+		if p.Currency == "" {
+			return nil
+		}
+		amount, currency := p.Weight()
+		sums[currency] += amount
+	}
+	currencies := []string{}
+	for currency, sum := range sums {
+		if math.Abs(sum) >= 0.005 {
+			currencies = append(currencies, fmt.Sprintf("%s %s", strconv.FormatFloat(roundAmount(sum), 'f', -1, 64), currency))
+		}
+	}
+	if len(currencies) > 0 {
+		sort.Strings(currencies)
+		return fmt.Errorf("the transaction does not balance (off by %s)", strings.Join(currencies, ", "))
+	}
+	return nil
+}
+
+func parseBeancountHeader(line string) (*BeancountEntry, error) {
+	match := beancountEntryHeader.FindStringSubmatch(line)
+	entry := &BeancountEntry{Date: match[1], Flag: match[2]}
+	if entry.Flag == "txn" {
+		entry.Flag = "*"
+	}
+	rest := strings.TrimSpace(match[3])
+	strs := []string{}
+	for strings.HasPrefix(rest, `"`) {
+		end := strings.Index(rest[1:], `"`)
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string in '%s'", line)
+		}
+		strs = append(strs, rest[1:end+1])
+		rest = strings.TrimSpace(rest[end+2:])
+	}
+	switch len(strs) {
+	case 0:
+	case 1:
+		entry.Narration = strs[0]
+	case 2:
+		entry.Payee = strs[0]
+		entry.Narration = strs[1]
+	default:
+		return nil, fmt.Errorf("too many strings in '%s'", line)
+	}
+	for _, field := range strings.Fields(rest) {
+		if !entry.addTagOrLink(field) {
+			return nil, fmt.Errorf("unexpected '%s' in '%s'", field, line)
+		}
+	}
+	return entry, nil
+}
+
+func parseBeancountPosting(line string) (*BeancountPosting, error) {
+	if len(line) > 1 && (line[0] == '*' || line[0] == '!') && line[1] == ' ' {
+		line = strings.TrimSpace(line[1:])
+	}
+	match := beancountAccount.FindStringSubmatch(line)
+	if match == nil {
+		// Metadata or unsupported syntax
+		return nil, nil
+	}
+	posting := &BeancountPosting{Account: match[1]}
+	rest := strings.TrimSpace(match[2])
+	if rest == "" {
+		return posting, nil
+	}
+	amountMatch := beancountAmount.FindStringSubmatch(rest)
+	if amountMatch == nil {
+		return nil, fmt.Errorf("could not read amount of posting '%s'", line)
+	}
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(amountMatch[1], ",", ""), 64)
+	if err != nil {
+		return nil, fmt.Errorf("could not read amount of posting '%s': %s", line, err.Error())
+	}
+	posting.Amount = amount
+	posting.Currency = amountMatch[2]
+	posting.Annotation = strings.TrimSpace(amountMatch[3])
+	return posting, nil
+}
+
+func stripBeancountComment(line string) string {
+	inString := false
+	for i, c := range line {
+		switch c {
+		case '"':
+			inString = !inString
+		case ';':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func roundAmount(f float64) float64 {
+	return math.Round(f*1e8) / 1e8
+}
+
+// BookingInfo extracts the date and the largest amount of a recorded transaction, used for filtering.
+// ok is false if tx is no (single) valid transaction, e.g. a comment.
+func BookingInfo(tx string) (date string, amount float64, ok bool) {
+	entry, err := ParseBeancountEntry(tx)
+	if err != nil {
+		return "", 0, false
+	}
+	return entry.Date, entry.MaxAbsAmount(), true
+}
+
+// IsPending is true if tx is a single transaction flagged as pending
+func IsPending(tx string) bool {
+	entry, err := ParseBeancountEntry(tx)
+	return err == nil && entry.Flag == FLAG_PENDING
+}
+
+// SetFlag replaces the flag of the first transaction header in tx, e.g. '*' by '!'.
+// ok is false if tx contains no transaction.
+func SetFlag(tx, flag string) (string, bool) {
+	lines := strings.Split(tx, "\n")
+	for i, line := range lines {
+		match := beancountEntryHeader.FindStringSubmatchIndex(line)
+		if match == nil {
+			continue
+		}
+		lines[i] = line[:match[4]] + flag + line[match[5]:]
+		return strings.Join(lines, "\n"), true
+	}
+	return tx, false
+}
+
+// AddTagsAndLinks appends tags and links like '#refund' or '^invoice-1' to the header of the first transaction in tx,
+// leaving out the ones it already has. ok is false if tx contains no transaction.
+func AddTagsAndLinks(tx string, fields ...string) (string, bool) {
+	lines := strings.Split(tx, "\n")
+	for i, line := range lines {
+		code := strings.TrimRight(stripBeancountComment(line), " \t")
+		if !beancountEntryHeader.MatchString(code) {
+			continue
+		}
+		comment := strings.TrimLeft(line[len(code):], " \t")
+		existing := strings.Fields(code)
+		for _, field := range fields {
+			if !ArrayContains(existing, field) {
+				existing = append(existing, field)
+				code += " " + field
+			}
+		}
+		lines[i] = code
+		if comment != "" {
+			lines[i] += " " + comment
+		}
+		return strings.Join(lines, "\n"), true
+	}
+	return tx, false
+}
+
+// ReverseTransaction returns a copy of the single transaction tx booked on date, with the amounts of all its postings
+// negated, e.g. for refunds. Elided amounts stay elided, annotations like prices and costs are kept as they are.
+func ReverseTransaction(tx, date string) (string, error) {
+	entry, err := ParseBeancountEntry(tx)
+	if err != nil {
+		return "", err
+	}
+	if len(entry.Postings) == 0 {
+		return "", fmt.Errorf("the transaction has no postings")
+	}
+	lines := strings.Split(tx, "\n")
+	inTransaction := false
+	for i, line := range lines {
+		code := stripBeancountComment(line)
+		trimmed := strings.TrimSpace(code)
+		if trimmed == "" {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			match := beancountEntryHeader.FindStringSubmatchIndex(line)
+			inTransaction = match != nil
+			if inTransaction {
+				lines[i] = line[:match[2]] + date + line[match[3]:]
+			}
+			continue
+		}
+		if !inTransaction {
+			continue
+		}
+		posting, err := parseBeancountPosting(trimmed)
+		if err != nil || posting == nil || posting.Currency == "" {
+			continue
+		}
+		match := beancountPostingSign.FindStringSubmatchIndex(code)
+		if match == nil {
+			return "", fmt.Errorf("could not negate the amount of posting '%s'", trimmed)
+		}
+		space := line[match[4]:match[5]]
+		if match[6] != match[7] {
+			// Keep the amounts aligned by replacing the sign with a space
+			lines[i] = line[:match[5]] + " " + line[match[7]:]
+		} else if len(space) > 1 {
+			lines[i] = line[:match[5]-1] + "-" + line[match[5]:]
+		} else {
+			lines[i] = line[:match[5]] + "-" + line[match[5]:]
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,264 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+func TestParseBeancount(t *testing.T) {
+	entries, err := helpers.ParseBeancount(`; recorded on 2022-01-02 10:00
+2022-01-01 * "Shop" "Groceries; with semicolon" #trip ^invoice-1 ; comment
+  #vacation
+  Assets:Wallet                               -1,012.50 EUR
+  Expenses:Food
+  created: "metadata is skipped"
+
+option "title" "ignored"
+2022-01-03 price USD 0.9 EUR
+
+2022-01-04 ! "Exchange"
+  Assets:Wallet  -10.00 EUR @ 1.1 USD
+  Assets:Dollars  11 USD
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, len(entries), 2, "entry count")
+
+	e := entries[0]
+	helpers.TestExpect(t, e.Date, "2022-01-01", "")
+	helpers.TestExpect(t, e.Flag, "*", "")
+	helpers.TestExpect(t, e.Payee, "Shop", "")
+	helpers.TestExpect(t, e.Narration, "Groceries; with semicolon", "")
+	helpers.TestExpectArrEq(t, e.Tags, []string{"trip", "vacation"}, "")
+	helpers.TestExpectArrEq(t, e.Links, []string{"invoice-1"}, "")
+	helpers.TestExpect(t, len(e.Postings), 2, "posting count")
+	helpers.TestExpect(t, e.Postings[0].Amount, -1012.5, "")
+	helpers.TestExpect(t, e.Postings[1].Account, "Expenses:Food", "")
+	helpers.TestExpect(t, e.Postings[1].Amount, 1012.5, "elided amount")
+	helpers.TestExpect(t, e.Postings[1].Currency, "EUR", "")
+	helpers.TestExpect(t, e.Postings[1].Inferred, true, "")
+	helpers.TestExpect(t, e.MaxAbsAmount(), 1012.5, "")
+	helpers.TestExpect(t, e.HasAmount(1012.5, "EUR"), true, "")
+	helpers.TestExpect(t, e.HasAmount(1012.5, "USD"), false, "the currency should match")
+	helpers.TestExpect(t, e.HasTag("#trip"), true, "")
+
+	e = entries[1]
+	helpers.TestExpect(t, e.Flag, "!", "")
+	helpers.TestExpect(t, e.Postings[0].Annotation, "@ 1.1 USD", "")
+	helpers.TestExpect(t, e.MaxAbsAmount(), 11.0, "")
+}
+
+func TestParseBeancountErrors(t *testing.T) {
+	_, err := helpers.ParseBeancount("2022-01-01 * \"unterminated\n  Assets:A 1 EUR")
+	helpers.TestExpect(t, err != nil, true, "unterminated string")
+
+	_, err = helpers.ParseBeancount("2022-01-01 * \"x\"\n  Assets:A  1 EUR\n  Assets:B\n  Assets:C")
+	helpers.TestExpect(t, err != nil, true, "multiple elided amounts")
+
+	_, err = helpers.ParseBeancountEntry("; only a comment")
+	helpers.TestExpect(t, err != nil, true, "no transaction")
+
+	e, err := helpers.ParseBeancountEntry("2022-01-01 txn \"x\"\n  Assets:A  1 EUR\n  Assets:B  1 USD\n  Assets:C")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, e.Postings[2].Currency, "", "ambiguous elided amount stays empty")
+}
+
+func TestParseBeancountLenient(t *testing.T) {
+	entries, skipped := helpers.ParseBeancountLenient(`option "title" "Ledger"
+2024-01-01 open Assets:Cash
+
+2024-01-02 * "Shop"
+  Assets:Cash  -12.50 EUR
+  Expenses:Food
+2024-01-03 * "Split"
+  Assets:Cash  (10 / 3) EUR
+  Expenses:Food
+; comment
+2024-01-04 txn "Lunch" ; with comment
+  Expenses:Food  7 EUR
+  Assets:Cash
+`)
+	helpers.TestExpect(t, len(entries), 2, "")
+	helpers.TestExpect(t, skipped, 1, "the entry using arithmetic should be skipped")
+	helpers.TestExpect(t, entries[1].Narration, "Lunch", "")
+}
+
+func TestBeancountFingerprint(t *testing.T) {
+	a, err := helpers.ParseBeancountEntry("2024-01-02 * \"Shop\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Food\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	b, _ := helpers.ParseBeancountEntry("2024-01-02 * \"Other\" \"description\" #tag\n  Expenses:Food  12.5 EUR\n  Assets:Cash  -12.5 EUR\n")
+	c, _ := helpers.ParseBeancountEntry("2024-01-02 * \"Shop\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Drinks\n")
+	helpers.TestExpect(t, a.Fingerprint(), "2024-01-02|Assets:Cash -12.5 EUR|Expenses:Food 12.5 EUR", "")
+	helpers.TestExpect(t, a.Fingerprint(), b.Fingerprint(), "descriptions and the order of postings should be ignored")
+	helpers.TestExpect(t, a.Fingerprint() == c.Fingerprint(), false, "accounts should be compared")
+}
+
+func TestSplitAndValidateBeancount(t *testing.T) {
+	blocks := helpers.SplitBeancount(`; Notes from the weekend
+
+2024-01-02 * "Shop"
+  Assets:Cash  -12.50 EUR
+  Expenses:Food
+; forgot the receipt
+2024-01-02 open Assets:Bank
+2024-01-03 * "Broken"
+  Assets:Cash  12,50.x EUR
+  Expenses:Food
+include "other.beancount"
+2024-01-04 * "Empty"
+2024-01-05 bought Assets:Cash
+Lunch with Anna
+`)
+	helpers.TestExpect(t, len(blocks), 8, "")
+	helpers.TestExpect(t, blocks[0].IsComment(), true, "")
+	helpers.TestExpect(t, blocks[1].IsComment(), false, "")
+	helpers.TestExpect(t, blocks[1].Line, 3, "")
+	helpers.TestExpect(t, blocks[1].Text, "2024-01-02 * \"Shop\"\n  Assets:Cash  -12.50 EUR\n  Expenses:Food\n; forgot the receipt", "")
+
+	errors := []string{}
+	for _, block := range blocks {
+		err := helpers.ValidateBeancountDirective(block.Text)
+		if err == nil {
+			errors = append(errors, "")
+		} else {
+			errors = append(errors, err.Error())
+		}
+	}
+	helpers.TestExpect(t, errors[0], "", "comments should be valid")
+	helpers.TestExpect(t, errors[1], "", "")
+	helpers.TestExpect(t, errors[2], "", "")
+	helpers.TestStringContains(t, errors[3], "could not read amount of posting", "")
+	helpers.TestExpect(t, errors[4], "'include' directives are not supported, only dated entries", "")
+	helpers.TestExpect(t, errors[5], "the transaction has no postings", "")
+	helpers.TestExpect(t, errors[6], "unknown directive 'bought'", "")
+	helpers.TestExpect(t, errors[7], "'Lunch with Anna' is no beancount directive", "")
+	err := helpers.ValidateBeancountDirective("  Assets:Cash  1 EUR")
+	helpers.TestExpect(t, err != nil && err.Error() == "indented line 'Assets:Cash  1 EUR' does not belong to any entry", true, "")
+}
+
+func TestValidateBeancountTransactionBalance(t *testing.T) {
+	for tx, expected := range map[string]string{
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Expenses:Food  10.00 EUR":                             "",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Expenses:Food":                                        "",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Assets:Card  -2.00 USD\n  Expenses:Food":              "",
+		"2024-01-02 * \"Dinner\"\n  Expenses:Food  50.00 USD @ 0.92 EUR\n  Assets:Cash  -46.00 EUR":                "",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Expenses:Food  3.33 EUR\n  Expenses:Drinks  6.67 EUR": "",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Expenses:Food  5.00 EUR":                              "the transaction does not balance (off by -5 EUR)",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Expenses:Food  10.00 USD":                             "the transaction does not balance (off by -10 EUR, 10 USD)",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR":                                                         "the transaction has only one posting",
+		"2024-01-02 * \"Shop\"\n  Assets:Cash  -10.00 EUR\n  Expenses:Food\n  Expenses:Drinks":                     "more than one posting without amount",
+	} {
+		err := helpers.ValidateBeancountDirective(tx)
+		if expected == "" {
+			helpers.TestExpect(t, err, nil, tx)
+		} else if err == nil {
+			t.Errorf("Expected error '%s' for '%s'", expected, tx)
+		} else {
+			helpers.TestStringContains(t, err.Error(), expected, tx)
+		}
+	}
+}
+
+func TestParseBeancountInferFromPriceAndCost(t *testing.T) {
+	entries, err := helpers.ParseBeancount(`2024-01-02 * "Dinner in New York"
+  Expenses:Food  50.00 USD @ 0.92 EUR
+  Assets:Cash
+2024-01-03 * "Taxi"
+  Assets:Cash  -30.00 USD @@ 27.60 EUR
+  Expenses:Transport
+2024-01-04 * "Buy ETF"
+  Assets:Depot  10 VWRL {95.30 EUR}
+  Assets:Bank
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, entries[0].Postings[1].Amount, -46.0, "")
+	helpers.TestExpect(t, entries[0].Postings[1].Currency, "EUR", "")
+	helpers.TestExpect(t, entries[1].Postings[1].Amount, 27.6, "total price")
+	helpers.TestExpect(t, entries[1].Postings[1].Currency, "EUR", "")
+	helpers.TestExpect(t, entries[2].Postings[1].Amount, -953.0, "cost")
+	helpers.TestExpect(t, entries[2].Postings[1].Currency, "EUR", "")
+	amount, currency := entries[2].Postings[0].Weight()
+	helpers.TestExpect(t, amount, 953.0, "")
+	helpers.TestExpect(t, currency, "EUR", "")
+}
+
+func TestAnnotatePostings(t *testing.T) {
+	price := func(currency string) string {
+		if currency == "USD" {
+			return "@ 0.92 EUR"
+		}
+		return ""
+	}
+	annotated := helpers.AnnotatePostings(`2024-01-02 open Assets:Dollars USD
+  note: "10 USD"
+2024-01-02 * "Dinner"
+  Assets:Dollars  -50.00 USD ; tip included
+  Assets:Cash     -10.00 EUR
+  Assets:Dollars  -5.00 USD @@ 4.50 EUR
+  Expenses:Food
+`, price)
+	helpers.TestExpect(t, annotated, `2024-01-02 open Assets:Dollars USD
+  note: "10 USD"
+2024-01-02 * "Dinner"
+  Assets:Dollars  -50.00 USD @ 0.92 EUR ; tip included
+  Assets:Cash     -10.00 EUR
+  Assets:Dollars  -5.00 USD @@ 4.50 EUR
+  Expenses:Food
+`, "")
+}
+
+func TestPendingFlag(t *testing.T) {
+	tx := `2024-01-02 * "Card payment" #trip
+  Liabilities:Card  -12.00 EUR
+  Expenses:Food
+`
+	helpers.TestExpect(t, helpers.IsPending(tx), false, "")
+	pending, ok := helpers.SetFlag(tx, helpers.FLAG_PENDING)
+	helpers.TestExpect(t, ok, true, "")
+	helpers.TestExpect(t, pending, `2024-01-02 ! "Card payment" #trip
+  Liabilities:Card  -12.00 EUR
+  Expenses:Food
+`, "")
+	helpers.TestExpect(t, helpers.IsPending(pending), true, "")
+	confirmed, _ := helpers.SetFlag(pending, helpers.FLAG_COMPLETE)
+	helpers.TestExpect(t, confirmed, tx, "")
+
+	_, ok = helpers.SetFlag("; only a comment\n", helpers.FLAG_PENDING)
+	helpers.TestExpect(t, ok, false, "comments have no flag")
+	helpers.TestExpect(t, helpers.IsPending("; only a comment\n"), false, "")
+}
+
+func TestReverseTransaction(t *testing.T) {
+	tx := `2024-01-02 * "Shop" "Shoes" #clothes ; bought online
+  Liabilities:Card  -90.00 EUR
+  Expenses:Clothes   60.00 EUR
+  Expenses:Shipping 5 USD @ 4 EUR
+  Expenses:Fees
+`
+	reversed, err := helpers.ReverseTransaction(tx, "2024-01-20")
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, reversed, `2024-01-20 * "Shop" "Shoes" #clothes ; bought online
+  Liabilities:Card   90.00 EUR
+  Expenses:Clothes  -60.00 EUR
+  Expenses:Shipping -5 USD @ 4 EUR
+  Expenses:Fees
+`, "")
+	entry, err := helpers.ParseBeancountEntry(reversed)
+	helpers.TestExpect(t, err, nil, "")
+	helpers.TestExpect(t, entry.Postings[3].Amount, -10.0, "the elided amount should be inferred as negation, too")
+
+	_, err = helpers.ReverseTransaction("; only a comment\n", "2024-01-20")
+	helpers.TestExpect(t, err != nil, true, "comments can not be reversed")
+
+	linked, ok := helpers.AddTagsAndLinks(reversed, "^reversal-1", "#refund", "#clothes")
+	helpers.TestExpect(t, ok, true, "")
+	helpers.TestStringContains(t, linked, `2024-01-20 * "Shop" "Shoes" #clothes ^reversal-1 #refund ; bought online`+"\n", "")
+	again, _ := helpers.AddTagsAndLinks(linked, "^reversal-1")
+	helpers.TestExpect(t, again, linked, "existing links should not be added again")
+}
@@ -22,11 +22,17 @@ const (
 	USERSET_TZOFF        = "user.tzOffset"
 	USERSET_OMITCMDSLASH = "user.omitCommandSlash"
 	USERSET_ENABLEAPI    = "user.enableApi"
+	USERSET_AUTOPRICE    = "user.autoPrice"
+	USERSET_PENDINGDAYS  = "user.pendingReminderDays"
 
 	DEFAULT_CURRENCY = "EUR"
 
 	TG_MAX_MSG_CHAR_LEN = 4096
 
+	MAX_DOCUMENT_SIZE = 1 << 20 // bytes
+	// MAX_LEDGER_SIZE allows whole ledger files up to the download limit of Telegram bots
+	MAX_LEDGER_SIZE = 20 << 20 // bytes
+
 	MAX_REPLY_KEYBOARD_ENTRIES = 40
 )
 
@@ -34,6 +40,7 @@ func AllowedSuggestionTypes() []string {
 	return []string{
 		FIELD_DESCRIPTION,
 		FIELD_ACCOUNT,
+		FIELD_TAG,
 	}
 }
 
@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// DUPLICATE_MAX_DAYS is the number of days the booking dates of duplicate transactions may differ
+const DUPLICATE_MAX_DAYS = 2
+
+func descriptionWords(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		// Short words like 'at' or the 's' of "Luigi's" say little about the purchase
+		if len([]rune(word)) >= 3 {
+			words[word] = true
+		}
+	}
+	return words
+}
+
+// SimilarDescriptions tells whether two descriptions likely name the same purchase. This is the case if more than half of
+// the words of the shorter one are contained in the other, e.g. for 'Rewe' and 'REWE Markt - Groceries'.
+func SimilarDescriptions(a, b string) bool {
+	wordsA, wordsB := descriptionWords(a), descriptionWords(b)
+	if len(wordsA) == 0 || len(wordsB) == 0 {
+		return len(wordsA) == len(wordsB)
+	}
+	common := 0
+	for word := range wordsA {
+		if wordsB[word] {
+			common++
+		}
+	}
+	return 2*common > min(len(wordsA), len(wordsB))
+}
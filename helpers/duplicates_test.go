@@ -0,0 +1,17 @@
+package helpers_test
+
+import (
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+func TestSimilarDescriptions(t *testing.T) {
+	helpers.TestExpect(t, helpers.SimilarDescriptions("Rewe", "REWE Markt - Groceries"), true, "contained words")
+	helpers.TestExpect(t, helpers.SimilarDescriptions("Dinner at Luigi's", "luigis dinner"), false, "")
+	helpers.TestExpect(t, helpers.SimilarDescriptions("Team dinner Luigi", "Dinner Luigi with friends"), true, "half of the words match")
+	helpers.TestExpect(t, helpers.SimilarDescriptions("Coffee", "Lunch"), false, "")
+	helpers.TestExpect(t, helpers.SimilarDescriptions("Coffee at station", "Coffee beans for office"), false, "")
+	helpers.TestExpect(t, helpers.SimilarDescriptions("", ""), true, "")
+	helpers.TestExpect(t, helpers.SimilarDescriptions("", "Coffee"), false, "")
+}
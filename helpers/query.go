@@ -0,0 +1,729 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryResult holds the formatted result rows of a query
+type QueryResult struct {
+	Columns []string
+	Rows    [][]string
+	// Numeric marks columns holding numbers, amounts or inventories
+	Numeric []bool
+}
+
+// queryPosition is the amount of a posting in a currency
+type queryPosition struct {
+	Number   float64
+	Currency string
+}
+
+// queryInventory is the sum of positions per currency
+type queryInventory map[string]float64
+
+type queryRow struct {
+	entry   *BeancountEntry
+	posting *BeancountPosting
+}
+
+type queryEvaluator struct {
+	regexps map[string]*regexp.Regexp
+}
+
+// RunQuery parses the query and runs it over the postings of the entries
+func RunQuery(q string, entries []*BeancountEntry) (*QueryResult, error) {
+	query, err := ParseQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return query.Run(entries)
+}
+
+// Run executes the query over the postings of the entries. Postings are visited in order of their entry's date.
+func (query *Query) Run(entries []*BeancountEntry) (*QueryResult, error) {
+	ev := &queryEvaluator{regexps: map[string]*regexp.Regexp{}}
+
+	sorted := append([]*BeancountEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date < sorted[j].Date })
+	rows := []*queryRow{}
+	for _, entry := range sorted {
+		for _, posting := range entry.Postings {
+			row := &queryRow{entry: entry, posting: posting}
+			if query.Where != nil {
+				match, err := ev.eval(query.Where, row, nil)
+				if err != nil {
+					return nil, err
+				}
+				if !queryTruthy(match) {
+					continue
+				}
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	groups, err := ev.group(query, rows)
+	if err != nil {
+		return nil, err
+	}
+	values := [][]interface{}{}
+	for _, group := range groups {
+		var first *queryRow
+		if len(group) > 0 {
+			first = group[0]
+		}
+		result := []interface{}{}
+		for _, target := range query.Targets {
+			value, err := ev.eval(target.expr, first, group)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+		values = append(values, result)
+	}
+
+	if query.Distinct {
+		seen := map[string]bool{}
+		distinct := [][]interface{}{}
+		for _, result := range values {
+			key := query.key(result)
+			if !seen[key] {
+				seen[key] = true
+				distinct = append(distinct, result)
+			}
+		}
+		values = distinct
+	}
+	if len(query.OrderBy) > 0 {
+		for _, order := range query.OrderBy {
+			for _, result := range values {
+				if inventory, isInventory := result[order.target].(queryInventory); isInventory && len(inventory) > 1 {
+					return nil, fmt.Errorf("can not order by '%s', as it holds amounts in several currencies", query.Targets[order.target].Name)
+				}
+			}
+		}
+		sort.SliceStable(values, func(i, j int) bool {
+			for _, order := range query.OrderBy {
+				c := queryCompare(values[i][order.target], values[j][order.target])
+				if c != 0 {
+					return (c < 0) != order.desc
+				}
+			}
+			return false
+		})
+	}
+	if query.Limit >= 0 && len(values) > query.Limit {
+		values = values[:query.Limit]
+	}
+
+	res := &QueryResult{Rows: [][]string{}}
+	for _, target := range query.Targets {
+		if !target.hidden {
+			res.Columns = append(res.Columns, target.Name)
+			res.Numeric = append(res.Numeric, false)
+		}
+	}
+	for _, result := range values {
+		row := []string{}
+		for i, target := range query.Targets {
+			if target.hidden {
+				continue
+			}
+			switch result[i].(type) {
+			case float64, queryPosition, queryInventory:
+				res.Numeric[len(row)] = true
+			}
+			row = append(row, queryFormat(result[i]))
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res, nil
+}
+
+// key identifies a result row by its visible values
+func (query *Query) key(result []interface{}) string {
+	parts := []string{}
+	for i, target := range query.Targets {
+		if !target.hidden {
+			parts = append(parts, queryFormat(result[i]))
+		}
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// group splits the rows into groups to aggregate. Without aggregation, each row is its own group.
+// If aggregate functions are used without GROUP BY, the rows are grouped by all other selected columns.
+func (ev *queryEvaluator) group(query *Query, rows []*queryRow) ([][]*queryRow, error) {
+	if !query.aggregated() {
+		groups := [][]*queryRow{}
+		for _, row := range rows {
+			groups = append(groups, []*queryRow{row})
+		}
+		return groups, nil
+	}
+	keys := query.groupKeys()
+	if len(keys) == 0 {
+		// Only aggregates: summarize all rows into one result row
+		return [][]*queryRow{rows}, nil
+	}
+	groups := [][]*queryRow{}
+	index := map[string]int{}
+	for _, row := range rows {
+		parts := []string{}
+		for _, key := range keys {
+			value, err := ev.eval(key, row, nil)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, queryFormat(value))
+		}
+		key := strings.Join(parts, "\x00")
+		i, exists := index[key]
+		if !exists {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, []*queryRow{})
+		}
+		groups[i] = append(groups[i], row)
+	}
+	return groups, nil
+}
+
+func (ev *queryEvaluator) eval(expr queryExpr, row *queryRow, group []*queryRow) (interface{}, error) {
+	switch e := expr.(type) {
+	case *queryLiteral:
+		return e.value, nil
+	case *queryColumn:
+		if row == nil {
+			return nil, nil
+		}
+		return row.column(e.name), nil
+	case *queryTuple:
+		items := []interface{}{}
+		for _, item := range e.items {
+			value, err := ev.eval(item, row, group)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	case *queryUnary:
+		x, err := ev.eval(e.x, row, group)
+		if err != nil {
+			return nil, err
+		}
+		if e.op == "NOT" {
+			return !queryTruthy(x), nil
+		}
+		switch v := x.(type) {
+		case float64:
+			return -v, nil
+		case queryPosition:
+			return queryPosition{-v.Number, v.Currency}, nil
+		case nil:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("can not negate '%s'", queryFormat(x))
+	case *queryBinary:
+		return ev.evalBinary(e, row, group)
+	case *queryCall:
+		if queryAggregates[e.name] {
+			return ev.evalAggregate(e, group)
+		}
+		args := []interface{}{}
+		for _, arg := range e.args {
+			value, err := ev.eval(arg, row, group)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, value)
+		}
+		return queryCallFunction(e.name, args)
+	}
+	return nil, fmt.Errorf("unsupported expression")
+}
+
+func (ev *queryEvaluator) evalBinary(e *queryBinary, row *queryRow, group []*queryRow) (interface{}, error) {
+	l, err := ev.eval(e.l, row, group)
+	if err != nil {
+		return nil, err
+	}
+	// Short-circuit boolean operators
+	if e.op == "AND" && !queryTruthy(l) {
+		return false, nil
+	}
+	if e.op == "OR" && queryTruthy(l) {
+		return true, nil
+	}
+	r, err := ev.eval(e.r, row, group)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "AND", "OR":
+		return queryTruthy(r), nil
+	case "=":
+		return queryCompare(l, r) == 0, nil
+	case "!=":
+		return queryCompare(l, r) != 0, nil
+	case "<":
+		return l != nil && r != nil && queryCompare(l, r) < 0, nil
+	case "<=":
+		return l != nil && r != nil && queryCompare(l, r) <= 0, nil
+	case ">":
+		return l != nil && r != nil && queryCompare(l, r) > 0, nil
+	case ">=":
+		return l != nil && r != nil && queryCompare(l, r) >= 0, nil
+	case "~", "!~":
+		pattern, isString := r.(string)
+		if !isString {
+			return nil, fmt.Errorf("the right side of '%s' must be a regular expression string", e.op)
+		}
+		re, err := ev.regexp(pattern)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString(queryFormat(l)) == (e.op == "~"), nil
+	case "IN":
+		switch set := r.(type) {
+		case []interface{}:
+			for _, item := range set {
+				if queryCompare(l, item) == 0 {
+					return true, nil
+				}
+			}
+			return false, nil
+		case []string:
+			return ArrayContains(set, queryFormat(l)), nil
+		}
+		return queryCompare(l, r) == 0, nil
+	}
+	return queryArithmetic(e.op, l, r)
+}
+
+func (ev *queryEvaluator) regexp(pattern string) (*regexp.Regexp, error) {
+	if re, cached := ev.regexps[pattern]; cached {
+		return re, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression '%s': %s", pattern, err.Error())
+	}
+	ev.regexps[pattern] = re
+	return re, nil
+}
+
+func (ev *queryEvaluator) evalAggregate(e *queryCall, group []*queryRow) (interface{}, error) {
+	if _, star := e.args[0].(*queryStar); star {
+		return float64(len(group)), nil
+	}
+	values := []interface{}{}
+	for _, row := range group {
+		value, err := ev.eval(e.args[0], row, nil)
+		if err != nil {
+			return nil, err
+		}
+		if value != nil {
+			values = append(values, value)
+		}
+	}
+	switch e.name {
+	case "count":
+		return float64(len(values)), nil
+	case "first", "last", "min", "max":
+		if len(values) == 0 {
+			return nil, nil
+		}
+		result := values[0]
+		for _, value := range values[1:] {
+			switch e.name {
+			case "last":
+				result = value
+			case "min":
+				if queryCompare(value, result) < 0 {
+					result = value
+				}
+			case "max":
+				if queryCompare(value, result) > 0 {
+					result = value
+				}
+			}
+		}
+		return result, nil
+	}
+	// sum
+	if len(values) == 0 {
+		return nil, nil
+	}
+	var sum float64
+	var inventory queryInventory
+	for _, value := range values {
+		switch v := value.(type) {
+		case float64:
+			sum += v
+		case queryPosition:
+			if inventory == nil {
+				inventory = queryInventory{}
+			}
+			inventory[v.Currency] += v.Number
+		case queryInventory:
+			if inventory == nil {
+				inventory = queryInventory{}
+			}
+			for currency, number := range v {
+				inventory[currency] += number
+			}
+		default:
+			return nil, fmt.Errorf("can not sum up '%s'", queryFormat(value))
+		}
+	}
+	if inventory != nil {
+		if sum != 0 {
+			return nil, fmt.Errorf("can not sum up numbers and positions")
+		}
+		return inventory, nil
+	}
+	return roundAmount(sum), nil
+}
+
+func queryCallFunction(name string, args []interface{}) (interface{}, error) {
+	if args[0] == nil {
+		return nil, nil
+	}
+	switch name {
+	case "year", "month", "day":
+		date, isDate := queryDate(args[0])
+		if !isDate {
+			return nil, fmt.Errorf("%s() expects a date", name)
+		}
+		switch name {
+		case "year":
+			return float64(date.Year()), nil
+		case "month":
+			return float64(date.Month()), nil
+		}
+		return float64(date.Day()), nil
+	case "root":
+		n, isNumber := args[1].(float64)
+		if !isNumber || n < 1 {
+			return nil, fmt.Errorf("root() expects a positive number of segments")
+		}
+		segments := strings.Split(queryFormat(args[0]), ":")
+		if int(n) < len(segments) {
+			segments = segments[:int(n)]
+		}
+		return strings.Join(segments, ":"), nil
+	case "leaf":
+		segments := strings.Split(queryFormat(args[0]), ":")
+		return segments[len(segments)-1], nil
+	case "parent":
+		account := queryFormat(args[0])
+		i := strings.LastIndex(account, ":")
+		if i < 0 {
+			return nil, nil
+		}
+		return account[:i], nil
+	case "abs":
+		switch v := args[0].(type) {
+		case float64:
+			return math.Abs(v), nil
+		case queryPosition:
+			return queryPosition{math.Abs(v.Number), v.Currency}, nil
+		}
+		return nil, fmt.Errorf("abs() expects a number or position")
+	case "units":
+		switch args[0].(type) {
+		case queryPosition, queryInventory:
+			return args[0], nil
+		}
+		return nil, fmt.Errorf("units() expects a position")
+	case "length":
+		if list, isList := args[0].([]string); isList {
+			return float64(len(list)), nil
+		}
+		return float64(len([]rune(queryFormat(args[0])))), nil
+	case "lower":
+		return strings.ToLower(queryFormat(args[0])), nil
+	case "upper":
+		return strings.ToUpper(queryFormat(args[0])), nil
+	}
+	return nil, fmt.Errorf("unknown function %s()", name)
+}
+
+func queryArithmetic(op string, l, r interface{}) (interface{}, error) {
+	if l == nil || r == nil {
+		return nil, nil
+	}
+	apply := func(a, b float64) (float64, bool) {
+		switch op {
+		case "+":
+			return a + b, true
+		case "-":
+			return a - b, true
+		case "*":
+			return a * b, true
+		}
+		if b == 0 {
+			return 0, false
+		}
+		return a / b, true
+	}
+	ln, lIsNumber := l.(float64)
+	rn, rIsNumber := r.(float64)
+	lp, lIsPosition := l.(queryPosition)
+	rp, rIsPosition := r.(queryPosition)
+	switch {
+	case lIsNumber && rIsNumber:
+		if result, ok := apply(ln, rn); ok {
+			return result, nil
+		}
+		return nil, nil
+	case lIsPosition && rIsNumber && (op == "*" || op == "/"):
+		if result, ok := apply(lp.Number, rn); ok {
+			return queryPosition{result, lp.Currency}, nil
+		}
+		return nil, nil
+	case lIsNumber && rIsPosition && op == "*":
+		return queryPosition{ln * rp.Number, rp.Currency}, nil
+	case lIsPosition && rIsPosition && lp.Currency == rp.Currency && (op == "+" || op == "-"):
+		result, _ := apply(lp.Number, rp.Number)
+		return queryPosition{result, lp.Currency}, nil
+	}
+	return nil, fmt.Errorf("can not calculate '%s %s %s'", queryFormat(l), op, queryFormat(r))
+}
+
+func (row *queryRow) column(name string) interface{} {
+	e, p := row.entry, row.posting
+	switch name {
+	case "date", "year", "month", "day":
+		date, err := time.Parse(BEANCOUNT_DATE_FORMAT, e.Date)
+		if err != nil {
+			return nil
+		}
+		if name == "date" {
+			return date
+		}
+		value, _ := queryCallFunction(name, []interface{}{date})
+		return value
+	case "flag":
+		return e.Flag
+	case "payee":
+		return e.Payee
+	case "narration":
+		return e.Narration
+	case "description":
+		if e.Payee != "" && e.Narration != "" {
+			return e.Payee + " | " + e.Narration
+		}
+		return e.Payee + e.Narration
+	case "tags":
+		return e.Tags
+	case "links":
+		return e.Links
+	case "account":
+		return p.Account
+	case "number":
+		return p.Amount
+	case "currency":
+		return p.Currency
+	case "position":
+		return queryPosition{p.Amount, p.Currency}
+	}
+	return nil
+}
+
+func queryTruthy(v interface{}) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case nil:
+		return false
+	case float64:
+		return b != 0
+	case string:
+		return b != ""
+	}
+	return true
+}
+
+func queryDate(v interface{}) (time.Time, bool) {
+	switch d := v.(type) {
+	case time.Time:
+		return d, true
+	case string:
+		date, err := time.Parse(BEANCOUNT_DATE_FORMAT, d)
+		return date, err == nil
+	}
+	return time.Time{}, false
+}
+
+// queryCompare orders values of the same type. Missing values come first. Positions and inventories in a single
+// currency are ordered by currency first and then by number, like amounts in beancount. Inventories in several
+// currencies can not be ordered meaningfully and are compared by their formatted value.
+func queryCompare(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	a, b = querySinglePosition(a), querySinglePosition(b)
+	floatCompare := func(x, y float64) int {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	pa, aIsPosition := a.(queryPosition)
+	pb, bIsPosition := b.(queryPosition)
+	if aIsPosition && bIsPosition && pa.Currency != pb.Currency {
+		return strings.Compare(pa.Currency, pb.Currency)
+	}
+	if aIsPosition {
+		a = pa.Number
+	}
+	if bIsPosition {
+		b = pb.Number
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, isNumber := b.(float64); isNumber {
+			return floatCompare(x, y)
+		}
+	case bool:
+		if y, isBool := b.(bool); isBool {
+			return floatCompare(queryBoolNumber(x), queryBoolNumber(y))
+		}
+	}
+	if x, isDate := a.(time.Time); isDate {
+		if y, isDate := queryDate(b); isDate {
+			return x.Compare(y)
+		}
+	}
+	if y, isDate := b.(time.Time); isDate {
+		if x, isDate := queryDate(a); isDate {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(queryFormat(a), queryFormat(b))
+}
+
+// querySinglePosition turns inventories holding a single currency into a position
+func querySinglePosition(v interface{}) interface{} {
+	if inventory, isInventory := v.(queryInventory); isInventory && len(inventory) == 1 {
+		for currency, number := range inventory {
+			return queryPosition{number, currency}
+		}
+	}
+	return v
+}
+
+func queryBoolNumber(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func queryFormat(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(roundAmount(x), 'f', -1, 64)
+	case bool:
+		if x {
+			return "TRUE"
+		}
+		return "FALSE"
+	case time.Time:
+		return x.Format(BEANCOUNT_DATE_FORMAT)
+	case []string:
+		return strings.Join(x, ",")
+	case []interface{}:
+		items := []string{}
+		for _, item := range x {
+			items = append(items, queryFormat(item))
+		}
+		return "(" + strings.Join(items, ", ") + ")"
+	case queryPosition:
+		return fmt.Sprintf("%.2f %s", x.Number, x.Currency)
+	case queryInventory:
+		currencies := []string{}
+		for currency := range x {
+			currencies = append(currencies, currency)
+		}
+		sort.Strings(currencies)
+		positions := []string{}
+		for _, currency := range currencies {
+			positions = append(positions, fmt.Sprintf("%.2f %s", x[currency], currency))
+		}
+		return strings.Join(positions, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+// CSV renders the result including a header row as comma-separated values
+func (res *QueryResult) CSV() (string, error) {
+	buffer := &bytes.Buffer{}
+	w := csv.NewWriter(buffer)
+	err := w.Write(res.Columns)
+	if err != nil {
+		return "", err
+	}
+	err = w.WriteAll(res.Rows)
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
+
+// Table renders the result as plain text table with a header. Numeric columns are right-aligned.
+func (res *QueryResult) Table() string {
+	rows := append([][]string{res.Columns}, res.Rows...)
+	widths := make([]int, len(res.Columns))
+	for _, row := range rows {
+		for i, cell := range row {
+			if l := len([]rune(cell)); l > widths[i] {
+				widths[i] = l
+			}
+		}
+	}
+	separator := []string{}
+	for _, width := range widths {
+		separator = append(separator, strings.Repeat("-", width))
+	}
+	rows = append([][]string{rows[0], separator}, rows[1:]...)
+	lines := []string{}
+	for _, row := range rows {
+		cells := []string{}
+		for i, cell := range row {
+			padding := strings.Repeat(" ", widths[i]-len([]rune(cell)))
+			if res.Numeric[i] {
+				cells = append(cells, padding+cell)
+			} else {
+				cells = append(cells, cell+padding)
+			}
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, "  "), " "))
+	}
+	return strings.Join(lines, "\n")
+}
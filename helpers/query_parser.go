@@ -0,0 +1,728 @@
+package helpers
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a parsed query in a subset of the beancount query language (BQL), e.g.:
+//
+//	SELECT account, sum(position) WHERE account ~ "Food" AND date >= 2024-01-01 GROUP BY account
+//
+// Queries run over the postings of transactions. FROM is supported as additional filter.
+type Query struct {
+	Distinct bool
+	Targets  []*QueryTarget
+	Where    queryExpr
+	GroupBy  []queryExpr
+	OrderBy  []*queryOrder
+	// Limit restricts the number of result rows if not negative
+	Limit int
+}
+
+type QueryTarget struct {
+	Name string
+	expr queryExpr
+	// hidden targets are only selected to order by them
+	hidden bool
+}
+
+type queryOrder struct {
+	target int
+	desc   bool
+}
+
+type queryExpr interface{}
+
+type queryLiteral struct{ value interface{} }
+type queryColumn struct{ name string }
+type queryStar struct{}
+type queryCall struct {
+	name string
+	args []queryExpr
+}
+type queryUnary struct {
+	op string
+	x  queryExpr
+}
+type queryBinary struct {
+	op   string
+	l, r queryExpr
+}
+type queryTuple struct{ items []queryExpr }
+
+// QUERY_COLUMNS lists the columns available per posting
+var QUERY_COLUMNS = []string{"date", "year", "month", "day", "flag", "payee", "narration", "description", "tags", "links", "account", "number", "currency", "position"}
+
+// QUERY_DEFAULT_COLUMNS are selected by 'SELECT *'
+var QUERY_DEFAULT_COLUMNS = []string{"date", "flag", "payee", "narration", "account", "position"}
+
+// queryFunctions maps the supported scalar functions to their number of arguments
+var queryFunctions = map[string]int{
+	"year": 1, "month": 1, "day": 1,
+	"root": 2, "leaf": 1, "parent": 1,
+	"abs": 1, "units": 1, "length": 1,
+	"lower": 1, "upper": 1,
+}
+
+var queryAggregates = map[string]bool{"sum": true, "count": true, "min": true, "max": true, "first": true, "last": true}
+
+var queryReserved = map[string]bool{
+	"SELECT": true, "DISTINCT": true, "FROM": true, "WHERE": true, "GROUP": true, "ORDER": true, "BY": true,
+	"LIMIT": true, "AS": true, "AND": true, "OR": true, "NOT": true, "IN": true, "ASC": true, "DESC": true,
+}
+
+const (
+	queryTokenEOF = iota
+	queryTokenIdent
+	queryTokenString
+	queryTokenNumber
+	queryTokenDate
+	queryTokenOp
+)
+
+type queryToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+var queryDatePattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+var queryNumberPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?`)
+
+func tokenizeQuery(q string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(q) && (q[i] == '_' || (q[i] >= 'a' && q[i] <= 'z') || (q[i] >= 'A' && q[i] <= 'Z') || (q[i] >= '0' && q[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, queryToken{queryTokenIdent, q[start:i], start})
+		case c >= '0' && c <= '9':
+			if date := queryDatePattern.FindString(q[i:]); date != "" {
+				tokens = append(tokens, queryToken{queryTokenDate, date, i})
+				i += len(date)
+				continue
+			}
+			number := queryNumberPattern.FindString(q[i:])
+			tokens = append(tokens, queryToken{queryTokenNumber, number, i})
+			i += len(number)
+		case c == '"' || c == '\'':
+			start := i
+			value := strings.Builder{}
+			i++
+			for i < len(q) && q[i] != c {
+				if q[i] == '\\' && i+1 < len(q) {
+					i++
+				}
+				value.WriteByte(q[i])
+				i++
+			}
+			if i >= len(q) {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, queryToken{queryTokenString, value.String(), start})
+		default:
+			op := ""
+			for _, candidate := range []string{"!=", "<>", "<=", ">=", "!~", "=", "<", ">", "~", "(", ")", ",", "*", "+", "-", "/"} {
+				if strings.HasPrefix(q[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i+1)
+			}
+			if op == "<>" {
+				op = "!="
+			}
+			tokens = append(tokens, queryToken{queryTokenOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, queryToken{queryTokenEOF, "", len(q)}), nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	i      int
+	// aliases of the selected columns can be referenced in GROUP BY and ORDER BY
+	aliases map[string]queryExpr
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.i]
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.tokens[p.i]
+	if t.kind != queryTokenEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *queryParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == queryTokenIdent && strings.EqualFold(t.text, keyword)
+}
+
+func (p *queryParser) acceptKeyword(keywords ...string) bool {
+	for i, keyword := range keywords {
+		if p.i+i >= len(p.tokens) {
+			return false
+		}
+		t := p.tokens[p.i+i]
+		if t.kind != queryTokenIdent || !strings.EqualFold(t.text, keyword) {
+			return false
+		}
+	}
+	p.i += len(keywords)
+	return true
+}
+
+func (p *queryParser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == queryTokenOp && t.text == op
+}
+
+func (p *queryParser) acceptOp(op string) bool {
+	if p.isOp(op) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) errorf(format string, v ...interface{}) error {
+	t := p.peek()
+	found := "end of query"
+	if t.kind != queryTokenEOF {
+		found = "'" + t.text + "'"
+	}
+	return fmt.Errorf("syntax error at %s (position %d): %s", found, t.pos+1, fmt.Sprintf(format, v...))
+}
+
+// ParseQuery parses a query in the supported subset of the beancount query language
+func ParseQuery(q string) (*Query, error) {
+	tokens, err := tokenizeQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	query := &Query{Limit: -1}
+	if !p.acceptKeyword("SELECT") {
+		return nil, p.errorf("expected SELECT")
+	}
+	query.Distinct = p.acceptKeyword("DISTINCT")
+	if p.acceptOp("*") {
+		for _, column := range QUERY_DEFAULT_COLUMNS {
+			query.Targets = append(query.Targets, &QueryTarget{Name: column, expr: &queryColumn{column}})
+		}
+	} else {
+		for {
+			start := p.i
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			target := &QueryTarget{expr: expr, Name: p.source(q, start)}
+			if p.acceptKeyword("AS") {
+				t := p.next()
+				if t.kind != queryTokenIdent {
+					return nil, p.errorf("expected column name after AS")
+				}
+				target.Name = t.text
+			}
+			query.Targets = append(query.Targets, target)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	conditions := []queryExpr{}
+	if p.acceptKeyword("FROM") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, expr)
+	}
+	if p.acceptKeyword("WHERE") {
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, expr)
+	}
+	for _, condition := range conditions {
+		if containsAggregate(condition) {
+			return nil, fmt.Errorf("aggregate functions are not allowed in FROM and WHERE")
+		}
+		if query.Where == nil {
+			query.Where = condition
+		} else {
+			query.Where = &queryBinary{op: "AND", l: query.Where, r: condition}
+		}
+	}
+	p.aliases = map[string]queryExpr{}
+	for _, target := range query.Targets {
+		if !ArrayContains(QUERY_COLUMNS, strings.ToLower(target.Name)) {
+			p.aliases[target.Name] = target.expr
+		}
+	}
+	if p.acceptKeyword("GROUP", "BY") {
+		for {
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			expr, err = query.resolveTarget(expr)
+			if err != nil {
+				return nil, err
+			}
+			if containsAggregate(expr) {
+				return nil, fmt.Errorf("aggregate functions are not allowed in GROUP BY")
+			}
+			query.GroupBy = append(query.GroupBy, expr)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("ORDER", "BY") {
+		for {
+			start := p.i
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			target, err := query.targetIndex(expr, p.source(q, start))
+			if err != nil {
+				return nil, err
+			}
+			order := &queryOrder{target: target}
+			if order.target < 0 {
+				query.Targets = append(query.Targets, &QueryTarget{Name: p.source(q, start), expr: expr, hidden: true})
+				order.target = len(query.Targets) - 1
+			}
+			if p.acceptKeyword("DESC") {
+				order.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			query.OrderBy = append(query.OrderBy, order)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("LIMIT") {
+		t := p.next()
+		limit, err := strconv.Atoi(t.text)
+		if t.kind != queryTokenNumber || err != nil {
+			p.i--
+			return nil, p.errorf("expected number of rows after LIMIT")
+		}
+		query.Limit = limit
+	}
+	if p.peek().kind != queryTokenEOF {
+		return nil, p.errorf("unexpected input")
+	}
+	err = query.checkGrouping()
+	if err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+// source returns the query text of the tokens parsed since start, used as column names
+func (p *queryParser) source(q string, start int) string {
+	end := len(q)
+	if p.i < len(p.tokens) {
+		end = p.tokens[p.i].pos
+	}
+	return strings.TrimSpace(q[p.tokens[start].pos:end])
+}
+
+// resolveTarget replaces references to selected columns by their alias or number with their expression
+func (query *Query) resolveTarget(expr queryExpr) (queryExpr, error) {
+	i, err := query.targetIndex(expr, "")
+	if err != nil || i < 0 {
+		return expr, err
+	}
+	return query.Targets[i].expr, nil
+}
+
+// targetIndex returns the index of the selected column the expression refers to by its number, name or query
+// text, or -1 if it does not refer to a selected column. Numbers have to refer to an existing column.
+func (query *Query) targetIndex(expr queryExpr, source string) (int, error) {
+	if literal, isLiteral := expr.(*queryLiteral); isLiteral {
+		if n, isNumber := literal.value.(float64); isNumber {
+			if n < 1 || int(n) > query.visibleTargets() || n != float64(int(n)) {
+				return -1, fmt.Errorf("there is no selected column number %s", queryFormat(n))
+			}
+			return int(n) - 1, nil
+		}
+	}
+	if column, isColumn := expr.(*queryColumn); isColumn {
+		for i, target := range query.Targets {
+			if target.Name == column.name {
+				return i, nil
+			}
+		}
+	}
+	for i, target := range query.Targets {
+		if source != "" && target.Name == source {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+func (query *Query) visibleTargets() int {
+	n := 0
+	for _, target := range query.Targets {
+		if !target.hidden {
+			n++
+		}
+	}
+	return n
+}
+
+// aggregated tells whether rows are combined into result rows, i.e. if aggregate functions or GROUP BY are used
+func (query *Query) aggregated() bool {
+	aggregated := len(query.GroupBy) > 0
+	for _, target := range query.Targets {
+		aggregated = aggregated || containsAggregate(target.expr)
+	}
+	return aggregated
+}
+
+// groupKeys returns the expressions to group rows by. Without GROUP BY, these are all selected columns not
+// using aggregate functions.
+func (query *Query) groupKeys() []queryExpr {
+	if len(query.GroupBy) > 0 {
+		return query.GroupBy
+	}
+	keys := []queryExpr{}
+	for _, target := range query.Targets {
+		if !target.hidden && !containsAggregate(target.expr) {
+			keys = append(keys, target.expr)
+		}
+	}
+	return keys
+}
+
+// checkGrouping makes sure that each selected column has a single value per group of rows
+func (query *Query) checkGrouping() error {
+	if !query.aggregated() {
+		return nil
+	}
+	keys := query.groupKeys()
+	for _, target := range query.Targets {
+		if !queryGrouped(target.expr, keys) {
+			return fmt.Errorf("'%s' has to be used in GROUP BY or in an aggregate function", target.Name)
+		}
+	}
+	return nil
+}
+
+// queryGrouped tells whether the expression only uses group keys, aggregate functions and constants
+func queryGrouped(expr queryExpr, keys []queryExpr) bool {
+	for _, key := range keys {
+		if reflect.DeepEqual(expr, key) {
+			return true
+		}
+	}
+	switch e := expr.(type) {
+	case *queryLiteral:
+		return true
+	case *queryCall:
+		if queryAggregates[e.name] {
+			return true
+		}
+		for _, arg := range e.args {
+			if !queryGrouped(arg, keys) {
+				return false
+			}
+		}
+		return true
+	case *queryUnary:
+		return queryGrouped(e.x, keys)
+	case *queryBinary:
+		return queryGrouped(e.l, keys) && queryGrouped(e.r, keys)
+	case *queryTuple:
+		for _, item := range e.items {
+			if !queryGrouped(item, keys) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseExpr() (queryExpr, error) {
+	return p.parseOr()
+}
+
+func (p *queryParser) parseOr() (queryExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("OR") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &queryBinary{op: "OR", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseAnd() (queryExpr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("AND") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &queryBinary{op: "AND", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseNot() (queryExpr, error) {
+	if p.acceptKeyword("NOT") {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &queryUnary{op: "NOT", x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryExpr, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"=", "!=", "<=", ">=", "<", ">", "~", "!~"} {
+		if p.acceptOp(op) {
+			r, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &queryBinary{op: op, l: l, r: r}, nil
+		}
+	}
+	negated := p.acceptKeyword("NOT", "IN")
+	if negated || p.acceptKeyword("IN") {
+		r, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		var expr queryExpr = &queryBinary{op: "IN", l: l, r: r}
+		if negated {
+			expr = &queryUnary{op: "NOT", x: expr}
+		}
+		return expr, nil
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseAdditive() (queryExpr, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &queryBinary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseMultiplicative() (queryExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") {
+		op := p.next().text
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = &queryBinary{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *queryParser) parseUnary() (queryExpr, error) {
+	if p.acceptOp("-") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryUnary{op: "-", x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (queryExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case queryTokenString:
+		p.next()
+		return &queryLiteral{t.text}, nil
+	case queryTokenNumber:
+		p.next()
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number")
+		}
+		return &queryLiteral{n}, nil
+	case queryTokenDate:
+		date, err := time.Parse(BEANCOUNT_DATE_FORMAT, t.text)
+		if err != nil {
+			return nil, p.errorf("invalid date")
+		}
+		p.next()
+		return &queryLiteral{date}, nil
+	case queryTokenOp:
+		if !p.acceptOp("(") {
+			return nil, p.errorf("expected expression")
+		}
+		items := []queryExpr{}
+		for {
+			item, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+		if !p.acceptOp(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		if len(items) == 1 {
+			return items[0], nil
+		}
+		return &queryTuple{items}, nil
+	case queryTokenIdent:
+		name := strings.ToLower(t.text)
+		switch strings.ToUpper(t.text) {
+		case "TRUE":
+			p.next()
+			return &queryLiteral{true}, nil
+		case "FALSE":
+			p.next()
+			return &queryLiteral{false}, nil
+		case "NULL":
+			p.next()
+			return &queryLiteral{nil}, nil
+		}
+		if queryReserved[strings.ToUpper(t.text)] {
+			return nil, p.errorf("expected expression")
+		}
+		p.next()
+		if p.acceptOp("(") {
+			return p.parseCall(name)
+		}
+		if alias, isAlias := p.aliases[t.text]; isAlias {
+			return alias, nil
+		}
+		if !ArrayContains(QUERY_COLUMNS, name) {
+			p.i--
+			return nil, p.errorf("unknown column (available: %s)", strings.Join(QUERY_COLUMNS, ", "))
+		}
+		return &queryColumn{name}, nil
+	}
+	return nil, p.errorf("expected expression")
+}
+
+func (p *queryParser) parseCall(name string) (queryExpr, error) {
+	call := &queryCall{name: name}
+	if name == "count" && p.acceptOp("*") {
+		call.args = []queryExpr{&queryStar{}}
+	} else if !p.isOp(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if !p.acceptOp(",") {
+				break
+			}
+		}
+	}
+	if !p.acceptOp(")") {
+		return nil, p.errorf("expected ')'")
+	}
+	if queryAggregates[name] {
+		if len(call.args) != 1 {
+			return nil, fmt.Errorf("aggregate function %s() expects one argument", name)
+		}
+		if containsAggregate(call.args[0]) {
+			return nil, fmt.Errorf("aggregate functions can not be nested")
+		}
+		return call, nil
+	}
+	arity, known := queryFunctions[name]
+	if !known {
+		return nil, fmt.Errorf("unknown function %s()", name)
+	}
+	if len(call.args) != arity {
+		return nil, fmt.Errorf("function %s() expects %d argument(s)", name, arity)
+	}
+	return call, nil
+}
+
+func containsAggregate(expr queryExpr) bool {
+	switch e := expr.(type) {
+	case *queryCall:
+		if queryAggregates[e.name] {
+			return true
+		}
+		for _, arg := range e.args {
+			if containsAggregate(arg) {
+				return true
+			}
+		}
+	case *queryUnary:
+		return containsAggregate(e.x)
+	case *queryBinary:
+		return containsAggregate(e.l) || containsAggregate(e.r)
+	case *queryTuple:
+		for _, item := range e.items {
+			if containsAggregate(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
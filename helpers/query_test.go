@@ -0,0 +1,261 @@
+package helpers_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/LucaBernstein/beancount-bot-tg/v2/helpers"
+)
+
+func queryTestEntries(t *testing.T) []*helpers.BeancountEntry {
+	entries, err := helpers.ParseBeancount(`2024-01-05 * "Bakery" "Bread" #trip
+  Assets:Wallet  -4.50 EUR
+  Expenses:Food:Bakery
+
+2023-12-30 * "Old year"
+  Assets:Wallet  -100.00 EUR
+  Expenses:Food:Groceries
+
+2024-01-02 * "Supermarket" "Weekly shopping"
+  Assets:Bank  -50.00 EUR
+  Expenses:Food:Groceries
+
+2024-02-01 ! "Cinema"
+  Assets:Wallet  -12.00 USD
+  Expenses:Fun
+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	return entries
+}
+
+func runTestQuery(t *testing.T, q string) *helpers.QueryResult {
+	res, err := helpers.RunQuery(q, queryTestEntries(t))
+	if err != nil {
+		t.Fatalf("Unexpected error running '%s': %s", q, err.Error())
+	}
+	return res
+}
+
+func queryResultRows(res *helpers.QueryResult) []string {
+	rows := []string{}
+	for _, row := range res.Rows {
+		rows = append(rows, strings.Join(row, "|"))
+	}
+	return rows
+}
+
+func TestQueryGroupBySum(t *testing.T) {
+	res := runTestQuery(t, `SELECT account, sum(position) WHERE account ~ "food" AND date >= 2024-01-01 GROUP BY account ORDER BY account`)
+	helpers.TestExpectArrEq(t, res.Columns, []string{"account", "sum(position)"}, "columns")
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{
+		"Expenses:Food:Bakery|4.50 EUR",
+		"Expenses:Food:Groceries|50.00 EUR",
+	}, "rows")
+	helpers.TestExpect(t, res.Numeric[0], false, "")
+	helpers.TestExpect(t, res.Numeric[1], true, "")
+}
+
+func TestQueryImplicitGroupingAndOrder(t *testing.T) {
+	res := runTestQuery(t, `select root(account, 1) as type, currency, sum(number) as total, count(*) where number > 0 order by total desc`)
+	helpers.TestExpectArrEq(t, res.Columns, []string{"type", "currency", "total", "count(*)"}, "columns")
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{
+		"Expenses|EUR|154.5|3",
+		"Expenses|USD|12|1",
+	}, "rows")
+}
+
+func TestQueryPostings(t *testing.T) {
+	res := runTestQuery(t, `SELECT date, flag, narration, account, number WHERE account = 'Assets:Wallet' AND NOT 'trip' IN tags ORDER BY date DESC LIMIT 1`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"2024-02-01|!|Cinema|Assets:Wallet|-12"}, "rows")
+
+	res = runTestQuery(t, `SELECT DISTINCT leaf(account) WHERE currency IN ('EUR', 'CHF') AND year(date) = 2024 ORDER BY 1`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Bakery", "Bank", "Groceries", "Wallet"}, "distinct leaves")
+
+	res = runTestQuery(t, `SELECT description, -number * 2 FROM month = 1 WHERE account !~ '^Expenses' ORDER BY number`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Supermarket | Weekly shopping|100", "Bakery | Bread|9"}, "arithmetic")
+}
+
+func TestQueryStar(t *testing.T) {
+	res := runTestQuery(t, `SELECT * WHERE date < 2024-01-01`)
+	helpers.TestExpectArrEq(t, res.Columns, helpers.QUERY_DEFAULT_COLUMNS, "columns")
+	helpers.TestExpect(t, len(res.Rows), 2, "postings of the old transaction")
+	helpers.TestExpect(t, res.Rows[0][5], "-100.00 EUR", "")
+}
+
+func TestQueryAggregateWithoutRows(t *testing.T) {
+	res := runTestQuery(t, `SELECT count(*), sum(position) WHERE payee = 'unknown'`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"0|"}, "single summary row")
+}
+
+func TestQueryErrors(t *testing.T) {
+	for q, msg := range map[string]string{
+		`SELEC account`:                            "expected SELECT",
+		`SELECT amount`:                            "unknown column",
+		`SELECT account WHERE sum(number) > 0`:     "not allowed in FROM and WHERE",
+		`SELECT foo(account)`:                      "unknown function",
+		`SELECT account WHERE payee = "unfinished`: "unterminated string",
+		`SELECT account LIMIT x`:                   "expected number of rows after LIMIT",
+		`SELECT account, sum(sum(number))`:         "can not be nested",
+		`SELECT account WHERE payee ~ "("`:         "invalid regular expression",
+		`SELECT account WHERE account = 'a' ORDER`: "unexpected input",
+		`SELECT sum(account)`:                      "can not sum up",
+	} {
+		_, err := helpers.RunQuery(q, queryTestEntries(t))
+		if err == nil {
+			t.Errorf("Expected error for '%s'", q)
+			continue
+		}
+		helpers.TestStringContains(t, err.Error(), msg, q)
+	}
+}
+
+func TestQueryResultFormats(t *testing.T) {
+	res := runTestQuery(t, `SELECT account, sum(position) AS total WHERE account ~ 'Assets' GROUP BY account ORDER BY account`)
+	helpers.TestExpect(t, res.Table(), `account                          total
+-------------  -----------------------
+Assets:Bank                 -50.00 EUR
+Assets:Wallet  -104.50 EUR, -12.00 USD`, "table")
+	csv, err := res.CSV()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err.Error())
+	}
+	helpers.TestExpect(t, csv, `account,total
+Assets:Bank,-50.00 EUR
+Assets:Wallet,"-104.50 EUR, -12.00 USD"
+`, "csv")
+}
+
+func TestQueryOrderByInventory(t *testing.T) {
+	// Ordered by number, not by the formatted value ("150.00 EUR" < "4.50 EUR")
+	res := runTestQuery(t, `SELECT account, sum(position) WHERE account ~ 'Expenses:Food' GROUP BY 1 ORDER BY 2 DESC`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{
+		"Expenses:Food:Groceries|150.00 EUR",
+		"Expenses:Food:Bakery|4.50 EUR",
+	}, "rows")
+
+	res = runTestQuery(t, `SELECT payee, sum(position) AS total WHERE account ~ '^Assets' AND currency = 'EUR' GROUP BY payee ORDER BY total`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"|-100.00 EUR", "Supermarket|-50.00 EUR", "Bakery|-4.50 EUR"}, "negative totals")
+
+	_, err := helpers.RunQuery(`SELECT account, sum(position) AS total WHERE account ~ '^Assets' GROUP BY account ORDER BY total`, queryTestEntries(t))
+	if err == nil {
+		t.Fatalf("Ordering by inventories in several currencies should fail")
+	}
+	helpers.TestStringContains(t, err.Error(), "can not order by 'total'", "")
+}
+
+func TestQueryOrderByPosition(t *testing.T) {
+	// Positions are ordered by currency first, like amounts in beancount
+	res := runTestQuery(t, `SELECT position WHERE account ~ '^Expenses' ORDER BY position`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"4.50 EUR", "50.00 EUR", "100.00 EUR", "12.00 USD"}, "ordered by currency")
+
+	res = runTestQuery(t, `SELECT narration WHERE position > 10 AND position < 60 ORDER BY date`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Weekly shopping", "Cinema"}, "positions compared to numbers")
+
+	res = runTestQuery(t, `SELECT max(position), min(position) WHERE account ~ '^Expenses' AND currency = 'EUR'`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"100.00 EUR|4.50 EUR"}, "")
+}
+
+func TestQueryOrderByHiddenColumns(t *testing.T) {
+	res := runTestQuery(t, `SELECT narration WHERE account = 'Assets:Wallet' ORDER BY date DESC`)
+	helpers.TestExpectArrEq(t, res.Columns, []string{"narration"}, "the ordering column should not be shown")
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Cinema", "Bread", "Old year"}, "")
+
+	res = runTestQuery(t, `SELECT leaf(account), count(*) GROUP BY account ORDER BY account DESC LIMIT 2`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Fun|1", "Groceries|2"}, "")
+}
+
+func TestQueryAggregates(t *testing.T) {
+	res := runTestQuery(t, `SELECT count(payee), count(*), first(narration), last(narration), min(date), max(number), sum(number) WHERE currency = 'EUR'`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"6|6|Old year|Bread|2023-12-30|100|0"}, "")
+
+	res = runTestQuery(t, `SELECT year, month, sum(number) WHERE number > 0 GROUP BY year, month ORDER BY year, month`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"2023|12|100", "2024|1|54.5", "2024|2|12"}, "")
+
+	res = runTestQuery(t, `SELECT root(account, 2) AS category, sum(number) * 2 AS double GROUP BY category ORDER BY category`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Assets:Bank|-100", "Assets:Wallet|-233", "Expenses:Food|309", "Expenses:Fun|24"}, "")
+
+	res = runTestQuery(t, `SELECT account, upper(leaf(account)), count(*) GROUP BY account ORDER BY 3 DESC, 1 LIMIT 1`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Assets:Wallet|WALLET|3"}, "expressions of group keys can be selected")
+}
+
+func TestQueryFunctions(t *testing.T) {
+	res := runTestQuery(t, `SELECT root(account, 1), leaf(account), parent(account), parent('Assets'), lower(account), length(account), length(tags), year(date), month(date), day(date) WHERE 'trip' IN tags AND number < 0`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"Assets|Wallet|Assets||assets:wallet|13|1|2024|1|5"}, "")
+
+	res = runTestQuery(t, `SELECT abs(number), abs(position), units(position), -position, number / 0, position * 2, 2 * position, position / 2, position - position WHERE narration = 'Cinema' AND number < 0`)
+	helpers.TestExpectArrEq(t, queryResultRows(res), []string{"12|12.00 USD|-12.00 USD|12.00 USD||-24.00 USD|-24.00 USD|-6.00 USD|0.00 USD"}, "")
+}
+
+func TestQueryConditions(t *testing.T) {
+	for q, expected := range map[string][]string{
+		`SELECT DISTINCT narration WHERE payee = NULL OR payee = '' ORDER BY narration`:                {"Cinema", "Old year"},
+		`SELECT DISTINCT narration WHERE narration = 'Cinema' OR narration = 'Bread' AND flag = '!'`:   {"Cinema"},
+		`SELECT DISTINCT narration WHERE (narration = 'Cinema' OR narration = 'Bread') AND flag = '*'`: {"Bread"},
+		`SELECT DISTINCT narration WHERE date >= '2024-01-02' AND date <= 2024-01-05 ORDER BY date`:    {"Weekly shopping", "Bread"},
+		`SELECT DISTINCT narration WHERE description ~ 'SUPERMARKET'`:                                  {"Weekly shopping"},
+		`SELECT DISTINCT narration WHERE NOT account ~ 'Food' AND account !~ 'Assets'`:                 {"Cinema"},
+		`SELECT DISTINCT narration WHERE number IN (4.5, 12) ORDER BY narration`:                       {"Bread", "Cinema"},
+		`SELECT DISTINCT narration WHERE currency != 'EUR'`:                                            {"Cinema"},
+		`SELECT DISTINCT narration WHERE length(links) > 0`:                                            {},
+		`SELECT DISTINCT narration WHERE position = -12 AND number != 12 AND TRUE`:                     {"Cinema"},
+		`SELECT DISTINCT narration WHERE year = 2023 OR FALSE`:                                         {"Old year"},
+		`SELECT narration WHERE account = 'Assets:Bank' LIMIT 0`:                                       {},
+	} {
+		res := runTestQuery(t, q)
+		helpers.TestExpectArrEq(t, queryResultRows(res), expected, q)
+	}
+}
+
+func TestQueryColumnReferences(t *testing.T) {
+	for q, msg := range map[string]string{
+		`SELECT account, count(*) GROUP BY 5`:                        "there is no selected column number 5",
+		`SELECT account, count(*) GROUP BY 0`:                        "there is no selected column number 0",
+		`SELECT account, count(*) GROUP BY 1.5`:                      "there is no selected column number 1.5",
+		`SELECT account WHERE number > 0 ORDER BY 9`:                 "there is no selected column number 9",
+		`SELECT account ORDER BY date, 2`:                            "there is no selected column number 2",
+		`SELECT number GROUP BY account`:                             "'number' has to be used in GROUP BY or in an aggregate function",
+		`SELECT account, number, sum(number) GROUP BY account`:       "'number' has to be used in GROUP BY",
+		`SELECT number + sum(number)`:                                "'number + sum(number)' has to be used in GROUP BY",
+		`SELECT account, sum(number) GROUP BY account ORDER BY date`: "'date' has to be used in GROUP BY",
+		`SELECT account, sum(number) ORDER BY date`:                  "'date' has to be used in GROUP BY",
+	} {
+		_, err := helpers.RunQuery(q, queryTestEntries(t))
+		if err == nil {
+			t.Errorf("Expected error for '%s'", q)
+			continue
+		}
+		helpers.TestStringContains(t, err.Error(), msg, q)
+	}
+}
+
+func TestQueryEvaluationErrors(t *testing.T) {
+	for q, msg := range map[string]string{
+		`SELECT -narration`:   "can not negate",
+		`SELECT position + 1`: "can not calculate",
+		`SELECT position WHERE narration = 'Bread' AND position + position * 0 + units(position) = 0`: "",
+		`SELECT year(account)`:                   "year() expects a date",
+		`SELECT root(account, 0)`:                "root() expects a positive number of segments",
+		`SELECT abs(account)`:                    "abs() expects a number or position",
+		`SELECT units(number)`:                   "units() expects a position",
+		`SELECT sum(number) + sum(position)`:     "can not calculate",
+		`SELECT account WHERE account ~ 1`:       "must be a regular expression string",
+		`SELECT leaf(account, 1)`:                "expects 1 argument(s)",
+		`SELECT count(account, payee)`:           "expects one argument",
+		`SELECT account AS`:                      "expected column name after AS",
+		`SELECT account GROUP BY sum(number)`:    "not allowed in GROUP BY",
+		`SELECT account WHERE date = 2024-13-01`: "invalid date",
+	} {
+		_, err := helpers.RunQuery(q, queryTestEntries(t))
+		if msg == "" {
+			helpers.TestExpect(t, err, nil, q)
+			continue
+		}
+		if err == nil {
+			t.Errorf("Expected error for '%s'", q)
+			continue
+		}
+		helpers.TestStringContains(t, err.Error(), msg, q)
+	}
+}
@@ -61,6 +61,19 @@ func (sh *SubcommandHandler) Handle(m *tb.Message) ([]string, error) {
 	return parameters, nil
 }
 
+// CommandRemainder returns the text following the first n whitespace-separated fields unchanged, e.g. including quotes and backslashes
+func CommandRemainder(s string, n int) string {
+	s = strings.TrimSpace(s)
+	for i := 0; i < n && s != ""; i++ {
+		end := strings.IndexAny(s, " \t\n")
+		if end < 0 {
+			return ""
+		}
+		s = strings.TrimSpace(s[end:])
+	}
+	return s
+}
+
 type TV struct {
 	T     string
 	Value string
@@ -118,3 +118,9 @@ func TestExtractTypeValue(t *testing.T) {
 		t.Errorf("Should return error for too many params")
 	}
 }
+
+func TestCommandRemainder(t *testing.T) {
+	helpers.TestExpect(t, helpers.CommandRemainder(`/import rule Expenses:Food  \bREWE\b|"Lidl" `, 3), `\bREWE\b|"Lidl"`, "")
+	helpers.TestExpect(t, helpers.CommandRemainder("/query\nSELECT *", 1), "SELECT *", "")
+	helpers.TestExpect(t, helpers.CommandRemainder("/import rule", 3), "", "")
+}